@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// localsCaller identifies the authenticated caller (a hash of its
+// credential) for the middlewares that run after authentication.
+const localsCaller = "caller"
+
+// requireAdmin guards management routes with the static ADMIN_TOKEN.
+// The token is accepted as "Authorization: Bearer <token>" or "X-Admin-Token".
+// If ADMIN_TOKEN is not configured every request is rejected.
+func requireAdmin() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !hasAdminToken(c) {
+			log.Printf("[admin] reject path=%q", c.Path())
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "invalid admin token"})
+		}
+		c.Locals(localsCaller, "admin:"+sha256Hex([]byte(localenv.GetAdminToken())))
+		return c.Next()
+	}
+}
+
+// requireAdminOrTolgeeSignature guards /api/update, which accepts either the
+// admin token (manual refresh) or a valid Tolgee webhook signature.
+func requireAdminOrTolgeeSignature() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if hasAdminToken(c) {
+			c.Locals(localsCaller, "admin:"+sha256Hex([]byte(localenv.GetAdminToken())))
+			return c.Next()
+		}
+		header := c.Get("Tolgee-Signature")
+		if !verifyTolgeeSignature(localenv.GetWebhookSecret(), header, c.Body()) {
+			log.Printf("[webhook] reject: invalid signature")
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "invalid webhook signature"})
+		}
+		c.Locals(localsCaller, "tolgee:"+sha256Hex([]byte(header)))
+		return c.Next()
+	}
+}
+
+// hasAdminToken reports whether the request carries ADMIN_TOKEN.
+func hasAdminToken(c *fiber.Ctx) bool {
+	token := localenv.GetAdminToken()
+	provided := c.Get("X-Admin-Token")
+	if provided == "" {
+		provided = strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(provided)) == 1
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+const (
+	keyAliasesCacheKey  = "tolgee:key-aliases"
+	keyAliasHitsKey     = "tolgee:key-aliases:hits"
+	keyAliasLastUsedKey = "tolgee:key-aliases:last-used"
+	keyAliasVersionsKey = "tolgee:key-aliases:versions"
+
+	keyAliasSourceAdmin  = "admin"
+	keyAliasSourceTolgee = "tolgee"
+
+	// keyAliasMaxChain bounds alias → alias resolution (a → b → c).
+	keyAliasMaxChain = 8
+	// keyAliasHistoryPages caps the Tolgee activity pages read per sync.
+	keyAliasHistoryPages = 20
+	// keyAliasMaxVersionFields caps tolgee:key-aliases:versions: past it, new
+	// <from>|<version> fields are counted under <from>|other.
+	keyAliasMaxVersionFields = 2000
+)
+
+// countKeyAliasVersion increments an existing field, or adds it while the
+// hash is under ARGV[2] fields, else counts the serve under ARGV[3].
+var countKeyAliasVersion = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 1 or redis.call('HLEN', KEYS[1]) < tonumber(ARGV[2]) then
+	return redis.call('HINCRBY', KEYS[1], ARGV[1], 1)
+end
+return redis.call('HINCRBY', KEYS[1], ARGV[3], 1)
+`)
+
+// keyAlias keeps a renamed Tolgee key resolvable under its old name for
+// clients built before the rename. From is served with the value of To
+// whenever the catalog has no From key of its own.
+type keyAlias struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Source    string    `json:"source"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Requests counts explicit lookups of From (/api/:lang/key, /keys);
+	// AppVersions counts catalogs served with the alias per X-App-Version.
+	Requests    int64            `json:"requests"`
+	LastUsedAt  *time.Time       `json:"last_used_at,omitempty"`
+	AppVersions map[string]int64 `json:"app_versions,omitempty"`
+}
+
+var (
+	errInvalidKeyAlias = errors.New("alias needs distinct from and to keys")
+	errKeyAliasMissing = errors.New("alias not found")
+
+	keyAliasesMu sync.Mutex
+)
+
+func loadKeyAliases(ctx context.Context) []keyAlias {
+	b, err := redisGet(ctx, keyAliasesCacheKey)
+	if err != nil || len(b) == 0 {
+		return []keyAlias{}
+	}
+	var list []keyAlias
+	if err := json.Unmarshal(b, &list); err != nil {
+		log.Printf("[aliases] unmarshal error: %v", err)
+		return []keyAlias{}
+	}
+	return list
+}
+
+// listKeyAliases returns every alias with its usage, for deciding which ones
+// can be retired.
+func listKeyAliases(ctx context.Context) []keyAlias {
+	list := loadKeyAliases(ctx)
+	hits, _ := rdb.HGetAll(ctx, keyAliasHitsKey).Result()
+	lastUsed, _ := rdb.HGetAll(ctx, keyAliasLastUsedKey).Result()
+	versions, _ := rdb.HGetAll(ctx, keyAliasVersionsKey).Result()
+	for i := range list {
+		a := &list[i]
+		a.Requests, _ = strconv.ParseInt(hits[a.From], 10, 64)
+		if unix, err := strconv.ParseInt(lastUsed[a.From], 10, 64); err == nil {
+			t := time.Unix(unix, 0).UTC()
+			a.LastUsedAt = &t
+		}
+		for field, n := range versions {
+			from, version, ok := strings.Cut(field, "|")
+			if !ok || from != a.From {
+				continue
+			}
+			if a.AppVersions == nil {
+				a.AppVersions = map[string]int64{}
+			}
+			a.AppVersions[version], _ = strconv.ParseInt(n, 10, 64)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].From < list[j].From })
+	return list
+}
+
+func putKeyAlias(ctx context.Context, a keyAlias) error {
+	if a.From == "" || a.To == "" || a.From == a.To {
+		return errInvalidKeyAlias
+	}
+	a.Source = keyAliasSourceAdmin
+	a.CreatedAt = time.Now().UTC()
+	a.Requests, a.LastUsedAt, a.AppVersions = 0, nil, nil
+	mutateKeyAliases(ctx, func(list []keyAlias) []keyAlias {
+		return append(withoutKeyAlias(list, a.From), a)
+	})
+	log.Printf("[aliases] put %q -> %q", a.From, a.To)
+	return nil
+}
+
+func deleteKeyAlias(ctx context.Context, from string) error {
+	found := false
+	mutateKeyAliases(ctx, func(list []keyAlias) []keyAlias {
+		kept := withoutKeyAlias(list, from)
+		found = len(kept) != len(list)
+		return kept
+	})
+	if !found {
+		return errKeyAliasMissing
+	}
+	clearKeyAliasUsage(ctx, from)
+	log.Printf("[aliases] delete %q", from)
+	return nil
+}
+
+func clearKeyAliasUsage(ctx context.Context, from string) {
+	_ = rdb.HDel(ctx, keyAliasHitsKey, from).Err()
+	_ = rdb.HDel(ctx, keyAliasLastUsedKey, from).Err()
+	fields, _ := rdb.HKeys(ctx, keyAliasVersionsKey).Result()
+	for _, field := range fields {
+		if strings.HasPrefix(field, from+"|") {
+			_ = rdb.HDel(ctx, keyAliasVersionsKey, field).Err()
+		}
+	}
+}
+
+func withoutKeyAlias(list []keyAlias, from string) []keyAlias {
+	kept := []keyAlias{}
+	for _, a := range list {
+		if a.From != from {
+			kept = append(kept, a)
+		}
+	}
+	return kept
+}
+
+// mutateKeyAliases applies change to the stored list and persists the result
+// to Redis and S3.
+func mutateKeyAliases(ctx context.Context, change func([]keyAlias) []keyAlias) {
+	keyAliasesMu.Lock()
+	defer keyAliasesMu.Unlock()
+
+	next := change(loadKeyAliases(ctx))
+	for i := range next {
+		next[i].Requests, next[i].LastUsedAt, next[i].AppVersions = 0, nil, nil
+	}
+	b, err := json.Marshal(next)
+	if err != nil {
+		log.Printf("[aliases] marshal error: %v", err)
+		return
+	}
+	storeCacheEntry(ctx, s3ClientIfEnabled(ctx), keyAliasesCacheKey, b, "application/json")
+}
+
+// resolveKeyAliases maps every alias to its final key, following chains
+// left by repeated renames; cycles resolve to nothing.
+func resolveKeyAliases(list []keyAlias) map[string]string {
+	direct := make(map[string]string, len(list))
+	for _, a := range list {
+		direct[a.From] = a.To
+	}
+	resolved := make(map[string]string, len(direct))
+	for from, to := range direct {
+		for i := 0; i < keyAliasMaxChain; i++ {
+			next, ok := direct[to]
+			if !ok {
+				break
+			}
+			to = next
+		}
+		if to != from {
+			resolved[from] = to
+		}
+	}
+	return resolved
+}
+
+// applyKeyAliases adds every aliased old key missing from the catalog with
+// the value of its current key, and counts the serve per X-App-Version.
+// Aliases from outside ENCRYPTED_NAMESPACES into them are skipped: the value
+// would be served in clear under the old key.
+func applyKeyAliases(c *fiber.Ctx, lang string, nested bool, payload []byte) ([]byte, error) {
+	list := loadKeyAliases(context.Background())
+	if len(list) == 0 {
+		return payload, nil
+	}
+	delim := ""
+	if !nested {
+		delim, _ = resolveDelimiter(c)
+	}
+	var tree map[string]any
+	if err := decodeJSON(payload, &tree); err != nil {
+		return nil, err
+	}
+	var applied []string
+	for from, to := range resolveKeyAliases(list) {
+		if _, exists := lookupCatalogValue(tree, from, nested, delim); exists {
+			continue
+		}
+		if isEncryptedNamespace(keyNamespace(to)) && !isEncryptedNamespace(keyNamespace(from)) {
+			continue
+		}
+		value, ok := lookupCatalogValue(tree, to, nested, delim)
+		if !ok {
+			continue
+		}
+		setCatalogValue(tree, from, nested, delim, value)
+		applied = append(applied, from)
+	}
+	if len(applied) == 0 {
+		return payload, nil
+	}
+	c.Locals(localsOverridden, true)
+
+	version := statsLabel(c.Get("X-App-Version"))
+	if !redisEnabled() {
+		return marshalJSON(tree)
+	}
+	go func() {
+		ctx := context.Background()
+		pipe := rdb.Pipeline()
+		for _, from := range applied {
+			countKeyAliasVersion.Eval(ctx, pipe, []string{keyAliasVersionsKey}, from+"|"+version, keyAliasMaxVersionFields, from+"|other")
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("[aliases] lang=%s usage error: %v", lang, err)
+		}
+	}()
+	return marshalJSON(tree)
+}
+
+// keyNamespace is the namespace of a Tolgee key: its first "." segment.
+func keyNamespace(key string) string {
+	ns, _, _ := strings.Cut(key, ".")
+	return ns
+}
+
+// trackKeyAliasLookups counts the requested keys that are aliases.
+func trackKeyAliasLookups(ctx context.Context, requested []string) {
+	aliases := map[string]bool{}
+	for _, a := range loadKeyAliases(ctx) {
+		aliases[a.From] = true
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	for _, key := range requested {
+		if aliases[key] {
+			_ = rdb.HIncrBy(ctx, keyAliasHitsKey, key, 1).Err()
+			_ = rdb.HSet(ctx, keyAliasLastUsedKey, key, now).Err()
+		}
+	}
+}
+
+// syncKeyAliasesFromTolgee derives aliases from the KEY_NAME_EDIT entries of
+// the Tolgee activity log. Admin aliases win over derived ones; derived
+// aliases no longer in the history are kept, since old clients may still
+// use them. It returns the number of aliases added or updated.
+func syncKeyAliasesFromTolgee(ctx context.Context, appKey string) (int, error) {
+	renames, err := fetchKeyRenames(ctx, appKey)
+	if err != nil {
+		return 0, err
+	}
+	changed := 0
+	mutateKeyAliases(ctx, func(list []keyAlias) []keyAlias {
+		existing := make(map[string]keyAlias, len(list))
+		for _, a := range list {
+			existing[a.From] = a
+		}
+		for from, to := range renames {
+			if a, ok := existing[from]; ok && (a.Source == keyAliasSourceAdmin || a.To == to) {
+				continue
+			}
+			list = append(withoutKeyAlias(list, from), keyAlias{From: from, To: to, Source: keyAliasSourceTolgee, CreatedAt: time.Now().UTC()})
+			changed++
+		}
+		return list
+	})
+	if changed > 0 {
+		log.Printf("[aliases] tolgee sync: %d alias(es) added or updated", changed)
+	}
+	return changed, nil
+}
+
+// tolgeeActivityPage is the subset of /v2/projects/activity used for renames.
+type tolgeeActivityPage struct {
+	Embedded struct {
+		Activities []struct {
+			Type             string `json:"type"`
+			ModifiedEntities map[string][]struct {
+				Modifications map[string]struct {
+					Old any `json:"old"`
+					New any `json:"new"`
+				} `json:"modifications"`
+			} `json:"modifiedEntities"`
+		} `json:"activities"`
+	} `json:"_embedded"`
+	Page struct {
+		TotalPages int `json:"totalPages"`
+	} `json:"page"`
+}
+
+// fetchKeyRenames returns old name → new name for every key rename in the
+// activity log, oldest first so later renames win.
+func fetchKeyRenames(ctx context.Context, appKey string) (map[string]string, error) {
+	type rename struct{ from, to string }
+	var newestFirst []rename
+	for page := 0; page < keyAliasHistoryPages; page++ {
+		raw, err := GetProjectResource(ctx, appKey, "activity", map[string]string{"size": "100", "page": strconv.Itoa(page)})
+		if err != nil {
+			return nil, err
+		}
+		var doc tolgeeActivityPage
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, err
+		}
+		for _, activity := range doc.Embedded.Activities {
+			if activity.Type != "KEY_NAME_EDIT" {
+				continue
+			}
+			for _, entity := range activity.ModifiedEntities["Key"] {
+				name, ok := entity.Modifications["name"]
+				from, _ := name.Old.(string)
+				to, _ := name.New.(string)
+				if ok && from != "" && to != "" && from != to {
+					newestFirst = append(newestFirst, rename{from, to})
+				}
+			}
+		}
+		if page+1 >= doc.Page.TotalPages {
+			break
+		}
+	}
+	renames := make(map[string]string, len(newestFirst))
+	for i := len(newestFirst) - 1; i >= 0; i-- {
+		renames[newestFirst[i].from] = newestFirst[i].to
+	}
+	return renames, nil
+}
+
+// refreshKeyAliases runs the Tolgee sync during a full refresh when
+// KEY_ALIASES_FROM_TOLGEE is on; failures only log.
+func refreshKeyAliases(ctx context.Context, appKey string) {
+	if !localenv.GetKeyAliasesFromTolgee() {
+		return
+	}
+	if _, err := syncKeyAliasesFromTolgee(ctx, appKey); err != nil {
+		log.Printf("[aliases] tolgee sync error: %v", err)
+	}
+}
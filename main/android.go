@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// androidQuantities are the plural categories Android accepts; ICU exact
+// matches are kept only for the category they usually stand for.
+var androidQuantities = map[string]string{
+	"zero": "zero", "one": "one", "two": "two", "few": "few", "many": "many", "other": "other",
+	"=0": "zero", "=1": "one", "=2": "two",
+}
+
+var androidQuantityOrder = []string{"zero", "one", "two", "few", "many", "other"}
+
+// encodeCatalogAndroid renders res/values/strings.xml: a <plurals> for every
+// message built around one ICU plural (text around it is copied into each
+// quantity), a <string-array> for every array of scalars and a <string> for
+// the rest. ICU arguments become positional format specifiers (%1$s, # and
+// numbers %1$d); names are the keys with every separator turned into "_".
+func encodeCatalogAndroid(_ string, payload []byte) ([]byte, error) {
+	var tree map[string]any
+	if err := decodeJSON(payload, &tree); err != nil {
+		return nil, err
+	}
+	var strs, plurals, arrays []string
+	// distinct keys can map to the same name ("a.b" and "a_b"): suffix the
+	// later ones, walking keys in sorted order so the suffixes are stable
+	used := map[string]bool{}
+	unique := func(name string) string {
+		out := name
+		for n := 2; used[out]; n++ {
+			out = name + strconv.Itoa(n)
+		}
+		used[out] = true
+		return out
+	}
+	var walk func(prefix string, node map[string]any)
+	walk = func(prefix string, node map[string]any) {
+		keys := make([]string, 0, len(node))
+		for k := range node {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := node[k]
+			name := androidResourceName(prefix + "_" + k)
+			switch val := v.(type) {
+			case map[string]any:
+				walk(name, val)
+			case []any:
+				if items, ok := scalarStrings(val); ok {
+					arrays = append(arrays, androidStringArray(unique(name), items))
+					continue
+				}
+				for i, item := range val {
+					walk(name, map[string]any{strconv.Itoa(i): item})
+				}
+			case nil:
+			default:
+				msg := fmt.Sprint(val)
+				name = unique(name)
+				if el, ok := androidPlurals(name, msg); ok {
+					plurals = append(plurals, el)
+				} else {
+					strs = append(strs, "    <string name=\""+name+"\">"+androidMessage(msg, "")+"</string>\n")
+				}
+			}
+		}
+	}
+	walk("", tree)
+	sort.Strings(strs)
+	sort.Strings(plurals)
+	sort.Strings(arrays)
+
+	var buf bytes.Buffer
+	buf.WriteString("<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<resources>\n")
+	for _, group := range [][]string{strs, plurals, arrays} {
+		for _, el := range group {
+			buf.WriteString(el)
+		}
+	}
+	buf.WriteString("</resources>\n")
+	return buf.Bytes(), nil
+}
+
+// androidResourceName keeps [a-z0-9_] (lower-cased) and prefixes names that
+// would not start with a letter.
+func androidResourceName(key string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimLeft(key, "_") {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteByte('_')
+		}
+	}
+	name := b.String()
+	if name == "" || !unicode.IsLetter(rune(name[0])) {
+		name = "key_" + name
+	}
+	return name
+}
+
+func scalarStrings(items []any) ([]string, bool) {
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		switch item.(type) {
+		case map[string]any, []any:
+			return nil, false
+		case nil:
+			out = append(out, "")
+		default:
+			out = append(out, fmt.Sprint(item))
+		}
+	}
+	return out, true
+}
+
+func androidStringArray(name string, items []string) string {
+	var b strings.Builder
+	b.WriteString("    <string-array name=\"" + name + "\">\n")
+	for _, item := range items {
+		b.WriteString("        <item>" + androidMessage(item, "") + "</item>\n")
+	}
+	b.WriteString("    </string-array>\n")
+	return b.String()
+}
+
+// singlePlural is a message built around exactly one top-level ICU plural:
+// prefix + {arg, plural, ...} + suffix, with the branches by category.
+type singlePlural struct {
+	prefix, arg, suffix string
+	branches            map[string]string
+}
+
+// parseSinglePlural splits msg when it holds exactly one top-level plural
+// with an "other" branch. Exact matches (=0, =1, =2) stand in for the
+// category they usually mean when that category is missing.
+func parseSinglePlural(msg string) (singlePlural, bool) {
+	start, end, arg, options := -1, -1, "", ""
+	for i := 0; i < len(msg); i++ {
+		if msg[i] != '{' {
+			continue
+		}
+		close := matchICUBrace(msg, i)
+		if close < 0 {
+			return singlePlural{}, false
+		}
+		parts := strings.SplitN(msg[i+1:close], ",", 3)
+		if len(parts) == 3 && strings.TrimSpace(parts[1]) == "plural" {
+			if start >= 0 {
+				return singlePlural{}, false
+			}
+			start, end, arg, options = i, close, strings.TrimSpace(parts[0]), parts[2]
+		}
+		i = close
+	}
+	if start < 0 {
+		return singlePlural{}, false
+	}
+	p := singlePlural{prefix: msg[:start], arg: arg, suffix: msg[end+1:], branches: map[string]string{}}
+	selectors, bodies := parseICUOptions(options)
+	for i, sel := range selectors {
+		q, ok := androidQuantities[sel]
+		if !ok {
+			continue
+		}
+		// a category wins over the exact match standing in for it
+		if _, taken := p.branches[q]; taken && strings.HasPrefix(sel, "=") {
+			continue
+		}
+		p.branches[q] = bodies[i]
+	}
+	if _, ok := p.branches["other"]; !ok {
+		return singlePlural{}, false
+	}
+	return p, true
+}
+
+// androidPlurals converts a message holding exactly one top-level plural.
+func androidPlurals(name, msg string) (string, bool) {
+	p, ok := parseSinglePlural(msg)
+	if !ok {
+		return "", false
+	}
+	var b strings.Builder
+	b.WriteString("    <plurals name=\"" + name + "\">\n")
+	for _, q := range androidQuantityOrder {
+		if body, ok := p.branches[q]; ok {
+			b.WriteString("        <item quantity=\"" + q + "\">" + androidMessage(p.prefix+body+p.suffix, p.arg) + "</item>\n")
+		}
+	}
+	b.WriteString("    </plurals>\n")
+	return b.String(), true
+}
+
+// androidMessage escapes literal text and turns ICU arguments into format
+// specifiers (see icuPrintf); "#" takes position 1, so
+// getQuantityString(id, n, n) works.
+func androidMessage(msg, pluralArg string) string {
+	out := icuPrintf(msg, pluralArg, "s", androidEscape)
+	if strings.HasPrefix(out, "@") || strings.HasPrefix(out, "?") {
+		out = `\` + out
+	}
+	return out
+}
+
+// icuPrintf turns ICU arguments into positional printf specifiers: {name}
+// becomes %N$<stringVerb>, {n, number} and the "#" of pluralArg %N$d.
+// pluralArg takes position 1, the other arguments follow in order of
+// appearance. Literal text goes through escape (with "%" doubled when the
+// result has specifiers); unsupported constructs (select, nested plurals) are
+// kept as text.
+func icuPrintf(msg, pluralArg, stringVerb string, escape func(string) string) string {
+	positions := map[string]int{}
+	if pluralArg != "" {
+		positions[pluralArg] = 1
+	}
+	position := func(arg string) int {
+		if p, ok := positions[arg]; ok {
+			return p
+		}
+		positions[arg] = len(positions) + 1
+		return positions[arg]
+	}
+	type segment struct {
+		text    string
+		literal bool
+	}
+	var segments []segment
+	literal := strings.Builder{}
+	flush := func() {
+		if literal.Len() > 0 {
+			segments = append(segments, segment{literal.String(), true})
+			literal.Reset()
+		}
+	}
+	for i := 0; i < len(msg); i++ {
+		switch {
+		case msg[i] == '#' && pluralArg != "":
+			flush()
+			segments = append(segments, segment{"%" + strconv.Itoa(position(pluralArg)) + "$d", false})
+		case msg[i] == '{':
+			close := matchICUBrace(msg, i)
+			if close < 0 {
+				literal.WriteString(msg[i:])
+				i = len(msg)
+				continue
+			}
+			parts := strings.SplitN(msg[i+1:close], ",", 3)
+			arg := strings.TrimSpace(parts[0])
+			kind := ""
+			if len(parts) > 1 {
+				kind = strings.TrimSpace(parts[1])
+			}
+			switch {
+			case len(parts) == 1 && arg != "":
+				flush()
+				segments = append(segments, segment{"%" + strconv.Itoa(position(arg)) + "$" + stringVerb, false})
+			case kind == "number" && len(parts) == 2:
+				flush()
+				segments = append(segments, segment{"%" + strconv.Itoa(position(arg)) + "$d", false})
+			default:
+				literal.WriteString(msg[i : close+1])
+			}
+			i = close
+		default:
+			literal.WriteByte(msg[i])
+		}
+	}
+	flush()
+	formatted := false
+	for _, s := range segments {
+		formatted = formatted || !s.literal
+	}
+	var b strings.Builder
+	for _, s := range segments {
+		if !s.literal {
+			b.WriteString(s.text)
+			continue
+		}
+		text := s.text
+		if formatted {
+			text = strings.ReplaceAll(text, "%", "%%")
+		}
+		b.WriteString(escape(text))
+	}
+	return b.String()
+}
+
+var androidEscaper = strings.NewReplacer(
+	`\`, `\\`, `'`, `\'`, `"`, `\"`, "\n", `\n`, "\t", `\t`,
+	"&", "&amp;", "<", "&lt;", ">", "&gt;",
+)
+
+func androidEscape(s string) string {
+	return androidEscaper.Replace(s)
+}
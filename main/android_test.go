@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncodeCatalogAndroid(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    []string // lines expected in strings.xml
+	}{
+		{
+			name:    "apostrophe and quotes",
+			payload: `{"a":"it's \"new\""}`,
+			want:    []string{`<string name="a">it\'s \"new\"</string>`},
+		},
+		{
+			name:    "markup characters",
+			payload: `{"a":"Tom & Jerry <b>"}`,
+			want:    []string{`<string name="a">Tom &amp; Jerry &lt;b&gt;</string>`},
+		},
+		{
+			name:    "backslash, newline and tab",
+			payload: `{"a":"C:\\dir\nnext\tcol"}`,
+			want:    []string{`<string name="a">C:\\dir\nnext\tcol</string>`},
+		},
+		{
+			name:    "leading at and question mark",
+			payload: `{"a":"@home","b":"?why"}`,
+			want:    []string{`<string name="a">\@home</string>`, `<string name="b">\?why</string>`},
+		},
+		{
+			name:    "percent without arguments",
+			payload: `{"a":"100%"}`,
+			want:    []string{`<string name="a">100%</string>`},
+		},
+		{
+			name:    "percent with arguments",
+			payload: `{"a":"{name} is 100% done"}`,
+			want:    []string{`<string name="a">%1$s is 100%% done</string>`},
+		},
+		{
+			name:    "numbered arguments",
+			payload: `{"a":"{user} has {n, number} points"}`,
+			want:    []string{`<string name="a">%1$s has %2$d points</string>`},
+		},
+		{
+			name:    "plurals",
+			payload: `{"files":"{count, plural, one {# file from {user}} other {# files from {user}}}"}`,
+			want: []string{
+				`<plurals name="files">`,
+				`<item quantity="one">%1$d file from %2$s</item>`,
+				`<item quantity="other">%1$d files from %2$s</item>`,
+			},
+		},
+		{
+			name:    "exact match stands in for its category",
+			payload: `{"n":"{c, plural, =1 {one item} other {# items}}"}`,
+			want:    []string{`<item quantity="one">one item</item>`, `<item quantity="other">%1$d items</item>`},
+		},
+		{
+			name:    "string array",
+			payload: `{"days":["Mon","it's Tue"]}`,
+			want:    []string{`<string-array name="days">`, `<item>Mon</item>`, `<item>it\'s Tue</item>`},
+		},
+		{
+			name:    "resource names",
+			payload: `{"home":{"Title-Main":"x"},"1st":"y"}`,
+			want:    []string{`<string name="home_title_main">x</string>`, `<string name="key_1st">y</string>`},
+		},
+		{
+			name:    "colliding names",
+			payload: `{"a":{"b":"x"},"a_b":"y"}`,
+			want:    []string{`<string name="a_b">x</string>`, `<string name="a_b2">y</string>`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := encodeCatalogAndroid("en", []byte(tt.payload))
+			if err != nil {
+				t.Fatalf("encodeCatalogAndroid: %v", err)
+			}
+			for _, line := range tt.want {
+				if !strings.Contains(string(out), line) {
+					t.Errorf("missing %s in\n%s", line, out)
+				}
+			}
+		})
+	}
+}
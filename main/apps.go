@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// Tenant apps: further Tolgee projects served by the same deployment on
+// /api/:app/languages and /api/:app/:lang. The default project
+// (TOLGEE_APP_KEY) keeps its unprefixed keys and every other endpoint;
+// tenant catalogs live under tolgee:app:<app>:* in Redis and S3, cached for
+// APP_CACHE_TTL and served from the last S3 copy while Tolgee fails.
+
+// appsRegistryKey is the Redis hash app -> Tolgee app key of the apps
+// registered through the admin API; APPS entries take precedence.
+const appsRegistryKey = "tolgee:apps"
+
+var (
+	errUnknownApp     = errors.New("unknown app")
+	errInvalidAppName = errors.New("app name must match [a-z][a-z0-9-]{1,31} and not be a reserved /api segment")
+	errConfiguredApp  = errors.New("app is configured through APPS")
+
+	errUnknownAppLanguage = errors.New("language not available for this app")
+)
+
+var appNamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]{1,31}$`)
+
+// reservedAppNames are the first /api segments of the fixed routes, which
+// are matched before the tenant routes.
+var reservedAppNames = map[string]bool{
+	"admin": true, "apps": true, "branch": true, "branches": true, "cache-policy": true,
+	"freshness": true, "git": true, "group": true, "healthz": true, "languages": true,
+	"manifest": true, "matrix": true, "namespaces": true, "readyz": true, "screenshots": true,
+	"stats": true, "sync": true, "tags": true, "tolgee": true, "transliterate": true,
+	"update": true, "v": true, "warmup": true,
+}
+
+func validAppName(name string) bool {
+	return appNamePattern.MatchString(name) && !reservedAppNames[name]
+}
+
+// validateConfiguredApps rejects APPS entries that the tenant routes could
+// never reach.
+func validateConfiguredApps() error {
+	for name := range localenv.GetApps() {
+		if !validAppName(name) {
+			return fmt.Errorf("APPS entry %q: %w", name, errInvalidAppName)
+		}
+	}
+	return nil
+}
+
+type appInfo struct {
+	Name   string `json:"name"`
+	Source string `json:"source"` // config or admin
+}
+
+func appKeyPrefix(app string) string {
+	return "tolgee:app:" + app + ":"
+}
+
+func appLanguagesKey(app string) string {
+	return appKeyPrefix(app) + "languages"
+}
+
+func appTranslationsKey(app, lang string, nested bool) string {
+	return appKeyPrefix(app) + "lang:" + lang + ":" + strconv.FormatBool(nested)
+}
+
+// lookupApp returns the Tolgee app key of a tenant app.
+func lookupApp(ctx context.Context, app string) (string, error) {
+	if key, ok := localenv.GetApps()[app]; ok && key != "" {
+		return key, nil
+	}
+	key, err := rdb.HGet(ctx, appsRegistryKey, app).Result()
+	if errors.Is(err, redis.Nil) || (err == nil && key == "") {
+		return "", errUnknownApp
+	}
+	return key, err
+}
+
+// listApps returns the tenant apps, sorted by name.
+func listApps(ctx context.Context) ([]appInfo, error) {
+	registered, err := rdb.HKeys(ctx, appsRegistryKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	configured := localenv.GetApps()
+	out := make([]appInfo, 0, len(configured)+len(registered))
+	for name := range configured {
+		out = append(out, appInfo{Name: name, Source: "config"})
+	}
+	for _, name := range registered {
+		if _, ok := configured[name]; !ok {
+			out = append(out, appInfo{Name: name, Source: "admin"})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// registerApp adds or replaces an app in the Redis registry; a new app key
+// drops the catalogs cached with the old one.
+func registerApp(ctx context.Context, app, appKey string) error {
+	if !validAppName(app) {
+		return errInvalidAppName
+	}
+	if _, ok := localenv.GetApps()[app]; ok {
+		return errConfiguredApp
+	}
+	if err := rdb.HSet(ctx, appsRegistryKey, app, appKey).Err(); err != nil {
+		return err
+	}
+	purgeAppCaches(ctx, app, false)
+	log.Printf("[apps] registered app=%s", app)
+	return nil
+}
+
+// unregisterApp removes an app from the registry together with its Redis
+// and S3 catalogs; it reports whether the app existed.
+func unregisterApp(ctx context.Context, app string) (bool, error) {
+	if _, ok := localenv.GetApps()[app]; ok {
+		return true, errConfiguredApp
+	}
+	n, err := rdb.HDel(ctx, appsRegistryKey, app).Result()
+	if err != nil || n == 0 {
+		return false, err
+	}
+	purgeAppCaches(ctx, app, true)
+	log.Printf("[apps] unregistered app=%s", app)
+	return true, nil
+}
+
+// purgeAppCaches drops the Redis catalogs of app, so the next request
+// exports them again; withS3 also deletes the S3 copies.
+func purgeAppCaches(ctx context.Context, app string, withS3 bool) {
+	if keys, err := redisScanKeys(ctx, appKeyPrefix(app)+"*"); err == nil && len(keys) > 0 {
+		if err := redisDel(ctx, keys...); err != nil {
+			log.Printf("[apps] purge error app=%s: %v", app, err)
+		}
+	}
+	if !withS3 {
+		return
+	}
+	if s3c := s3ClientIfEnabled(ctx); s3c != nil {
+		keys, err := s3c.listKeys(ctx, appKeyPrefix(app))
+		if err != nil {
+			log.Printf("[apps] s3 list error app=%s: %v", app, err)
+			return
+		}
+		for _, key := range keys {
+			_ = s3c.deleteObject(ctx, key)
+		}
+	}
+}
+
+// getAppResource serves key from Redis, else calls fetch (deduplicated and
+// cooled down like every upstream call) and stores the result in Redis for
+// APP_CACHE_TTL and in S3; when Tolgee fails the S3 copy is served.
+func getAppResource(ctx context.Context, app, key string, fetch func(appKey string) ([]byte, error)) ([]byte, error) {
+	if cached, err := redisGet(ctx, key); err == nil && len(cached) > 0 {
+		return cached, nil
+	}
+	appKey, err := lookupApp(ctx, app)
+	if err != nil {
+		return nil, err
+	}
+	if tolgeeOffline() {
+		// local, git and fixture sources only hold the default project
+		return nil, errLocalSourceUnsupported
+	}
+	s3c := s3ClientIfEnabled(ctx)
+	payload, err := fetchUpstream(ctx, key, func() ([]byte, error) { return fetch(appKey) })
+	if err == nil && (len(payload) == 0 || string(payload) == "{}") {
+		// nothing worth keeping: empty exports are served, never persisted
+		return []byte("{}"), nil
+	}
+	if err != nil {
+		if s3c != nil {
+			if stale, s3Err := s3c.getObject(ctx, key); s3Err == nil && len(stale) > 0 {
+				log.Printf("[apps] tolgee error, serving s3 copy key=%q: %v", key, err)
+				return stale, nil
+			}
+		}
+		return nil, err
+	}
+	if err := redisPut(ctx, key, payload, localenv.GetAppCacheTTL()); err != nil {
+		log.Printf("[apps] cache put error key=%q: %v", key, err)
+	}
+	if s3c != nil {
+		if err := s3c.putObject(ctx, key, payload, "application/json", map[string]string{}); err != nil {
+			log.Printf("[apps] s3 put error key=%q: %v", key, err)
+		}
+	}
+	return payload, nil
+}
+
+// GetAppLanguagesFromCache returns the Tolgee /languages body of a tenant app.
+func GetAppLanguagesFromCache(ctx context.Context, app string) ([]byte, error) {
+	return getAppResource(ctx, app, appLanguagesKey(app), func(appKey string) ([]byte, error) {
+		_, b, err := GetLanguages(ctx, appKey)
+		return b, err
+	})
+}
+
+// appHasLanguage checks lang against the app's Tolgee languages, so unknown
+// tags never reach Tolgee nor the caches.
+func appHasLanguage(ctx context.Context, app, lang string) error {
+	b, err := GetAppLanguagesFromCache(ctx, app)
+	if err != nil {
+		return err
+	}
+	var model TolgeeModel
+	if err := decodeJSON(b, &model); err != nil {
+		return err
+	}
+	for _, tag := range languageTags(&model) {
+		if tag == lang {
+			return nil
+		}
+	}
+	return errUnknownAppLanguage
+}
+
+// GetAppTranslationsFromCache returns the catalog of lang for a tenant app.
+func GetAppTranslationsFromCache(ctx context.Context, app, lang string, nested bool) ([]byte, error) {
+	if err := appHasLanguage(ctx, app, lang); err != nil {
+		return nil, err
+	}
+	return getAppResource(ctx, app, appTranslationsKey(app, lang, nested), func(appKey string) ([]byte, error) {
+		files, err := GetTranslations(ctx, appKey, lang, nested)
+		if err != nil {
+			return nil, err
+		}
+		if payload := files[lang]; len(payload) > 0 {
+			return payload, nil
+		}
+		return []byte("{}"), nil
+	})
+}
+
+// loadAppVariant resolves the payload shape like loadTranslationsVariant;
+// custom delimiters are derived from the nested export.
+func loadAppVariant(c *fiber.Ctx, app, lang string, nested bool) ([]byte, error) {
+	if nested {
+		return GetAppTranslationsFromCache(c.UserContext(), app, lang, true)
+	}
+	delim, err := resolveDelimiter(c)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if delim == "" {
+		return GetAppTranslationsFromCache(c.UserContext(), app, lang, false)
+	}
+	source, err := GetAppTranslationsFromCache(c.UserContext(), app, lang, true)
+	if err != nil {
+		return nil, err
+	}
+	return flattenTranslations(source, delim)
+}
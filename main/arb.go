@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/goccy/go-json"
+)
+
+// catalogStrings flattens a nested or flat catalog to "a.b"/"a[0]" keys with
+// string values (numbers and booleans are stringified, nulls dropped) and
+// returns the keys sorted.
+func catalogStrings(payload []byte) ([]string, map[string]string, error) {
+	var tree map[string]any
+	if err := decodeJSON(payload, &tree); err != nil {
+		return nil, nil, err
+	}
+	flat := map[string]any{}
+	flattenInto(flat, "", tree, ".")
+	values := make(map[string]string, len(flat))
+	keys := make([]string, 0, len(flat))
+	for k, v := range flat {
+		if v == nil {
+			continue
+		}
+		values[k] = fmt.Sprint(v)
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, values, nil
+}
+
+// icuArguments returns the arguments of an ICU message with their type
+// ("" for a plain {name}, else "plural", "select", "number", "date"...),
+// including those nested inside plural/select branches.
+func icuArguments(msg string, out map[string]string) {
+	for i := 0; i < len(msg); i++ {
+		if msg[i] != '{' {
+			continue
+		}
+		end := matchICUBrace(msg, i)
+		if end < 0 {
+			return
+		}
+		parts := strings.SplitN(msg[i+1:end], ",", 3)
+		name := strings.TrimSpace(parts[0])
+		kind := ""
+		if len(parts) > 1 {
+			kind = strings.TrimSpace(parts[1])
+		}
+		if name != "" && !strings.ContainsAny(name, " {}") {
+			if _, seen := out[name]; !seen || out[name] == "" {
+				out[name] = kind
+			}
+		}
+		if len(parts) == 3 && (kind == "plural" || kind == "selectordinal" || kind == "select") {
+			_, bodies := parseICUOptions(parts[2])
+			for _, body := range bodies {
+				icuArguments(body, out)
+			}
+		}
+		i = end
+	}
+}
+
+// arbPlaceholder maps an ICU argument type to the gen-l10n placeholder
+// declaration; DateTime needs a format, "yMd" is the neutral default.
+func arbPlaceholder(kind string) map[string]string {
+	switch kind {
+	case "plural", "selectordinal", "number":
+		return map[string]string{"type": "num"}
+	case "date", "time":
+		return map[string]string{"type": "DateTime", "format": "yMd"}
+	}
+	return map[string]string{"type": "String"}
+}
+
+// arbResourceName turns a Tolgee key into the lowerCamelCase Dart identifier
+// gen-l10n requires ("home.title" -> "homeTitle").
+func arbResourceName(key string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range key {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			upper = b.Len() > 0
+			continue
+		}
+		switch {
+		case b.Len() == 0:
+			r = unicode.ToLower(r)
+		case upper:
+			r = unicode.ToUpper(r)
+		}
+		upper = false
+		b.WriteRune(r)
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "key" + name
+	}
+	return name
+}
+
+// encodeCatalogARB renders a Flutter ARB file: "@@locale", then every message
+// followed by its "@key" block with the original Tolgee key as description
+// and the placeholders found by ICU analysis, so gen-l10n accepts it as is.
+func encodeCatalogARB(lang string, payload []byte) ([]byte, error) {
+	keys, values, err := catalogStrings(payload)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString("{\n  \"@@locale\": ")
+	writeJSONString(&buf, strings.ReplaceAll(lang, "-", "_"))
+	used := map[string]bool{}
+	for _, key := range keys {
+		name := arbResourceName(key)
+		for n := 2; used[name]; n++ {
+			name = arbResourceName(key) + strconv.Itoa(n)
+		}
+		used[name] = true
+
+		meta := map[string]any{"description": key}
+		args := map[string]string{}
+		icuArguments(values[key], args)
+		if len(args) > 0 {
+			placeholders := make(map[string]any, len(args))
+			for arg, kind := range args {
+				placeholders[arg] = arbPlaceholder(kind)
+			}
+			meta["placeholders"] = placeholders
+		}
+		metaJSON, err := marshalJSON(meta)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(",\n  ")
+		writeJSONString(&buf, name)
+		buf.WriteString(": ")
+		writeJSONString(&buf, values[key])
+		buf.WriteString(",\n  ")
+		writeJSONString(&buf, "@"+name)
+		buf.WriteString(": ")
+		buf.Write(metaJSON)
+	}
+	buf.WriteString("\n}\n")
+	return buf.Bytes(), nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	b, _ := json.Marshal(s)
+	buf.Write(b)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// baseLanguageTag returns the Tolgee base language from the cached languages
+// payload, or "" when unknown.
+func baseLanguageTag(ctx context.Context) string {
+	b, err := GetLanguagesFromCache(ctx)
+	if err != nil {
+		return ""
+	}
+	var model TolgeeModel
+	if err := json.Unmarshal(b, &model); err != nil {
+		return ""
+	}
+	for _, l := range model.Embedded.Languages {
+		if l.Base {
+			return l.Tag
+		}
+	}
+	return ""
+}
+
+// applyBaseBackfill replaces empty-string values of a non-base language with
+// the base-language value (BACKFILL_EMPTY_FROM_BASE), listing the replaced
+// Tolgee keys in "<STALE_BANNER_KEY>.backfilled" so apps can tell them apart.
+// Results are cached under the served language; unknown ones are filled inline.
+func applyBaseBackfill(c *fiber.Ctx, lang string, nested bool, payload []byte) ([]byte, error) {
+	if !localenv.GetBackfillEmptyFromBase() {
+		return payload, nil
+	}
+	served, known := servedLanguageOf(c)
+	if !known {
+		served = lang
+	}
+	base := baseLanguageTag(context.Background())
+	if base == "" || base == served {
+		return payload, nil
+	}
+	source, err := loadTranslationsVariant(c, base, nested)
+	if err != nil {
+		return payload, nil
+	}
+	key := "tolgee:backfilled:" + served + ":" + sha256Hex([]byte(sha256Hex(payload) + sha256Hex(source)))[:12]
+	if known {
+		if cached, err := redisGet(context.Background(), key); err == nil && len(cached) > 0 {
+			c.Locals(localsOverridden, true)
+			return cached, nil
+		}
+	}
+
+	var tree, baseTree map[string]any
+	if err := decodeJSON(payload, &tree); err != nil {
+		return nil, err
+	}
+	if err := decodeJSON(source, &baseTree); err != nil {
+		return nil, err
+	}
+	delim := ""
+	if !nested {
+		delim, _ = resolveDelimiter(c)
+	}
+	var filled []string
+	backfillTree(tree, baseTree, "", nested, delim, &filled)
+	if len(filled) == 0 {
+		return payload, nil
+	}
+	sort.Strings(filled)
+	setCatalogValue(tree, localenv.GetStaleBannerKey()+".backfilled", nested, delim, filled)
+	out, err := marshalJSON(tree)
+	if err != nil {
+		return nil, err
+	}
+	if known {
+		_ = redisPut(context.Background(), key, out, derivedVariantTTL())
+	}
+	c.Locals(localsOverridden, true)
+	return out, nil
+}
+
+func backfillTree(tree, base map[string]any, prefix string, nested bool, delim string, filled *[]string) {
+	for k, v := range tree {
+		key := k
+		switch {
+		case nested && prefix != "":
+			key = prefix + "." + k
+		case !nested && delim != "":
+			key = strings.ReplaceAll(k, delim, ".")
+		}
+		tree[k] = backfillValue(v, base[k], key, nested, delim, filled)
+	}
+}
+
+func backfillValue(v, base any, key string, nested bool, delim string, filled *[]string) any {
+	switch val := v.(type) {
+	case map[string]any:
+		if b, ok := base.(map[string]any); ok {
+			backfillTree(val, b, key, nested, delim, filled)
+		}
+	case []any:
+		b, _ := base.([]any)
+		for i, item := range val {
+			var baseItem any
+			if i < len(b) {
+				baseItem = b[i]
+			}
+			val[i] = backfillValue(item, baseItem, key+"["+strconv.Itoa(i)+"]", nested, delim, filled)
+		}
+	case string:
+		if b, ok := base.(string); ok && val == "" && b != "" {
+			*filled = append(*filled, key)
+			return b
+		}
+	}
+	return v
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// Beta locales (BETA_LANGUAGES or the runtime config) are soft-launched: they
+// are only served to clients that opt in with ?include_beta=true or the
+// X-Include-Beta: true header. For everyone else they do not exist: the
+// languages list hides them, negotiation skips them and a direct request
+// follows the fallback chain.
+
+type betaOptInCtxKey struct{}
+
+// resolveIncludeBeta reads the opt-in from the query, then the header.
+func resolveIncludeBeta(c *fiber.Ctx) bool {
+	c.Vary("X-Include-Beta")
+	if raw := c.Query("include_beta"); raw != "" {
+		ok, _ := strconv.ParseBool(raw)
+		return ok
+	}
+	ok, _ := strconv.ParseBool(c.Get("X-Include-Beta"))
+	return ok
+}
+
+func withBetaOptIn(ctx context.Context, optIn bool) context.Context {
+	return context.WithValue(ctx, betaOptInCtxKey{}, optIn)
+}
+
+// isBetaLanguage reports whether lang is a beta locale.
+func isBetaLanguage(lang string) bool {
+	for _, l := range betaLanguages() {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// betaHidden reports whether lang must be skipped for this context. Lookups
+// without a request context (refresh, sync, admin) see every language.
+func betaHidden(ctx context.Context, lang string) bool {
+	optIn, scoped := ctx.Value(betaOptInCtxKey{}).(bool)
+	return scoped && !optIn && isBetaLanguage(lang)
+}
+
+// withoutBetaLanguages drops the beta locales from a Tolgee languages payload.
+func withoutBetaLanguages(payload []byte) ([]byte, error) {
+	if len(betaLanguages()) == 0 {
+		return payload, nil
+	}
+	var doc map[string]any
+	if err := decodeJSON(payload, &doc); err != nil {
+		return nil, err
+	}
+	embedded, _ := doc["_embedded"].(map[string]any)
+	languages, _ := embedded["languages"].([]any)
+	kept := make([]any, 0, len(languages))
+	for _, l := range languages {
+		if m, ok := l.(map[string]any); ok {
+			if tag, _ := m["tag"].(string); isBetaLanguage(tag) {
+				continue
+			}
+		}
+		kept = append(kept, l)
+	}
+	if embedded == nil {
+		return payload, nil
+	}
+	embedded["languages"] = kept
+	return json.Marshal(doc)
+}
+
+// betaLanguages returns the runtime list, or BETA_LANGUAGES.
+func betaLanguages() []string {
+	if cfg := currentRuntimeConfig(); cfg.BetaLanguages != nil {
+		return cfg.BetaLanguages
+	}
+	return localenv.GetBetaLanguages()
+}
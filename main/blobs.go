@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// Content-addressed snapshots (CONTENT_ADDRESSED_STORAGE): a snapshot key
+// holds a small pointer to tolgee:blob:<sha>, written once per distinct
+// content, so variants and languages with byte-identical payloads (flat and
+// nested catalogs without nested keys, region variants equal to their base,
+// unchanged variants across refreshes) share one copy in Redis and S3.
+// Readers resolve pointers transparently in redisGet and s3Client.getObject.
+
+const (
+	blobKeyPrefix = "tolgee:blob:"
+	// casPointerMagic cannot start a JSON document or a protobuf message.
+	casPointerMagic = "\x00cas1:"
+	blobGCLockKey   = "tolgee:blob-gc:lock"
+	// blobGCCandidatesKey holds the blobs found unreferenced by the previous
+	// sweep: a blob is deleted only when two sweeps in a row find it unused,
+	// so a blob written just before its pointer is never collected.
+	blobGCCandidatesKey = "tolgee:blob-gc:candidates"
+	// blobDeletingPrefix marks, inside the candidates set, a blob the sweep
+	// is deleting right now: storeBlob does not point new snapshots to it.
+	blobDeletingPrefix = "deleting:"
+)
+
+// casPointerSize is the size of a stored pointer, used to skip reading
+// full snapshots while looking for pointers in S3.
+var casPointerSize = int64(len(casPointerMagic) + 64)
+
+// claimBlob takes ARGV[1] off the GC candidates and reports whether the
+// sweep is deleting it (1) and it must not be referenced.
+var claimBlob = redis.NewScript(`
+redis.call('SREM', KEYS[1], ARGV[1])
+return redis.call('SISMEMBER', KEYS[1], ARGV[2])
+`)
+
+// startBlobDeletion marks ARGV[1] as being deleted if it is still a
+// candidate: 0 means storeBlob referenced it again since the mark.
+var startBlobDeletion = redis.NewScript(`
+if redis.call('SREM', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('SADD', KEYS[1], ARGV[2])
+return 1
+`)
+
+func blobKey(sha string) string {
+	return blobKeyPrefix + sha
+}
+
+func casPointer(sha string) []byte {
+	return []byte(casPointerMagic + sha)
+}
+
+// casPointerSHA returns the blob sha a stored value points to.
+func casPointerSHA(b []byte) (string, bool) {
+	if len(b) != len(casPointerMagic)+64 || !bytes.HasPrefix(b, []byte(casPointerMagic)) {
+		return "", false
+	}
+	return string(b[len(casPointerMagic):]), true
+}
+
+// contentAddressed reports whether key is stored as a pointer to a blob.
+func contentAddressed(key string) bool {
+	return localenv.GetContentAddressedStorage() && strings.HasPrefix(key, "tolgee:lang:")
+}
+
+// storeBlob writes payload once under its sha and returns the pointer to
+// store under the variant key. The S3 blob is only uploaded when missing;
+// the Redis blob is rewritten to extend its TTL along with the pointer.
+// While the sweep is deleting that blob it returns payload itself, stored
+// inline under the variant key.
+func storeBlob(ctx context.Context, s3c *s3Client, payload []byte, contentType string, ttl time.Duration) ([]byte, error) {
+	sha := sha256Hex(payload)
+	key := blobKey(sha)
+	// referenced again: no longer a GC candidate (no GC runs without Redis)
+	if redisEnabled() {
+		deleting, err := claimBlob.Run(ctx, rdb, []string{blobGCCandidatesKey}, sha, blobDeletingPrefix+sha).Int()
+		if err != nil {
+			return nil, err
+		}
+		if deleting == 1 {
+			return payload, nil
+		}
+	}
+	if !storeHoldsRefreshOutput(s3c) {
+		_ = redisDel(ctx, key)
+	} else if err := redisPut(ctx, key, payload, ttl); err != nil {
+		return nil, err
+	}
+	if s3c != nil {
+		if _, err := s3c.headObject(ctx, key); err != nil {
+			// blobs are immutable: never conditional on the refresh generation
+			if err := s3c.putObject(context.WithValue(ctx, refreshGenerationCtxKey{}, nil), key, payload, contentType, nil); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return casPointer(sha), nil
+}
+
+// resolveRedisPointer follows a pointer read from the store, falling back to
+// the S3 blob when the Redis copy is gone.
+func resolveRedisPointer(ctx context.Context, b []byte) ([]byte, error) {
+	sha, ok := casPointerSHA(b)
+	if !ok {
+		return b, nil
+	}
+	blob, err := store.get(ctx, blobKey(sha))
+	if err == nil {
+		return blob, nil
+	}
+	// the memory store has already read S3
+	if s3c := s3ClientIfEnabled(ctx); s3c != nil && redisEnabled() {
+		return s3c.getObject(ctx, blobKey(sha))
+	}
+	return nil, err
+}
+
+type blobGCReport struct {
+	Pointers int `json:"pointers"`
+	Blobs    int `json:"blobs"`
+	Marked   int `json:"marked"`
+	Deleted  int `json:"deleted"`
+}
+
+// collectBlobs deletes the blobs no snapshot points to anymore, in Redis and
+// S3 (mark and sweep across two runs, see blobGCCandidatesKey).
+func collectBlobs(ctx context.Context) (*blobGCReport, error) {
+	report := &blobGCReport{}
+	if !redisEnabled() {
+		return report, errRedisDisabled
+	}
+	s3c := s3ClientIfEnabled(ctx)
+	live := map[string]bool{}
+
+	keys, err := redisScanKeys(ctx, "tolgee:lang:*")
+	if err != nil {
+		return report, err
+	}
+	for _, key := range keys {
+		if raw, err := rdb.Get(ctx, key).Bytes(); err == nil {
+			if sha, ok := casPointerSHA(raw); ok {
+				live[sha] = true
+				report.Pointers++
+			}
+		}
+	}
+	var s3Blobs []string
+	if s3c != nil {
+		objects, err := s3c.listObjectSizes(ctx, "tolgee:lang:")
+		if err != nil {
+			return report, err
+		}
+		for key, size := range objects {
+			if size != casPointerSize {
+				continue
+			}
+			if raw, _, err := s3c.readObject(ctx, key); err == nil {
+				if sha, ok := casPointerSHA(raw); ok {
+					live[sha] = true
+					report.Pointers++
+				}
+			}
+		}
+		if s3Blobs, err = s3c.listKeys(ctx, blobKeyPrefix); err != nil {
+			return report, err
+		}
+	}
+	redisBlobs, err := redisScanKeys(ctx, blobKeyPrefix+"*")
+	if err != nil {
+		return report, err
+	}
+
+	previous, err := rdb.SMembers(ctx, blobGCCandidatesKey).Result()
+	if err != nil && err != redis.Nil {
+		return report, err
+	}
+	wasCandidate := map[string]bool{}
+	for _, sha := range previous {
+		if !strings.HasPrefix(sha, blobDeletingPrefix) {
+			wasCandidate[sha] = true
+		}
+	}
+	blobs := map[string]bool{}
+	for _, key := range append(redisBlobs, s3Blobs...) {
+		blobs[strings.TrimPrefix(key, blobKeyPrefix)] = true
+	}
+	report.Blobs = len(blobs)
+	unused := map[string]bool{}
+	for sha := range blobs {
+		if !live[sha] {
+			unused[sha] = true
+		}
+	}
+	var next []any
+	for sha := range unused {
+		if !wasCandidate[sha] {
+			next = append(next, sha)
+			report.Marked++
+			continue
+		}
+		// storeBlob may have referenced it since SMembers above
+		if ok, err := startBlobDeletion.Run(ctx, rdb, []string{blobGCCandidatesKey}, sha, blobDeletingPrefix+sha).Int(); err != nil || ok == 0 {
+			continue
+		}
+		_ = redisDel(ctx, blobKey(sha))
+		if s3c != nil {
+			_ = s3c.deleteObject(ctx, blobKey(sha))
+		}
+		rdb.SRem(ctx, blobGCCandidatesKey, blobDeletingPrefix+sha)
+		report.Deleted++
+	}
+	pipe := rdb.TxPipeline()
+	pipe.Del(ctx, blobGCCandidatesKey)
+	if len(next) > 0 {
+		pipe.SAdd(ctx, blobGCCandidatesKey, next...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return report, err
+	}
+	log.Printf("[blob] gc pointers=%d blobs=%d marked=%d deleted=%d", report.Pointers, report.Blobs, report.Marked, report.Deleted)
+	return report, nil
+}
+
+// errBlobGCBusy is returned when another sweep holds blobGCLockKey.
+var errBlobGCBusy = errors.New("a blob GC sweep is running or ran less than BLOB_GC_INTERVAL/2 ago")
+
+// blobGCLockLease is the TTL of a running sweep's lock, renewed while it
+// runs, so only a crashed sweep frees it early.
+const blobGCLockLease = time.Minute
+
+// runBlobGC sweeps under blobGCLockKey, for the ticker and the admin endpoint
+// alike. The lock then stays held for BLOB_GC_INTERVAL/2: two sweeps too
+// close together would delete a blob marked by the first one right after
+// it was written, before its pointer.
+func runBlobGC(ctx context.Context) (*blobGCReport, error) {
+	if !redisEnabled() {
+		return &blobGCReport{}, errRedisDisabled
+	}
+	token := newJobID()
+	ok, err := rdb.SetNX(ctx, blobGCLockKey, token, blobGCLockLease).Result()
+	if err != nil {
+		return &blobGCReport{}, err
+	}
+	if !ok {
+		return &blobGCReport{}, errBlobGCBusy
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(blobGCLockLease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				n, err := extendAdminOpsLock.Run(context.Background(), rdb, []string{blobGCLockKey}, token, blobGCLockLease.Milliseconds()).Int()
+				if err == nil && n == 0 {
+					return
+				}
+			}
+		}
+	}()
+	defer func() {
+		close(done)
+		if cooldown := localenv.GetBlobGCInterval() / 2; cooldown > 0 {
+			extendAdminOpsLock.Run(context.Background(), rdb, []string{blobGCLockKey}, token, cooldown.Milliseconds())
+		} else {
+			releaseAdminOpsLock.Run(context.Background(), rdb, []string{blobGCLockKey}, token)
+		}
+	}()
+	return collectBlobs(ctx)
+}
+
+// startBlobGC sweeps unreferenced blobs every BLOB_GC_INTERVAL on one replica.
+func startBlobGC() {
+	interval := localenv.GetBlobGCInterval()
+	if !localenv.GetContentAddressedStorage() || interval <= 0 {
+		return
+	}
+	if !redisEnabled() {
+		log.Printf("[blob] gc disabled: it needs Redis to mark candidates")
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := runBlobGC(context.Background()); err != nil && !errors.Is(err, errBlobGCBusy) {
+				log.Printf("[blob] gc error: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// branchCachePrefix keeps branch previews apart from the production
+// snapshots (tolgee:lang:*): they are Redis-only, expire after
+// BRANCH_CACHE_TTL and never reach S3, the manifest or the derived caches.
+const branchCachePrefix = "tolgee:branch:"
+
+var errUnknownBranchChannel = errors.New("unknown branch channel")
+
+// branchForChannel maps a /api/branch/:name channel to its Tolgee branch.
+func branchForChannel(channel string) (string, bool) {
+	branch, ok := localenv.GetTolgeeBranchChannels()[channel]
+	return branch, ok && branch != ""
+}
+
+func branchCacheKey(channel, lang string, nested bool) string {
+	return branchCachePrefix + channel + ":lang:" + lang + ":" + strconv.FormatBool(nested)
+}
+
+// GetBranchTranslationsFromCache serves the catalog of a Tolgee branch for
+// translators to validate before merge, exported on demand and cached
+// briefly so edits on the branch show up within BRANCH_CACHE_TTL.
+// ENCRYPTED_NAMESPACES are left out: previews are public and unencrypted.
+func GetBranchTranslationsFromCache(ctx context.Context, channel, lang string, nested bool) ([]byte, error) {
+	branch, ok := branchForChannel(channel)
+	if !ok {
+		return nil, errUnknownBranchChannel
+	}
+	key := branchCacheKey(channel, lang, nested)
+	if cached, err := redisGet(ctx, key); err == nil && len(cached) > 0 {
+		return cached, nil
+	}
+	payload, err := fetchUpstream(ctx, "branch:"+channel+":"+lang+":"+strconv.FormatBool(nested), func() ([]byte, error) {
+		files, err := exportTranslations(ctx, localenv.GetTolgeeAppKey(), lang, nested, map[string]string{"branch": branch})
+		if err != nil {
+			return nil, err
+		}
+		if payload := files[lang]; len(payload) > 0 {
+			return withoutEncryptedNamespaces(payload, nested)
+		}
+		return []byte("{}"), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := redisPut(ctx, key, payload, localenv.GetBranchCacheTTL()); err != nil {
+		log.Printf("[branch] cache put error key=%q: %v", key, err)
+	}
+	return payload, nil
+}
+
+// loadBranchVariant resolves the payload shape like loadTranslationsVariant;
+// custom delimiters are derived from the nested branch export.
+func loadBranchVariant(c *fiber.Ctx, channel, lang string, nested bool) ([]byte, error) {
+	if nested {
+		return GetBranchTranslationsFromCache(c.UserContext(), channel, lang, true)
+	}
+	delim, err := resolveDelimiter(c)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if delim == "" {
+		return GetBranchTranslationsFromCache(c.UserContext(), channel, lang, false)
+	}
+	source, err := GetBranchTranslationsFromCache(c.UserContext(), channel, lang, true)
+	if err != nil {
+		return nil, err
+	}
+	return flattenTranslations(source, delim)
+}
+
+// purgeBranchCaches drops every branch preview, so a Tolgee webhook makes
+// branch edits visible right away instead of after BRANCH_CACHE_TTL.
+func purgeBranchCaches(ctx context.Context) {
+	keys, err := redisScanKeys(ctx, branchCachePrefix+"*")
+	if err != nil || len(keys) == 0 {
+		return
+	}
+	if err := redisDel(ctx, keys...); err != nil {
+		log.Printf("[branch] purge error: %v", err)
+	}
+}
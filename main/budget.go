@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+var errStaleContinuation = errors.New("continuation token does not match the current catalog, restart without ?continue=")
+
+// applyPayloadBudget enforces the PLATFORM_MAX_PAYLOAD_BYTES of the request
+// platform: when the catalog is larger, only the highest-priority namespaces
+// that fit are returned and X-Continuation-Token carries the offset of the
+// rest, to be fetched with ?continue=<token>. Namespaces are the top-level
+// sections (nested) or the first key segment (flat).
+func applyPayloadBudget(c *fiber.Ctx, nested bool, payload []byte) ([]byte, error) {
+	token := c.Query("continue")
+	budget := int64(0)
+	if platform := requestPlatform(c); platform != "" {
+		budget = localenv.GetPlatformMaxPayloadBytes()[platform]
+	}
+	if token == "" && (budget <= 0 || int64(len(payload)) <= budget) {
+		return payload, nil
+	}
+
+	var tree map[string]any
+	if err := decodeJSON(payload, &tree); err != nil {
+		return nil, err
+	}
+	delim := "."
+	if !nested {
+		if d, _ := resolveDelimiter(c); d != "" {
+			delim = d
+		}
+	}
+	groups := map[string]map[string]any{}
+	for k, v := range tree {
+		ns := k
+		if !nested {
+			ns, _, _ = strings.Cut(k, delim)
+		}
+		if groups[ns] == nil {
+			groups[ns] = map[string]any{}
+		}
+		groups[ns][k] = v
+	}
+	order := orderNamespaces(groups)
+
+	version := sha256Hex(payload)[:12]
+	start := 0
+	if token != "" {
+		offset, err := parseContinuationToken(token, version)
+		if err != nil {
+			return nil, fiber.NewError(http.StatusGone, err.Error())
+		}
+		start = offset
+	}
+
+	out := map[string]any{}
+	size := int64(2)
+	next := len(order)
+	for i := start; i < len(order); i++ {
+		chunk, err := marshalJSON(groups[order[i]])
+		if err != nil {
+			return nil, err
+		}
+		if budget > 0 && i > start && size+int64(len(chunk)) > budget {
+			next = i
+			break
+		}
+		for k, v := range groups[order[i]] {
+			out[k] = v
+		}
+		size += int64(len(chunk))
+	}
+	c.Locals(localsOverridden, true)
+	if next < len(order) {
+		c.Set("X-Continuation-Token", base64.RawURLEncoding.EncodeToString([]byte(version+"."+strconv.Itoa(next))))
+	}
+	return marshalJSON(out)
+}
+
+// orderNamespaces lists NAMESPACE_PRIORITY first, then the rest alphabetically.
+func orderNamespaces(groups map[string]map[string]any) []string {
+	order := make([]string, 0, len(groups))
+	seen := map[string]bool{}
+	for _, ns := range localenv.GetNamespacePriority() {
+		if _, ok := groups[ns]; ok && !seen[ns] {
+			seen[ns] = true
+			order = append(order, ns)
+		}
+	}
+	var rest []string
+	for ns := range groups {
+		if !seen[ns] {
+			rest = append(rest, ns)
+		}
+	}
+	sort.Strings(rest)
+	return append(order, rest...)
+}
+
+func parseContinuationToken(token, version string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, errStaleContinuation
+	}
+	v, offset, ok := strings.Cut(string(raw), ".")
+	if !ok || v != version {
+		return 0, errStaleContinuation
+	}
+	n, err := strconv.Atoi(offset)
+	if err != nil || n < 0 {
+		return 0, errStaleContinuation
+	}
+	return n, nil
+}
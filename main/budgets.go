@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+const lengthBudgetEllipsis = "…"
+
+var errInvalidTruncate = errors.New("truncate must be \"true\", \"false\" or empty")
+
+// lengthBudget returns the maximum length configured for key in
+// LINT_MAX_LENGTH; the longest matching prefix wins. 0 means no budget.
+func lengthBudget(key string) (int, string) {
+	limit, prefix := 0, ""
+	for p, n := range localenv.GetLintMaxLength() {
+		if n > 0 && strings.HasPrefix(key, p) && len(p) >= len(prefix) {
+			limit, prefix = n, p
+		}
+	}
+	return limit, prefix
+}
+
+// resolveTruncate reports whether over-budget values must be cut:
+// ?truncate= wins over LENGTH_BUDGET_TRUNCATE.
+func resolveTruncate(c *fiber.Ctx) (bool, error) {
+	switch c.Query("truncate") {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "":
+		return localenv.GetLengthBudgetTruncate(), nil
+	}
+	return false, errInvalidTruncate
+}
+
+// applyLengthBudgets cuts the values longer than their key prefix budget,
+// ending them with an ellipsis, so layouts with fixed widths (buttons) keep
+// working until the translation is shortened. ICU messages and values with
+// markup are left alone since cutting them would break their syntax. Cut
+// variants are cached under the served language; unknown ones are cut inline.
+func applyLengthBudgets(c *fiber.Ctx, nested bool, payload []byte) ([]byte, error) {
+	truncate, err := resolveTruncate(c)
+	if err != nil {
+		return nil, fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	if !truncate || len(localenv.GetLintMaxLength()) == 0 {
+		return payload, nil
+	}
+	c.Locals(localsOverridden, true)
+
+	served, known := servedLanguageOf(c)
+	sum := sha256.Sum256(payload)
+	key := "tolgee:truncated:" + served + ":" + hex.EncodeToString(sum[:8])
+	if known {
+		if cached, err := redisGet(context.Background(), key); err == nil && len(cached) > 0 {
+			return cached, nil
+		}
+	}
+
+	delim := ""
+	if !nested {
+		delim, _ = resolveDelimiter(c)
+	}
+	var tree map[string]any
+	if err := decodeJSON(payload, &tree); err != nil {
+		return nil, err
+	}
+	postProcessTree(tree, "", nested, delim, []stringProcessor{truncateToBudget})
+	out, err := marshalJSON(tree)
+	if err != nil {
+		return nil, err
+	}
+	if known {
+		_ = redisPut(context.Background(), key, out, derivedVariantTTL())
+	}
+	return out, nil
+}
+
+func truncateToBudget(key, value string) string {
+	limit, _ := lengthBudget(key)
+	if limit == 0 || utf8.RuneCountInString(value) <= limit || strings.ContainsAny(value, "{<") {
+		return value
+	}
+	runes := []rune(value)
+	cut := strings.TrimRightFunc(string(runes[:limit-1]), unicode.IsSpace)
+	return cut + lengthBudgetEllipsis
+}
@@ -3,63 +3,11 @@ package main
 import (
 	"context"
 	"errors"
-	"log"
+	"fmt"
 	localenv "mensalocalizations/tools/env"
+	"strings"
 )
 
-func RebuildTheCache() {
-	rootCtx := context.Background()
-	appKey := localenv.GetTolgeeAppKey()
-
-	_, bytesOfLanguages, err := GetLanguages(rootCtx, appKey)
-	if err != nil || len(bytesOfLanguages) == 0 {
-		return
-	}
-
-	_ = redisPut(rootCtx, "tolgee:languages", bytesOfLanguages, 0)
-
-	var s3c *s3Client
-	if localenv.GetS3Enabled() {
-		c, err := newS3ClientFromEnv(rootCtx)
-		if err != nil {
-			log.Printf("[cache][s3] disabled (config error): %v", err)
-		} else {
-			log.Printf("[cache][s3] enabled bucket=%q", c.bucket)
-			s3c = c
-			_ = s3c.putObject(rootCtx, "tolgee:languages", bytesOfLanguages, "application/json", map[string]string{})
-		}
-	}
-
-	langAndTrans, err := GetAllLanguagesAndTranslations(rootCtx, appKey, false)
-	if err != nil {
-		return
-	}
-	for name, translations := range langAndTrans {
-		if len(translations) == 0 {
-			continue
-		}
-		_ = redisPut(rootCtx, "tolgee:lang:"+name+":false", translations, 0)
-		if s3c != nil {
-			_ = s3c.putObject(rootCtx, "tolgee:lang:"+name+":false", translations, "application/json", map[string]string{})
-		}
-	}
-
-	langAndTransNested, err := GetAllLanguagesAndTranslations(rootCtx, appKey, true)
-	if err != nil {
-		return
-	}
-	for name, translations := range langAndTransNested {
-		if len(translations) == 0 {
-			continue
-		}
-		_ = redisPut(rootCtx, "tolgee:lang:"+name+":true", translations, 0)
-		if s3c != nil {
-			_ = s3c.putObject(rootCtx, "tolgee:lang:"+name+":true", translations, "application/json", map[string]string{})
-		}
-	}
-
-}
-
 func GetLanguagesFromCache(ctx context.Context) ([]byte, error) {
 	cached, err := redisGet(ctx, "tolgee:languages")
 	if err == nil && len(cached) > 0 {
@@ -67,22 +15,22 @@ func GetLanguagesFromCache(ctx context.Context) ([]byte, error) {
 	}
 
 	var s3c *s3Client
-	if localenv.GetS3Enabled() {
-		c, err := newS3ClientFromEnv(ctx)
-		if err != nil {
-			log.Printf("[cache][s3] disabled (config error): %v", err)
-		} else {
-			log.Printf("[cache][s3] enabled bucket=%q", c.bucket)
-			s3c = c
-			cached, err = s3c.getObject(ctx, "tolgee:languages")
-			if err == nil && len(cached) > 0 {
-				_ = redisPut(ctx, "tolgee:languages", cached, 0)
-				return cached, nil
-			}
+	if s3c = s3ClientIfEnabled(ctx); s3c != nil {
+		cached, err = s3c.getObject(ctx, "tolgee:languages")
+		if err == nil && len(cached) > 0 {
+			_ = redisPut(ctx, "tolgee:languages", cached, 0)
+			return cached, nil
 		}
 	}
 
-	_, i, err := GetLanguages(ctx, localenv.GetTolgeeAppKey())
+	if localenv.GetPromotedOnly() {
+		return nil, errors.New("languages not promoted yet")
+	}
+
+	i, err := fetchUpstream(ctx, "languages", func() ([]byte, error) {
+		_, b, err := GetLanguages(ctx, localenv.GetTolgeeAppKey())
+		return b, err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -95,35 +43,57 @@ func GetLanguagesFromCache(ctx context.Context) ([]byte, error) {
 	return i, nil
 }
 
+// GetTranslationsFromCache resolves language aliases, then serves the first
+// cached language among lang and its fallback chain (English by default).
 func GetTranslationsFromCache(ctx context.Context, lang string, nested bool) ([]byte, error) {
-	nestedStr := "false"
-	if nested {
-		nestedStr = "true"
-	}
-
-	cached, err := redisGet(ctx, "tolgee:lang:"+lang+":"+nestedStr)
-	if err == nil && len(cached) > 0 {
-		return cached, nil
-	}
-
+	lang = resolveLanguageAlias(lang)
 	var s3c *s3Client
-	if localenv.GetS3Enabled() {
-		c, err := newS3ClientFromEnv(ctx)
-		if err != nil {
-			log.Printf("[cache][s3] disabled (config error): %v", err)
-		} else {
-			log.Printf("[cache][s3] enabled bucket=%q", c.bucket)
-			s3c = c
-			cached, err = s3c.getObject(ctx, "tolgee:lang:"+lang+":"+nestedStr)
+	s3Checked := false
+	candidates := append([]string{lang}, fallbackChain(lang)...)
+	tried := map[string]bool{}
+	var order []string
+	for _, candidate := range candidates {
+		if tried[candidate] {
+			continue
+		}
+		tried[candidate] = true
+		if betaHidden(ctx, candidate) {
+			continue
+		}
+		order = append(order, candidate)
+		key := translationsCacheKey(candidate, nested)
+		if cached, ok := memGet(key); ok {
+			recordServedLanguage(ctx, candidate, order, servedFromMemory)
+			return cached, nil
+		}
+		if !s3Checked {
+			s3Checked = true
+			s3c = s3ClientIfEnabled(ctx)
+		}
+		// without Redis the store reads S3 itself
+		readsThrough := !storeHoldsRefreshOutput(s3c)
+		cached, err := redisGet(ctx, key)
+		if err == nil && len(cached) > 0 {
+			memPut(key, candidate, cached)
+			maybeRevalidate(candidate, nested)
+			source := servedFromRedis
+			if readsThrough {
+				source = servedFromS3
+			}
+			recordServedLanguage(ctx, candidate, order, source)
+			return cached, nil
+		}
+
+		if s3c != nil && !readsThrough {
+			cached, err = s3c.getObject(ctx, key)
 			if err == nil && len(cached) > 0 {
-				_ = redisPut(ctx, "tolgee:lang:"+lang, cached, 0)
+				_ = redisPut(ctx, key, cached, snapshotHardTTL(key))
+				memPut(key, candidate, cached)
+				maybeRevalidate(candidate, nested)
+				recordServedLanguage(ctx, candidate, order, servedFromS3)
 				return cached, nil
 			}
 		}
 	}
-
-	if lang == "en" {
-		return nil, errors.New("english translations not found in cache")
-	}
-	return GetTranslationsFromCache(ctx, "en", nested)
+	return nil, fmt.Errorf("translations not found in cache for %s (tried %s)", lang, strings.Join(candidates, ", "))
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// cachePolicyMaxAge is how long clients may keep /api/cache-policy itself;
+// runtime TTL overrides show up within a minute.
+const cachePolicyMaxAge = time.Minute
+
+// cachePolicy describes, in seconds, how long each tier keeps a catalog and
+// what drives refreshes, so clients can size their own caches from it.
+// A duration of 0 means "no expiry" for TTLs and "disabled" for intervals.
+type cachePolicy struct {
+	Tiers     []cachePolicyTier    `json:"tiers"`
+	Derived   cachePolicyDerived   `json:"derived"`
+	Refresh   cachePolicyRefresh   `json:"refresh"`
+	Freshness cachePolicyFreshness `json:"freshness"`
+	Client    cachePolicyClient    `json:"client"`
+}
+
+type cachePolicyTier struct {
+	Name       string `json:"name"`
+	Enabled    bool   `json:"enabled"`
+	TTLSeconds int64  `json:"ttl_seconds"`
+	MaxBytes   int64  `json:"max_bytes,omitempty"`
+}
+
+type cachePolicyDerived struct {
+	VariantTTLSeconds     int64 `json:"variant_ttl_seconds"`
+	ArtifactTTLSeconds    int64 `json:"artifact_ttl_seconds"`
+	TolgeeProxyTTLSeconds int64 `json:"tolgee_proxy_ttl_seconds"`
+}
+
+type cachePolicyRefresh struct {
+	// Triggers lists what starts a refresh; there is no fixed schedule, a
+	// Tolgee webhook refreshes within seconds of a change.
+	Triggers               []string `json:"triggers"`
+	DebounceSeconds        int64    `json:"debounce_seconds"`
+	SoftTTLSeconds         int64    `json:"soft_ttl_seconds"`
+	SoftTTLCheckSeconds    int64    `json:"soft_ttl_check_seconds"`
+	RetryMaxAttempts       int64    `json:"retry_max_attempts"`
+	RetryBackoffSeconds    int64    `json:"retry_backoff_seconds"`
+	RetryBackoffMaxSeconds int64    `json:"retry_backoff_max_seconds"`
+	RepairIntervalSeconds  int64    `json:"repair_interval_seconds"`
+	PatchesPollSeconds     int64    `json:"patches_poll_seconds"`
+	ReadOnly               bool     `json:"read_only"`
+	PromotedOnly           bool     `json:"promoted_only"`
+}
+
+type cachePolicyFreshness struct {
+	SLOMaxAgeSeconds        int64   `json:"slo_max_age_seconds"`
+	SLOTarget               float64 `json:"slo_target"`
+	SLOWindowSeconds        int64   `json:"slo_window_seconds"`
+	StaleBannerAfterSeconds int64   `json:"stale_banner_after_seconds"`
+	StaleBannerKey          string  `json:"stale_banner_key,omitempty"`
+}
+
+// cachePolicyClient is the guidance derived from the values above.
+type cachePolicyClient struct {
+	RecommendedMaxAgeSeconds int64  `json:"recommended_max_age_seconds"`
+	Revalidate               string `json:"revalidate"`
+	Manifest                 string `json:"manifest"`
+	Immutable                string `json:"immutable"`
+}
+
+func durationSeconds(d time.Duration) int64 { return int64(d / time.Second) }
+
+// currentCachePolicy reads the effective settings, runtime TTL overrides
+// included.
+func currentCachePolicy(ctx context.Context) cachePolicy {
+	softTTL := localenv.GetSnapshotSoftTTL()
+	triggers := []string{"webhook", "manual"}
+	if localenv.GetRefreshRetryMaxAttempts() > 0 {
+		triggers = append(triggers, "retry")
+	}
+	if softTTL > 0 {
+		triggers = append(triggers, "revalidate")
+	}
+	if localenv.GetRepairInterval() > 0 {
+		triggers = append(triggers, "repair")
+	}
+	debounce := localenv.GetRefreshDebounce()
+
+	return cachePolicy{
+		Tiers: []cachePolicyTier{
+			{Name: "memory", Enabled: memTierEnabled(), TTLSeconds: durationSeconds(memoryTierTTL()), MaxBytes: localenv.GetMemoryCacheMaxBytes()},
+			{Name: "redis", Enabled: true, TTLSeconds: durationSeconds(localenv.GetSnapshotHardTTL())},
+			{Name: "s3", Enabled: localenv.GetS3Enabled()},
+		},
+		Derived: cachePolicyDerived{
+			VariantTTLSeconds:     durationSeconds(derivedVariantTTL()),
+			ArtifactTTLSeconds:    durationSeconds(derivedArtifactTTL()),
+			TolgeeProxyTTLSeconds: durationSeconds(tolgeeProxyTTL()),
+		},
+		Refresh: cachePolicyRefresh{
+			Triggers:               triggers,
+			DebounceSeconds:        durationSeconds(debounce),
+			SoftTTLSeconds:         durationSeconds(softTTL),
+			SoftTTLCheckSeconds:    durationSeconds(revalidateCheckEvery),
+			RetryMaxAttempts:       localenv.GetRefreshRetryMaxAttempts(),
+			RetryBackoffSeconds:    durationSeconds(localenv.GetRefreshRetryBackoff()),
+			RetryBackoffMaxSeconds: durationSeconds(localenv.GetRefreshRetryBackoffMax()),
+			RepairIntervalSeconds:  durationSeconds(localenv.GetRepairInterval()),
+			PatchesPollSeconds:     durationSeconds(localenv.GetPatchesPollInterval()),
+			ReadOnly:               isReadOnly(ctx),
+			PromotedOnly:           localenv.GetPromotedOnly(),
+		},
+		Freshness: cachePolicyFreshness{
+			SLOMaxAgeSeconds:        durationSeconds(localenv.GetFreshnessSLOMaxAge()),
+			SLOTarget:               localenv.GetFreshnessSLOTarget(),
+			SLOWindowSeconds:        durationSeconds(localenv.GetFreshnessSLOWindow()),
+			StaleBannerAfterSeconds: durationSeconds(localenv.GetStaleBannerAfter()),
+			StaleBannerKey:          localenv.GetStaleBannerKey(),
+		},
+		Client: cachePolicyClient{
+			RecommendedMaxAgeSeconds: durationSeconds(recommendedClientMaxAge(softTTL)),
+			Revalidate:               "POST /api/freshness",
+			Manifest:                 "GET /api/manifest",
+			Immutable:                "GET /api/v/:sha/:lang",
+		},
+	}
+}
+
+// recommendedClientMaxAge keeps a client within the freshness SLO: it is the
+// SLO max age, shortened to the soft TTL when snapshots are revalidated
+// sooner than that; 5 minutes when neither is configured.
+func recommendedClientMaxAge(softTTL time.Duration) time.Duration {
+	maxAge := localenv.GetFreshnessSLOMaxAge()
+	if softTTL > 0 && (maxAge <= 0 || softTTL < maxAge) {
+		maxAge = softTTL
+	}
+	if maxAge <= 0 {
+		maxAge = 5 * time.Minute
+	}
+	return maxAge
+}
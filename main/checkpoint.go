@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/go-redis/redis/v8"
+)
+
+// snapshotCheckpoint is the state of some languages before an admin op, to
+// undo it: both snapshots of each language (nil when it had none) and, for
+// the whole project, the language list.
+type snapshotCheckpoint struct {
+	all       bool
+	langs     []string
+	snapshots map[string][]byte // by translationsCacheKey
+	languages []byte
+}
+
+// checkpointSnapshots captures the snapshots of langs (every language in the
+// manifest when empty) and returns the undo restoring them.
+func checkpointSnapshots(ctx context.Context, langs []string) (func(ctx context.Context) error, error) {
+	cp := &snapshotCheckpoint{all: len(langs) == 0, langs: langs, snapshots: map[string][]byte{}}
+	if cp.all {
+		for lang := range loadManifest(ctx).Languages {
+			cp.langs = append(cp.langs, lang)
+		}
+		sort.Strings(cp.langs)
+	}
+	s3c := s3ClientIfEnabled(ctx)
+	for _, lang := range cp.langs {
+		for _, nested := range []bool{false, true} {
+			key := translationsCacheKey(lang, nested)
+			payload, err := readCheckpointKey(ctx, s3c, key)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			cp.snapshots[key] = payload
+		}
+	}
+	if cp.all {
+		languages, err := readCheckpointKey(ctx, s3c, "tolgee:languages")
+		if err != nil {
+			return nil, fmt.Errorf("tolgee:languages: %w", err)
+		}
+		cp.languages = languages
+	}
+	return cp.restore, nil
+}
+
+// readCheckpointKey reads the current value of key from the store, then S3;
+// a key found in neither is nil.
+func readCheckpointKey(ctx context.Context, s3c *s3Client, key string) ([]byte, error) {
+	b, err := redisGet(ctx, key)
+	if err == nil && len(b) > 0 {
+		return b, nil
+	}
+	if s3c == nil {
+		if err == nil || errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	b, err = s3c.getObject(ctx, key)
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, nil
+	}
+	return b, err
+}
+
+// restore writes the captured snapshots back like a refresh does (variants,
+// derived artifacts, manifest) and purges the languages that had none, then
+// checks every restored key reads back as captured.
+func (cp *snapshotCheckpoint) restore(ctx context.Context) error {
+	s3c := s3ClientIfEnabled(ctx)
+	var manifest manifestBatch
+	present := map[string]bool{}
+	for _, lang := range cp.langs {
+		for _, nested := range []bool{false, true} {
+			key := translationsCacheKey(lang, nested)
+			payload := cp.snapshots[key]
+			if payload == nil {
+				continue
+			}
+			present[lang] = true
+			invalidateDerivedArtifacts(ctx, s3c, lang, nested, payload)
+			storeCacheEntry(ctx, s3c, key, payload, "application/json")
+			storeCompressedVariants(ctx, s3c, key, payload, "application/json")
+			storeFormatVariants(ctx, s3c, key, lang, nested, payload)
+			manifest.add(lang, nested, payload)
+		}
+	}
+	manifest.flush(ctx, s3c)
+
+	var errs []error
+	for _, lang := range cp.langs {
+		if !present[lang] {
+			purgeLanguage(ctx, s3c, lang)
+			continue
+		}
+		for _, nested := range []bool{false, true} {
+			key := translationsCacheKey(lang, nested)
+			want := cp.snapshots[key]
+			if want == nil {
+				// the op added the other mode: drop it
+				errs = append(errs, dropCheckpointKey(ctx, s3c, key))
+				continue
+			}
+			if got, err := redisGet(ctx, key); err != nil || !bytes.Equal(got, want) {
+				errs = append(errs, fmt.Errorf("%s not restored", key))
+			}
+		}
+	}
+	if cp.all {
+		known := map[string]bool{}
+		for _, lang := range cp.langs {
+			known[lang] = true
+		}
+		for lang := range loadManifest(ctx).Languages {
+			if !known[lang] {
+				purgeLanguage(ctx, s3c, lang)
+			}
+		}
+		if cp.languages != nil {
+			errs = append(errs, redisPut(ctx, "tolgee:languages", cp.languages, 0))
+			if s3c != nil {
+				errs = append(errs, s3c.putObject(ctx, "tolgee:languages", cp.languages, "application/json", map[string]string{}))
+			}
+		}
+	}
+	err := errors.Join(errs...)
+	log.Printf("[ops] checkpoint restored langs=%d err=%v", len(cp.langs), err)
+	return err
+}
+
+func dropCheckpointKey(ctx context.Context, s3c *s3Client, key string) error {
+	memForget(key)
+	err := redisDel(ctx, key)
+	if s3c != nil {
+		err = errors.Join(err, s3c.deleteObject(ctx, key))
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+const (
+	maxCollateStrings     = 1000
+	maxCollateStringBytes = 1024
+)
+
+var (
+	errCollateEmpty    = errors.New("at least one s query parameter is required")
+	errCollateTooMany  = errors.New("at most " + strconv.Itoa(maxCollateStrings) + " strings can be collated")
+	errCollateTooLong  = errors.New("strings must be at most " + strconv.Itoa(maxCollateStringBytes) + " bytes")
+	errCollateLanguage = errors.New("invalid language tag")
+)
+
+// collateMatcher picks the closest tag with CLDR collation tailoring.
+var collateMatcher = language.NewMatcher(collate.Supported())
+
+// collateOptions are the optional ?numeric, ?ignore_case and
+// ?ignore_diacritics switches.
+type collateOptions struct {
+	Numeric          bool
+	IgnoreCase       bool
+	IgnoreDiacritics bool
+}
+
+// collateStrings sorts values with the CLDR collation rules of lang (the
+// same data ICU uses), returning the sorted copy and the tag whose
+// tailoring was applied ("und" = root collation).
+func collateStrings(lang string, values []string, opts collateOptions) ([]string, string, error) {
+	if len(values) == 0 {
+		return nil, "", errCollateEmpty
+	}
+	if len(values) > maxCollateStrings {
+		return nil, "", errCollateTooMany
+	}
+	for _, v := range values {
+		if len(v) > maxCollateStringBytes {
+			return nil, "", errCollateTooLong
+		}
+	}
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return nil, "", errCollateLanguage
+	}
+	_, index, confidence := collateMatcher.Match(tag)
+	matched := language.Und
+	if confidence != language.No {
+		matched = collate.Supported()[index]
+	}
+
+	var options []collate.Option
+	if opts.Numeric {
+		options = append(options, collate.Numeric)
+	}
+	if opts.IgnoreCase {
+		options = append(options, collate.IgnoreCase)
+	}
+	if opts.IgnoreDiacritics {
+		options = append(options, collate.IgnoreDiacritics)
+	}
+	sorted := append([]string(nil), values...)
+	collate.New(matched, options...).SortStrings(sorted)
+	return sorted, matched.String(), nil
+}
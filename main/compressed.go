@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gofiber/fiber/v2"
+)
+
+// compressedVariant is a pre-compressed copy of a snapshot stored in S3 as
+// "<key><suffix>" with Content-Encoding set, for S3 fallback reads and for
+// CDNs pulling compressed bodies straight from the bucket.
+type compressedVariant struct {
+	encoding string
+	suffix   string
+	compress func([]byte) ([]byte, error)
+}
+
+// compressedVariants is in preference order for Accept-Encoding.
+var compressedVariants = []compressedVariant{
+	{encoding: "br", suffix: ":br", compress: brotliBytes},
+	{encoding: "gzip", suffix: ":gz", compress: gzipBytes},
+}
+
+// compressedSourceMetadata carries the sha256 of the uncompressed snapshot,
+// so a variant left behind by an older refresh is never served.
+const compressedSourceMetadata = "source-sha256"
+
+func gzipBytes(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func brotliBytes(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	bw := brotli.NewWriterLevel(&buf, brotli.BestCompression)
+	if _, err := bw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := bw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// isCompressedVariantKey reports whether an S3 key is a compressed copy
+// rather than a payload of its own.
+func isCompressedVariantKey(key string) bool {
+	for _, v := range compressedVariants {
+		if strings.HasSuffix(key, v.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// storeCompressedVariants writes the gzip and brotli copies of a snapshot to
+// S3 next to it; Redis keeps only the raw payload.
+func storeCompressedVariants(ctx context.Context, s3c *s3Client, key string, payload []byte, contentType string) {
+	if s3c == nil || len(payload) == 0 {
+		return
+	}
+	meta := map[string]string{compressedSourceMetadata: sha256Hex(payload)}
+	for _, v := range compressedVariants {
+		start := time.Now()
+		body, err := v.compress(payload)
+		if err != nil {
+			log.Printf("[s3] %s compress error key=%q: %v", v.encoding, key, err)
+			continue
+		}
+		observeStage(ctx, stageStore, start)
+		_ = s3c.putEncodedObject(ctx, key+v.suffix, body, contentType, v.encoding, meta)
+	}
+}
+
+// loadPrecompressedSnapshot returns the stored compressed copy of payload and
+// its encoding when the request is for the untransformed JSON snapshot, the
+// snapshot came from the S3 fallback (Redis missed), the client accepts the
+// encoding and the copy matches payload. Otherwise it returns nil.
+func loadPrecompressedSnapshot(c *fiber.Ctx, nested bool, payload []byte) ([]byte, string) {
+	rec, ok := c.Locals(localsServedLanguage).(*servedLanguage)
+	if !ok || rec.source != servedFromS3 || !isPlainVariantRequest(c, nested) {
+		return nil, ""
+	}
+	c.Vary("Accept-Encoding")
+	if c.Get("Accept-Encoding") == "" {
+		return nil, ""
+	}
+	ctx := c.UserContext()
+	s3c := s3ClientIfEnabled(ctx)
+	if s3c == nil {
+		return nil, ""
+	}
+	sha := sha256Hex(payload)
+	for _, v := range compressedVariants {
+		if c.AcceptsEncodings(v.encoding) != v.encoding {
+			continue
+		}
+		body, meta, err := s3c.getObjectWithMetadata(ctx, translationsCacheKey(rec.lang, nested)+v.suffix)
+		if err != nil || meta[compressedSourceMetadata] != sha {
+			continue
+		}
+		return body, v.encoding
+	}
+	return nil, ""
+}
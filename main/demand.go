@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	demandBucket    = 24 * time.Hour
+	demandRetention = 90 * 24 * time.Hour
+	demandMaxTags   = 5
+	demandTop       = 50
+)
+
+var errInvalidDemandDays = errors.New("days must be an integer between 1 and 90")
+
+func demandBucketKey(t time.Time) string {
+	return "tolgee:demand:" + t.UTC().Truncate(demandBucket).Format("20060102")
+}
+
+// demandTag normalizes an Accept-Language range to "ll" or "ll-RR". Anything
+// else (wildcards, private use, garbage) collapses to "other" so the hash
+// stays bounded and never carries free-form client input.
+func demandTag(v string) string {
+	v, _, _ = strings.Cut(strings.TrimSpace(v), ";")
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) > 2 || !isAlphaLen(parts[0], 2, 3) {
+		return "other"
+	}
+	tag := strings.ToLower(parts[0])
+	if len(parts) == 2 {
+		if !isAlphaLen(parts[1], 2, 4) {
+			return "other"
+		}
+		tag += "-" + strings.ToUpper(parts[1])
+	}
+	return tag
+}
+
+func isAlphaLen(s string, min, max int) bool {
+	if len(s) < min || len(s) > max {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// recordLanguageDemand counts, in the daily bucket, the preferred language of
+// an Accept-Language header (first listed range, as clients sort by q) and,
+// when negotiation found no cached language, every listed range as unmatched.
+// Only normalized tags are stored, nothing that identifies the client.
+func recordLanguageDemand(header string, matched bool) {
+	if !redisEnabled() {
+		return
+	}
+	var tags []string
+	for _, r := range strings.Split(header, ",") {
+		if strings.TrimSpace(r) == "" {
+			continue
+		}
+		if tags = append(tags, demandTag(r)); len(tags) == demandMaxTags {
+			break
+		}
+	}
+	if len(tags) == 0 {
+		return
+	}
+	key := demandBucketKey(time.Now())
+	go func() {
+		ctx := context.Background()
+		pipe := rdb.Pipeline()
+		pipe.HIncrBy(ctx, key, "total", 1)
+		pipe.HIncrBy(ctx, key, "want|"+tags[0], 1)
+		if !matched {
+			pipe.HIncrBy(ctx, key, "unmatched", 1)
+			seen := map[string]bool{}
+			for _, tag := range tags {
+				if !seen[tag] {
+					seen[tag] = true
+					pipe.HIncrBy(ctx, key, "miss|"+tag, 1)
+				}
+			}
+		}
+		pipe.Expire(ctx, key, demandRetention)
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("[demand] record error: %v", err)
+		}
+	}()
+}
+
+// demandCount is one language row of the demand report.
+type demandCount struct {
+	Lang     string `json:"lang"`
+	Requests int64  `json:"requests"`
+	Cached   bool   `json:"cached"`
+}
+
+// demandDay is the per-day total of negotiated requests.
+type demandDay struct {
+	Date      string `json:"date"`
+	Requests  int64  `json:"requests"`
+	Unmatched int64  `json:"unmatched"`
+}
+
+type demandReport struct {
+	From      time.Time     `json:"from"`
+	To        time.Time     `json:"to"`
+	Requests  int64         `json:"requests"`
+	Unmatched int64         `json:"unmatched"`
+	Days      []demandDay   `json:"days"`
+	Preferred []demandCount `json:"preferred"`
+	Missing   []demandCount `json:"missing"`
+}
+
+// parseDemandDays reads ?days= (default 7).
+func parseDemandDays(c *fiber.Ctx) (int, error) {
+	days, err := strconv.Atoi(c.Query("days", "7"))
+	if err != nil || days < 1 || days > int(demandRetention/demandBucket) {
+		return 0, errInvalidDemandDays
+	}
+	return days, nil
+}
+
+// loadLanguageDemand sums the last days daily buckets: the most preferred
+// languages and the most requested ranges no cached language could serve,
+// each capped to the top 50.
+func loadLanguageDemand(ctx context.Context, days int) (*demandReport, error) {
+	to := time.Now().UTC().Truncate(demandBucket).Add(demandBucket)
+	from := to.Add(-time.Duration(days) * demandBucket)
+	pipe := rdb.Pipeline()
+	var cmds []*redis.StringStringMapCmd
+	for t := from; t.Before(to); t = t.Add(demandBucket) {
+		cmds = append(cmds, pipe.HGetAll(ctx, demandBucketKey(t)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	cached := loadManifest(ctx).Languages
+	report := &demandReport{From: from, To: to, Days: []demandDay{}}
+	preferred, missing := map[string]int64{}, map[string]int64{}
+	for i, cmd := range cmds {
+		day := demandDay{Date: from.Add(time.Duration(i) * demandBucket).Format("2006-01-02")}
+		for field, raw := range cmd.Val() {
+			n, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				continue
+			}
+			kind, tag, _ := strings.Cut(field, "|")
+			switch kind {
+			case "total":
+				day.Requests += n
+			case "unmatched":
+				day.Unmatched += n
+			case "want":
+				preferred[tag] += n
+			case "miss":
+				missing[tag] += n
+			}
+		}
+		report.Requests += day.Requests
+		report.Unmatched += day.Unmatched
+		report.Days = append(report.Days, day)
+	}
+	report.Preferred = topDemand(preferred, cached)
+	report.Missing = topDemand(missing, cached)
+	return report, nil
+}
+
+func topDemand(counts map[string]int64, cached map[string]manifestEntry) []demandCount {
+	rows := make([]demandCount, 0, len(counts))
+	for tag, n := range counts {
+		_, ok := cached[tag]
+		rows = append(rows, demandCount{Lang: tag, Requests: n, Cached: ok})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Requests != rows[j].Requests {
+			return rows[i].Requests > rows[j].Requests
+		}
+		return rows[i].Lang < rows[j].Lang
+	})
+	if len(rows) > demandTop {
+		rows = rows[:demandTop]
+	}
+	return rows
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+const (
+	deprecatedKeysCacheKey = "tolgee:deprecated-keys"
+	deprecatedHitsKey      = "tolgee:deprecated-keys:hits"
+)
+
+// deprecatedKey marks a Tolgee key as on its way out: it is still served,
+// but its lookups are counted and flagged to clients.
+type deprecatedKey struct {
+	Key          string    `json:"key"`
+	Reason       string    `json:"reason,omitempty"`
+	Replacement  string    `json:"replacement,omitempty"`
+	DeprecatedAt time.Time `json:"deprecated_at"`
+	Requests     int64     `json:"requests"`
+}
+
+var (
+	errInvalidDeprecation = errors.New("deprecation needs a key")
+	errDeprecationMissing = errors.New("key is not deprecated")
+
+	deprecatedKeysMu sync.Mutex
+)
+
+func loadDeprecatedKeys(ctx context.Context) []deprecatedKey {
+	b, err := redisGet(ctx, deprecatedKeysCacheKey)
+	if err != nil || len(b) == 0 {
+		return []deprecatedKey{}
+	}
+	var list []deprecatedKey
+	if err := json.Unmarshal(b, &list); err != nil {
+		log.Printf("[deprecated] unmarshal error: %v", err)
+		return []deprecatedKey{}
+	}
+	return list
+}
+
+// listDeprecatedKeys returns every deprecated key with its request count.
+func listDeprecatedKeys(ctx context.Context) []deprecatedKey {
+	list := loadDeprecatedKeys(ctx)
+	hits, _ := rdb.HGetAll(ctx, deprecatedHitsKey).Result()
+	for i := range list {
+		if n, ok := hits[list[i].Key]; ok {
+			_ = json.Unmarshal([]byte(n), &list[i].Requests)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list
+}
+
+func putDeprecatedKey(ctx context.Context, d deprecatedKey) error {
+	if d.Key == "" {
+		return errInvalidDeprecation
+	}
+	d.DeprecatedAt = time.Now().UTC()
+	d.Requests = 0
+	mutateDeprecatedKeys(ctx, d.Key, &d)
+	log.Printf("[deprecated] put key=%q", d.Key)
+	return nil
+}
+
+func deleteDeprecatedKey(ctx context.Context, key string) error {
+	if !mutateDeprecatedKeys(ctx, key, nil) {
+		return errDeprecationMissing
+	}
+	_ = rdb.HDel(ctx, deprecatedHitsKey, key).Err()
+	log.Printf("[deprecated] delete key=%q", key)
+	return nil
+}
+
+// mutateDeprecatedKeys replaces (or with next == nil removes) the entry for
+// key and persists the list; it reports whether an entry was replaced.
+func mutateDeprecatedKeys(ctx context.Context, key string, next *deprecatedKey) bool {
+	deprecatedKeysMu.Lock()
+	defer deprecatedKeysMu.Unlock()
+
+	found := false
+	kept := []deprecatedKey{}
+	for _, d := range loadDeprecatedKeys(ctx) {
+		if d.Key == key {
+			found = true
+			continue
+		}
+		kept = append(kept, d)
+	}
+	if next != nil {
+		kept = append(kept, *next)
+	}
+	b, err := json.Marshal(kept)
+	if err != nil {
+		log.Printf("[deprecated] marshal error: %v", err)
+		return found
+	}
+	storeCacheEntry(ctx, s3ClientIfEnabled(ctx), deprecatedKeysCacheKey, b, "application/json")
+	return found
+}
+
+// trackDeprecatedLookups counts the deprecated keys among requested and
+// returns them, for the X-Deprecated-Keys header.
+func trackDeprecatedLookups(ctx context.Context, requested []string) []string {
+	deprecated := map[string]bool{}
+	for _, d := range loadDeprecatedKeys(ctx) {
+		deprecated[d.Key] = true
+	}
+	var hit []string
+	for _, key := range requested {
+		if deprecated[key] {
+			hit = append(hit, key)
+			_ = rdb.HIncrBy(ctx, deprecatedHitsKey, key, 1).Err()
+		}
+	}
+	return hit
+}
+
+// splitKeysQuery parses ?keys=a.b,c.d, dropping empty entries.
+func splitKeysQuery(raw string) []string {
+	var keys []string
+	for _, k := range strings.Split(raw, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
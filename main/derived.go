@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"log"
+	"strconv"
+)
+
+// derivedArtifact names a payload computed from a language snapshot: a format
+// conversion, a filtered subset, or both. Filters is the canonical form of
+// the parameters that shaped it ("" for none).
+type derivedArtifact struct {
+	Lang    string
+	Nested  bool
+	Format  string
+	Filters string
+}
+
+// cacheKey lives under the snapshot key, so purging a language drops its
+// artifacts too; the input sha keeps transformed payloads apart.
+func (a derivedArtifact) cacheKey(input []byte) string {
+	return translationsCacheKey(a.Lang, a.Nested) + ":artifact:" + a.Format + ":" +
+		hex.EncodeToString([]byte(a.Filters)) + ":" + sha256Hex(input)[:12]
+}
+
+// derivedArtifactIndexKey lists the artifacts of a snapshot, for invalidation.
+func derivedArtifactIndexKey(lang string, nested bool) string {
+	return translationsCacheKey(lang, nested) + ":artifacts"
+}
+
+// loadDerivedArtifact returns the artifact built from input, reading Redis,
+// then S3, and calling build only on a miss; built artifacts are stored in
+// both and indexed under their snapshot.
+func loadDerivedArtifact(ctx context.Context, a derivedArtifact, input []byte, build func() ([]byte, error)) ([]byte, error) {
+	key := a.cacheKey(input)
+	if cached, err := redisGet(ctx, key); err == nil && len(cached) > 0 {
+		return cached, nil
+	}
+	s3c := s3ClientIfEnabled(ctx)
+	if s3c != nil {
+		if stored, err := s3c.getObject(ctx, key); err == nil && len(stored) > 0 {
+			_ = redisPut(ctx, key, stored, derivedArtifactTTL())
+			return stored, nil
+		}
+	}
+
+	out, err := build()
+	if err != nil {
+		return nil, err
+	}
+	_ = redisPut(ctx, key, out, derivedArtifactTTL())
+	if redisEnabled() {
+		if err := rdb.SAdd(ctx, derivedArtifactIndexKey(a.Lang, a.Nested), key).Err(); err != nil {
+			log.Printf("[artifact] index error key=%q: %v", key, err)
+		}
+	}
+	if s3c != nil {
+		if err := s3c.putObject(ctx, key, out, "application/octet-stream", map[string]string{"format": a.Format, "nested": strconv.FormatBool(a.Nested)}); err != nil {
+			log.Printf("[artifact] s3 put error key=%q: %v", key, err)
+		}
+	}
+	return out, nil
+}
+
+// invalidateDerivedArtifacts drops every artifact of a snapshot from Redis
+// and S3 when the snapshot about to be stored differs from the current one.
+func invalidateDerivedArtifacts(ctx context.Context, s3c *s3Client, lang string, nested bool, next []byte) {
+	if next != nil {
+		if current, err := redisGet(ctx, translationsCacheKey(lang, nested)); err == nil && bytes.Equal(current, next) {
+			return
+		}
+	}
+	index := derivedArtifactIndexKey(lang, nested)
+	var keys []string
+	var err error
+	if redisEnabled() {
+		keys, err = rdb.SMembers(ctx, index).Result()
+	} else {
+		// the memory store can list its keys: no index is kept
+		keys, err = redisScanKeys(ctx, translationsCacheKey(lang, nested)+":artifact:*")
+	}
+	if err != nil || len(keys) == 0 {
+		return
+	}
+	if err := redisDel(ctx, append(keys, index)...); err != nil {
+		log.Printf("[artifact] redis del error lang=%s nested=%t: %v", lang, nested, err)
+	}
+	if s3c != nil {
+		for _, k := range keys {
+			if err := s3c.deleteObject(ctx, k); err != nil {
+				log.Printf("[artifact] s3 delete error key=%q: %v", k, err)
+			}
+		}
+	}
+	log.Printf("[artifact] invalidated lang=%s nested=%t artifacts=%d", lang, nested, len(keys))
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+)
+
+const (
+	diagnosticsPrefix   = "diagnostics/"
+	diagnosticsMaxKeys  = 5000
+	diagnosticsScanPage = 500
+)
+
+// diagnosticKey is one Redis key of the snapshot; Bytes is 0 for non-strings.
+type diagnosticKey struct {
+	Key        string `json:"key"`
+	Bytes      int64  `json:"bytes"`
+	TTLSeconds int64  `json:"ttl_seconds"`
+}
+
+// diagnosticLanguage is the manifest view of a cached language.
+type diagnosticLanguage struct {
+	Lang       string    `json:"lang"`
+	FlatSha    string    `json:"flat_sha,omitempty"`
+	NestedSha  string    `json:"nested_sha,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	AgeSeconds int64     `json:"age_seconds"`
+}
+
+// diagnosticSnapshot is what a replica knew about its caches at a point in
+// time, kept in S3 so it outlives the pod.
+type diagnosticSnapshot struct {
+	Host           string               `json:"host"`
+	Reason         string               `json:"reason"`
+	TakenAt        time.Time            `json:"taken_at"`
+	Languages      []diagnosticLanguage `json:"languages"`
+	MemoryTier     []memTierEntry       `json:"memory_tier"`
+	RedisKeys      []diagnosticKey      `json:"redis_keys"`
+	RedisTruncated bool                 `json:"redis_truncated,omitempty"`
+	Refresh        refreshState         `json:"refresh"`
+	Warmup         warmupProgress       `json:"warmup"`
+	ReadOnly       readOnlyState        `json:"read_only"`
+	S3Key          string               `json:"s3_key,omitempty"`
+}
+
+// takeDiagnosticSnapshot collects the state summary (shas and ages, never
+// payloads) and stores it in S3 under diagnostics/<host>/<time>, outside the
+// tolgee: cache prefix that rehydrate and the storage migrations walk.
+// Without S3 the snapshot is only returned.
+func takeDiagnosticSnapshot(ctx context.Context, reason string) (*diagnosticSnapshot, error) {
+	host, _ := os.Hostname()
+	snap := &diagnosticSnapshot{
+		Host:       host,
+		Reason:     reason,
+		TakenAt:    time.Now().UTC(),
+		Languages:  []diagnosticLanguage{},
+		MemoryTier: memTierEntries(),
+		Refresh:    getRefreshState(ctx),
+		Warmup:     getWarmupProgress(),
+		ReadOnly:   loadReadOnly(ctx),
+	}
+	for lang, entry := range loadManifest(ctx).Languages {
+		snap.Languages = append(snap.Languages, diagnosticLanguage{
+			Lang:       lang,
+			FlatSha:    entry.FlatSha,
+			NestedSha:  entry.NestedSha,
+			UpdatedAt:  entry.UpdatedAt,
+			AgeSeconds: int64(snap.TakenAt.Sub(entry.UpdatedAt).Seconds()),
+		})
+	}
+	sort.Slice(snap.Languages, func(i, j int) bool { return snap.Languages[i].Lang < snap.Languages[j].Lang })
+
+	keys, truncated, err := scanDiagnosticKeys(ctx)
+	if err != nil {
+		log.Printf("[diagnostics] redis scan error: %v", err)
+	}
+	snap.RedisKeys, snap.RedisTruncated = keys, truncated
+
+	s3c := s3ClientIfEnabled(ctx)
+	if s3c == nil {
+		return snap, nil
+	}
+	snap.S3Key = diagnosticsPrefix + host + "/" + snap.TakenAt.Format("20060102T150405Z")
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return snap, err
+	}
+	if err := s3c.putObject(ctx, snap.S3Key, b, "application/json", map[string]string{"reason": reason}); err != nil {
+		return snap, err
+	}
+	log.Printf("[diagnostics] stored key=%q reason=%s languages=%d keys=%d", snap.S3Key, reason, len(snap.Languages), len(snap.RedisKeys))
+	return snap, nil
+}
+
+// scanDiagnosticKeys lists the tolgee:* keys with size and TTL, up to
+// diagnosticsMaxKeys (in key order, across every Redis shard).
+func scanDiagnosticKeys(ctx context.Context) ([]diagnosticKey, bool, error) {
+	out := []diagnosticKey{}
+	keys, err := redisScanKeys(ctx, "tolgee:*")
+	if err != nil {
+		return out, false, err
+	}
+	sort.Strings(keys)
+	truncated := len(keys) > diagnosticsMaxKeys
+	if truncated {
+		keys = keys[:diagnosticsMaxKeys]
+	}
+	for start := 0; start < len(keys); start += diagnosticsScanPage {
+		page := keys[start:min(start+diagnosticsScanPage, len(keys))]
+		pipe := rdb.Pipeline()
+		lens := make([]*redis.IntCmd, len(page))
+		ttls := make([]*redis.DurationCmd, len(page))
+		for i, key := range page {
+			lens[i] = pipe.StrLen(ctx, key)
+			ttls[i] = pipe.TTL(ctx, key)
+		}
+		// StrLen fails on hashes, lists and streams: their size stays 0
+		_, _ = pipe.Exec(ctx)
+		for i, key := range page {
+			ttl := int64(-1)
+			if d := ttls[i].Val(); d > 0 {
+				ttl = int64(d.Seconds())
+			}
+			out = append(out, diagnosticKey{Key: key, Bytes: lens[i].Val(), TTLSeconds: ttl})
+		}
+	}
+	return out, truncated, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// encryptedValuePrefix marks a value encrypted with the client key:
+// "enc:v1:" + base64(nonce || AES-256-GCM ciphertext).
+const encryptedValuePrefix = "enc:v1:"
+
+// applyNamespaceEncryption protects the ENCRYPTED_NAMESPACES: for a client
+// whose X-Client-Id has a key in CLIENT_ENCRYPTION_KEYS their values are
+// encrypted with it, for everyone else they are removed. Other namespaces are
+// served unchanged. Output is never cached (nonces are random).
+func applyNamespaceEncryption(c *fiber.Ctx, nested bool, payload []byte) ([]byte, error) {
+	protected := map[string]bool{}
+	for _, ns := range localenv.GetEncryptedNamespaces() {
+		protected[ns] = true
+	}
+	if len(protected) == 0 {
+		return payload, nil
+	}
+	c.Vary("X-Client-Id")
+
+	var aead cipher.AEAD
+	if key, ok := localenv.GetClientEncryptionKeys()[c.Get("X-Client-Id")]; ok && c.Get("X-Client-Id") != "" {
+		raw, err := hex.DecodeString(key)
+		if err != nil {
+			return nil, err
+		}
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, err
+		}
+		if aead, err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+
+	delim := "."
+	if !nested {
+		if d, _ := resolveDelimiter(c); d != "" {
+			delim = d
+		}
+	}
+	var tree map[string]any
+	if err := decodeJSON(payload, &tree); err != nil {
+		return nil, err
+	}
+	changed := false
+	for k, v := range tree {
+		ns := k
+		if !nested {
+			ns, _, _ = strings.Cut(k, delim)
+		}
+		if !protected[ns] {
+			continue
+		}
+		changed = true
+		if aead == nil {
+			delete(tree, k)
+			continue
+		}
+		enc, err := encryptValues(aead, v)
+		if err != nil {
+			return nil, err
+		}
+		tree[k] = enc
+	}
+	if !changed {
+		return payload, nil
+	}
+	c.Locals(localsOverridden, true)
+	return marshalJSON(tree)
+}
+
+func encryptValues(aead cipher.AEAD, v any) (any, error) {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, child := range val {
+			enc, err := encryptValues(aead, child)
+			if err != nil {
+				return nil, err
+			}
+			val[k] = enc
+		}
+		return val, nil
+	case []any:
+		for i, item := range val {
+			enc, err := encryptValues(aead, item)
+			if err != nil {
+				return nil, err
+			}
+			val[i] = enc
+		}
+		return val, nil
+	case string:
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		sealed := aead.Seal(nonce, nonce, []byte(val), nil)
+		return encryptedValuePrefix + base64.StdEncoding.EncodeToString(sealed), nil
+	}
+	return v, nil
+}
+
+// isEncryptedNamespace reports whether ns is listed in ENCRYPTED_NAMESPACES.
+func isEncryptedNamespace(ns string) bool {
+	for _, p := range localenv.GetEncryptedNamespaces() {
+		if p == ns {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutEncryptedNamespaces drops the protected namespaces from a stored
+// catalog (Tolgee keys, "." separated), for endpoints without a client key.
+func withoutEncryptedNamespaces(payload []byte, nested bool) ([]byte, error) {
+	if len(localenv.GetEncryptedNamespaces()) == 0 {
+		return payload, nil
+	}
+	var tree map[string]any
+	if err := decodeJSON(payload, &tree); err != nil {
+		return nil, err
+	}
+	for k := range tree {
+		ns := k
+		if !nested {
+			ns, _, _ = strings.Cut(k, ".")
+		}
+		if isEncryptedNamespace(ns) {
+			delete(tree, k)
+		}
+	}
+	return marshalJSON(tree)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+var errEnvelopeFormat = errors.New("envelope is only available with format=json")
+
+// envelopeMeta carries in-band what the headers say for clients that cannot
+// read them: the served language, the sha of data, when the snapshot was
+// stored, whether it is stale and which language was asked for on fallback.
+type envelopeMeta struct {
+	Lang         string     `json:"lang"`
+	Sha          string     `json:"sha"`
+	GeneratedAt  *time.Time `json:"generated_at,omitempty"`
+	Stale        bool       `json:"stale"`
+	FallbackFrom string     `json:"fallback_from,omitempty"`
+}
+
+type envelope struct {
+	Data json.RawMessage `json:"data"`
+	Meta envelopeMeta    `json:"meta"`
+}
+
+// resolveEnvelope reports whether ?envelope=true asked for {data, meta}.
+func resolveEnvelope(c *fiber.Ctx, f payloadFormat) (bool, error) {
+	if !c.QueryBool("envelope", false) {
+		return false, nil
+	}
+	if f.name != "json" {
+		return false, fiber.NewError(http.StatusBadRequest, errEnvelopeFormat.Error())
+	}
+	return true, nil
+}
+
+// wrapEnvelope wraps a JSON catalog. A snapshot is stale when Tolgee changed
+// after it was stored, or when it is older than STALE_BANNER_AFTER.
+func wrapEnvelope(c *fiber.Ctx, lang string, payload []byte) ([]byte, error) {
+	meta := envelopeMeta{Lang: lang, Sha: sha256Hex(payload)}
+	if served := c.GetRespHeader("X-Served-Language"); served != "" {
+		meta.Lang, meta.FallbackFrom = served, lang
+	}
+	ctx := context.Background()
+	if entry, ok := loadManifest(ctx).Languages[meta.Lang]; ok && !entry.UpdatedAt.IsZero() {
+		updated := entry.UpdatedAt
+		meta.GeneratedAt = &updated
+		after := localenv.GetStaleBannerAfter()
+		meta.Stale = after > 0 && time.Since(updated) > after
+	}
+	if servedStaleness(ctx, meta.Lang) > 0 {
+		meta.Stale = true
+	}
+	return json.Marshal(envelope{Data: payload, Meta: meta})
+}
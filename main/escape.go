@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"html"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// applyHTMLEscape HTML-escapes every string of the catalog when the request
+// asks for it (?escape=html or the platform default), for clients that inject
+// strings into innerHTML. Escaped variants are cached by served language and
+// input sha; when the served language is unknown they are computed inline.
+func applyHTMLEscape(c *fiber.Ctx, payload []byte) ([]byte, error) {
+	escape, err := resolveEscapeHTML(c)
+	if err != nil {
+		return nil, fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	if !escape {
+		return payload, nil
+	}
+	c.Locals(localsOverridden, true)
+
+	served, known := servedLanguageOf(c)
+	sum := sha256.Sum256(payload)
+	key := "tolgee:escaped:" + served + ":" + hex.EncodeToString(sum[:8])
+	if known {
+		if cached, err := redisGet(context.Background(), key); err == nil && len(cached) > 0 {
+			return cached, nil
+		}
+	}
+	var tree map[string]any
+	if err := decodeJSON(payload, &tree); err != nil {
+		return nil, err
+	}
+	escapeStrings(tree)
+	out, err := marshalJSON(tree)
+	if err != nil {
+		return nil, err
+	}
+	if known {
+		_ = redisPut(context.Background(), key, out, derivedVariantTTL())
+	}
+	return out, nil
+}
+
+func escapeStrings(tree map[string]any) {
+	for k, v := range tree {
+		tree[k] = escapeValue(v)
+	}
+}
+
+func escapeValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		escapeStrings(val)
+	case []any:
+		for i, item := range val {
+			val[i] = escapeValue(item)
+		}
+	case string:
+		return html.EscapeString(val)
+	}
+	return v
+}
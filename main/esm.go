@@ -0,0 +1,20 @@
+package main
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+)
+
+// esModuleFromJSON wraps a JSON catalog as an ES module with a default export.
+func esModuleFromJSON(payload []byte) []byte {
+	out := make([]byte, 0, len(payload)+len("export default ;\n"))
+	out = append(out, "export default "...)
+	out = append(out, payload...)
+	return append(out, ";\n"...)
+}
+
+// subresourceIntegrity returns the SRI value ("sha384-<base64>") of a body.
+func subresourceIntegrity(body []byte) string {
+	sum := sha512.Sum384(body)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
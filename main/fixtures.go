@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/goccy/go-json"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+const (
+	fixtureModeRecord = "record"
+	fixtureModeReplay = "replay"
+)
+
+var (
+	errNoFixture = errors.New("no recorded fixture for request")
+
+	fixtureNameRe = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+)
+
+// fixtureMode returns the mode and directory of SOURCE=record:/path (call
+// Tolgee and save every response) or SOURCE=replay:/path (answer from the
+// saved responses only, without network or credentials).
+func fixtureMode() (mode, dir string, ok bool) {
+	mode, dir, found := strings.Cut(localenv.GetSource(), ":")
+	if !found || dir == "" || mode != fixtureModeRecord && mode != fixtureModeReplay {
+		return "", "", false
+	}
+	return mode, dir, true
+}
+
+// tolgeeOffline reports whether Tolgee is never called, so no app key is
+// needed: local directory source or fixture replay.
+func tolgeeOffline() bool {
+	if _, ok := localSourceDir(); ok {
+		return true
+	}
+	mode, _, ok := fixtureMode()
+	return ok && mode == fixtureModeReplay
+}
+
+// fixtureMeta is written next to each recorded body, for humans and for the
+// replayed status and headers.
+type fixtureMeta struct {
+	Method      string    `json:"method"`
+	URL         string    `json:"url"`
+	Status      int       `json:"status"`
+	ContentType string    `json:"content_type,omitempty"`
+	RecordedAt  time.Time `json:"recorded_at"`
+}
+
+// fixtureName identifies a request independently of credentials: the app
+// key is dropped from the query, and signed URLs outside the Tolgee API
+// (screenshots) are keyed on their path only.
+func fixtureName(req *http.Request) string {
+	canonical := req.Method + " " + req.URL.Host + req.URL.Path
+	if strings.HasPrefix(req.URL.Path, "/v2/") {
+		query := req.URL.Query()
+		query.Del("ak")
+		keys := make([]string, 0, len(query))
+		for k := range query {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			canonical += "\n" + k + "=" + strings.Join(query[k], ",")
+		}
+	}
+	slug := strings.Trim(fixtureNameRe.ReplaceAllString(strings.TrimPrefix(req.URL.Path, "/v2/projects/"), "-"), "-")
+	return slug + "-" + sha256Hex([]byte(canonical))[:16]
+}
+
+// fixtureTransport records or replays the HTTP exchanges of a Tolgee client.
+type fixtureTransport struct {
+	mode string
+	dir  string
+	next http.RoundTripper
+}
+
+func (t *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	name := fixtureName(req)
+	bodyPath := filepath.Join(t.dir, name+".body")
+	metaPath := filepath.Join(t.dir, name+".json")
+
+	if t.mode == fixtureModeReplay {
+		body, err := os.ReadFile(bodyPath)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s %s (%s)", errNoFixture, req.Method, req.URL.Path, name)
+		}
+		meta := fixtureMeta{Status: http.StatusOK}
+		if raw, err := os.ReadFile(metaPath); err == nil {
+			_ = json.Unmarshal(raw, &meta)
+		}
+		header := http.Header{}
+		if meta.ContentType != "" {
+			header.Set("Content-Type", meta.ContentType)
+		}
+		return &http.Response{
+			Status:        http.StatusText(meta.Status),
+			StatusCode:    meta.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp, nil
+	}
+	redacted := *req.URL
+	query := redacted.Query()
+	if query.Has("ak") {
+		query.Set("ak", "REDACTED")
+		redacted.RawQuery = query.Encode()
+	}
+	meta, _ := json.MarshalIndent(fixtureMeta{
+		Method:      req.Method,
+		URL:         redacted.String(),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		RecordedAt:  time.Now().UTC(),
+	}, "", "  ")
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		log.Printf("[fixtures] record error: %v", err)
+		return resp, nil
+	}
+	if err := os.WriteFile(bodyPath, body, 0o644); err != nil {
+		log.Printf("[fixtures] record error: %v", err)
+		return resp, nil
+	}
+	_ = os.WriteFile(metaPath, meta, 0o644)
+	log.Printf("[fixtures] recorded %s %s -> %s", req.Method, req.URL.Path, name)
+	return resp, nil
+}
+
+// withFixtures routes a Tolgee client through the fixture recorder/replayer
+// when SOURCE selects one; otherwise the client is returned unchanged.
+func withFixtures(client *resty.Client) *resty.Client {
+	mode, dir, ok := fixtureMode()
+	if !ok {
+		return client
+	}
+	next := http.DefaultTransport
+	if t, err := client.Transport(); err == nil {
+		next = t
+	}
+	return client.SetTransport(&fixtureTransport{mode: mode, dir: dir, next: next})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"strconv"
+)
+
+// GetFlatTranslationsWithDelimiter serves the flat catalog with nested keys
+// joined by delim, derived from the cached nested payload.
+func GetFlatTranslationsWithDelimiter(ctx context.Context, lang, delim string) ([]byte, error) {
+	source, err := GetTranslationsFromCache(ctx, lang, true)
+	if err != nil {
+		return nil, err
+	}
+	artifact := derivedArtifact{Lang: lang, Nested: true, Format: "json", Filters: "delimiter=" + delim}
+	return loadDerivedArtifact(ctx, artifact, source, func() ([]byte, error) {
+		return flattenTranslations(source, delim)
+	})
+}
+
+// flattenTranslations joins nested object keys with delim and array items
+// with an [index] suffix, e.g. {"a":{"b":"x","c":["y"]}} with "_" becomes
+// {"a_b":"x","a_c[0]":"y"}, the same keys Tolgee uses for arrays in flat exports.
+func flattenTranslations(nested []byte, delim string) ([]byte, error) {
+	var tree map[string]any
+	if err := decodeJSON(nested, &tree); err != nil {
+		return nil, err
+	}
+	flat := make(map[string]any, len(tree))
+	flattenInto(flat, "", tree, delim)
+	return marshalJSON(flat)
+}
+
+func flattenInto(out map[string]any, prefix string, node map[string]any, delim string) {
+	for k, v := range node {
+		key := k
+		if prefix != "" {
+			key = prefix + delim + k
+		}
+		flattenValue(out, key, v, delim)
+	}
+}
+
+func flattenValue(out map[string]any, key string, v any, delim string) {
+	switch val := v.(type) {
+	case map[string]any:
+		flattenInto(out, key, val, delim)
+	case []any:
+		for i, item := range val {
+			flattenValue(out, key+"["+strconv.Itoa(i)+"]", item, delim)
+		}
+	default:
+		out[key] = v
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func mustDecode(t *testing.T, s string) map[string]any {
+	t.Helper()
+	var tree map[string]any
+	if err := decodeJSON([]byte(s), &tree); err != nil {
+		t.Fatalf("decode %s: %v", s, err)
+	}
+	return tree
+}
+
+func TestFlattenTranslationsArrays(t *testing.T) {
+	tests := []struct {
+		name   string
+		nested string
+		delim  string
+		want   string
+	}{
+		{
+			name:   "objects",
+			nested: `{"a":{"b":"x","c":{"d":"y"}}}`,
+			delim:  ".",
+			want:   `{"a.b":"x","a.c.d":"y"}`,
+		},
+		{
+			name:   "array of strings",
+			nested: `{"onboarding":{"slides":["one","two","three"]}}`,
+			delim:  ".",
+			want:   `{"onboarding.slides[0]":"one","onboarding.slides[1]":"two","onboarding.slides[2]":"three"}`,
+		},
+		{
+			name:   "array of objects",
+			nested: `{"carousel":[{"title":"A","body":"a"},{"title":"B"}]}`,
+			delim:  "_",
+			want:   `{"carousel[0]_title":"A","carousel[0]_body":"a","carousel[1]_title":"B"}`,
+		},
+		{
+			name:   "nested arrays",
+			nested: `{"grid":[["a","b"],["c"]]}`,
+			delim:  ".",
+			want:   `{"grid[0][0]":"a","grid[0][1]":"b","grid[1][0]":"c"}`,
+		},
+		{
+			name:   "null items keep their index",
+			nested: `{"list":["a",null,"c"]}`,
+			delim:  ".",
+			want:   `{"list[0]":"a","list[1]":null,"list[2]":"c"}`,
+		},
+		{
+			name:   "empty array",
+			nested: `{"list":[],"k":"v"}`,
+			delim:  ".",
+			want:   `{"k":"v"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := flattenTranslations([]byte(tt.nested), tt.delim)
+			if err != nil {
+				t.Fatalf("flattenTranslations: %v", err)
+			}
+			if got, want := mustDecode(t, string(out)), mustDecode(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSplitArrayIndexes(t *testing.T) {
+	tests := []struct {
+		part    string
+		name    string
+		indexes []int
+	}{
+		{"items", "items", nil},
+		{"items[0]", "items", []int{0}},
+		{"items[2][10]", "items", []int{2, 10}},
+		{"[0]", "[0]", nil},
+		{"items[x]", "items[x]", nil},
+		{"items[-1]", "items[-1]", nil},
+		{"items[1]tail", "items[1]tail", nil},
+		{"items[x][1]", "items[x]", []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.part, func(t *testing.T) {
+			name, indexes := splitArrayIndexes(tt.part)
+			if name != tt.name || !reflect.DeepEqual(indexes, tt.indexes) {
+				t.Errorf("splitArrayIndexes(%q) = %q %v, want %q %v", tt.part, name, indexes, tt.name, tt.indexes)
+			}
+		})
+	}
+}
+
+func TestCatalogPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    string
+		nested bool
+		delim  string
+		want   []catalogStep
+	}{
+		{
+			name:   "nested object",
+			key:    "a.b",
+			nested: true,
+			want:   []catalogStep{{key: "a", index: -1}, {key: "b", index: -1}},
+		},
+		{
+			name:   "nested array",
+			key:    "onboarding.slides[1]",
+			nested: true,
+			want:   []catalogStep{{key: "onboarding", index: -1}, {key: "slides", index: -1}, {index: 1}},
+		},
+		{
+			name:   "nested array of objects",
+			key:    "carousel[0].title",
+			nested: true,
+			want:   []catalogStep{{key: "carousel", index: -1}, {index: 0}, {key: "title", index: -1}},
+		},
+		{
+			name: "flat keeps the index in the key",
+			key:  "onboarding.slides[1]",
+			want: []catalogStep{{key: "onboarding.slides[1]", index: -1}},
+		},
+		{
+			name:  "flat with delimiter",
+			key:   "carousel[0].title",
+			delim: "_",
+			want:  []catalogStep{{key: "carousel[0]_title", index: -1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := catalogPath(tt.key, tt.nested, tt.delim); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("catalogPath(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCatalogValueArrays(t *testing.T) {
+	tests := []struct {
+		name   string
+		tree   string
+		key    string
+		set    any
+		delete bool
+		want   string
+	}{
+		{
+			name: "replace an item",
+			tree: `{"slides":["a","b"]}`,
+			key:  "slides[1]",
+			set:  "B",
+			want: `{"slides":["a","B"]}`,
+		},
+		{
+			name: "pad a missing item with null",
+			tree: `{"slides":["a"]}`,
+			key:  "slides[2]",
+			set:  "c",
+			want: `{"slides":["a",null,"c"]}`,
+		},
+		{
+			name: "create the array",
+			tree: `{}`,
+			key:  "carousel[0].title",
+			set:  "A",
+			want: `{"carousel":[{"title":"A"}]}`,
+		},
+		{
+			name:   "delete keeps following indexes",
+			tree:   `{"slides":["a","b","c"]}`,
+			key:    "slides[1]",
+			delete: true,
+			want:   `{"slides":["a",null,"c"]}`,
+		},
+		{
+			name:   "delete inside an array item",
+			tree:   `{"carousel":[{"title":"A","body":"a"}]}`,
+			key:    "carousel[0].body",
+			delete: true,
+			want:   `{"carousel":[{"title":"A"}]}`,
+		},
+		{
+			name:   "delete out of range is a no-op",
+			tree:   `{"slides":["a"]}`,
+			key:    "slides[3]",
+			delete: true,
+			want:   `{"slides":["a"]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := mustDecode(t, tt.tree)
+			if tt.delete {
+				deleteCatalogValue(tree, tt.key, true, "")
+			} else {
+				setCatalogValue(tree, tt.key, true, "", tt.set)
+				if v, ok := lookupCatalogValue(tree, tt.key, true, ""); !ok || v != tt.set {
+					t.Errorf("lookup after set = %v %t, want %v", v, ok, tt.set)
+				}
+			}
+			if want := mustDecode(t, tt.want); !reflect.DeepEqual(tree, want) {
+				t.Errorf("got %v, want %v", tree, want)
+			}
+		})
+	}
+}
+
+func TestFlattenRoundTripsCatalogPaths(t *testing.T) {
+	nested := `{"onboarding":{"slides":["one","two"]},"carousel":[{"title":"A"}],"grid":[["x"]]}`
+	out, err := flattenTranslations([]byte(nested), ".")
+	if err != nil {
+		t.Fatalf("flattenTranslations: %v", err)
+	}
+	tree := mustDecode(t, nested)
+	for key, want := range mustDecode(t, string(out)) {
+		if got, ok := lookupCatalogValue(tree, key, true, ""); !ok || got != want {
+			t.Errorf("lookup %q = %v %t, want %v", key, got, ok, want)
+		}
+	}
+}
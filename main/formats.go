@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// payloadFormat converts a cached JSON catalog into a wire format; name is its ?format= value.
+// Formats with a storedVariant are also encoded at cache-write time and kept
+// next to the JSON under "<cache key>:<storedVariant>".
+type payloadFormat struct {
+	name          string
+	contentType   string
+	encode        func(lang string, payload []byte) ([]byte, error)
+	storedVariant string
+	// adminOnly formats bypass the serving pipeline (overrides, tags, beta,
+	// namespace encryption) and require ADMIN_TOKEN
+	adminOnly bool
+}
+
+// payloadFormats is the registry behind ?format=; "json" is the default.
+var payloadFormats = map[string]payloadFormat{
+	"json": {
+		contentType: "application/json; charset=utf-8",
+		encode:      func(_ string, payload []byte) ([]byte, error) { return payload, nil },
+	},
+	"pb": {
+		contentType: "application/x-protobuf",
+		encode:      encodeCatalogProto,
+	},
+	"msgpack": {
+		contentType:   "application/msgpack",
+		encode:        encodeCatalogMsgpack,
+		storedVariant: "msgpack",
+	},
+	"arb": {
+		contentType: "application/json; charset=utf-8",
+		encode:      encodeCatalogARB,
+	},
+	"android": {
+		contentType: "application/xml; charset=utf-8",
+		encode:      encodeCatalogAndroid,
+	},
+	"ios": {
+		contentType: "application/zip",
+		encode:      encodeCatalogIOS,
+	},
+	"ts": {
+		contentType:   "application/xml; charset=utf-8",
+		encode:        encodeCatalogQtTS,
+		storedVariant: "ts",
+	},
+	"properties": {
+		contentType:   "text/plain; charset=iso-8859-1",
+		encode:        encodeCatalogProperties,
+		storedVariant: "properties",
+	},
+	"laravel": {
+		contentType: "application/zip",
+		encode:      encodeCatalogLaravel,
+	},
+	"tolgee-structured": {
+		contentType: "application/json; charset=utf-8",
+		encode:      encodeTolgeeStructured,
+		adminOnly:   true,
+	},
+}
+
+var errUnknownFormat = errors.New("unknown format")
+
+// resolveFormat reads ?format=, falling back to the Accept header for
+// MessagePack clients that cannot add query parameters.
+func resolveFormat(c *fiber.Ctx) (payloadFormat, error) {
+	name := c.Query("format")
+	if name == "" {
+		c.Vary("Accept")
+		name = "json"
+		if accept := c.Get("Accept"); strings.Contains(accept, "application/msgpack") || strings.Contains(accept, "application/x-msgpack") {
+			name = "msgpack"
+		}
+	}
+	f, ok := payloadFormats[name]
+	if !ok {
+		return payloadFormat{}, fiber.NewError(http.StatusBadRequest, errUnknownFormat.Error()+": "+name)
+	}
+	if f.adminOnly {
+		if !hasAdminToken(c) {
+			log.Printf("[admin] reject path=%q format=%s", c.Path(), name)
+			return payloadFormat{}, fiber.NewError(http.StatusUnauthorized, "invalid admin token")
+		}
+		c.Set("Cache-Control", "no-store")
+	}
+	f.name = name
+	return f, nil
+}
+
+// sendTranslations encodes the catalog in the requested format and writes it.
+// Untransformed catalogs are served from the pre-encoded variant when stored,
+// other conversions of them go through the derived-artifact cache keyed on
+// the served language; per-request catalogs (overrides, encryption, ...) are
+// encoded inline so they never mint cache entries. JSON snapshots
+// read from the S3 fallback are sent pre-compressed when the client accepts it.
+// JSON catalogs are wrapped in {data, meta} with ?envelope=true.
+func sendTranslations(c *fiber.Ctx, lang string, nested bool, payload []byte) error {
+	f, err := resolveFormat(c)
+	if err != nil {
+		return err
+	}
+	wrap, err := resolveEnvelope(c, f)
+	if err != nil {
+		return err
+	}
+	if f.name == "json" && !wrap {
+		if body, encoding := loadPrecompressedSnapshot(c, nested, payload); body != nil {
+			recordRequestStats(c)
+			observeServedPayload(servedLanguageLabel(c), nested, "json+"+encoding, len(payload), len(body))
+			c.Set("Content-Encoding", encoding)
+			c.Set("Content-type", f.contentType)
+			return c.Status(http.StatusOK).Send(body)
+		}
+	}
+	var body []byte
+	served, known := servedLanguageOf(c)
+	cacheable := known && isPlainVariantRequest(c, nested)
+	if f.storedVariant != "" && cacheable {
+		body, _ = redisGet(context.Background(), translationsCacheKey(served, nested)+":"+f.storedVariant)
+	}
+	if len(body) == 0 {
+		switch {
+		case f.name == "json":
+			body = payload
+		case cacheable:
+			body, err = loadDerivedArtifact(context.Background(), derivedArtifact{Lang: served, Nested: nested, Format: f.name}, payload, func() ([]byte, error) {
+				return f.encode(served, payload)
+			})
+		default:
+			body, err = f.encode(lang, payload)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if wrap {
+		if body, err = wrapEnvelope(c, lang, body); err != nil {
+			return err
+		}
+	}
+	recordRequestStats(c)
+	observeServedPayload(servedLanguageLabel(c), nested, f.name, len(payload), len(body))
+	c.Set("Content-type", f.contentType)
+	return c.Status(http.StatusOK).Send(body)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// freshnessEntry is the current snapshot of a language the client holds stale.
+type freshnessEntry struct {
+	Sha       string    `json:"sha"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// freshnessResponse lists only the languages whose sha differs from the
+// client's, plus those that are not cached at all.
+type freshnessResponse struct {
+	Stale   map[string]freshnessEntry `json:"stale"`
+	Missing []string                  `json:"missing,omitempty"`
+}
+
+// checkFreshness compares the client's lang -> sha map with the manifest.
+// Shas are sha256 hex of the plain JSON snapshot in the requested shape.
+func checkFreshness(ctx context.Context, held map[string]string, nested bool) freshnessResponse {
+	m := loadManifest(ctx)
+	resp := freshnessResponse{Stale: map[string]freshnessEntry{}}
+	missing := map[string]bool{}
+	for lang, sha := range held {
+		entry, ok := m.Languages[lang]
+		current := entry.FlatSha
+		if nested {
+			current = entry.NestedSha
+		}
+		if !ok || current == "" {
+			missing[lang] = true
+			continue
+		}
+		if current != sha {
+			resp.Stale[lang] = freshnessEntry{Sha: current, UpdatedAt: entry.UpdatedAt}
+		}
+	}
+	if len(missing) > 0 {
+		resp.Missing = sortedSetKeys(missing)
+	}
+	return resp
+}
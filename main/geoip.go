@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/oschwald/maxminddb-golang"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+var (
+	geoDBOnce sync.Once
+	geoDB     *maxminddb.Reader
+)
+
+// geoCountry returns the ISO country code of ip from the GEOIP_DB_PATH
+// database (MaxMind GeoLite2/GeoIP2 Country or City), "" when unknown.
+func geoCountry(ip string) string {
+	geoDBOnce.Do(func() {
+		path := localenv.GetGeoIPDBPath()
+		if path == "" {
+			return
+		}
+		db, err := maxminddb.Open(path)
+		if err != nil {
+			log.Printf("[geoip] disabled, open %q: %v", path, err)
+			return
+		}
+		geoDB = db
+		log.Printf("[geoip] enabled db=%q type=%s", path, db.Metadata.DatabaseType)
+	})
+	parsed := net.ParseIP(ip)
+	if geoDB == nil || parsed == nil {
+		return ""
+	}
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	if err := geoDB.Lookup(parsed, &record); err != nil {
+		return ""
+	}
+	return record.Country.ISOCode
+}
+
+// inferFallbackLanguage guesses the language for requests without :lang:
+// Accept-Language among the cached (non-beta, unless opted in) languages, then the COUNTRY_LANGUAGES entry
+// of the GeoIP country (clients that send no Accept-Language, e.g. smart TVs),
+// finally en.
+func inferFallbackLanguage(c *fiber.Ctx) string {
+	defer observeStage(c.UserContext(), stageNegotiate, time.Now())
+	c.Vary("Accept-Language")
+	available := fallbackCandidates(loadManifest(context.Background()), resolveIncludeBeta(c))
+	if header := c.Get("Accept-Language"); header != "" {
+		lang := c.AcceptsLanguages(available...)
+		recordLanguageDemand(header, lang != "")
+		if lang != "" {
+			return lang
+		}
+		return "en"
+	}
+	if localenv.GetGeoIPDBPath() == "" {
+		return "en"
+	}
+	// the answer depends on the client address, which Vary cannot express
+	c.Set("Cache-Control", "private")
+	// c.IP() reads PROXY_HEADER only from TRUSTED_PROXIES (see newFiberApp)
+	if country := geoCountry(c.IP()); country != "" {
+		if lang, ok := localenv.GetCountryLanguages()[strings.ToUpper(country)]; ok {
+			for _, tag := range available {
+				if tag == lang {
+					return lang
+				}
+			}
+		}
+	}
+	return "en"
+}
+
+// fallbackCandidates lists the languages inferFallbackLanguage can pick, en
+// first and then sorted: "Accept-Language: *" takes the first one, which
+// must not depend on map order.
+func fallbackCandidates(m *translationsManifest, includeBeta bool) []string {
+	available := make([]string, 0, len(m.Languages))
+	for tag := range m.Languages {
+		if includeBeta || !isBetaLanguage(tag) {
+			available = append(available, tag)
+		}
+	}
+	sort.Slice(available, func(i, j int) bool {
+		if (available[i] == "en") != (available[j] == "en") {
+			return available[i] == "en"
+		}
+		return available[i] < available[j]
+	})
+	return available
+}
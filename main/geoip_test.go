@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFallbackCandidatesOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		langs []string
+		want  []string
+	}{
+		{name: "en first", langs: []string{"it", "de", "en", "fr"}, want: []string{"en", "de", "fr", "it"}},
+		{name: "without en", langs: []string{"pt", "es", "de-AT"}, want: []string{"de-AT", "es", "pt"}},
+		{name: "empty", langs: nil, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &translationsManifest{Languages: map[string]manifestEntry{}}
+			for _, lang := range tt.langs {
+				m.Languages[lang] = manifestEntry{}
+			}
+			// map order varies between runs: the result must not
+			for i := 0; i < 20; i++ {
+				if got := fallbackCandidates(m, true); !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("fallbackCandidates = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
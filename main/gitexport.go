@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+const gitExportStatusKey = "tolgee:git-export:last"
+
+// gitExportTimeout bounds one export (fetch, commit and push).
+const gitExportTimeout = 2 * time.Minute
+
+// gitExportMu serializes the exports of this replica: they share the clone.
+var gitExportMu sync.Mutex
+
+// gitExportStatus is the outcome of the last export, kept in Redis.
+type gitExportStatus struct {
+	At        time.Time `json:"at"`
+	Commit    string    `json:"commit,omitempty"`
+	Languages []string  `json:"languages,omitempty"`
+	Removed   []string  `json:"removed,omitempty"`
+	Unchanged bool      `json:"unchanged,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+func gitAuth(token string) transport.AuthMethod {
+	if token == "" {
+		return nil
+	}
+	return &githttp.BasicAuth{Username: localenv.GetGitUsername(), Password: token}
+}
+
+// openGitClone clones url/branch into dir on first use, then fetches and
+// hard-resets the clone to the remote branch so every export starts from
+// the published history. An empty remote or a missing branch starts a new
+// branch that the first push creates.
+func openGitClone(ctx context.Context, dir, url, branch string, auth transport.AuthMethod) (*git.Repository, error) {
+	ref := plumbing.NewBranchReferenceName(branch)
+	repo, err := git.PlainOpen(dir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		repo, err = git.PlainCloneContext(ctx, dir, false, &git.CloneOptions{
+			URL: url, Auth: auth, ReferenceName: ref, SingleBranch: true, Depth: 1,
+		})
+		if errors.Is(err, transport.ErrEmptyRemoteRepository) || isMissingBranch(err) {
+			_ = os.RemoveAll(dir)
+			return initGitClone(dir, url, ref)
+		}
+		return repo, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: "origin", Auth: auth, Force: true,
+		RefSpecs: []gitconfig.RefSpec{gitconfig.RefSpec(fmt.Sprintf("+%s:refs/remotes/origin/%s", ref, branch))},
+	})
+	switch {
+	case err == nil, errors.Is(err, git.NoErrAlreadyUpToDate):
+	case errors.Is(err, transport.ErrEmptyRemoteRepository), isMissingBranch(err):
+		return repo, nil
+	default:
+		return nil, err
+	}
+	remote, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", branch), true)
+	if err != nil {
+		return nil, err
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	return repo, wt.Reset(&git.ResetOptions{Commit: remote.Hash(), Mode: git.HardReset})
+}
+
+func initGitClone(dir, url string, ref plumbing.ReferenceName) (*git.Repository, error) {
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := repo.CreateRemote(&gitconfig.RemoteConfig{Name: "origin", URLs: []string{url}}); err != nil {
+		return nil, err
+	}
+	return repo, repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, ref))
+}
+
+func isMissingBranch(err error) bool {
+	var noMatch git.NoMatchingRefSpecError
+	return errors.As(err, &noMatch) || errors.Is(err, plumbing.ErrReferenceNotFound)
+}
+
+// gitSnapshotFile renders a stored snapshot for review: sorted keys, indented.
+func gitSnapshotFile(payload []byte) ([]byte, error) {
+	sorted, err := sortJSONKeys(payload)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, sorted, "", "  "); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// exportSnapshotsToGit commits the nested snapshot of every refreshed
+// language to GIT_EXPORT_PATH/<lang>.json on GIT_EXPORT_BRANCH of
+// GIT_EXPORT_URL, deletes the files of removed languages, and pushes. It
+// runs in the background so a slow remote never delays the refresh.
+func exportSnapshotsToGit(ctx context.Context, summary *updateSummary) {
+	url := localenv.GetGitExportURL()
+	if url == "" || (len(summary.Refreshed) == 0 && len(summary.RemovedLanguages) == 0) {
+		return
+	}
+	refreshed := append([]string(nil), summary.Refreshed...)
+	removed := append([]string(nil), summary.RemovedLanguages...)
+	gen, hasGen := refreshGenerationFrom(ctx)
+	go func() {
+		gitExportMu.Lock()
+		defer gitExportMu.Unlock()
+		ctx, cancel := context.WithTimeout(context.Background(), gitExportTimeout)
+		defer cancel()
+		if hasGen {
+			ctx = withRefreshGeneration(ctx, gen)
+		}
+		status := gitExportStatus{At: time.Now().UTC()}
+		commit, changed, err := commitSnapshotsToGit(ctx, url, refreshed, removed)
+		switch {
+		case err != nil:
+			status.Error = err.Error()
+			log.Printf("[git] export error: %v", err)
+		case commit == "":
+			status.Unchanged = true
+		default:
+			status.Commit, status.Languages, status.Removed = commit, changed, removed
+			log.Printf("[git] exported commit=%s langs=%v removed=%v", commit[:12], changed, removed)
+		}
+		if b, err := json.Marshal(status); err == nil {
+			_ = redisPut(context.Background(), gitExportStatusKey, b, 0)
+		}
+	}()
+}
+
+func commitSnapshotsToGit(ctx context.Context, url string, refreshed, removed []string) (string, []string, error) {
+	branch := localenv.GetGitExportBranch()
+	auth := gitAuth(localenv.GetGitExportToken())
+	dir := localenv.GetGitExportDir()
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "mensa-git-export")
+	}
+	repo, err := openGitClone(ctx, dir, url, branch, auth)
+	if err != nil {
+		return "", nil, fmt.Errorf("clone: %w", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return "", nil, err
+	}
+	base := strings.Trim(localenv.GetGitExportPath(), "/")
+	if err := os.MkdirAll(filepath.Join(dir, filepath.FromSlash(base)), 0o755); err != nil {
+		return "", nil, err
+	}
+	for _, lang := range refreshed {
+		payload, err := redisGet(ctx, translationsCacheKey(lang, true))
+		if err != nil || len(payload) == 0 {
+			continue
+		}
+		// protected namespaces never leave the service in clear
+		if payload, err = withoutEncryptedNamespaces(payload, true); err != nil {
+			log.Printf("[git] encode lang=%s: %v", lang, err)
+			continue
+		}
+		file, err := gitSnapshotFile(payload)
+		if err != nil {
+			log.Printf("[git] encode lang=%s: %v", lang, err)
+			continue
+		}
+		rel := path.Join(base, filepath.Base(lang)+".json")
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(rel)), file, 0o644); err != nil {
+			return "", nil, err
+		}
+		if _, err := wt.Add(rel); err != nil {
+			return "", nil, err
+		}
+	}
+	for _, lang := range removed {
+		rel := path.Join(base, filepath.Base(lang)+".json")
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(rel))); err == nil {
+			if _, err := wt.Remove(rel); err != nil {
+				return "", nil, err
+			}
+		}
+	}
+	st, err := wt.Status()
+	if err != nil {
+		return "", nil, err
+	}
+	if st.IsClean() {
+		return "", nil, nil
+	}
+	var changed []string
+	for file := range st {
+		if lang, ok := strings.CutSuffix(path.Base(file), ".json"); ok && st.File(file).Staging != git.Deleted {
+			changed = append(changed, lang)
+		}
+	}
+	sort.Strings(changed)
+	message := "Update translations: " + strings.Join(changed, ", ")
+	if len(changed) == 0 {
+		message = "Remove translations: " + strings.Join(removed, ", ")
+	}
+	if gen, ok := refreshGenerationFrom(ctx); ok {
+		message += fmt.Sprintf("\n\nRefresh generation %d", gen)
+	}
+	hash, err := wt.Commit(message, &git.CommitOptions{Author: &object.Signature{
+		Name: localenv.GetGitAuthorName(), Email: localenv.GetGitAuthorEmail(), When: time.Now(),
+	}})
+	if err != nil {
+		return "", nil, fmt.Errorf("commit: %w", err)
+	}
+	ref := plumbing.NewBranchReferenceName(branch)
+	err = repo.PushContext(ctx, &git.PushOptions{
+		RemoteName: "origin", Auth: auth,
+		RefSpecs: []gitconfig.RefSpec{gitconfig.RefSpec(fmt.Sprintf("%s:%s", ref, ref))},
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return "", nil, fmt.Errorf("push: %w", err)
+	}
+	return hash.String(), changed, nil
+}
+
+func loadGitExportStatus(ctx context.Context) (*gitExportStatus, error) {
+	b, err := redisGet(ctx, gitExportStatusKey)
+	if err != nil || len(b) == 0 {
+		return nil, err
+	}
+	var status gitExportStatus
+	if err := json.Unmarshal(b, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+const gitSourceLockKey = "tolgee:git-source:lock"
+
+// gitSourceMu serializes the pulls of this replica: they share the clone.
+var gitSourceMu sync.Mutex
+
+// gitSourceURL returns the repository of SOURCE=git:<url>. In that mode the
+// repository is cloned locally and served like SOURCE=local: from its
+// GIT_SOURCE_PATH directory, one <lang>.json per language.
+func gitSourceURL() (string, bool) {
+	url, ok := strings.CutPrefix(localenv.GetSource(), "git:")
+	return url, ok && url != ""
+}
+
+func gitSourceCloneDir() string {
+	if dir := localenv.GetGitSourceDir(); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "mensa-git-source")
+}
+
+// gitSourceCatalogDir is the directory of the clone holding the catalogs.
+func gitSourceCatalogDir() string {
+	return filepath.Join(gitSourceCloneDir(), filepath.FromSlash(strings.Trim(localenv.GetGitSourcePath(), "/")))
+}
+
+// syncGitSource clones or fast-forwards the clone to the remote branch and
+// returns the head before and after; before is zero on the first clone.
+func syncGitSource(ctx context.Context) (*git.Repository, plumbing.Hash, plumbing.Hash, error) {
+	url, _ := gitSourceURL()
+	gitSourceMu.Lock()
+	defer gitSourceMu.Unlock()
+	dir := gitSourceCloneDir()
+	var before plumbing.Hash
+	if repo, err := git.PlainOpen(dir); err == nil {
+		if head, err := repo.Head(); err == nil {
+			before = head.Hash()
+		}
+	}
+	repo, err := openGitClone(ctx, dir, url, localenv.GetGitSourceBranch(), gitAuth(localenv.GetGitSourceToken()))
+	if err != nil {
+		return nil, before, before, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return nil, before, before, err
+	}
+	return repo, before, head.Hash(), nil
+}
+
+// gitSourceChanges returns the languages whose catalog differs between two
+// commits, and whether a catalog was added or removed (the language list
+// changed, so a full refresh is needed).
+func gitSourceChanges(repo *git.Repository, from, to plumbing.Hash) ([]string, bool, error) {
+	fromCommit, err := repo.CommitObject(from)
+	if err != nil {
+		return nil, true, err
+	}
+	toCommit, err := repo.CommitObject(to)
+	if err != nil {
+		return nil, true, err
+	}
+	fromTree, err := fromCommit.Tree()
+	if err != nil {
+		return nil, true, err
+	}
+	toTree, err := toCommit.Tree()
+	if err != nil {
+		return nil, true, err
+	}
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, true, err
+	}
+	dir := path.Clean(strings.Trim(localenv.GetGitSourcePath(), "/"))
+	langs := map[string]bool{}
+	full := false
+	for _, change := range changes {
+		for _, name := range []string{change.From.Name, change.To.Name} {
+			if name == "" || path.Dir(name) != dir {
+				continue
+			}
+			lang, ok := strings.CutSuffix(path.Base(name), ".json")
+			if !ok || strings.HasPrefix(lang, ".") {
+				continue
+			}
+			langs[lang] = true
+			if change.From.Name == "" || change.To.Name == "" || change.From.Name != change.To.Name {
+				full = true
+			}
+		}
+	}
+	out := make([]string, 0, len(langs))
+	for lang := range langs {
+		out = append(out, lang)
+	}
+	sort.Strings(out)
+	return out, full, nil
+}
+
+// pollGitSource pulls the repository and queues a refresh of the languages
+// changed since the previous head (everything when catalogs were added or
+// removed, or when the previous head is unknown).
+func pollGitSource(ctx context.Context, trigger string) error {
+	repo, before, after, err := syncGitSource(ctx)
+	if err != nil {
+		return err
+	}
+	if before == after {
+		return nil
+	}
+	var scope *refreshScope
+	if !before.IsZero() {
+		langs, full, err := gitSourceChanges(repo, before, after)
+		if err != nil {
+			log.Printf("[git] diff %s..%s error, full refresh: %v", before.String()[:12], after.String()[:12], err)
+		} else if len(langs) == 0 {
+			log.Printf("[git] head=%s no catalog changed", after.String()[:12])
+			return nil
+		} else if !full {
+			scope = &refreshScope{Languages: langs}
+		}
+	}
+	if scope == nil {
+		log.Printf("[git] head=%s trigger=%s full refresh", after.String()[:12], trigger)
+	} else {
+		log.Printf("[git] head=%s trigger=%s langs=%v", after.String()[:12], trigger, scope.Languages)
+	}
+	enqueueRefreshJob(ctx, trigger, scope)
+	return nil
+}
+
+// startGitSourcePolling checks the repository every GIT_SOURCE_POLL_INTERVAL;
+// one replica polls at a time, the refresh itself pulls on the replica that
+// runs it.
+func startGitSourcePolling() {
+	interval := localenv.GetGitSourcePollInterval()
+	if _, ok := gitSourceURL(); !ok || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ctx := context.Background()
+			if !redisTryLock(ctx, gitSourceLockKey, interval/2) {
+				continue
+			}
+			if err := pollGitSource(ctx, "git:poll"); err != nil {
+				log.Printf("[git] poll error: %v", err)
+			}
+		}
+	}()
+}
+
+// verifyGitWebhook accepts GitHub/Gitea (HMAC-SHA256 of the body) and GitLab
+// (shared token) push webhooks signed with GIT_SOURCE_WEBHOOK_SECRET.
+func verifyGitWebhook(secret string, header func(string) string, body []byte) bool {
+	if secret == "" {
+		return false
+	}
+	if token := header("X-Gitlab-Token"); token != "" {
+		return hmac.Equal([]byte(token), []byte(secret))
+	}
+	signature := strings.TrimPrefix(header("X-Hub-Signature-256"), "sha256=")
+	if signature == "" {
+		signature = header("X-Gitea-Signature")
+	}
+	if signature == "" {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(signature))
+}
+
+var errGitSourceDisabled = errors.New("git source not configured (SOURCE=git:<url>)")
+
+// initGitSource clones the repository before the warm-up reads it.
+func initGitSource() {
+	url, ok := gitSourceURL()
+	if !ok {
+		return
+	}
+	_, _, head, err := syncGitSource(context.Background())
+	if err != nil {
+		log.Printf("[git] clone %s error: %v", url, err)
+		return
+	}
+	log.Printf("[git] serving translations from %s head=%s", url, head.String()[:12])
+}
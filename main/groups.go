@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+
+	"github.com/goccy/go-json"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+var errUnknownGroup = errors.New("unknown language group")
+
+// GetGroupTranslations serves the languages of a LANGUAGE_GROUPS entry in one
+// payload: keyed by language tag, or with merge deep-merged in group order so
+// that later (regional) languages override the earlier base ones.
+// The result is cached under a key derived from the member snapshots.
+func GetGroupTranslations(ctx context.Context, name string, nested, merge bool) ([]byte, error) {
+	members, ok := localenv.GetLanguageGroups()[name]
+	if !ok || len(members) == 0 {
+		return nil, errUnknownGroup
+	}
+	payloads := make([][]byte, len(members))
+	hash := sha256.New()
+	for i, tag := range members {
+		p, err := GetTranslationsFromCache(ctx, tag, nested)
+		if err != nil {
+			return nil, err
+		}
+		if p, err = withoutEncryptedNamespaces(p, nested); err != nil {
+			return nil, err
+		}
+		payloads[i] = p
+		hash.Write([]byte(tag + "\x00" + sha256Hex(p) + "\x00"))
+	}
+	mode := "multi"
+	if merge {
+		mode = "merged"
+	}
+	key := "tolgee:group:" + name + ":" + strconv.FormatBool(nested) + ":" + mode + ":" + hex.EncodeToString(hash.Sum(nil)[:6])
+	if cached, err := redisGet(ctx, key); err == nil && len(cached) > 0 {
+		return cached, nil
+	}
+
+	var out []byte
+	var err error
+	if merge {
+		out, err = mergeCatalogs(payloads)
+	} else {
+		byTag := make(map[string]json.RawMessage, len(members))
+		for i, tag := range members {
+			byTag[tag] = payloads[i]
+		}
+		out, err = marshalJSON(byTag)
+	}
+	if err != nil {
+		return nil, err
+	}
+	_ = redisPut(ctx, key, out, derivedVariantTTL())
+	return out, nil
+}
+
+// mergeCatalogs deep-merges JSON objects, later payloads winning on conflicts.
+func mergeCatalogs(payloads [][]byte) ([]byte, error) {
+	merged := map[string]any{}
+	for _, p := range payloads {
+		var tree map[string]any
+		if err := decodeJSON(p, &tree); err != nil {
+			return nil, err
+		}
+		mergeInto(merged, tree)
+	}
+	return marshalJSON(merged)
+}
+
+func mergeInto(dst, src map[string]any) {
+	for k, v := range src {
+		child, isMap := v.(map[string]any)
+		existing, hasMap := dst[k].(map[string]any)
+		if isMap && hasMap {
+			mergeInto(existing, child)
+			continue
+		}
+		dst[k] = v
+	}
+}
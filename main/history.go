@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+const updateHistoryKey = "tolgee:update:history"
+
+// updateHistoryEntry is one finished refresh as kept in tolgee:update:history.
+type updateHistoryEntry struct {
+	JobID      string                   `json:"job_id,omitempty"`
+	Trigger    string                   `json:"trigger"`
+	Status     string                   `json:"status"`
+	Error      string                   `json:"error,omitempty"`
+	StartedAt  time.Time                `json:"started_at"`
+	FinishedAt time.Time                `json:"finished_at"`
+	Summary    *updateSummary           `json:"summary,omitempty"`
+	Shas       map[string]manifestEntry `json:"shas,omitempty"`
+}
+
+// recordUpdateHistory appends a finished refresh to the history, keeping the
+// last UPDATE_HISTORY_SIZE entries. Shas are the manifest entries of the
+// refreshed languages right after the run.
+func recordUpdateHistory(ctx context.Context, entry updateHistoryEntry) {
+	size := localenv.GetUpdateHistorySize()
+	if size <= 0 || !redisEnabled() {
+		return
+	}
+	if entry.Summary != nil && len(entry.Summary.Refreshed) > 0 {
+		languages := loadManifest(ctx).Languages
+		entry.Shas = make(map[string]manifestEntry, len(entry.Summary.Refreshed))
+		for _, lang := range entry.Summary.Refreshed {
+			entry.Shas[lang] = languages[lang]
+		}
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("[history] marshal error: %v", err)
+		return
+	}
+	pipe := rdb.Pipeline()
+	pipe.LPush(ctx, updateHistoryKey, b)
+	pipe.LTrim(ctx, updateHistoryKey, 0, size-1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("[history] record error: %v", err)
+	}
+}
+
+// listUpdateHistory returns up to limit entries, newest first.
+func listUpdateHistory(ctx context.Context, limit int64) []updateHistoryEntry {
+	entries := []updateHistoryEntry{}
+	raw, err := rdb.LRange(ctx, updateHistoryKey, 0, limit-1).Result()
+	if err != nil {
+		return entries
+	}
+	for _, r := range raw {
+		var entry updateHistoryEntry
+		if err := json.Unmarshal([]byte(r), &entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// parseHistoryLimit reads ?limit= (default and maximum UPDATE_HISTORY_SIZE).
+func parseHistoryLimit(c *fiber.Ctx) int64 {
+	size := localenv.GetUpdateHistorySize()
+	limit, err := strconv.ParseInt(c.Query("limit"), 10, 64)
+	if err != nil || limit <= 0 || limit > size {
+		return size
+	}
+	return limit
+}
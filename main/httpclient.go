@@ -0,0 +1,77 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// outboundClients are the process-wide HTTP clients: they share one tuned
+// transport, so connections (and TLS sessions) to Tolgee and the webhook
+// receivers are pooled and reused across requests. Resty clients are safe
+// for concurrent use; per-call settings go on the request.
+type outboundClients struct {
+	// tolgee calls the Tolgee API; no client timeout, the caller's context
+	// bounds the call (exports of large projects can be slow)
+	tolgee *resty.Client
+	// screenshots downloads signed screenshot URLs returned by Tolgee
+	screenshots *resty.Client
+	// webhooks delivers OUTGOING_WEBHOOK_URL notifications
+	webhooks *resty.Client
+}
+
+var (
+	outboundOnce sync.Once
+	outbound     *outboundClients
+)
+
+// httpClients returns the shared clients, built on first use.
+func httpClients() *outboundClients {
+	outboundOnce.Do(func() {
+		transport := newOutboundTransport()
+		outbound = &outboundClients{
+			tolgee:      withFixtures(newRestyClient(transport, 0)),
+			screenshots: withFixtures(newRestyClient(transport, 30*time.Second)),
+			webhooks:    newRestyClient(transport, 10*time.Second),
+		}
+	})
+	return outbound
+}
+
+func newRestyClient(transport *http.Transport, timeout time.Duration) *resty.Client {
+	return resty.NewWithClient(&http.Client{Transport: transport, Timeout: timeout}).
+		SetRetryCount(0)
+}
+
+// newOutboundTransport keeps idle connections alive for reuse and bounds
+// connections per host. HTTPS_PROXY/HTTP_PROXY (and NO_PROXY) are honoured
+// when set.
+func newOutboundTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   localenv.GetHTTPDialTimeout(),
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          localenv.GetHTTPMaxIdleConns(),
+		MaxIdleConnsPerHost:   localenv.GetHTTPMaxIdleConnsPerHost(),
+		MaxConnsPerHost:       localenv.GetHTTPMaxConnsPerHost(),
+		IdleConnTimeout:       localenv.GetHTTPIdleConnTimeout(),
+		TLSHandshakeTimeout:   localenv.GetHTTPTLSHandshakeTimeout(),
+		ExpectContinueTimeout: time.Second,
+	}
+	probe := &http.Request{URL: &url.URL{Scheme: "https", Host: "app.tolgee.io"}}
+	if proxy, err := transport.Proxy(probe); err == nil && proxy != nil {
+		log.Printf("[http] outbound requests to Tolgee go through proxy %s", proxy.Redacted())
+	}
+	return transport
+}
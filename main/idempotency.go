@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+const (
+	idempotencyKeyPrefix = "tolgee:idempotency:"
+	// idempotencyPendingTTL releases a key whose request never completed
+	// (replica killed mid-refresh), longer than the slowest ops batch.
+	idempotencyPendingTTL = 30 * time.Minute
+	idempotencyMaxKeyLen  = 255
+)
+
+// idempotencyRecord is the stored outcome of one Idempotency-Key; Pending is
+// set while the first request is still running.
+type idempotencyRecord struct {
+	Pending     bool      `json:"pending,omitempty"`
+	Fingerprint string    `json:"fingerprint"`
+	Status      int       `json:"status,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	Body        []byte    `json:"body,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// idempotent makes a mutating endpoint safe to retry: the first request with
+// a given Idempotency-Key runs, and its response is stored for
+// IDEMPOTENCY_TTL and replayed (with Idempotent-Replayed: true) to retries.
+// A retry while the first one runs gets 409; reusing a key for a different
+// request gets 422. Server errors and 401s are not stored, so they can be
+// retried. Redis errors fail open.
+//
+// It must run after the route's authentication: keys are scoped to the
+// caller set there (localsCaller), so a stored response is only replayed to
+// the credential that produced it. Without a caller the key is ignored, and
+// so it is without Redis (REDIS_ENABLED=false).
+func idempotent() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := strings.TrimSpace(c.Get("Idempotency-Key"))
+		caller, _ := c.Locals(localsCaller).(string)
+		if !redisEnabled() || key == "" || caller == "" || c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+			return c.Next()
+		}
+		if len(key) > idempotencyMaxKeyLen {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Idempotency-Key is too long"})
+		}
+		ctx := context.Background()
+		redisKey := idempotencyKeyPrefix + sha256Hex([]byte(caller+"\x00"+c.Path()+"\x00"+key))
+		fingerprint := sha256Hex(append([]byte(c.Method()+" "+c.OriginalURL()+"\x00"), c.Body()...))
+
+		pending, _ := json.Marshal(idempotencyRecord{Pending: true, Fingerprint: fingerprint, CreatedAt: time.Now().UTC()})
+		claimed, err := rdb.SetNX(ctx, redisKey, pending, idempotencyPendingTTL).Result()
+		if err != nil {
+			log.Printf("[idempotency] redis error, running without key: %v", err)
+			return c.Next()
+		}
+		if !claimed {
+			return replayIdempotent(c, redisKey, fingerprint)
+		}
+
+		if err := c.Next(); err != nil {
+			_ = rdb.Del(ctx, redisKey).Err()
+			return err
+		}
+		status := c.Response().StatusCode()
+		if status >= http.StatusInternalServerError || status == http.StatusUnauthorized {
+			_ = rdb.Del(ctx, redisKey).Err()
+			return nil
+		}
+		record, err := json.Marshal(idempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: string(c.Response().Header.ContentType()),
+			Body:        append([]byte(nil), c.Response().Body()...),
+			CreatedAt:   time.Now().UTC(),
+		})
+		if err != nil {
+			_ = rdb.Del(ctx, redisKey).Err()
+			return nil
+		}
+		if err := rdb.Set(ctx, redisKey, record, localenv.GetIdempotencyTTL()).Err(); err != nil {
+			log.Printf("[idempotency] store error: %v", err)
+		}
+		return nil
+	}
+}
+
+func replayIdempotent(c *fiber.Ctx, redisKey, fingerprint string) error {
+	b, err := redisGet(context.Background(), redisKey)
+	var record idempotencyRecord
+	if err != nil || json.Unmarshal(b, &record) != nil {
+		// expired between SETNX and GET: let the caller retry
+		c.Set("Retry-After", "1")
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "idempotency key state changed, retry"})
+	}
+	if record.Fingerprint != fingerprint {
+		return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{"error": "Idempotency-Key was used for a different request"})
+	}
+	if record.Pending {
+		c.Set("Retry-After", "5")
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "a request with this Idempotency-Key is still running"})
+	}
+	log.Printf("[idempotency] replay path=%q status=%d", c.Path(), record.Status)
+	c.Set("Idempotent-Replayed", "true")
+	if record.ContentType != "" {
+		c.Set("Content-type", record.ContentType)
+	}
+	return c.Status(record.Status).Send(record.Body)
+}
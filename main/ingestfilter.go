@@ -0,0 +1,182 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"unicode"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// ingestFilter rewrites one value at ingest and reports whether it changed it.
+type ingestFilter func(value string) (string, bool)
+
+// ingestFilters is the registry behind INGEST_FILTERS. allow_emoji has no
+// effect of its own: it lifts a deny_emoji inherited from "*".
+var ingestFilters = map[string]ingestFilter{
+	"strip_control":    stripControlChars,
+	"strip_zero_width": normalizeZeroWidth,
+	"deny_emoji":       stripEmoji,
+	"allow_emoji":      nil,
+}
+
+// namespaceIngestFilters returns the filter names that apply to namespace:
+// the "*" ones, then the namespace ones, with allow_emoji cancelling
+// deny_emoji.
+func namespaceIngestFilters(namespace string) []string {
+	rules := localenv.GetIngestFilters()
+	seen := map[string]bool{}
+	var names []string
+	for _, name := range append(append([]string(nil), rules["*"]...), rules[namespace]...) {
+		if _, ok := ingestFilters[name]; !ok {
+			log.Printf("[ingest] unknown filter %q for namespace=%s", name, namespace)
+			continue
+		}
+		if name == "allow_emoji" {
+			delete(seen, "deny_emoji")
+			continue
+		}
+		seen[name] = true
+	}
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyIngestFilters runs the INGEST_FILTERS policies over every string of
+// an exported catalog before it is validated and stored. Changed values are
+// logged per filter (when report is set, so each language is logged once
+// per refresh) and the catalog is re-encoded only if something changed.
+func applyIngestFilters(lang string, nested bool, payload []byte, report bool) []byte {
+	if len(localenv.GetIngestFilters()) == 0 {
+		return payload
+	}
+	var tree map[string]any
+	if err := decodeJSON(payload, &tree); err != nil {
+		return payload
+	}
+	cache := map[string][]string{}
+	changed := map[string][]string{}
+	filter := func(key, value string) string {
+		namespace, _, _ := strings.Cut(key, ".")
+		names, ok := cache[namespace]
+		if !ok {
+			names = namespaceIngestFilters(namespace)
+			cache[namespace] = names
+		}
+		for _, name := range names {
+			if out, hit := ingestFilters[name](value); hit {
+				value = out
+				changed[name] = append(changed[name], key)
+			}
+		}
+		return value
+	}
+	postProcessTree(tree, "", nested, "", []stringProcessor{filter})
+	if len(changed) == 0 {
+		return payload
+	}
+	out, err := marshalJSON(tree)
+	if err != nil {
+		return payload
+	}
+	if report {
+		for name, keys := range changed {
+			sort.Strings(keys)
+			sample := keys
+			if len(sample) > 5 {
+				sample = sample[:5]
+			}
+			log.Printf("[ingest] lang=%s filter=%s changed=%d keys=%v", lang, name, len(keys), sample)
+			recordIngestFiltered(lang, name, len(keys))
+		}
+	}
+	return out
+}
+
+// stripControlChars removes C0/C1 control characters, keeping newlines and
+// tabs that translators use on purpose.
+func stripControlChars(value string) (string, bool) {
+	if strings.IndexFunc(value, isStrippedControl) < 0 {
+		return value, false
+	}
+	return strings.Map(func(r rune) rune {
+		if isStrippedControl(r) {
+			return -1
+		}
+		return r
+	}, value), true
+}
+
+func isStrippedControl(r rune) bool {
+	return unicode.IsControl(r) && r != '\n' && r != '\t'
+}
+
+// normalizeZeroWidth drops invisible characters that carry no meaning
+// (zero-width space, word joiner, BOM, Mongolian vowel separator) and keeps
+// ZWJ/ZWNJ only once and between two visible characters, where scripts and
+// emoji sequences need them.
+func normalizeZeroWidth(value string) (string, bool) {
+	runes := []rune(value)
+	out := make([]rune, 0, len(runes))
+	for i, r := range runes {
+		switch r {
+		case '\u200b', '\u2060', '\ufeff', '\u180e':
+			continue
+		case '\u200c', '\u200d':
+			if len(out) == 0 || out[len(out)-1] == '\u200c' || out[len(out)-1] == '\u200d' || i == len(runes)-1 {
+				continue
+			}
+		}
+		out = append(out, r)
+	}
+	if n := len(out); n > 0 && (out[n-1] == '\u200c' || out[n-1] == '\u200d') {
+		out = out[:n-1]
+	}
+	if len(out) == len(runes) {
+		return value, false
+	}
+	return string(out), true
+}
+
+// stripEmoji removes emoji together with their presentation selectors and
+// the joiners of emoji sequences, and the space they leave behind.
+func stripEmoji(value string) (string, bool) {
+	var b strings.Builder
+	hit, afterEmoji, dropSpace := false, false, false
+	for _, r := range value {
+		if isEmoji(r) || r == '\ufe0f' || r == '\u20e3' || (r == '\u200d' && afterEmoji) {
+			hit, afterEmoji = true, true
+			dropSpace = b.Len() == 0 || strings.HasSuffix(b.String(), " ")
+			continue
+		}
+		if r == ' ' && dropSpace {
+			dropSpace = false
+			continue
+		}
+		afterEmoji, dropSpace = false, false
+		b.WriteRune(r)
+	}
+	if !hit {
+		return value, false
+	}
+	out := b.String()
+	if afterEmoji {
+		out = strings.TrimSuffix(out, " ")
+	}
+	return out, true
+}
+
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF, // pictographs, emoticons, flags, skin tones
+		r >= 0x2600 && r <= 0x27BF,   // misc symbols, dingbats
+		r >= 0x2B00 && r <= 0x2BFF,   // arrows and stars (⭐, ⬆)
+		r >= 0xE0020 && r <= 0xE007F: // tag sequences (subdivision flags)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+)
+
+const plistHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`
+
+// encodeCatalogIOS returns a ZIP with <lang>.lproj/Localizable.strings and,
+// for messages built around one ICU plural, Localizable.stringsdict with a
+// NSStringPluralRuleType entry per message (the .strings keeps their "other"
+// form as fallback). Keys are the flat Tolgee keys; ICU arguments become
+// positional specifiers (%1$@, # and numbers %1$d).
+func encodeCatalogIOS(lang string, payload []byte) ([]byte, error) {
+	keys, values, err := catalogStrings(payload)
+	if err != nil {
+		return nil, err
+	}
+	var strs, dict bytes.Buffer
+	dict.WriteString(plistHeader)
+	for _, key := range keys {
+		msg := values[key]
+		p, isPlural := parseSinglePlural(msg)
+		if isPlural {
+			writeStringsdictEntry(&dict, key, p)
+			msg = p.prefix + p.branches["other"] + p.suffix
+		}
+		arg := ""
+		if isPlural {
+			arg = p.arg
+		}
+		strs.WriteString(`"` + iosEscape(key) + `" = "` + icuPrintf(msg, arg, "@", iosEscape) + "\";\n")
+	}
+	dict.WriteString("</dict>\n</plist>\n")
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	dir := lang + ".lproj/"
+	for name, body := range map[string][]byte{
+		dir + "Localizable.strings":     strs.Bytes(),
+		dir + "Localizable.stringsdict": dict.Bytes(),
+	} {
+		if err := writeZipFile(zw, name, body); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeStringsdictEntry writes the plural of key: the format key keeps the
+// text around the plural with %#@<arg>@ in its place.
+func writeStringsdictEntry(dict *bytes.Buffer, key string, p singlePlural) {
+	format := icuPrintf(p.prefix+"{"+p.arg+"}"+p.suffix, p.arg, "@", plistEscape)
+	format = strings.Replace(format, "%1$@", "%1$#@"+p.arg+"@", 1)
+	dict.WriteString("    <key>" + plistEscape(key) + "</key>\n    <dict>\n")
+	dict.WriteString("        <key>NSStringLocalizedFormatKey</key>\n        <string>" + format + "</string>\n")
+	dict.WriteString("        <key>" + plistEscape(p.arg) + "</key>\n        <dict>\n")
+	dict.WriteString("            <key>NSStringFormatSpecTypeKey</key>\n            <string>NSStringPluralRuleType</string>\n")
+	dict.WriteString("            <key>NSStringFormatValueTypeKey</key>\n            <string>d</string>\n")
+	for _, q := range androidQuantityOrder {
+		if body, ok := p.branches[q]; ok {
+			dict.WriteString("            <key>" + q + "</key>\n            <string>" + icuPrintf(body, p.arg, "@", plistEscape) + "</string>\n")
+		}
+	}
+	dict.WriteString("        </dict>\n    </dict>\n")
+}
+
+var (
+	iosEscaper   = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	plistEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+func iosEscape(s string) string   { return iosEscaper.Replace(s) }
+func plistEscape(s string) string { return plistEscaper.Replace(s) }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func readZipFiles(t *testing.T, b []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		body, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", f.Name, err)
+		}
+		files[f.Name] = string(body)
+	}
+	return files
+}
+
+func TestEncodeCatalogIOS(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     string
+		wantStrings []string // lines expected in Localizable.strings
+		wantDict    []string // fragments expected in Localizable.stringsdict
+	}{
+		{
+			name:        "quotes and backslash",
+			payload:     `{"a":"say \"hi\" to C:\\dir"}`,
+			wantStrings: []string{`"a" = "say \"hi\" to C:\\dir";`},
+		},
+		{
+			name:        "apostrophe and markup stay as-is",
+			payload:     `{"a":"it's <b>&</b>"}`,
+			wantStrings: []string{`"a" = "it's <b>&</b>";`},
+		},
+		{
+			name:        "newline and tab",
+			payload:     `{"a":"one\ntwo\tthree"}`,
+			wantStrings: []string{`"a" = "one\ntwo\tthree";`},
+		},
+		{
+			name:        "escaped key",
+			payload:     `{"say \"x\"":"y"}`,
+			wantStrings: []string{`"say \"x\"" = "y";`},
+		},
+		{
+			name:        "nested keys are flattened",
+			payload:     `{"home":{"title":"x"}}`,
+			wantStrings: []string{`"home.title" = "x";`},
+		},
+		{
+			name:        "arguments",
+			payload:     `{"a":"{user} has {n, number} points, 100%"}`,
+			wantStrings: []string{`"a" = "%1$@ has %2$d points, 100%%";`},
+		},
+		{
+			name:        "plural",
+			payload:     `{"files":"Found {count, plural, one {# file} other {# \"files\"}} & more"}`,
+			wantStrings: []string{`"files" = "Found %1$d \"files\" & more";`},
+			wantDict: []string{
+				"<key>files</key>",
+				"<string>Found %1$#@count@ &amp; more</string>",
+				"<key>count</key>",
+				"<string>NSStringPluralRuleType</string>",
+				"<key>one</key>\n            <string>%1$d file</string>",
+				"<key>other</key>\n            <string>%1$d \"files\"</string>",
+			},
+		},
+		{
+			name:     "markup in a plural key",
+			payload:  `{"a<b":"{n, plural, other {# x}}"}`,
+			wantDict: []string{"<key>a&lt;b</key>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := encodeCatalogIOS("it", []byte(tt.payload))
+			if err != nil {
+				t.Fatalf("encodeCatalogIOS: %v", err)
+			}
+			files := readZipFiles(t, out)
+			strs, dict := files["it.lproj/Localizable.strings"], files["it.lproj/Localizable.stringsdict"]
+			for _, line := range tt.wantStrings {
+				if !strings.Contains(strs, line+"\n") {
+					t.Errorf("missing %s in Localizable.strings:\n%s", line, strs)
+				}
+			}
+			for _, fragment := range tt.wantDict {
+				if !strings.Contains(dict, fragment) {
+					t.Errorf("missing %q in Localizable.stringsdict:\n%s", fragment, dict)
+				}
+			}
+		})
+	}
+}
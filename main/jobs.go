@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+const (
+	jobStatusQueued  = "queued"
+	jobStatusRunning = "running"
+	jobStatusDone    = "done"
+	jobStatusFailed  = "failed"
+
+	jobTTL         = 24 * time.Hour
+	jobListKey     = "tolgee:jobs"
+	jobListMaxSize = 100
+
+	refreshLastFinishedKey = "tolgee:refresh:last-finished"
+
+	// pendingRefreshLease is the TTL of a queued slot; the replica holding
+	// the job renews it, so a job lost to a crash frees the slot quickly.
+	pendingRefreshLease = 30 * time.Second
+	// pendingRefreshStaleAfter is how long past the debounce window a queued
+	// job may keep its slot before new triggers take it over.
+	pendingRefreshStaleAfter = 15 * time.Minute
+)
+
+// refreshJob is an asynchronous refresh tracked in Redis under tolgee:jobs:<id>.
+type refreshJob struct {
+	ID             string         `json:"id"`
+	Status         string         `json:"status"`
+	Trigger        string         `json:"trigger"`
+	CreatedAt      time.Time      `json:"created_at"`
+	DebouncedUntil *time.Time     `json:"debounced_until,omitempty"`
+	StartedAt      *time.Time     `json:"started_at,omitempty"`
+	FinishedAt     *time.Time     `json:"finished_at,omitempty"`
+	Error          string         `json:"error,omitempty"`
+	Summary        *updateSummary `json:"summary,omitempty"`
+	Scope          *refreshScope  `json:"scope,omitempty"`
+
+	pendingKey string
+	// leaseDone stops renewing the pending slot once the job starts
+	leaseDone chan struct{}
+}
+
+// refreshState is the worker state exposed on the admin API.
+type refreshState struct {
+	Debounce       string     `json:"debounce"`
+	LastFinishedAt *time.Time `json:"last_finished_at,omitempty"`
+	DebouncedUntil *time.Time `json:"debounced_until,omitempty"`
+	PendingJob     string     `json:"pending_job,omitempty"`
+	RunningJob     string     `json:"running_job,omitempty"`
+
+	PendingRetries []pendingRetry `json:"pending_retries"`
+}
+
+var (
+	refreshJobQueue = make(chan *refreshJob, 64)
+	refreshJobOnce  sync.Once
+
+	refreshStateMu  sync.Mutex
+	pendingJobID    string
+	runningJobID    string
+	debouncingUntil time.Time
+
+	// localPending holds the queued slots with REDIS_ENABLED=false
+	localPendingMu sync.Mutex
+	localPending   = map[string]string{}
+)
+
+// enqueueRefreshJob records a queued job and hands it to the single refresh worker.
+// If an equivalent job (same scope) is still queued (on any replica) the trigger
+// is coalesced into it and that job is returned instead: at most one queued plus
+// one running. A nil scope refreshes the whole project.
+func enqueueRefreshJob(ctx context.Context, trigger string, scope *refreshScope) *refreshJob {
+	refreshJobOnce.Do(func() { go refreshJobWorker() })
+
+	var langs []string
+	if !scope.isFull() {
+		langs = scope.Languages
+	} else {
+		scope = nil
+	}
+	job := &refreshJob{
+		ID:        newJobID(),
+		Status:    jobStatusQueued,
+		Trigger:   trigger,
+		CreatedAt: time.Now().UTC(),
+		Scope:     scope,
+
+		pendingKey: "tolgee:jobs:pending:" + refreshDedupeKey(localenv.GetTolgeeAppKey(), langs) + scope.dedupeScope(),
+		leaseDone:  make(chan struct{}),
+	}
+	if existing := claimPendingRefresh(ctx, job.pendingKey, job.ID); existing != nil {
+		log.Printf("[jobs] coalesced trigger=%s into id=%s", trigger, existing.ID)
+		return existing
+	}
+	saveRefreshJob(ctx, job)
+	if redisEnabled() {
+		_ = rdb.LPush(ctx, jobListKey, job.ID).Err()
+		_ = rdb.LTrim(ctx, jobListKey, 0, jobListMaxSize-1).Err()
+	}
+
+	// the worker mutates job from here on: callers get a snapshot
+	queued := *job
+	if redisEnabled() {
+		go renewPendingRefresh(job.pendingKey, job.ID, job.leaseDone)
+	}
+	refreshJobQueue <- job
+	log.Printf("[jobs] queued id=%s trigger=%s", job.ID, trigger)
+	return &queued
+}
+
+func refreshJobWorker() {
+	for job := range refreshJobQueue {
+		runRefreshJob(context.Background(), job)
+	}
+}
+
+// claimPendingRefresh registers id as the queued job for pendingKey. If another
+// job already holds the slot, is still queued and is not overdue, that job is
+// returned. The slot only lives for pendingRefreshLease unless its owner
+// renews it (renewPendingRefresh).
+func claimPendingRefresh(ctx context.Context, pendingKey, id string) *refreshJob {
+	if !redisEnabled() {
+		return claimLocalPendingRefresh(ctx, pendingKey, id)
+	}
+	ok, err := rdb.SetNX(ctx, pendingKey, id, pendingRefreshLease).Result()
+	if err != nil || ok {
+		return nil
+	}
+	existingID, err := rdb.Get(ctx, pendingKey).Result()
+	if err == nil {
+		if existing := getRefreshJob(ctx, existingID); existing != nil && existing.Status == jobStatusQueued && !pendingRefreshOverdue(existing) {
+			return existing
+		}
+	}
+	// stale slot (job lost on restart, stuck or already started): take it over
+	_ = rdb.Set(ctx, pendingKey, id, pendingRefreshLease).Err()
+	return nil
+}
+
+// claimLocalPendingRefresh is claimPendingRefresh for a single replica
+// without Redis: the slots live in this process and need no lease.
+func claimLocalPendingRefresh(ctx context.Context, pendingKey, id string) *refreshJob {
+	localPendingMu.Lock()
+	defer localPendingMu.Unlock()
+	if existingID, ok := localPending[pendingKey]; ok {
+		if existing := getRefreshJob(ctx, existingID); existing != nil && existing.Status == jobStatusQueued && !pendingRefreshOverdue(existing) {
+			return existing
+		}
+	}
+	localPending[pendingKey] = id
+	return nil
+}
+
+// pendingRefreshOverdue reports whether a queued job has waited longer than
+// the debounce window plus pendingRefreshStaleAfter: its replica may still
+// renew the lease, but the job is no longer worth coalescing into.
+func pendingRefreshOverdue(job *refreshJob) bool {
+	return time.Since(job.CreatedAt) > localenv.GetRefreshDebounce()+pendingRefreshStaleAfter
+}
+
+// renewPendingRefresh extends the pending slot lease while it still names id,
+// until done is closed (the job started) or another job took the slot over.
+// The slot is a token lock like the ops lock and shares its scripts.
+func renewPendingRefresh(pendingKey, id string, done <-chan struct{}) {
+	ticker := time.NewTicker(pendingRefreshLease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			n, err := extendAdminOpsLock.Run(context.Background(), rdb, []string{pendingKey}, id, pendingRefreshLease.Milliseconds()).Int()
+			if err == nil && n == 0 {
+				return
+			}
+		}
+	}
+}
+
+// releasePendingRefresh frees the queued slot once its job starts running.
+func releasePendingRefresh(ctx context.Context, pendingKey, id string) {
+	if !redisEnabled() {
+		localPendingMu.Lock()
+		if localPending[pendingKey] == id {
+			delete(localPending, pendingKey)
+		}
+		localPendingMu.Unlock()
+		return
+	}
+	_ = releaseAdminOpsLock.Run(ctx, rdb, []string{pendingKey}, id).Err()
+}
+
+// refreshDedupeKey identifies equivalent refreshes: same app, same languages
+// (nil or empty means every language).
+func refreshDedupeKey(appKey string, langs []string) string {
+	sum := sha256.Sum256([]byte(appKey))
+	scope := "*"
+	if len(langs) > 0 {
+		sorted := append([]string(nil), langs...)
+		sort.Strings(sorted)
+		scope = strings.Join(sorted, ",")
+	}
+	return hex.EncodeToString(sum[:4]) + ":" + scope
+}
+
+func runRefreshJob(ctx context.Context, job *refreshJob) {
+	waitRefreshDebounce(ctx, job)
+
+	refreshStateMu.Lock()
+	pendingJobID, runningJobID = "", job.ID
+	refreshStateMu.Unlock()
+	defer func() {
+		refreshStateMu.Lock()
+		runningJobID = ""
+		refreshStateMu.Unlock()
+	}()
+
+	started := time.Now().UTC()
+	job.Status = jobStatusRunning
+	job.StartedAt = &started
+	saveRefreshJob(ctx, job)
+	close(job.leaseDone)
+	releasePendingRefresh(ctx, job.pendingKey, job.ID)
+
+	// read-only mode may have been turned on while the job was queued
+	var summary *updateSummary
+	var err error
+	if isReadOnly(ctx) {
+		deferRefresh(ctx, job.Trigger)
+		err = errReadOnly
+	} else {
+		summary, err = runRefresh(ctx, job.Scope)
+	}
+
+	finished := time.Now().UTC()
+	job.FinishedAt = &finished
+	job.Summary = summary
+	job.Status = jobStatusDone
+	if err != nil {
+		job.Status = jobStatusFailed
+		job.Error = err.Error()
+	}
+	saveRefreshJob(ctx, job)
+	recordUpdateHistory(ctx, updateHistoryEntry{
+		JobID:      job.ID,
+		Trigger:    job.Trigger,
+		Status:     job.Status,
+		Error:      job.Error,
+		StartedAt:  started,
+		FinishedAt: finished,
+		Summary:    summary,
+	})
+	_ = redisPut(ctx, refreshLastFinishedKey, []byte(finished.Format(time.RFC3339Nano)), 0)
+	log.Printf("[jobs] finished id=%s status=%s", job.ID, job.Status)
+}
+
+// waitRefreshDebounce holds a job until REFRESH_DEBOUNCE has elapsed since the
+// last completed refresh. The job stays queued meanwhile, so further triggers
+// are coalesced into it and run once when the window closes.
+func waitRefreshDebounce(ctx context.Context, job *refreshJob) {
+	debounce := localenv.GetRefreshDebounce()
+	if debounce <= 0 {
+		return
+	}
+	last := lastRefreshFinished(ctx)
+	if last == nil {
+		return
+	}
+	until := last.Add(debounce).UTC()
+	if !time.Now().Before(until) {
+		return
+	}
+
+	job.DebouncedUntil = &until
+	saveRefreshJob(ctx, job)
+	refreshStateMu.Lock()
+	pendingJobID, debouncingUntil = job.ID, until
+	refreshStateMu.Unlock()
+
+	log.Printf("[jobs] debounced id=%s until=%s", job.ID, until.Format(time.RFC3339))
+	time.Sleep(time.Until(until))
+
+	refreshStateMu.Lock()
+	debouncingUntil = time.Time{}
+	refreshStateMu.Unlock()
+}
+
+// lastRefreshFinished returns when the last refresh completed (on any replica).
+func lastRefreshFinished(ctx context.Context) *time.Time {
+	raw, err := redisGet(ctx, refreshLastFinishedKey)
+	if err != nil {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, string(raw))
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+func getRefreshState(ctx context.Context) refreshState {
+	state := refreshState{
+		Debounce:       localenv.GetRefreshDebounce().String(),
+		LastFinishedAt: lastRefreshFinished(ctx),
+		PendingRetries: listPendingRetries(ctx),
+	}
+	refreshStateMu.Lock()
+	defer refreshStateMu.Unlock()
+	state.PendingJob = pendingJobID
+	state.RunningJob = runningJobID
+	if !debouncingUntil.IsZero() {
+		until := debouncingUntil
+		state.DebouncedUntil = &until
+	}
+	return state
+}
+
+func saveRefreshJob(ctx context.Context, job *refreshJob) {
+	b, err := json.Marshal(job)
+	if err != nil {
+		log.Printf("[jobs] marshal error id=%s: %v", job.ID, err)
+		return
+	}
+	if err := redisPut(ctx, "tolgee:jobs:"+job.ID, b, jobTTL); err != nil {
+		log.Printf("[jobs] save error id=%s: %v", job.ID, err)
+	}
+}
+
+// getRefreshJob loads a job by id; it returns nil if unknown or expired.
+func getRefreshJob(ctx context.Context, id string) *refreshJob {
+	b, err := redisGet(ctx, "tolgee:jobs:"+id)
+	if err != nil || len(b) == 0 {
+		return nil
+	}
+	var job refreshJob
+	if err := json.Unmarshal(b, &job); err != nil {
+		return nil
+	}
+	return &job
+}
+
+// listRefreshJobs returns the most recent jobs, newest first.
+func listRefreshJobs(ctx context.Context) []*refreshJob {
+	ids, err := rdb.LRange(ctx, jobListKey, 0, jobListMaxSize-1).Result()
+	if err != nil {
+		return []*refreshJob{}
+	}
+	jobs := make([]*refreshJob, 0, len(ids))
+	for _, id := range ids {
+		if job := getRefreshJob(ctx, id); job != nil {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
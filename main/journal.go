@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+const journalStreamKey = "tolgee:journal"
+
+// journalEntry is one cache mutation as recorded in the tolgee:journal stream.
+type journalEntry struct {
+	ID         string `json:"id"`
+	Key        string `json:"key"`
+	Tiers      string `json:"tiers"`
+	BeforeSha  string `json:"before_sha,omitempty"`
+	AfterSha   string `json:"after_sha"`
+	Bytes      int    `json:"bytes"`
+	Generation string `json:"generation,omitempty"`
+	At         string `json:"at"`
+	Error      string `json:"error,omitempty"`
+}
+
+// journalEnabled reports whether mutations are journaled (JOURNAL_MAX_LEN > 0
+// and Redis enabled: the journal is a Redis stream).
+func journalEnabled() bool { return localenv.GetJournalMaxLen() > 0 && redisEnabled() }
+
+// journalCacheMutation appends a write performed by a refresh to the journal
+// (Redis stream, approximately capped at JOURNAL_MAX_LEN entries).
+func journalCacheMutation(ctx context.Context, key string, before, after []byte, toS3 bool, writeErr error) {
+	if !journalEnabled() {
+		return
+	}
+	tiers := "redis"
+	if toS3 {
+		tiers = "redis+s3"
+	}
+	values := map[string]interface{}{
+		"key":       key,
+		"tiers":     tiers,
+		"after_sha": sha256Hex(after),
+		"bytes":     len(after),
+		"at":        time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if len(before) > 0 {
+		values["before_sha"] = sha256Hex(before)
+	}
+	if gen, ok := refreshGenerationFrom(ctx); ok {
+		values["generation"] = strconv.FormatInt(gen, 10)
+	}
+	if writeErr != nil {
+		values["error"] = writeErr.Error()
+	}
+	err := rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: journalStreamKey,
+		MaxLen: localenv.GetJournalMaxLen(),
+		Approx: true,
+		Values: values,
+	}).Err()
+	if err != nil {
+		log.Printf("[journal] append error key=%q: %v", key, err)
+	}
+}
+
+// readJournal returns the newest count entries, newest first.
+func readJournal(ctx context.Context, count int64) ([]journalEntry, error) {
+	msgs, err := rdb.XRevRangeN(ctx, journalStreamKey, "+", "-", count).Result()
+	if err != nil {
+		return nil, err
+	}
+	return journalEntriesFrom(msgs), nil
+}
+
+func journalEntriesFrom(msgs []redis.XMessage) []journalEntry {
+	entries := make([]journalEntry, 0, len(msgs))
+	for _, m := range msgs {
+		str := func(k string) string {
+			v, _ := m.Values[k].(string)
+			return v
+		}
+		n, _ := strconv.Atoi(str("bytes"))
+		entries = append(entries, journalEntry{
+			ID:         m.ID,
+			Key:        str("key"),
+			Tiers:      str("tiers"),
+			BeforeSha:  str("before_sha"),
+			AfterSha:   str("after_sha"),
+			Bytes:      n,
+			Generation: str("generation"),
+			At:         str("at"),
+			Error:      str("error"),
+		})
+	}
+	return entries
+}
+
+// journalReplayReport is the outcome of replayJournal, by cache key.
+type journalReplayReport struct {
+	Restored   []string `json:"restored"`
+	UpToDate   []string `json:"up_to_date"`
+	Mismatched []string `json:"mismatched"`
+	Missing    []string `json:"missing"`
+}
+
+// replayJournal restores into Redis the last journaled version of every key,
+// reading the payload from S3 and accepting it only if its sha matches.
+func replayJournal(ctx context.Context) (*journalReplayReport, error) {
+	msgs, err := rdb.XRange(ctx, journalStreamKey, "-", "+").Result()
+	if err != nil {
+		return nil, err
+	}
+	latest := map[string]string{}
+	var order []string
+	for _, e := range journalEntriesFrom(msgs) {
+		if e.Error != "" || e.Tiers != "redis+s3" {
+			continue
+		}
+		if _, seen := latest[e.Key]; !seen {
+			order = append(order, e.Key)
+		}
+		latest[e.Key] = e.AfterSha
+	}
+
+	report := &journalReplayReport{Restored: []string{}, UpToDate: []string{}, Mismatched: []string{}, Missing: []string{}}
+	s3c := s3ClientIfEnabled(ctx)
+	for _, key := range order {
+		want := latest[key]
+		if current, err := redisGet(ctx, key); err == nil && sha256Hex(current) == want {
+			report.UpToDate = append(report.UpToDate, key)
+			continue
+		}
+		payload, err := s3c.getObject(ctx, key)
+		if err != nil {
+			report.Missing = append(report.Missing, key)
+			continue
+		}
+		if sha256Hex(payload) != want {
+			report.Mismatched = append(report.Mismatched, key)
+			continue
+		}
+		_ = redisPut(ctx, key, payload, 0)
+		report.Restored = append(report.Restored, key)
+	}
+	log.Printf("[journal] replay restored=%d up_to_date=%d mismatched=%d missing=%d",
+		len(report.Restored), len(report.UpToDate), len(report.Mismatched), len(report.Missing))
+	return report, nil
+}
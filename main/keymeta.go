@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/goccy/go-json"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// screenshotCacheKey stores a Tolgee screenshot image when SCREENSHOT_PROXY is on.
+func screenshotCacheKey(id int64) string {
+	return "tolgee:screenshot:" + strconv.FormatInt(id, 10)
+}
+
+type keyScreenshot struct {
+	ID           int64  `json:"id"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+	Width        int    `json:"width,omitempty"`
+	Height       int    `json:"height,omitempty"`
+}
+
+// keyMeta is the translator context of a key: description and screenshots.
+type keyMeta struct {
+	Namespace   string          `json:"namespace,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Screenshots []keyScreenshot `json:"screenshots"`
+}
+
+var errKeyNotFound = errors.New("key not found")
+
+// GetKeyMeta reads the key from Tolgee /v2/projects/translations through the
+// proxy cache (TOLGEE_PROXY_TTL). With SCREENSHOT_PROXY the images are copied
+// to S3 and their URLs point to /api/screenshots/:id, so reviewers need no
+// Tolgee login.
+func GetKeyMeta(ctx context.Context, lang, key string) (*keyMeta, error) {
+	raw, err := getTolgeeResourceCached(ctx, "translations", map[string]string{
+		"filterKeyName": key,
+		"languages":     lang,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var page struct {
+		Embedded struct {
+			Keys []struct {
+				KeyName        string `json:"keyName"`
+				KeyNamespace   string `json:"keyNamespace"`
+				KeyDescription string `json:"keyDescription"`
+				Screenshots    []struct {
+					ID           int64  `json:"id"`
+					FileURL      string `json:"fileUrl"`
+					ThumbnailURL string `json:"thumbnailUrl"`
+					Width        int    `json:"width"`
+					Height       int    `json:"height"`
+				} `json:"screenshots"`
+			} `json:"keys"`
+		} `json:"_embedded"`
+	}
+	if err := json.Unmarshal(raw, &page); err != nil {
+		return nil, err
+	}
+	for _, k := range page.Embedded.Keys {
+		if k.KeyName != key {
+			continue
+		}
+		meta := &keyMeta{Namespace: k.KeyNamespace, Description: k.KeyDescription, Screenshots: []keyScreenshot{}}
+		for _, s := range k.Screenshots {
+			shot := keyScreenshot{ID: s.ID, URL: s.FileURL, ThumbnailURL: s.ThumbnailURL, Width: s.Width, Height: s.Height}
+			if localenv.GetScreenshotProxy() {
+				if err := proxyScreenshot(ctx, s.ID, s.FileURL); err != nil {
+					log.Printf("[keymeta] screenshot %d proxy error: %v", s.ID, err)
+				} else {
+					shot.URL = "/api/screenshots/" + strconv.FormatInt(s.ID, 10)
+					shot.ThumbnailURL = ""
+				}
+			}
+			meta.Screenshots = append(meta.Screenshots, shot)
+		}
+		return meta, nil
+	}
+	return nil, errKeyNotFound
+}
+
+// proxyScreenshot copies the image to S3 once; screenshots are immutable in
+// Tolgee (a new upload gets a new id).
+func proxyScreenshot(ctx context.Context, id int64, url string) error {
+	s3c := s3ClientIfEnabled(ctx)
+	if s3c == nil {
+		return errors.New("s3 disabled")
+	}
+	key := screenshotCacheKey(id)
+	if _, err := s3c.headObject(ctx, key); err == nil {
+		return nil
+	}
+	img, contentType, err := DownloadScreenshot(ctx, url)
+	if err != nil {
+		return err
+	}
+	return s3c.putObject(ctx, key, img, contentType, map[string]string{})
+}
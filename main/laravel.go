@@ -0,0 +1,164 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// encodeCatalogLaravel returns a ZIP laid out like Laravel's lang directory:
+// lang/<locale>/<namespace>.php returning the namespace array for each
+// top-level object, and lang/<locale>.json for loose top-level strings.
+// Flat catalogs are split on the first "." so __('ns.key') resolves the same.
+// Messages whose only ICU constructs are plain {name} arguments use Laravel's
+// :name placeholders; plural/select messages keep their ICU text.
+func encodeCatalogLaravel(lang string, payload []byte) ([]byte, error) {
+	var tree map[string]any
+	if err := decodeJSON(payload, &tree); err != nil {
+		return nil, err
+	}
+	namespaces := map[string]map[string]any{}
+	loose := map[string]any{}
+	for k, v := range tree {
+		if sub, ok := v.(map[string]any); ok {
+			ns := namespaces[k]
+			if ns == nil {
+				ns = map[string]any{}
+				namespaces[k] = ns
+			}
+			for sk, sv := range sub {
+				ns[sk] = sv
+			}
+			continue
+		}
+		if nsName, rest, ok := strings.Cut(k, "."); ok && nsName != "" && rest != "" {
+			ns := namespaces[nsName]
+			if ns == nil {
+				ns = map[string]any{}
+				namespaces[nsName] = ns
+			}
+			ns[rest] = v
+			continue
+		}
+		loose[k] = v
+	}
+
+	locale := strings.ReplaceAll(lang, "-", "_")
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	names := make([]string, 0, len(namespaces))
+	for name := range namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var php bytes.Buffer
+		php.WriteString("<?php\n\nreturn ")
+		writePHPValue(&php, namespaces[name], 0)
+		php.WriteString(";\n")
+		file := strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+		if err := writeZipFile(zw, "lang/"+locale+"/"+file+".php", php.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	if len(loose) > 0 {
+		strs := make(map[string]any, len(loose))
+		for k, v := range loose {
+			if s, ok := v.(string); ok {
+				strs[k] = laravelMessage(s)
+			} else {
+				strs[k] = v
+			}
+		}
+		body, err := marshalJSON(strs)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeZipFile(zw, "lang/"+locale+".json", body); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeZipFile(zw *zip.Writer, name string, body []byte) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+// writePHPValue writes v as a PHP short-array literal with sorted keys.
+func writePHPValue(buf *bytes.Buffer, v any, depth int) {
+	indent := strings.Repeat("    ", depth+1)
+	switch val := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf.WriteString("[\n")
+		for _, k := range keys {
+			buf.WriteString(indent + phpString(k) + " => ")
+			writePHPValue(buf, val[k], depth+1)
+			buf.WriteString(",\n")
+		}
+		buf.WriteString(strings.Repeat("    ", depth) + "]")
+	case []any:
+		buf.WriteString("[\n")
+		for _, item := range val {
+			buf.WriteString(indent)
+			writePHPValue(buf, item, depth+1)
+			buf.WriteString(",\n")
+		}
+		buf.WriteString(strings.Repeat("    ", depth) + "]")
+	case string:
+		buf.WriteString(phpString(laravelMessage(val)))
+	case json.Number:
+		buf.WriteString(val.String())
+	case bool:
+		buf.WriteString(strconv.FormatBool(val))
+	case nil:
+		buf.WriteString("null")
+	}
+}
+
+var phpEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+func phpString(s string) string { return "'" + phpEscaper.Replace(s) + "'" }
+
+// laravelMessage rewrites {name} as :name when the message has no other ICU
+// construct, leaving it untouched otherwise.
+func laravelMessage(msg string) string {
+	args := map[string]string{}
+	icuArguments(msg, args)
+	if len(args) == 0 {
+		return msg
+	}
+	for _, kind := range args {
+		if kind != "" {
+			return msg
+		}
+	}
+	var out strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if msg[i] == '{' {
+			if end := matchICUBrace(msg, i); end > 0 {
+				out.WriteString(":" + strings.TrimSpace(msg[i+1:end]))
+				i = end
+				continue
+			}
+		}
+		out.WriteByte(msg[i])
+	}
+	return out.String()
+}
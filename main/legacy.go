@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const legacyRedisTranslationsNS = "translations:"
+
+// Outcomes of one legacy key.
+const (
+	legacyStatusMigrated     = "migrated"
+	legacyStatusWouldMigrate = "would_migrate"
+	legacyStatusUpToDate     = "up_to_date"
+	legacyStatusConflict     = "conflict"
+	legacyStatusFailed       = "failed"
+)
+
+// legacyItem is one legacy object and the unified key it maps to.
+type legacyItem struct {
+	Source string `json:"source"` // "redis" or "s3"
+	Key    string `json:"key"`
+	Target string `json:"target"`
+	Sha    string `json:"sha,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// legacyMigrationReport is the outcome (or, while running, the progress) of
+// importLegacyData.
+type legacyMigrationReport struct {
+	DryRun     bool           `json:"dry_run"`
+	Running    bool           `json:"running"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+	Total      int            `json:"total"`
+	Done       int            `json:"done"`
+	Counts     map[string]int `json:"counts"`
+	Items      []legacyItem   `json:"items"`
+}
+
+var errLegacyMigrationRunning = errors.New("a legacy migration is already running")
+
+// legacyMigration holds the last report, readable while the import runs.
+var legacyMigration struct {
+	sync.Mutex
+	report *legacyMigrationReport
+}
+
+// currentLegacyMigration returns a copy of the running or last report.
+func currentLegacyMigration() *legacyMigrationReport {
+	legacyMigration.Lock()
+	defer legacyMigration.Unlock()
+	if legacyMigration.report == nil {
+		return nil
+	}
+	r := *legacyMigration.report
+	r.Counts = make(map[string]int, len(legacyMigration.report.Counts))
+	for k, v := range legacyMigration.report.Counts {
+		r.Counts[k] = v
+	}
+	r.Items = append([]legacyItem(nil), legacyMigration.report.Items...)
+	return &r
+}
+
+// legacyRedisTarget maps the Redis key formats used before the unified
+// tolgee:lang:<tag>:<nested> scheme: translations:<tag>[:flat|nested|true|false]
+// and tolgee:lang:<tag> without a mode (flat). Unified keys map to themselves,
+// so a snapshot only present in Redis also reaches S3.
+func legacyRedisTarget(key string) (string, bool) {
+	var rest string
+	switch {
+	case strings.HasPrefix(key, legacyRedisTranslationsNS):
+		rest = strings.TrimPrefix(key, legacyRedisTranslationsNS)
+	case strings.HasPrefix(key, "tolgee:lang:"):
+		rest = strings.TrimPrefix(key, "tolgee:lang:")
+	default:
+		return "", false
+	}
+	tag, mode, hasMode := strings.Cut(rest, ":")
+	if tag == "" || strings.Contains(mode, ":") {
+		return "", false
+	}
+	switch {
+	case !hasMode || mode == "flat" || mode == "false":
+		return translationsCacheKey(tag, false), true
+	case mode == "nested" || mode == "true":
+		return translationsCacheKey(tag, true), true
+	}
+	return "", false
+}
+
+// legacyTargetLang extracts tag and mode back from a unified key.
+func legacyTargetLang(target string) (string, bool) {
+	rest := strings.TrimPrefix(target, "tolgee:lang:")
+	tag, mode, _ := strings.Cut(rest, ":")
+	return tag, mode == "true"
+}
+
+// planLegacyMigration lists every legacy object in Redis and S3.
+func planLegacyMigration(ctx context.Context, s3c *s3Client) ([]legacyItem, error) {
+	var items []legacyItem
+	for _, pattern := range []string{legacyRedisTranslationsNS + "*", "tolgee:lang:*"} {
+		keys, err := redisScanKeys(ctx, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if target, ok := legacyRedisTarget(key); ok {
+				items = append(items, legacyItem{Source: "redis", Key: key, Target: target})
+			}
+		}
+	}
+	if s3c != nil {
+		keys, err := s3c.listKeys(ctx, legacyLocalizationsNS)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if target, ok := legacyLocalizationsTarget(key); ok {
+				items = append(items, legacyItem{Source: "s3", Key: key, Target: target})
+			}
+		}
+	}
+	return items, nil
+}
+
+// importLegacyData copies every legacy snapshot into its unified key, in both
+// Redis and S3, verifying the sha of what was written. A target that already
+// holds a different payload is a conflict and is left alone unless force is
+// set; with dryRun nothing is written. Sources are never deleted.
+func importLegacyData(ctx context.Context, dryRun, force bool) (*legacyMigrationReport, error) {
+	report := &legacyMigrationReport{DryRun: dryRun, Running: true, StartedAt: time.Now().UTC(), Counts: map[string]int{}}
+	legacyMigration.Lock()
+	if legacyMigration.report != nil && legacyMigration.report.Running {
+		legacyMigration.Unlock()
+		return nil, errLegacyMigrationRunning
+	}
+	legacyMigration.report = report
+	legacyMigration.Unlock()
+	defer func() {
+		finished := time.Now().UTC()
+		legacyMigration.Lock()
+		report.Running = false
+		report.FinishedAt = &finished
+		legacyMigration.Unlock()
+	}()
+
+	s3c := s3ClientIfEnabled(ctx)
+	items, err := planLegacyMigration(ctx, s3c)
+	if err != nil {
+		return report, err
+	}
+	legacyMigration.Lock()
+	report.Total = len(items)
+	legacyMigration.Unlock()
+	log.Printf("[legacy] %d legacy objects found dry_run=%t force=%t", len(items), dryRun, force)
+
+	for i, item := range items {
+		item = migrateLegacyItem(ctx, s3c, item, dryRun, force)
+		legacyMigration.Lock()
+		report.Items = append(report.Items, item)
+		report.Counts[item.Status]++
+		report.Done = i + 1
+		legacyMigration.Unlock()
+		if item.Status == legacyStatusFailed || item.Status == legacyStatusConflict {
+			log.Printf("[legacy] %s source=%s key=%q target=%q %s", item.Status, item.Source, item.Key, item.Target, item.Error)
+		}
+		if (i+1)%25 == 0 || i+1 == len(items) {
+			log.Printf("[legacy] progress %d/%d counts=%v", i+1, len(items), report.Counts)
+		}
+	}
+	return report, nil
+}
+
+func migrateLegacyItem(ctx context.Context, s3c *s3Client, item legacyItem, dryRun, force bool) legacyItem {
+	fail := func(err error) legacyItem {
+		item.Status, item.Error = legacyStatusFailed, err.Error()
+		return item
+	}
+	var payload []byte
+	var err error
+	if item.Source == "s3" {
+		payload, err = s3c.getObject(ctx, item.Key)
+	} else {
+		payload, err = redisGet(ctx, item.Key)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	var tree map[string]any
+	if err := decodeJSON(payload, &tree); err != nil {
+		return fail(errors.New("not a JSON object: " + err.Error()))
+	}
+	item.Sha = sha256Hex(payload)
+
+	// the tiers still missing the payload; a different payload is a conflict
+	inRedis, inS3 := false, s3c == nil
+	if current, err := redisGet(ctx, item.Target); err == nil && len(current) > 0 {
+		if sha256Hex(current) != item.Sha && !force {
+			item.Status, item.Error = legacyStatusConflict, "redis target holds a different payload"
+			return item
+		}
+		inRedis = sha256Hex(current) == item.Sha
+	}
+	if s3c != nil {
+		if current, err := s3c.getObject(ctx, item.Target); err == nil && len(current) > 0 {
+			if sha256Hex(current) != item.Sha && !force {
+				item.Status, item.Error = legacyStatusConflict, "s3 target holds a different payload"
+				return item
+			}
+			inS3 = sha256Hex(current) == item.Sha
+		}
+	}
+	if inRedis && inS3 {
+		item.Status = legacyStatusUpToDate
+		return item
+	}
+	if dryRun {
+		item.Status = legacyStatusWouldMigrate
+		return item
+	}
+
+	storeCacheEntry(ctx, s3c, item.Target, payload, "application/json")
+	if written, err := redisGet(ctx, item.Target); err != nil || sha256Hex(written) != item.Sha {
+		return fail(errors.New("redis sha mismatch after write"))
+	}
+	if s3c != nil {
+		if written, err := s3c.getObject(ctx, item.Target); err != nil || sha256Hex(written) != item.Sha {
+			return fail(errors.New("s3 sha mismatch after write"))
+		}
+	}
+	tag, nested := legacyTargetLang(item.Target)
+	recordManifestSnapshot(ctx, s3c, tag, nested, payload)
+	item.Status = legacyStatusMigrated
+	return item
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+var ErrPayloadTooLarge = errors.New("payload exceeds configured size limit")
+
+// checkPayloadSize rejects a payload larger than limit (limit <= 0 disables the check).
+// Every rejection is logged as an alert and counted in payload_rejected.
+func checkPayloadSize(source, key string, size, limit int64) error {
+	if limit <= 0 || size <= limit {
+		return nil
+	}
+	log.Printf("[alert] payload too large source=%s key=%q bytes=%d limit=%d", source, key, size, limit)
+	expPayloadRejected.Add(1)
+	return fmt.Errorf("%w: %s %q (%d > %d bytes)", ErrPayloadTooLarge, source, key, size, limit)
+}
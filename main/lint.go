@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// lintMaxFindings caps the findings kept per language; counts stay exact.
+const lintMaxFindings = 500
+
+const (
+	lintError   = "error"
+	lintWarning = "warning"
+	lintInfo    = "info"
+)
+
+// lintValue is one string of a catalog as seen by the lint rules.
+type lintValue struct {
+	Lang    string
+	Key     string
+	Value   string
+	Base    string
+	HasBase bool
+}
+
+// lintRule returns a message describing the problem, or "" when the value
+// passes.
+type lintRule func(v lintValue) string
+
+// lintRules is the registry of available rules; LINT_RULES picks which ones
+// run and with which severity.
+var lintRules = map[string]lintRule{
+	"max_length":          lintMaxLength,
+	"forbidden_chars":     lintForbiddenChars,
+	"double_spaces":       lintDoubleSpaces,
+	"trailing_whitespace": lintTrailingWhitespace,
+	"equals_base":         lintEqualsBase,
+}
+
+type lintFinding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Key      string `json:"key"`
+	Message  string `json:"message"`
+}
+
+type lintCounts struct {
+	Error   int `json:"error"`
+	Warning int `json:"warning"`
+	Info    int `json:"info"`
+}
+
+type lintReport struct {
+	Lang      string         `json:"lang"`
+	CheckedAt time.Time      `json:"checked_at"`
+	Counts    lintCounts     `json:"counts"`
+	ByRule    map[string]int `json:"by_rule"`
+	Findings  []lintFinding  `json:"findings"`
+	Truncated bool           `json:"truncated,omitempty"`
+}
+
+func lintReportKey(lang string) string {
+	return "tolgee:lint:" + lang
+}
+
+// activeLintRules returns the configured rules with a known name and severity,
+// in name order so findings are stable.
+func activeLintRules() ([]string, map[string]string) {
+	severities := map[string]string{}
+	names := []string{}
+	for name, severity := range localenv.GetLintRules() {
+		severity = strings.ToLower(strings.TrimSpace(severity))
+		if _, ok := lintRules[name]; !ok {
+			continue
+		}
+		switch severity {
+		case lintError, lintWarning, lintInfo:
+			severities[name] = severity
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, severities
+}
+
+// lintCatalog runs the active rules over every string of payload. base holds
+// the base-language values (nil when lang is the base or it is unknown).
+func lintCatalog(lang string, payload []byte, base map[string]string) (*lintReport, error) {
+	keys, values, err := catalogStrings(payload)
+	if err != nil {
+		return nil, err
+	}
+	report := &lintReport{Lang: lang, CheckedAt: time.Now().UTC(), ByRule: map[string]int{}, Findings: []lintFinding{}}
+	names, severities := activeLintRules()
+	for _, key := range keys {
+		v := lintValue{Lang: lang, Key: key, Value: values[key]}
+		v.Base, v.HasBase = base[key]
+		for _, name := range names {
+			msg := lintRules[name](v)
+			if msg == "" {
+				continue
+			}
+			severity := severities[name]
+			switch severity {
+			case lintError:
+				report.Counts.Error++
+			case lintWarning:
+				report.Counts.Warning++
+			default:
+				report.Counts.Info++
+			}
+			report.ByRule[name]++
+			if len(report.Findings) >= lintMaxFindings {
+				report.Truncated = true
+				continue
+			}
+			report.Findings = append(report.Findings, lintFinding{Rule: name, Severity: severity, Key: key, Message: msg})
+		}
+	}
+	return report, nil
+}
+
+// runIngestLint lints a freshly exported catalog, stores the report and
+// publishes the metrics. It never rejects the snapshot.
+func runIngestLint(ctx context.Context, lang string, nested bool, payload []byte, batch map[string][]byte) {
+	names, _ := activeLintRules()
+	if len(names) == 0 {
+		return
+	}
+	report, err := lintCatalog(lang, payload, lintBaseValues(ctx, lang, nested, batch))
+	if err != nil {
+		log.Printf("[lint] lang=%s: %v", lang, err)
+		return
+	}
+	if b, err := json.Marshal(report); err == nil {
+		_ = redisPut(ctx, lintReportKey(lang), b, 0)
+	}
+	recordLintFindings(report)
+	if report.Counts.Error > 0 || report.Counts.Warning > 0 {
+		log.Printf("[lint] lang=%s errors=%d warnings=%d info=%d", lang, report.Counts.Error, report.Counts.Warning, report.Counts.Info)
+	}
+}
+
+// lintBaseValues returns the base-language strings, taken from the batch
+// being refreshed when it contains the base language, else from Redis.
+func lintBaseValues(ctx context.Context, lang string, nested bool, batch map[string][]byte) map[string]string {
+	base := baseLanguageTag(ctx)
+	if base == "" || base == lang {
+		return nil
+	}
+	payload, ok := batch[base]
+	if !ok {
+		stored, err := redisGet(ctx, translationsCacheKey(base, nested))
+		if err != nil || len(stored) == 0 {
+			return nil
+		}
+		payload = stored
+	}
+	_, values, err := catalogStrings(payload)
+	if err != nil {
+		return nil
+	}
+	return values
+}
+
+func loadLintReport(ctx context.Context, lang string) (*lintReport, error) {
+	b, err := redisGet(ctx, lintReportKey(lang))
+	if err != nil || len(b) == 0 {
+		return nil, err
+	}
+	var report lintReport
+	if err := json.Unmarshal(b, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// lintSummaries returns the counts of every stored report, per language.
+func lintSummaries(ctx context.Context) map[string]lintCounts {
+	out := map[string]lintCounts{}
+	keys, err := redisScanKeys(ctx, lintReportKey("*"))
+	if err != nil {
+		return out
+	}
+	for _, key := range keys {
+		lang := strings.TrimPrefix(key, lintReportKey(""))
+		if report, err := loadLintReport(ctx, lang); err == nil && report != nil {
+			out[lang] = report.Counts
+		}
+	}
+	return out
+}
+
+func lintMaxLength(v lintValue) string {
+	limit, prefix := lengthBudget(v.Key)
+	if limit == 0 {
+		return ""
+	}
+	if n := utf8.RuneCountInString(v.Value); n > limit {
+		return fmt.Sprintf("length %d exceeds %d (prefix %q)", n, limit, prefix)
+	}
+	return ""
+}
+
+func lintForbiddenChars(v lintValue) string {
+	forbidden := localenv.GetLintForbiddenChars()
+	if forbidden == "" {
+		return ""
+	}
+	if i := strings.IndexAny(v.Value, forbidden); i >= 0 {
+		r, _ := utf8.DecodeRuneInString(v.Value[i:])
+		return fmt.Sprintf("contains forbidden character %q", r)
+	}
+	return ""
+}
+
+func lintDoubleSpaces(v lintValue) string {
+	if strings.Contains(v.Value, "  ") {
+		return "contains consecutive spaces"
+	}
+	return ""
+}
+
+func lintTrailingWhitespace(v lintValue) string {
+	if v.Value != strings.TrimFunc(v.Value, unicode.IsSpace) {
+		return "leading or trailing whitespace"
+	}
+	return ""
+}
+
+// lintEqualsBase flags values identical to the base language, a common sign
+// of a string pasted in untranslated. Values without letters are skipped.
+func lintEqualsBase(v lintValue) string {
+	if !v.HasBase || v.Value != v.Base || strings.IndexFunc(v.Value, unicode.IsLetter) < 0 {
+		return ""
+	}
+	return "identical to the base language"
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// localeDataJSON is a CLDR-derived subset (numbers, currency, date patterns,
+// week data) for the languages we ship, so clients need not bundle full CLDR.
+//
+//go:embed cldr/locale_data.json
+var localeDataJSON []byte
+
+type localeData struct {
+	Decimal  string `json:"decimal"`
+	Group    string `json:"group"`
+	Currency struct {
+		Code    string `json:"code"`
+		Symbol  string `json:"symbol"`
+		Pattern string `json:"pattern"`
+	} `json:"currency"`
+	Date struct {
+		Short  string `json:"short"`
+		Medium string `json:"medium"`
+		Long   string `json:"long"`
+	} `json:"date"`
+	Time struct {
+		Short string `json:"short"`
+	} `json:"time"`
+	FirstDayOfWeek string `json:"first_day_of_week"`
+}
+
+var (
+	errNoLocaleData = errors.New("no locale data for language")
+
+	localeDataTable = mustLoadLocaleData()
+)
+
+func mustLoadLocaleData() map[string]localeData {
+	table := map[string]localeData{}
+	if err := json.Unmarshal(localeDataJSON, &table); err != nil {
+		panic("cldr/locale_data.json: " + err.Error())
+	}
+	return table
+}
+
+// resolveLocaleData looks up a tag, falling back to its base language
+// (de-LI -> de). It returns the tag the data belongs to.
+func resolveLocaleData(lang string) (string, localeData, error) {
+	if d, ok := localeDataTable[lang]; ok {
+		return lang, d, nil
+	}
+	base, _, _ := strings.Cut(lang, "-")
+	if d, ok := localeDataTable[base]; ok {
+		return base, d, nil
+	}
+	return "", localeData{}, errNoLocaleData
+}
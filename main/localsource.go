@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/goccy/go-json"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// localReloadDelay batches the events of one save (editors often write a
+// temp file, rename it and touch it again) into a single refresh.
+const localReloadDelay = 300 * time.Millisecond
+
+var errLocalSourceUnsupported = errors.New("not available with a local translation source")
+
+// localSourceDir returns the directory of SOURCE=local:/path. In that mode
+// the Tolgee client reads <dir>/<lang>.json instead of calling Tolgee, and
+// everything above it (refresh, Redis/S3 tiers, variants, serving) is unchanged.
+// SOURCE=git:<url> reads the same layout from its clone.
+func localSourceDir() (string, bool) {
+	if _, ok := gitSourceURL(); ok {
+		return gitSourceCatalogDir(), true
+	}
+	dir, ok := strings.CutPrefix(localenv.GetSource(), "local:")
+	return dir, ok && dir != ""
+}
+
+// localLanguageTags lists the languages of the directory, one per JSON file.
+func localLanguageTags(dir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]string, 0, len(matches))
+	for _, m := range matches {
+		tags = append(tags, strings.TrimSuffix(filepath.Base(m), ".json"))
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// localLanguages builds the Tolgee /languages payload for the directory;
+// the default fallback language is marked as base.
+func localLanguages(dir string) (*TolgeeModel, []byte, error) {
+	tags, err := localLanguageTags(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	model := &TolgeeModel{}
+	for i, tag := range tags {
+		model.Embedded.Languages = append(model.Embedded.Languages, struct {
+			Id           int    `json:"id"`
+			Name         string `json:"name"`
+			Tag          string `json:"tag"`
+			OriginalName string `json:"originalName"`
+			FlagEmoji    string `json:"flagEmoji"`
+			Base         bool   `json:"base"`
+		}{Id: i + 1, Name: tag, Tag: tag, OriginalName: tag, Base: tag == defaultFallbackLanguage})
+	}
+	model.Page.Size = len(tags)
+	model.Page.TotalElements = len(tags)
+	model.Page.TotalPages = 1
+	b, err := json.Marshal(model)
+	return model, b, err
+}
+
+// localExport mirrors the Tolgee export for the ", "-separated languages:
+// files may be nested or use dotted keys, and are normalized to the shape
+// Tolgee would return (nested objects, or flat "a.b" keys).
+func localExport(dir, langs string, nested bool, filters map[string]string) (map[string][]byte, error) {
+	if len(filters) > 0 {
+		return nil, errLocalSourceUnsupported
+	}
+	files := map[string][]byte{}
+	for _, lang := range strings.Split(langs, ",") {
+		lang = strings.TrimSpace(lang)
+		if lang == "" {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join(dir, filepath.Base(lang)+".json"))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		payload, err := normalizeLocalCatalog(raw, nested)
+		if err != nil {
+			return nil, fmt.Errorf("%s.json: %w", lang, err)
+		}
+		files[lang] = payload
+	}
+	return files, nil
+}
+
+func normalizeLocalCatalog(raw []byte, nested bool) ([]byte, error) {
+	flat, err := flattenTranslations(raw, ".")
+	if err != nil || !nested {
+		return flat, err
+	}
+	var keys map[string]any
+	if err := decodeJSON(flat, &keys); err != nil {
+		return nil, err
+	}
+	tree := map[string]any{}
+	for key, value := range keys {
+		setCatalogValue(tree, key, true, "", value)
+	}
+	return marshalJSON(tree)
+}
+
+// startLocalSourceWatcher refreshes the changed languages whenever a file of
+// the local source directory is written; adding or removing a file runs a
+// full refresh so the language list follows.
+func startLocalSourceWatcher() {
+	dir, ok := localSourceDir()
+	if _, git := gitSourceURL(); !ok || git {
+		return
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("[local] watcher error: %v", err)
+		return
+	}
+	if err := watcher.Add(dir); err != nil {
+		log.Printf("[local] watch %s error: %v", dir, err)
+		_ = watcher.Close()
+		return
+	}
+	log.Printf("[local] serving translations from %s (hot reload on)", dir)
+
+	var (
+		mu      sync.Mutex
+		changed = map[string]bool{}
+		full    bool
+		timer   *time.Timer
+	)
+	flush := func() {
+		mu.Lock()
+		langs := make([]string, 0, len(changed))
+		for lang := range changed {
+			langs = append(langs, lang)
+		}
+		scope := &refreshScope{Languages: langs}
+		if full {
+			scope = nil
+		}
+		changed, full = map[string]bool{}, false
+		mu.Unlock()
+		log.Printf("[local] reload langs=%v full=%t", langs, scope == nil)
+		enqueueRefreshJob(context.Background(), "local", scope)
+	}
+	go func() {
+		for {
+			select {
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				lang, isJSON := strings.CutSuffix(filepath.Base(ev.Name), ".json")
+				if !isJSON || strings.HasPrefix(lang, ".") {
+					continue
+				}
+				mu.Lock()
+				changed[lang] = true
+				if ev.Op&(fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+					full = true
+				}
+				if timer == nil {
+					timer = time.AfterFunc(localReloadDelay, flush)
+				} else {
+					timer.Reset(localReloadDelay)
+				}
+				mu.Unlock()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("[local] watcher error: %v", err)
+			}
+		}
+	}()
+}
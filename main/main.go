@@ -2,13 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/goccy/go-json"
 	"github.com/gofiber/fiber/v2"
+	fiberexpvar "github.com/gofiber/fiber/v2/middleware/expvar"
+	"github.com/gofiber/fiber/v2/middleware/pprof"
 
 	localenv "mensalocalizations/tools/env"
 )
@@ -17,91 +24,1639 @@ import (
 
 func main() {
 	appKey := localenv.GetTolgeeAppKey()
-	if appKey == "" {
+	if appKey == "" && !tolgeeOffline() {
 		log.Fatal("TOLGEE_APP_KEY is required")
 	}
+	if err := validateConfiguredApps(); err != nil {
+		log.Fatalf("[apps] %v", err)
+	}
+
+	// "migrate" runs the S3 layout migrations and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		report, err := runStorageMigrations(context.Background(), len(os.Args) > 2 && os.Args[2] == "--force")
+		if err != nil {
+			log.Fatalf("[storage] migrate: %v", err)
+		}
+		log.Printf("[storage] migrate from=%d to=%d migrated=%v skipped=%t", report.FromVersion, report.ToVersion, report.Migrated, report.Skipped)
+		return
+	}
+
+	// "migrate-legacy [--dry-run] [--force]" imports the pre-unified key
+	// formats and exits; exit status 1 on failures or conflicts
+	if len(os.Args) > 1 && os.Args[1] == "migrate-legacy" {
+		dryRun, force := false, false
+		for _, arg := range os.Args[2:] {
+			dryRun = dryRun || arg == "--dry-run"
+			force = force || arg == "--force"
+		}
+		report, err := importLegacyData(context.Background(), dryRun, force)
+		if err != nil {
+			log.Fatalf("[legacy] %v", err)
+		}
+		log.Printf("[legacy] done total=%d counts=%v dry_run=%t", report.Total, report.Counts, report.DryRun)
+		if report.Counts[legacyStatusFailed] > 0 || report.Counts[legacyStatusConflict] > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "verify" reports drift between Redis, S3 and Tolgee; exit status 1 on drift
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		report, err := runVerification(context.Background())
+		if err != nil {
+			log.Fatalf("[verify] %v", err)
+		}
+		for _, check := range report.Checks {
+			if check.Status == "drift" {
+				log.Printf("[verify] drift lang=%s mode=%s tiers=%v redis=%.12s s3=%.12s tolgee=%.12s", check.Lang, check.Mode, check.Drift, check.RedisSha, check.S3Sha, check.TolgeeSha)
+			}
+		}
+		log.Printf("[verify] in_sync=%d drifted=%d", report.InSync, report.Drifted)
+		if report.Drifted > 0 {
+			os.Exit(1)
+		}
+		return
+	}
 
+	watchS3Credentials()
 	if !fiber.IsChild() {
-		RebuildTheCache()
+		if localenv.GetStorageMigrateOnStart() {
+			if _, err := runStorageMigrations(context.Background(), false); err != nil {
+				log.Printf("[storage] migrate error: %v", err)
+			}
+		}
+		initGitSource()
+		if _, err := rehydrateFromS3(context.Background(), false); err != nil {
+			log.Printf("[rehydrate] error: %v", err)
+		}
+		if localenv.GetPromotedOnly() {
+			log.Printf("[warmup] skipped: PROMOTED_ONLY")
+		} else if isReadOnly(context.Background()) {
+			log.Printf("[warmup] skipped: %v", errReadOnly)
+		} else {
+			runWarmup()
+		}
+		startRepairSchedule()
+		startRetryLoop()
+		startPatchPolling()
+		startLocalSourceWatcher()
+		startGitSourcePolling()
+		startBlobGC()
+	}
+	cacheReady.Store(true)
+
+	app := newFiberApp()
+
+	// Management routes live on ADMIN_LISTEN_ADDR when set, so the public
+	// listener never exposes them; otherwise they share :3000.
+	mgmt := app
+	if addr := localenv.GetAdminListenAddr(); addr != "" {
+		mgmt = newFiberApp()
+		for _, path := range []string{"/api/admin", "/api/admin/*", "/metrics", "/debug", "/debug/*"} {
+			app.All(path, func(c *fiber.Ctx) error {
+				return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "not found"})
+			})
+		}
+		go listenAndServe(mgmt, addr)
+	}
+
+	// Profiling and runtime vars, admin token required
+	mgmt.Use("/debug", requireAdmin())
+	mgmt.Use(pprof.New())
+	mgmt.Use(fiberexpvar.New())
+	mgmt.Get("/metrics", requireAdmin(), makeMetricsHandler())
+
+	admin := mgmt.Group("/api/admin", requireAdmin())
+	admin.Get("/refresh", makeAdminRefreshStateHandler())
+	admin.Get("/read-only", makeAdminReadOnlyHandler())
+	admin.Put("/read-only", makeAdminPutReadOnlyHandler())
+	admin.Post("/promote", idempotent(), makeAdminPromoteHandler())
+	admin.Post("/ops", idempotent(), makeAdminOpsHandler())
+	admin.Get("/ops/:id", makeAdminOpsStatusHandler())
+	admin.Post("/rehydrate", makeAdminRehydrateHandler())
+	admin.Post("/storage/migrate", makeAdminMigrateHandler())
+	admin.Post("/storage/import-legacy", makeAdminImportLegacyHandler())
+	admin.Get("/storage/import-legacy", makeAdminImportLegacyStatusHandler())
+	admin.Get("/overrides", makeAdminOverridesHandler())
+	admin.Put("/overrides", makeAdminPutOverrideHandler())
+	admin.Delete("/overrides", makeAdminDeleteOverrideHandler())
+	admin.Get("/overrides/audit", makeAdminOverridesAuditHandler())
+	admin.Get("/config", makeAdminConfigHandler())
+	admin.Put("/config", makeAdminPutConfigHandler())
+	admin.Get("/config/audit", makeAdminConfigAuditHandler())
+	admin.Get("/schedules", makeAdminSchedulesHandler())
+	admin.Put("/schedules", makeAdminPutScheduleHandler())
+	admin.Delete("/schedules", makeAdminDeleteScheduleHandler())
+	admin.Get("/deprecated", makeAdminDeprecatedHandler())
+	admin.Put("/deprecated", makeAdminPutDeprecatedHandler())
+	admin.Delete("/deprecated", makeAdminDeleteDeprecatedHandler())
+	admin.Get("/patches", makeAdminPatchesHandler())
+	admin.Post("/patches/reload", makeAdminReloadPatchesHandler())
+	admin.Get("/key-aliases", makeAdminKeyAliasesHandler())
+	admin.Put("/key-aliases", makeAdminPutKeyAliasHandler())
+	admin.Delete("/key-aliases", makeAdminDeleteKeyAliasHandler())
+	admin.Post("/key-aliases/sync", makeAdminSyncKeyAliasesHandler())
+	admin.Get("/schemas", makeAdminSchemasHandler())
+	admin.Put("/schemas/:scope", makeAdminPutSchemaHandler())
+	admin.Delete("/schemas/:scope", makeAdminDeleteSchemaHandler())
+	admin.Get("/screens", makeAdminScreensHandler())
+	admin.Put("/screens/:name", makeAdminPutScreenHandler())
+	admin.Delete("/screens/:name", makeAdminDeleteScreenHandler())
+	admin.Get("/stats", makeAdminStatsHandler())
+	admin.Get("/demand", makeAdminDemandHandler())
+	admin.Get("/coverage", makeAdminCoverageHandler())
+	admin.Get("/git-export", makeAdminGitExportHandler())
+	admin.Get("/apps", makeAdminAppsHandler())
+	admin.Put("/apps/:app", makeAdminPutAppHandler())
+	admin.Delete("/apps/:app", makeAdminDeleteAppHandler())
+	admin.Post("/apps/:app/refresh", makeAdminRefreshAppHandler())
+	admin.Post("/blobs/gc", makeAdminBlobGCHandler())
+	admin.Get("/redis/shards", makeAdminRedisShardsHandler())
+	admin.Get("/lint", makeAdminLintHandler())
+	admin.Get("/lint/:lang", makeAdminLintReportHandler())
+	admin.Get("/required-keys", makeAdminRequiredKeysHandler())
+	admin.Put("/required-keys", makeAdminPutRequiredKeysHandler())
+	admin.Delete("/required-keys", makeAdminDeleteRequiredKeysHandler())
+	admin.Post("/verify", makeAdminVerifyHandler())
+	admin.Post("/repair", makeAdminRepairHandler())
+	admin.Post("/diagnostics", makeAdminDiagnosticsHandler())
+	admin.Get("/journal", makeAdminJournalHandler())
+	admin.Post("/journal/replay", idempotent(), makeAdminJournalReplayHandler())
+
+	app.Get("/robots.txt", makeRobotsHandler())
+	app.Get("/favicon.ico", makeFaviconHandler())
+	app.Get("/.well-known/health", makeWellKnownHealthHandler())
+	app.Get("/api/healthz", makeHealthHandler())
+	app.Get("/api/readyz", makeReadyHandler())
+	app.Get("/api/warmup/status", makeWarmupStatusHandler())
+	app.Get("/api/update/status", requireAdmin(), makeUpdateJobsHandler())
+	app.Get("/api/update/history", requireAdmin(), makeUpdateHistoryHandler())
+	app.Get("/api/update/status/:id", requireAdmin(), makeUpdateStatusHandler())
+	app.All("/api/update", requireAdminOrTolgeeSignature(), idempotent(), makeUpdateHandler())
+	app.Post("/api/git/webhook", makeGitWebhookHandler())
+	app.Get("/api/languages", makeLanguagesHandler())
+	app.Get("/api/namespaces", makeNamespacesHandler())
+	app.Get("/api/tags", makeTagsHandler())
+	app.Get("/api/tolgee/*", makeTolgeeProxyHandler())
+	app.Get("/api/stats", makeProjectStatsHandler())
+	app.Get("/api/matrix", makeMatrixHandler())
+	app.Post("/api/sync", makeSyncHandler())
+	app.Post("/api/freshness", makeFreshnessHandler())
+	app.Post("/api/transliterate", makeTransliterateHandler())
+	app.Get("/api/manifest", makeVersionManifestHandler())
+	app.Get("/api/cache-policy", makeCachePolicyHandler())
+	app.Get("/api/v/:sha/:lang", makeVersionedTranslationsHandler())
+	app.Get("/api/catalog.proto", makeCatalogProtoHandler())
+	app.Get("/api/group/:name", makeGroupHandler())
+	app.Get("/api/branches", makeBranchesHandler())
+	app.Get("/api/branch/:name/:lang", makeBranchTranslationsHandler())
+	app.Get("/api/:lang.mjs", makeESModuleHandler())
+	app.Get("/api/:lang/integrity", makeIntegrityHandler())
+	app.Get("/api/:lang/keys", makeKeysHandler())
+	app.Get("/api/screenshots/:id", makeScreenshotHandler())
+	app.Get("/api/:lang/key/:key", makeKeyHandler())
+	app.Get("/api/:lang/screen/:screen", makeScreenHandler())
+	app.Get("/api/:lang/locale-data", makeLocaleDataHandler())
+	app.Get("/api/:lang/plural-rules", makePluralRulesHandler())
+	app.Get("/api/:lang/collate", makeCollateHandler())
+	app.Get("/api/:lang", makeTranslationsHandler())
+	// tenant apps: after every fixed two-segment route, see reservedAppNames
+	app.Get("/api/:app/languages", makeAppLanguagesHandler())
+	app.Get("/api/:app/:lang", makeAppTranslationsHandler())
+
+	// Catch-all 404: return inferred language (Accept-Language, GeoIP, en) payload
+	app.All("*", makeFallbackHandler())
+
+	go listenAndServe(app, ":3000")
+	// returns once every listener has drained
+	waitForShutdown(app, mgmt)
+}
+
+// listenAndServe serves a until it is shut down. Listen returns nil as soon
+// as ShutdownWithContext closes the listener, while connections are still
+// draining: only a real error (e.g. the port is taken) is fatal.
+func listenAndServe(a *fiber.App, addr string) {
+	if err := a.Listen(addr); err != nil {
+		log.Fatalf("[http] listen %s: %v", addr, err)
+	}
+}
+
+// shutdownTimeout bounds the diagnostic snapshot plus the listener drain.
+const shutdownTimeout = 10 * time.Second
+
+// waitForShutdown blocks until SIGINT/SIGTERM, stores a diagnostic snapshot
+// (DIAGNOSTICS_ON_SHUTDOWN) and drains the listeners.
+func waitForShutdown(apps ...*fiber.App) {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	sig := <-quit
+	log.Printf("[shutdown] signal=%s", sig)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if localenv.GetDiagnosticsOnShutdown() {
+		// half of the budget at most: in-flight requests still need to drain
+		snapCtx, snapCancel := context.WithTimeout(ctx, shutdownTimeout/2)
+		if _, err := takeDiagnosticSnapshot(snapCtx, "shutdown"); err != nil {
+			log.Printf("[diagnostics] shutdown snapshot error: %v", err)
+		}
+		snapCancel()
+	}
+	for i, a := range apps {
+		if i > 0 && a == apps[0] {
+			continue
+		}
+		if err := a.ShutdownWithContext(ctx); err != nil {
+			log.Printf("[shutdown] error: %v", err)
+		}
 	}
+}
 
+// newFiberApp builds a listener with the shared JSON codec and Server-Timing.
+func newFiberApp() *fiber.App {
 	app := fiber.New(fiber.Config{
 		JSONEncoder: json.Marshal,
 		JSONDecoder: json.Unmarshal,
+		// c.IP() is the peer address unless it is a trusted proxy
+		ProxyHeader:             localenv.GetProxyHeader(),
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          localenv.GetTrustedProxies(),
+		EnableIPValidation:      true,
 	})
-
 	app.Use(func(c *fiber.Ctx) error {
 		start := time.Now()
+		ctx, timings := withStageTimings(c.UserContext(), "request")
+		c.SetUserContext(ctx)
 		err := c.Next()
 		duration := time.Since(start)
-		c.Append("Server-Timing", "app;dur="+strconv.FormatInt(duration.Milliseconds(), 10)+"ms")
+		if c.QueryBool("debug_timing") {
+			c.Append("Server-Timing", timings.serverTiming(duration))
+		} else {
+			c.Append("Server-Timing", "app;dur="+strconv.FormatInt(duration.Milliseconds(), 10)+"ms")
+		}
 		return err
 	})
-
-	app.Get("/api/healthz", makeHealthHandler())
-	app.All("/api/update", makeUpdateHandler())
-	app.Get("/api/languages", makeLanguagesHandler())
-	app.Get("/api/:lang", makeTranslationsHandler())
-
-	// Catch-all 404: return inferred language (or en) payload
-	app.All("*", makeFallbackHandler())
-
-	log.Fatal(app.Listen(":3000"))
+	return app
 }
 
 // --- Handlers ---
 
 func makeHealthHandler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		// failed languages still serve their previous snapshot: report, don't fail
+		if n, err := rdb.ZCard(context.Background(), retryQueueKey).Result(); err == nil {
+			c.Set("X-Pending-Retries", strconv.FormatInt(n, 10))
+		}
 		return c.Status(http.StatusOK).SendString("ok")
 	}
 }
 
+func makeReadyHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !cacheReady.Load() {
+			return c.Status(http.StatusServiceUnavailable).SendString("rehydrating")
+		}
+		return c.Status(http.StatusOK).SendString("ready")
+	}
+}
+
+func makeWarmupStatusHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(getWarmupProgress())
+	}
+}
+
+func makeMetricsHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set("Content-type", "text/plain; version=0.0.4; charset=utf-8")
+		writePrometheus(c)
+		return nil
+	}
+}
+
 func makeUpdateHandler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		secret := localenv.GetWebhookSecret()
-		header := c.Get("Tolgee-Signature")
+		// requireAdminOrTolgeeSignature already let only these two callers in
+		if hasAdminToken(c) {
+			return handleManualUpdate(c)
+		}
 		body := c.Body()
-		if !verifyTolgeeSignature(secret, header, body) {
-			log.Printf("[webhook] reject: invalid signature")
+		if localenv.GetPromotedOnly() {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "refresh disabled: PROMOTED_ONLY mode serves promoted snapshots only"})
+		}
+		// fiber reuses the body buffer: hooks run after the response
+		ev := parseTolgeeWebhook(append([]byte(nil), body...))
+		if state := loadReadOnly(context.Background()); state.Enabled {
+			// Tolgee must not retry: the refresh runs when the freeze is lifted
+			deferRefresh(context.Background(), "webhook:"+string(ev.Type))
+			go dispatchWebhookEvent(context.Background(), ev)
+			return c.Status(http.StatusAccepted).JSON(fiber.Map{"deferred": true, "read_only": state})
+		}
+		job := enqueueRefreshJob(context.Background(), "webhook:"+string(ev.Type), nil)
+		go purgeBranchCaches(context.Background())
+		go dispatchWebhookEvent(context.Background(), ev)
+		return c.Status(http.StatusAccepted).JSON(job)
+	}
+}
+
+// makeGitWebhookHandler pulls the source repository on a signed push
+// webhook and queues the refresh of the changed languages.
+func makeGitWebhookHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := gitSourceURL(); !ok {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": errGitSourceDisabled.Error()})
+		}
+		if !verifyGitWebhook(localenv.GetGitSourceWebhookSecret(), func(name string) string { return c.Get(name) }, c.Body()) {
+			log.Printf("[git] webhook reject: invalid signature")
 			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "invalid webhook signature"})
 		}
-		RebuildTheCache()
-		return c.SendStatus(http.StatusOK)
+		if isReadOnly(context.Background()) {
+			deferRefresh(context.Background(), "git:webhook")
+			return c.Status(http.StatusAccepted).JSON(fiber.Map{"deferred": true})
+		}
+		go func() {
+			if err := pollGitSource(context.Background(), "git:webhook"); err != nil {
+				log.Printf("[git] webhook pull error: %v", err)
+			}
+		}()
+		return c.Status(http.StatusAccepted).JSON(fiber.Map{"accepted": true})
 	}
 }
 
-func makeLanguagesHandler() fiber.Handler {
+// handleManualUpdate queues a refresh for an admin caller, limited to the
+// optional {languages, modes, namespaces} body.
+func handleManualUpdate(c *fiber.Ctx) error {
+	if localenv.GetPromotedOnly() {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "refresh disabled: PROMOTED_ONLY mode serves promoted snapshots only"})
+	}
+	if isReadOnly(context.Background()) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": errReadOnly.Error()})
+	}
+	scope := &refreshScope{}
+	if len(c.Body()) > 0 {
+		if err := json.Unmarshal(c.Body(), scope); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": errInvalidRefreshScope.Error()})
+		}
+	}
+	if err := scope.validate(); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	job := enqueueRefreshJob(context.Background(), "manual", scope)
+	return c.Status(http.StatusAccepted).JSON(job)
+}
+
+func makeUpdateStatusHandler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		cache, err := GetLanguagesFromCache(context.Background())
+		job := getRefreshJob(context.Background(), c.Params("id"))
+		if job == nil {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "job not found"})
+		}
+		return c.Status(http.StatusOK).JSON(job)
+	}
+}
+
+func makeUpdateJobsHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(listRefreshJobs(context.Background()))
+	}
+}
+
+func makeUpdateHistoryHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(listUpdateHistory(context.Background(), parseHistoryLimit(c)))
+	}
+}
+
+func makeAdminRefreshStateHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(getRefreshState(context.Background()))
+	}
+}
+
+func makeAdminReadOnlyHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(loadReadOnly(context.Background()))
+	}
+}
+
+func makeAdminPutReadOnlyHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req struct {
+			Enabled *bool  `json:"enabled"`
+			Reason  string `json:"reason"`
+		}
+		if err := c.BodyParser(&req); err != nil || req.Enabled == nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "body must be {enabled, reason?}"})
+		}
+		state, err := setReadOnly(context.Background(), *req.Enabled, req.Reason, c.Get("X-Admin-Actor"))
 		if err != nil {
 			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 		}
-		return c.Status(http.StatusOK).Send(cache)
+		return c.Status(http.StatusOK).JSON(state)
 	}
 }
 
-func makeTranslationsHandler() fiber.Handler {
+func makeAdminPromoteHandler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		nested := c.Query("nested") == "true"
-		lang := c.Params("lang")
-		cache, err := GetTranslationsFromCache(context.Background(), lang, nested)
+		if isReadOnly(context.Background()) {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{"error": errReadOnly.Error()})
+		}
+		var req promoteRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "body must be {languages?: [...]}"})
+			}
+		}
+		res, err := promoteSnapshots(context.Background(), req)
 		if err != nil {
-			return err
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 		}
-		c.Set("Content-type", "application/json; charset=utf-8")
-		return c.Status(http.StatusOK).Send(cache)
+		return c.Status(http.StatusOK).JSON(res)
 	}
 }
 
-func makeFallbackHandler() fiber.Handler {
+// makeAdminOpsHandler runs a runbook as one call: the ops are validated up
+// front and executed in order, stopping at the first failure, with a
+// combined report (200 when every op succeeded, 207 otherwise). Ops applied
+// before a failure are not rolled back.
+func makeAdminOpsHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req adminOpsRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "body must be {ops: [{op, ...}], continue_on_error?}"})
+		}
+		if err := validateAdminOps(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		report, err := startAdminOps(context.Background(), req)
+		switch {
+		case errors.Is(err, errAdminOpsBusy), errors.Is(err, errAdminOpsRejected):
+			return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		case err != nil:
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		c.Location(report.StatusURL)
+		return c.Status(http.StatusAccepted).JSON(report)
+	}
+}
+
+func makeAdminOpsStatusHandler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		nested := c.Query("nested") == "true"
-		cache, err := GetTranslationsFromCache(context.Background(), "en", nested)
+		report := getAdminOpsReport(context.Background(), c.Params("id"))
+		if report == nil {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "ops batch not found"})
+		}
+		return c.Status(http.StatusOK).JSON(report)
+	}
+}
+
+func makeAdminRehydrateHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report, err := rehydrateFromS3(context.Background(), c.QueryBool("force", false))
 		if err != nil {
-			return err
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 		}
-		c.Set("Content-type", "application/json; charset=utf-8")
-		return c.Status(http.StatusOK).Send(cache)
+		return c.Status(http.StatusOK).JSON(report)
+	}
+}
+
+func makeAdminOverridesHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(activeOverrides(context.Background(), c.Query("lang")))
+	}
+}
+
+func makeAdminPutOverrideHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req overrideRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "body must be {lang, key, value, reason?, ttl?}"})
+		}
+		o, err := putOverride(context.Background(), req, c.Get("X-Admin-Actor"))
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(http.StatusOK).JSON(o)
+	}
+}
+
+func makeAdminDeleteOverrideHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := deleteOverride(context.Background(), c.Query("lang"), c.Query("key"), c.Get("X-Admin-Actor"))
+		if errors.Is(err, errOverrideMissing) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.SendStatus(http.StatusNoContent)
+	}
+}
+
+func makeAdminOverridesAuditHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(listOverrideAudit(context.Background(), int64(c.QueryInt("count", 100))))
+	}
+}
+
+func makeAdminVerifyHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report, err := runVerification(context.Background())
+		if err != nil {
+			return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(http.StatusOK).JSON(report)
+	}
+}
+
+func makeAdminRepairHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if isReadOnly(context.Background()) {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{"error": errReadOnly.Error()})
+		}
+		report, err := runRepair(context.Background())
+		if err != nil {
+			return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(http.StatusOK).JSON(report)
+	}
+}
+
+func makeAdminDiagnosticsHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		snap, err := takeDiagnosticSnapshot(context.Background(), "admin")
+		if err != nil {
+			return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(http.StatusOK).JSON(snap)
+	}
+}
+
+func makeAdminConfigHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(effectiveRuntimeConfig())
+	}
+}
+
+func makeAdminPutConfigHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var next runtimeConfig
+		if err := c.BodyParser(&next); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "body must be {priority_languages?, aliases?, fallback_chains?, ttl_overrides?}"})
+		}
+		cfg, err := putRuntimeConfig(context.Background(), next, c.Get("X-Admin-Actor"))
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(http.StatusOK).JSON(cfg)
+	}
+}
+
+func makeAdminConfigAuditHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(listRuntimeConfigAudit(context.Background(), int64(c.QueryInt("count", 100))))
+	}
+}
+
+func makeAdminSchedulesHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(loadKeySchedules(context.Background()))
+	}
+}
+
+func makeAdminPutScheduleHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var s keySchedule
+		if err := c.BodyParser(&s); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "body must be {lang?, key, valid_from?, valid_until?, fallback_key?, reason?}"})
+		}
+		err := putKeySchedule(context.Background(), s)
+		if errors.Is(err, errInvalidSchedule) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(http.StatusOK).JSON(s)
+	}
+}
+
+func makeAdminDeleteScheduleHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := deleteKeySchedule(context.Background(), c.Query("lang"), c.Query("key"))
+		if errors.Is(err, errScheduleMissing) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.SendStatus(http.StatusNoContent)
+	}
+}
+
+func makeAdminDeprecatedHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(listDeprecatedKeys(context.Background()))
+	}
+}
+
+func makeAdminPutDeprecatedHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var d deprecatedKey
+		if err := c.BodyParser(&d); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "body must be {key, reason?, replacement?}"})
+		}
+		if err := putDeprecatedKey(context.Background(), d); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.SendStatus(http.StatusNoContent)
+	}
+}
+
+func makeAdminDeleteDeprecatedHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := deleteDeprecatedKey(context.Background(), c.Query("key")); err != nil {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.SendStatus(http.StatusNoContent)
+	}
+}
+
+func makeAdminPatchesHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(listActivePatches(context.Background()))
+	}
+}
+
+// makeAdminReloadPatchesHandler rescans the patch files now instead of
+// waiting for PATCHES_POLL_INTERVAL.
+func makeAdminReloadPatchesHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		patches, err := scanPatches(context.Background())
+		switch {
+		case errors.Is(err, errPatchesNoS3):
+			return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, errPatchesPartial):
+			return c.Status(http.StatusMultiStatus).JSON(fiber.Map{"error": err.Error(), "patches": patches})
+		case err != nil:
+			return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(http.StatusOK).JSON(patches)
+	}
+}
+
+func makeAdminKeyAliasesHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(listKeyAliases(context.Background()))
+	}
+}
+
+func makeAdminPutKeyAliasHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var a keyAlias
+		if err := c.BodyParser(&a); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "body must be {from, to, reason?}"})
+		}
+		if err := putKeyAlias(context.Background(), a); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.SendStatus(http.StatusNoContent)
+	}
+}
+
+func makeAdminDeleteKeyAliasHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := deleteKeyAlias(context.Background(), c.Query("from")); err != nil {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.SendStatus(http.StatusNoContent)
+	}
+}
+
+// makeAdminSyncKeyAliasesHandler derives aliases from the Tolgee key rename
+// history on demand.
+func makeAdminSyncKeyAliasesHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		changed, err := syncKeyAliasesFromTolgee(context.Background(), localenv.GetTolgeeAppKey())
+		if err != nil {
+			return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(http.StatusOK).JSON(fiber.Map{"changed": changed})
+	}
+}
+
+func makeAdminSchemasHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(loadSchemas(context.Background()))
+	}
+}
+
+func makeAdminPutSchemaHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		body := append(json.RawMessage(nil), c.Body()...)
+		if len(body) == 0 {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": errInvalidSchema.Error()})
+		}
+		if _, err := putSchema(context.Background(), c.Params("scope"), body); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.SendStatus(http.StatusNoContent)
+	}
+}
+
+func makeAdminDeleteSchemaHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		existed, err := putSchema(context.Background(), c.Params("scope"), nil)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		if !existed {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": errUnknownSchema.Error()})
+		}
+		return c.SendStatus(http.StatusNoContent)
+	}
+}
+
+func makeAdminScreensHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(listScreens(context.Background()))
+	}
+}
+
+func makeAdminPutScreenHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Prefixes []string `json:"prefixes"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.Prefixes == nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "body must be {prefixes: [...]}"})
+		}
+		if _, err := putScreen(context.Background(), c.Params("name"), body.Prefixes); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.SendStatus(http.StatusNoContent)
+	}
+}
+
+func makeAdminDeleteScreenHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		existed, err := putScreen(context.Background(), c.Params("name"), nil)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		if !existed {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": errUnknownScreen.Error()})
+		}
+		return c.SendStatus(http.StatusNoContent)
+	}
+}
+
+func makeAdminStatsHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		from, to, groupBy, err := parseStatsQuery(c)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		report, err := loadRequestStats(context.Background(), from, to, groupBy)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(http.StatusOK).JSON(report)
+	}
+}
+
+func makeAdminDemandHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		days, err := parseDemandDays(c)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		report, err := loadLanguageDemand(context.Background(), days)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(http.StatusOK).JSON(report)
+	}
+}
+
+func makeAdminRequiredKeysHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(listRequiredKeys(context.Background()))
+	}
+}
+
+func makeAdminPutRequiredKeysHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var m requiredKeyManifest
+		if err := c.BodyParser(&m); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "body must be {app, version, keys: [...]}"})
+		}
+		missing, err := putRequiredKeys(context.Background(), m)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(http.StatusOK).JSON(fiber.Map{"release": m.id(), "missing": missing})
+	}
+}
+
+func makeAdminDeleteRequiredKeysHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := deleteRequiredKeys(context.Background(), c.Query("app"), c.Query("version"))
+		if errors.Is(err, errUnknownRequiredKeys) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.SendStatus(http.StatusNoContent)
+	}
+}
+
+func makeAdminCoverageHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(loadCoverageReport(context.Background()))
+	}
+}
+
+func makeAdminRedisShardsHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if len(localenv.GetRedisShards()) == 0 {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "redis sharding not configured (REDIS_SHARDS)"})
+		}
+		return c.Status(http.StatusOK).JSON(fiber.Map{"shards": checkRedisShards(context.Background())})
+	}
+}
+
+func makeAdminGitExportHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		status, err := loadGitExportStatus(context.Background())
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		if status == nil {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "no git export yet"})
+		}
+		return c.Status(http.StatusOK).JSON(status)
+	}
+}
+
+// makeAdminBlobGCHandler runs one blob GC sweep and returns its report.
+func makeAdminBlobGCHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !localenv.GetContentAddressedStorage() {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "CONTENT_ADDRESSED_STORAGE is disabled"})
+		}
+		report, err := runBlobGC(context.Background())
+		if errors.Is(err, errBlobGCBusy) {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(http.StatusOK).JSON(report)
+	}
+}
+
+// makeAdminLintHandler lists the lint counts of every language.
+func makeAdminLintHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Status(http.StatusOK).JSON(fiber.Map{"languages": lintSummaries(context.Background())})
+	}
+}
+
+// makeAdminLintReportHandler returns the last lint report of a language,
+// optionally filtered by ?severity= and ?rule=.
+func makeAdminLintReportHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report, err := loadLintReport(context.Background(), c.Params("lang"))
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		if report == nil {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "no lint report for this language"})
+		}
+		severity, rule := c.Query("severity"), c.Query("rule")
+		if severity != "" || rule != "" {
+			kept := report.Findings[:0]
+			for _, f := range report.Findings {
+				if (severity == "" || f.Severity == severity) && (rule == "" || f.Rule == rule) {
+					kept = append(kept, f)
+				}
+			}
+			report.Findings = kept
+		}
+		return c.Status(http.StatusOK).JSON(report)
+	}
+}
+
+func makeAdminMigrateHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report, err := runStorageMigrations(context.Background(), c.QueryBool("force", false))
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error(), "report": report})
+		}
+		return c.Status(http.StatusOK).JSON(report)
+	}
+}
+
+// makeAdminImportLegacyHandler runs the legacy import synchronously; its
+// progress can be followed from another request on the GET route.
+func makeAdminImportLegacyHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		dryRun := c.QueryBool("dry_run", false)
+		if !dryRun && isReadOnly(context.Background()) {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{"error": errReadOnly.Error()})
+		}
+		report, err := importLegacyData(context.Background(), dryRun, c.QueryBool("force", false))
+		if errors.Is(err, errLegacyMigrationRunning) {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error(), "report": report})
+		}
+		return c.Status(http.StatusOK).JSON(report)
+	}
+}
+
+func makeAdminImportLegacyStatusHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report := currentLegacyMigration()
+		if report == nil {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "no legacy migration has run"})
+		}
+		return c.Status(http.StatusOK).JSON(report)
+	}
+}
+
+func makeAdminJournalHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		entries, err := readJournal(context.Background(), int64(c.QueryInt("count", 100)))
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(http.StatusOK).JSON(entries)
+	}
+}
+
+func makeAdminJournalReplayHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report, err := replayJournal(context.Background())
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(http.StatusOK).JSON(report)
+	}
+}
+
+func makeLanguagesHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cache, err := GetLanguagesFromCache(context.Background())
+		if err != nil {
+			return upstreamErrorResponse(c, http.StatusInternalServerError, err)
+		}
+		if !resolveIncludeBeta(c) {
+			if cache, err = withoutBetaLanguages(cache); err != nil {
+				return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+			}
+		}
+		return c.Status(http.StatusOK).Send(cache)
+	}
+}
+
+func makeNamespacesHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cache, err := GetNamespacesFromCache(context.Background())
+		if err != nil {
+			return upstreamErrorResponse(c, http.StatusInternalServerError, err)
+		}
+		return c.Status(http.StatusOK).Send(cache)
+	}
+}
+
+func makeTagsHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cache, err := GetTagsFromCache(context.Background())
+		if err != nil {
+			return upstreamErrorResponse(c, http.StatusInternalServerError, err)
+		}
+		return c.Status(http.StatusOK).Send(cache)
+	}
+}
+
+// makeTolgeeProxyHandler forwards whitelisted read-only Tolgee endpoints, so
+// internal tools do not need their own Tolgee credentials.
+func makeTolgeeProxyHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		body, err := GetTolgeeResourceFromCache(context.Background(), c.Params("*"), c.Queries())
+		switch {
+		case errors.Is(err, errProxyPathNotAllowed):
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, errProxyQueryNotAllowed):
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		case err != nil:
+			return upstreamErrorResponse(c, http.StatusBadGateway, err)
+		}
+		c.Set("Content-type", "application/json")
+		return c.Status(http.StatusOK).Send(body)
+	}
+}
+
+func makeProjectStatsHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		stats, err := GetProjectStats(context.Background())
+		if err != nil {
+			return upstreamErrorResponse(c, http.StatusBadGateway, err)
+		}
+		return c.Status(http.StatusOK).JSON(stats)
+	}
+}
+
+func makeTranslationsHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		nested := resolveNested(c)
+		lang := c.Params("lang")
+		cache, err := getTranslationsForRequest(c, lang, nested)
+		if err != nil {
+			return err
+		}
+		if cache, err = applyPayloadBudget(c, nested, cache); err != nil {
+			return err
+		}
+		return sendTranslations(c, lang, nested, cache)
+	}
+}
+
+func makeGroupHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		merge := c.QueryBool("merge", false)
+		cache, err := GetGroupTranslations(context.Background(), c.Params("name"), resolveNested(c), merge)
+		if errors.Is(err, errUnknownGroup) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return err
+		}
+		if merge {
+			// the merged catalog mixes every member, later ones winning
+			c.Set("X-Requested-Language", c.Params("name"))
+			c.Set("X-Served-Language", strings.Join(localenv.GetLanguageGroups()[c.Params("name")], ","))
+		}
+		c.Set("Content-type", "application/json")
+		return c.Status(http.StatusOK).Send(cache)
+	}
+}
+
+func makeMatrixHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		format := c.Query("format", "json")
+		if format != "json" && format != "csv" {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": errInvalidMatrixFormat.Error()})
+		}
+		matrix, err := buildCoverageMatrix(context.Background())
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		if format == "csv" {
+			body, err := coverageMatrixCSV(matrix)
+			if err != nil {
+				return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+			}
+			c.Set("Content-type", "text/csv; charset=utf-8")
+			return c.Status(http.StatusOK).Send(body)
+		}
+		c.Set("Content-type", "application/json")
+		return c.Status(http.StatusOK).Send(matrix)
+	}
+}
+
+// makeVersionManifestHandler is always revalidated: it is the one place that
+// moves when a snapshot changes.
+func makeVersionManifestHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set("Cache-Control", "no-cache")
+		return c.Status(http.StatusOK).JSON(buildVersionManifest(context.Background()))
+	}
+}
+
+// makeVersionedTranslationsHandler serves /api/v/:sha/:lang, the raw snapshot
+// (no overrides or other per-request transforms) in any ?format=, cacheable
+// forever because a given sha always yields the same bytes.
+func makeVersionedTranslationsHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Query("envelope") != "" {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "envelope is not available on versioned URLs"})
+		}
+		sha, lang := c.Params("sha"), c.Params("lang")
+		payload, nested, err := loadVersionedSnapshot(context.Background(), sha, lang)
+		if errors.Is(err, errVersionNotCurrent) {
+			c.Set("Cache-Control", "no-store")
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		// lang is known to the manifest: artifacts may be cached under it
+		c.Locals(localsServedLanguage, &servedLanguage{lang: lang})
+		etag := `"` + sha + `"`
+		c.Set("Cache-Control", versionedCacheControl)
+		c.Set("ETag", etag)
+		if c.Get("If-None-Match") == etag {
+			return c.SendStatus(http.StatusNotModified)
+		}
+		return sendTranslations(c, lang, nested, payload)
+	}
+}
+
+// makeCollateHandler sorts the repeated ?s= values with the collation rules
+// of :lang, for services that need locale-correct ordering without ICU.
+func makeCollateHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var values []string
+		for _, v := range c.Context().QueryArgs().PeekMulti("s") {
+			values = append(values, string(v))
+		}
+		opts := collateOptions{
+			Numeric:          c.QueryBool("numeric", false),
+			IgnoreCase:       c.QueryBool("ignore_case", false),
+			IgnoreDiacritics: c.QueryBool("ignore_diacritics", false),
+		}
+		sorted, collation, err := collateStrings(c.Params("lang"), values, opts)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(http.StatusOK).JSON(fiber.Map{"lang": c.Params("lang"), "collation": collation, "sorted": sorted})
+	}
+}
+
+func makeSyncHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req syncRequest
+		if err := c.BodyParser(&req); err != nil || req.Lang == "" {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "body must be {lang, sha?, sections?}"})
+		}
+		resp, err := buildSyncResponse(context.Background(), req)
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(resp)
+	}
+}
+
+func makeFreshnessHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var held map[string]string
+		if err := c.BodyParser(&held); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "body must be {lang: sha, ...}"})
+		}
+		return c.Status(http.StatusOK).JSON(checkFreshness(context.Background(), held, resolveNested(c)))
+	}
+}
+
+func makeTransliterateHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req transliterateRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "body must be {id, text | texts, rules?}"})
+		}
+		out, err := transliterate(req)
+		if errors.Is(err, errTransliterateTooMany) || errors.Is(err, errTransliterateTooLarge) {
+			return c.Status(http.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(http.StatusOK).JSON(out)
+	}
+}
+
+func makeCachePolicyHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set("Cache-Control", "public, max-age="+strconv.FormatInt(durationSeconds(cachePolicyMaxAge), 10))
+		return c.Status(http.StatusOK).JSON(currentCachePolicy(context.Background()))
+	}
+}
+
+// makeBranchesHandler lists the preview channels and their Tolgee branches.
+func makeBranchesHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channels := localenv.GetTolgeeBranchChannels()
+		if channels == nil {
+			channels = map[string]string{}
+		}
+		return c.Status(http.StatusOK).JSON(fiber.Map{"production": localenv.GetTolgeeProductionBranch(), "channels": channels})
+	}
+}
+
+// makeBranchTranslationsHandler serves a Tolgee branch preview: the raw
+// catalog, without overrides, patches or formats, never cached downstream.
+func makeBranchTranslationsHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channel, lang := c.Params("name"), c.Params("lang")
+		payload, err := loadBranchVariant(c, channel, lang, resolveNested(c))
+		switch {
+		case errors.Is(err, errUnknownBranchChannel):
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		case err != nil:
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				return err
+			}
+			return upstreamErrorResponse(c, http.StatusBadGateway, err)
+		}
+		branch, _ := branchForChannel(channel)
+		c.Set("X-Tolgee-Branch", branch)
+		c.Set("Cache-Control", "no-store")
+		c.Set("Content-type", "application/json; charset=utf-8")
+		return c.Status(http.StatusOK).Send(payload)
+	}
+}
+
+// appErrorResponse maps the errors of the tenant app handlers.
+func appErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errUnknownApp), errors.Is(err, errUnknownAppLanguage):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, errLocalSourceUnsupported):
+		return c.Status(http.StatusNotImplemented).JSON(fiber.Map{"error": err.Error()})
+	default:
+		var fe *fiber.Error
+		if errors.As(err, &fe) {
+			return err
+		}
+		return upstreamErrorResponse(c, http.StatusBadGateway, err)
+	}
+}
+
+// upstreamErrorResponse answers a failed Tolgee read without the error text,
+// which can carry the request URL: 503 with Retry-After during the failure
+// cooldown, status otherwise. The detail only goes to the log.
+func upstreamErrorResponse(c *fiber.Ctx, status int, err error) error {
+	var cooldown *upstreamCooldownError
+	if errors.As(err, &cooldown) {
+		c.Set("Retry-After", cooldown.RetryAfterSeconds())
+		return c.Status(http.StatusServiceUnavailable).JSON(fiber.Map{"error": cooldown.Error()})
+	}
+	log.Printf("[http] %s upstream error: %v", c.Path(), err)
+	return c.Status(status).JSON(fiber.Map{"error": errUpstreamUnavailable.Error()})
+}
+
+// makeAppLanguagesHandler serves the Tolgee languages of a tenant app.
+func makeAppLanguagesHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cache, err := GetAppLanguagesFromCache(c.UserContext(), c.Params("app"))
+		if errors.Is(err, errUnknownApp) {
+			return c.Next()
+		}
+		if err != nil {
+			return appErrorResponse(c, err)
+		}
+		c.Set("Content-type", "application/json")
+		return c.Status(http.StatusOK).Send(cache)
+	}
+}
+
+// makeAppTranslationsHandler serves the raw catalog of a tenant app: the
+// default project pipeline (overrides, patches, formats) does not apply.
+func makeAppTranslationsHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		app, lang := c.Params("app"), c.Params("lang")
+		payload, err := loadAppVariant(c, app, lang, resolveNested(c))
+		if errors.Is(err, errUnknownApp) {
+			// not a tenant: the catch-all answers like before
+			return c.Next()
+		}
+		if err != nil {
+			return appErrorResponse(c, err)
+		}
+		c.Set("X-Mensa-App", app)
+		c.Set("Content-type", "application/json; charset=utf-8")
+		return c.Status(http.StatusOK).Send(payload)
+	}
+}
+
+// makeAdminAppsHandler lists the tenant apps (app keys are never returned).
+func makeAdminAppsHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		apps, err := listApps(context.Background())
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(http.StatusOK).JSON(fiber.Map{"apps": apps})
+	}
+}
+
+func makeAdminPutAppHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			AppKey string `json:"app_key"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.AppKey == "" {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "body must be {app_key: \"...\"}"})
+		}
+		err := registerApp(context.Background(), c.Params("app"), body.AppKey)
+		switch {
+		case errors.Is(err, errInvalidAppName):
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, errConfiguredApp):
+			return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		case err != nil:
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.SendStatus(http.StatusNoContent)
+	}
+}
+
+func makeAdminDeleteAppHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		existed, err := unregisterApp(context.Background(), c.Params("app"))
+		switch {
+		case errors.Is(err, errConfiguredApp):
+			return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		case err != nil:
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		case !existed:
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": errUnknownApp.Error()})
+		}
+		return c.SendStatus(http.StatusNoContent)
+	}
+}
+
+// makeAdminRefreshAppHandler drops the Redis catalogs of an app, so the next
+// requests export them from Tolgee again.
+func makeAdminRefreshAppHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		app := c.Params("app")
+		if _, err := lookupApp(context.Background(), app); err != nil {
+			return appErrorResponse(c, err)
+		}
+		purgeAppCaches(context.Background(), app, false)
+		return c.SendStatus(http.StatusNoContent)
+	}
+}
+
+func makeCatalogProtoHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set("Content-type", "text/plain; charset=utf-8")
+		return c.Status(http.StatusOK).Send(catalogProto)
+	}
+}
+
+func makeESModuleHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		lang := c.Params("lang")
+		cache, err := getTranslationsForRequest(c, lang, resolveNested(c))
+		if err != nil {
+			return err
+		}
+		recordRequestStats(c)
+		module := esModuleFromJSON(cache)
+		c.Set("Content-type", "text/javascript; charset=utf-8")
+		c.Set("X-Content-Integrity", subresourceIntegrity(module))
+		return c.Status(http.StatusOK).Send(module)
+	}
+}
+
+// makeIntegrityHandler returns the SRI hashes of the JSON and ES module
+// variants for the same query options, for use in integrity="" attributes.
+func makeIntegrityHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		lang := c.Params("lang")
+		cache, err := getTranslationsForRequest(c, lang, resolveNested(c))
+		if err != nil {
+			return err
+		}
+		query := ""
+		if q := string(c.Request().URI().QueryString()); q != "" {
+			query = "?" + q
+		}
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"json": fiber.Map{"url": "/api/" + lang + query, "integrity": subresourceIntegrity(cache)},
+			"mjs":  fiber.Map{"url": "/api/" + lang + ".mjs" + query, "integrity": subresourceIntegrity(esModuleFromJSON(cache))},
+		})
+	}
+}
+
+// makeKeysHandler serves selected keys (?keys=a.b,c.d) from the flat catalog;
+// deprecated ones are counted and listed in X-Deprecated-Keys, aliased old
+// names are resolved and counted.
+func makeKeysHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		keys := splitKeysQuery(c.Query("keys"))
+		if len(keys) == 0 {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "keys query is required"})
+		}
+		lang := c.Params("lang")
+		cache, err := getTranslationsForRequest(c, lang, false)
+		if err != nil {
+			return err
+		}
+		var tree map[string]any
+		if err := decodeJSON(cache, &tree); err != nil {
+			return err
+		}
+		delim, _ := resolveDelimiter(c)
+		out := make(map[string]any, len(keys))
+		for _, key := range keys {
+			if v, ok := lookupCatalogValue(tree, key, false, delim); ok {
+				out[key] = v
+			}
+		}
+		if deprecated := trackDeprecatedLookups(context.Background(), keys); len(deprecated) > 0 {
+			c.Set("X-Deprecated-Keys", strings.Join(deprecated, ","))
+		}
+		trackKeyAliasLookups(context.Background(), keys)
+		return c.Status(http.StatusOK).JSON(out)
+	}
+}
+
+func makeKeyHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		lang := c.Params("lang")
+		key := c.Params("key")
+		cache, err := getTranslationsForRequest(c, lang, false)
+		if err != nil {
+			return err
+		}
+		var tree map[string]any
+		if err := decodeJSON(cache, &tree); err != nil {
+			return err
+		}
+		delim, _ := resolveDelimiter(c)
+		value, found := lookupCatalogValue(tree, key, false, delim)
+		trackKeyAliasLookups(context.Background(), []string{key})
+		out := fiber.Map{"lang": lang, "key": key, "value": value}
+		// only keys of the served catalog reach Tolgee and the proxy cache
+		if !found {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": errKeyNotFound.Error()})
+		}
+		if !c.QueryBool("meta", false) {
+			return c.Status(http.StatusOK).JSON(out)
+		}
+
+		served, ok := servedLanguageOf(c)
+		if !ok {
+			served = lang
+		}
+		meta, err := GetKeyMeta(context.Background(), served, key)
+		switch {
+		case errors.Is(err, errKeyNotFound):
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		case err != nil:
+			return upstreamErrorResponse(c, http.StatusBadGateway, err)
+		}
+		out["meta"] = meta
+		return c.Status(http.StatusOK).JSON(out)
+	}
+}
+
+func makeScreenshotHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid screenshot id"})
+		}
+		img, err := s3ClientIfEnabled(context.Background()).getObject(context.Background(), screenshotCacheKey(id))
+		if err != nil || len(img) == 0 {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "screenshot not found"})
+		}
+		c.Set("Content-type", http.DetectContentType(img))
+		c.Set("Cache-Control", "public, max-age=86400, immutable")
+		return c.Status(http.StatusOK).Send(img)
+	}
+}
+
+func makeScreenHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// unknown screens stop here, before the catalog is loaded or derived
+		screen, err := lookupScreen(context.Background(), c.Params("screen"))
+		if err != nil {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		nested := resolveNested(c)
+		lang := c.Params("lang")
+		cache, err := getTranslationsForRequest(c, lang, nested)
+		if err != nil {
+			return err
+		}
+		subset, err := screenSubset(c, screen, nested, cache)
+		if err != nil {
+			return err
+		}
+		c.Locals(localsOverridden, true)
+		return sendTranslations(c, lang, nested, subset)
+	}
+}
+
+func makeLocaleDataHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		resolved, data, err := resolveLocaleData(c.Params("lang"))
+		if err != nil {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(http.StatusOK).JSON(fiber.Map{"lang": c.Params("lang"), "resolved": resolved, "data": data})
+	}
+}
+
+func makePluralRulesHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		resolved, rules, err := resolvePluralRules(c.Params("lang"))
+		if err != nil {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(http.StatusOK).JSON(fiber.Map{"lang": c.Params("lang"), "resolved": resolved, "rules": rules.Rules, "required": rules.Required})
+	}
+}
+
+func makeFallbackHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		nested := resolveNested(c)
+		lang := inferFallbackLanguage(c)
+		cache, err := getTranslationsForRequest(c, lang, nested)
+		if err != nil {
+			return err
+		}
+		if cache, err = applyPayloadBudget(c, nested, cache); err != nil {
+			return err
+		}
+		return sendTranslations(c, lang, nested, cache)
+	}
+}
+
+// getTranslationsForRequest loads the catalog applying the request options
+// (flat delimiter, overrides, key schedules, post-processors, HTML escaping,
+// stale banner, namespace encryption, key sorting) on top of the cached payload.
+func getTranslationsForRequest(c *fiber.Ctx, lang string, nested bool) ([]byte, error) {
+	negotiateStart := time.Now()
+	sortAlpha, err := resolveSortAlpha(c)
+	if err != nil {
+		return nil, fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	served := &servedLanguage{}
+	c.Locals(localsServedLanguage, served)
+	c.SetUserContext(withServedLanguage(withBetaOptIn(c.UserContext(), resolveIncludeBeta(c)), served))
+	base, overlay := resolveRegionOverlay(c, lang)
+	observeStage(c.UserContext(), stageNegotiate, negotiateStart)
+	payload, err := loadTranslationsVariant(c, base, nested)
+	if err != nil {
+		return nil, err
+	}
+	setServedLanguageHeaders(c, lang, served)
+	if payload, err = applyRegionOverlay(c, overlay, nested, payload); err != nil {
+		return nil, err
+	}
+	if payload, err = applyBaseBackfill(c, lang, nested, payload); err != nil {
+		return nil, err
+	}
+	if payload, err = applyS3Patch(c, lang, nested, payload); err != nil {
+		return nil, err
+	}
+	if payload, err = applyRequestOverrides(c, lang, nested, payload); err != nil {
+		return nil, err
+	}
+	if payload, err = applyKeyAliases(c, lang, nested, payload); err != nil {
+		return nil, err
+	}
+	if payload, err = applyKeySchedules(c, lang, nested, payload); err != nil {
+		return nil, err
+	}
+	if payload, err = applyPostProcessors(c, lang, nested, payload); err != nil {
+		return nil, err
+	}
+	if payload, err = applyLengthBudgets(c, nested, payload); err != nil {
+		return nil, err
+	}
+	if payload, err = applyHTMLEscape(c, payload); err != nil {
+		return nil, err
+	}
+	if payload, err = applyStaleBanner(c, nested, payload); err != nil {
+		return nil, err
+	}
+	if payload, err = applyNamespaceEncryption(c, nested, payload); err != nil {
+		return nil, err
+	}
+	if !sortAlpha || localenv.GetSortSnapshots() {
+		return payload, nil
+	}
+	return sortJSONKeys(payload)
+}
+
+func loadTranslationsVariant(c *fiber.Ctx, lang string, nested bool) ([]byte, error) {
+	tags, err := resolveTags(c)
+	if err != nil {
+		return nil, fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	if len(tags) > 0 {
+		return loadTaggedVariant(c, lang, nested, tags)
+	}
+	if nested {
+		return GetTranslationsFromCache(c.UserContext(), lang, true)
+	}
+	delim, err := resolveDelimiter(c)
+	if err != nil {
+		return nil, fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	if delim == "" {
+		return GetTranslationsFromCache(c.UserContext(), lang, false)
 	}
+	return GetFlatTranslationsWithDelimiter(c.UserContext(), lang, delim)
 }
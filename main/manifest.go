@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+const manifestCacheKey = "tolgee:manifest"
+
+// manifestEntry tracks the current snapshot of one language.
+type manifestEntry struct {
+	FlatSha   string    `json:"flat_sha,omitempty"`
+	NestedSha string    `json:"nested_sha,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// translationsManifest lists every cached language with its snapshot shas.
+type translationsManifest struct {
+	GeneratedAt time.Time                `json:"generated_at"`
+	Languages   map[string]manifestEntry `json:"languages"`
+}
+
+// manifestMu serializes read-modify-write cycles within this process.
+var manifestMu sync.Mutex
+
+func loadManifest(ctx context.Context) *translationsManifest {
+	m := &translationsManifest{Languages: map[string]manifestEntry{}}
+	b, err := redisGet(ctx, manifestCacheKey)
+	if err != nil || len(b) == 0 {
+		return m
+	}
+	if err := json.Unmarshal(b, m); err != nil {
+		log.Printf("[manifest] unmarshal error: %v", err)
+		return &translationsManifest{Languages: map[string]manifestEntry{}}
+	}
+	if m.Languages == nil {
+		m.Languages = map[string]manifestEntry{}
+	}
+	return m
+}
+
+// updateManifest applies fn to the stored manifest and saves it to Redis (and S3).
+func updateManifest(ctx context.Context, s3c *s3Client, fn func(m *translationsManifest)) {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	m := loadManifest(ctx)
+	fn(m)
+	m.GeneratedAt = time.Now().UTC()
+	b, err := json.Marshal(m)
+	if err != nil {
+		log.Printf("[manifest] marshal error: %v", err)
+		return
+	}
+	storeCacheEntry(ctx, s3c, manifestCacheKey, b, "application/json")
+}
+
+// recordManifestSnapshot stores the sha of a freshly written translation payload.
+func recordManifestSnapshot(ctx context.Context, s3c *s3Client, lang string, nested bool, payload []byte) {
+	var batch manifestBatch
+	batch.add(lang, nested, payload)
+	batch.flush(ctx, s3c)
+}
+
+type manifestSnapshot struct {
+	lang   string
+	nested bool
+	sha    string
+}
+
+// manifestBatch collects the snapshots written by a refresh or a repair so
+// the manifest is rewritten once at the end rather than once per file.
+type manifestBatch struct {
+	snapshots []manifestSnapshot
+}
+
+func (b *manifestBatch) add(lang string, nested bool, payload []byte) {
+	b.snapshots = append(b.snapshots, manifestSnapshot{lang: lang, nested: nested, sha: sha256Hex(payload)})
+}
+
+// flush records every collected sha in a single manifest write.
+func (b *manifestBatch) flush(ctx context.Context, s3c *s3Client) {
+	if len(b.snapshots) == 0 {
+		return
+	}
+	updateManifest(ctx, s3c, func(m *translationsManifest) {
+		now := time.Now().UTC()
+		for _, snap := range b.snapshots {
+			entry := m.Languages[snap.lang]
+			if snap.nested {
+				entry.NestedSha = snap.sha
+			} else {
+				entry.FlatSha = snap.sha
+			}
+			entry.UpdatedAt = now
+			m.Languages[snap.lang] = entry
+		}
+	})
+	b.snapshots = nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var errInvalidMatrixFormat = errors.New("format must be \"json\" or \"csv\"")
+
+// matrixCell is the coverage of one namespace (top-level section) in one language.
+type matrixCell struct {
+	Namespace  string  `json:"namespace"`
+	Translated int     `json:"translated"`
+	Total      int     `json:"total"`
+	Percent    float64 `json:"percent"`
+}
+
+type matrixRow struct {
+	Language string       `json:"language"`
+	Percent  float64      `json:"percent"`
+	Cells    []matrixCell `json:"cells"`
+}
+
+// coverageMatrix is languages × namespaces; every row lists its cells in
+// Namespaces order.
+type coverageMatrix struct {
+	GeneratedAt  time.Time   `json:"generated_at"`
+	BaseLanguage string      `json:"base_language,omitempty"`
+	Namespaces   []string    `json:"namespaces"`
+	Rows         []matrixRow `json:"rows"`
+}
+
+// buildCoverageMatrix counts, per language and namespace, the non-empty
+// values among the keys of the base language (all languages' keys when the
+// project has no base). It reads the cached nested snapshots only and is
+// cached under a key derived from their shas.
+func buildCoverageMatrix(ctx context.Context) ([]byte, error) {
+	m := loadManifest(ctx)
+	langs := make([]string, 0, len(m.Languages))
+	hash := ""
+	for lang := range m.Languages {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		hash += lang + "\x00" + m.Languages[lang].NestedSha + "\x00"
+	}
+	key := "tolgee:matrix:" + sha256Hex([]byte(hash))[:12]
+	if cached, err := redisGet(ctx, key); err == nil && len(cached) > 0 {
+		return cached, nil
+	}
+
+	// lang -> namespace -> flat key -> translated
+	leaves := map[string]map[string]map[string]bool{}
+	for _, lang := range langs {
+		raw, err := redisGet(ctx, translationsCacheKey(lang, true))
+		if err != nil || len(raw) == 0 {
+			continue
+		}
+		var tree map[string]any
+		if err := decodeJSON(raw, &tree); err != nil {
+			return nil, err
+		}
+		leaves[lang] = map[string]map[string]bool{}
+		for ns, section := range tree {
+			flat := map[string]any{}
+			flattenValue(flat, ns, section, ".")
+			keys := make(map[string]bool, len(flat))
+			for k, v := range flat {
+				s, isString := v.(string)
+				keys[k] = v != nil && (!isString || s != "")
+			}
+			leaves[lang][ns] = keys
+		}
+	}
+
+	matrix := coverageMatrix{GeneratedAt: time.Now().UTC(), BaseLanguage: baseLanguageTag(ctx), Rows: []matrixRow{}}
+	expected := map[string]map[string]bool{}
+	if base, ok := leaves[matrix.BaseLanguage]; ok {
+		expected = base
+	} else {
+		matrix.BaseLanguage = ""
+		for _, byNs := range leaves {
+			for ns, keys := range byNs {
+				if expected[ns] == nil {
+					expected[ns] = map[string]bool{}
+				}
+				for k := range keys {
+					expected[ns][k] = true
+				}
+			}
+		}
+	}
+	for ns := range expected {
+		matrix.Namespaces = append(matrix.Namespaces, ns)
+	}
+	sort.Strings(matrix.Namespaces)
+
+	for _, lang := range langs {
+		byNs, ok := leaves[lang]
+		if !ok {
+			continue
+		}
+		row := matrixRow{Language: lang, Cells: make([]matrixCell, 0, len(matrix.Namespaces))}
+		translated, total := 0, 0
+		for _, ns := range matrix.Namespaces {
+			cell := matrixCell{Namespace: ns, Total: len(expected[ns])}
+			for k := range expected[ns] {
+				if byNs[ns][k] {
+					cell.Translated++
+				}
+			}
+			cell.Percent = coveragePercent(cell.Translated, cell.Total)
+			translated += cell.Translated
+			total += cell.Total
+			row.Cells = append(row.Cells, cell)
+		}
+		row.Percent = coveragePercent(translated, total)
+		matrix.Rows = append(matrix.Rows, row)
+	}
+	out, err := marshalJSON(matrix)
+	if err != nil {
+		return nil, err
+	}
+	_ = redisPut(ctx, key, out, derivedVariantTTL())
+	return out, nil
+}
+
+// coveragePercent rounds to one decimal; an empty namespace counts as complete.
+func coveragePercent(translated, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return math.Round(float64(translated)*1000/float64(total)) / 10
+}
+
+// coverageMatrixCSV renders one line per language: language, overall percent,
+// then one percent column per namespace.
+func coverageMatrixCSV(payload []byte) ([]byte, error) {
+	var matrix coverageMatrix
+	if err := decodeJSON(payload, &matrix); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write(append([]string{"language", "total"}, matrix.Namespaces...))
+	for _, row := range matrix.Rows {
+		record := []string{row.Language, strconv.FormatFloat(row.Percent, 'f', 1, 64)}
+		for _, cell := range row.Cells {
+			record = append(record, strconv.FormatFloat(cell.Percent, 'f', 1, 64))
+		}
+		_ = w.Write(record)
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
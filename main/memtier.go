@@ -0,0 +1,153 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// In-process tier in front of Redis for translation snapshots, bounded by
+// MEMORY_CACHE_MAX_BYTES. When full, the entry with the fewest hits per byte
+// is evicted (a large, rarely requested catalog goes before a small popular
+// one); the priority languages are never evicted. Entries expire after
+// MEMORY_CACHE_TTL, since a refresh on another replica cannot reach them.
+
+type memEntry struct {
+	lang     string
+	payload  []byte
+	hits     float64
+	storedAt time.Time
+}
+
+var memTier = struct {
+	sync.Mutex
+	entries map[string]*memEntry
+	bytes   int64
+}{entries: map[string]*memEntry{}}
+
+func memTierEnabled() bool { return localenv.GetMemoryCacheMaxBytes() > 0 }
+
+// memGet returns a live entry and counts the hit.
+func memGet(key string) ([]byte, bool) {
+	if !memTierEnabled() {
+		return nil, false
+	}
+	ttl := memoryTierTTL()
+	memTier.Lock()
+	defer memTier.Unlock()
+	e, ok := memTier.entries[key]
+	if !ok {
+		promMemTierLookups.add(1, "miss")
+		return nil, false
+	}
+	if time.Since(e.storedAt) > ttl {
+		memRemoveLocked(key, e)
+		promMemTierLookups.add(1, "expired")
+		return nil, false
+	}
+	e.hits++
+	promMemTierLookups.add(1, "hit")
+	return e.payload, true
+}
+
+// memPut stores a snapshot, evicting until the tier fits its budget again.
+// The hits of a replaced entry are kept, so popularity survives refreshes.
+func memPut(key, lang string, payload []byte) {
+	max := localenv.GetMemoryCacheMaxBytes()
+	if max <= 0 || int64(len(payload)) > max {
+		return
+	}
+	pinned := map[string]bool{}
+	for _, l := range priorityLanguages() {
+		pinned[l] = true
+	}
+	memTier.Lock()
+	defer memTier.Unlock()
+	hits := 1.0
+	if old, ok := memTier.entries[key]; ok {
+		hits = old.hits
+		memRemoveLocked(key, old)
+	}
+	memTier.entries[key] = &memEntry{lang: lang, payload: payload, hits: hits, storedAt: time.Now()}
+	memTier.bytes += int64(len(payload))
+	for memTier.bytes > max {
+		if !memEvictLocked(key, pinned) {
+			break
+		}
+	}
+	promMemTierBytes.set(float64(memTier.bytes))
+}
+
+// memForget drops a key after it was rewritten in Redis.
+func memForget(key string) {
+	if !memTierEnabled() {
+		return
+	}
+	memTier.Lock()
+	defer memTier.Unlock()
+	if e, ok := memTier.entries[key]; ok {
+		memRemoveLocked(key, e)
+		promMemTierBytes.set(float64(memTier.bytes))
+	}
+}
+
+// memEvictLocked removes the unpinned entry with the lowest hits per byte,
+// never the one just stored. It reports whether anything was evicted.
+func memEvictLocked(keep string, pinned map[string]bool) bool {
+	victim := ""
+	var victimScore float64
+	for key, e := range memTier.entries {
+		if key == keep || pinned[e.lang] {
+			continue
+		}
+		score := e.hits / float64(len(e.payload)+1)
+		if victim == "" || score < victimScore {
+			victim, victimScore = key, score
+		}
+	}
+	if victim == "" {
+		return false
+	}
+	e := memTier.entries[victim]
+	memRemoveLocked(victim, e)
+	promMemTierEvictions.add(1, e.lang)
+	// age the survivors, so past popularity fades over time
+	for _, other := range memTier.entries {
+		other.hits *= 0.9
+	}
+	return true
+}
+
+func memRemoveLocked(key string, e *memEntry) {
+	delete(memTier.entries, key)
+	memTier.bytes -= int64(len(e.payload))
+}
+
+// memTierEntry describes a held snapshot for diagnostics.
+type memTierEntry struct {
+	Key        string  `json:"key"`
+	Lang       string  `json:"lang"`
+	Bytes      int     `json:"bytes"`
+	Sha        string  `json:"sha"`
+	Hits       float64 `json:"hits"`
+	AgeSeconds int64   `json:"age_seconds"`
+}
+
+// memTierEntries lists the held snapshots, without their payloads.
+func memTierEntries() []memTierEntry {
+	memTier.Lock()
+	defer memTier.Unlock()
+	out := make([]memTierEntry, 0, len(memTier.entries))
+	for key, e := range memTier.entries {
+		out = append(out, memTierEntry{
+			Key:        key,
+			Lang:       e.lang,
+			Bytes:      len(e.payload),
+			Sha:        sha256Hex(e.payload),
+			Hits:       e.hits,
+			AgeSeconds: int64(time.Since(e.storedAt).Seconds()),
+		})
+	}
+	return out
+}
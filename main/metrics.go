@@ -0,0 +1,26 @@
+package main
+
+import (
+	"expvar"
+	"runtime"
+)
+
+// Runtime and cache counters published on /debug/vars.
+// memstats (heap, GC) is already published by the expvar package itself.
+var (
+	expCacheBytes      = expvar.NewMap("cache_bytes")
+	expPayloadRejected = expvar.NewInt("payload_rejected")
+)
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() any {
+		return runtime.NumGoroutine()
+	}))
+}
+
+// recordCacheSize tracks the last written payload size for a cache key.
+func recordCacheSize(key string, size int) {
+	v := new(expvar.Int)
+	v.Set(int64(size))
+	expCacheBytes.Set(key, v)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/goccy/go-json"
+)
+
+// encodeCatalogMsgpack encodes a JSON catalog as MessagePack (maps keep the
+// lexicographic key order, so equal catalogs produce identical bytes).
+func encodeCatalogMsgpack(_ string, payload []byte) ([]byte, error) {
+	var v any
+	if err := decodeJSON(payload, &v); err != nil {
+		return nil, err
+	}
+	return msgpackAppend(nil, v)
+}
+
+func msgpackAppend(out []byte, v any) ([]byte, error) {
+	switch t := v.(type) {
+	case nil:
+		return append(out, 0xc0), nil
+	case bool:
+		if t {
+			return append(out, 0xc3), nil
+		}
+		return append(out, 0xc2), nil
+	case string:
+		return msgpackAppendString(out, t), nil
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return msgpackAppendInt(out, i), nil
+		}
+		f, err := t.Float64()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, 0xcb)
+		return binary.BigEndian.AppendUint64(out, math.Float64bits(f)), nil
+	case []any:
+		out = msgpackAppendHeader(out, len(t), 0x90, 0xdc, 0xdd)
+		for _, item := range t {
+			var err error
+			if out, err = msgpackAppend(out, item); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out = msgpackAppendHeader(out, len(t), 0x80, 0xde, 0xdf)
+		for _, k := range keys {
+			out = msgpackAppendString(out, k)
+			var err error
+			if out, err = msgpackAppend(out, t[k]); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type %T", v)
+}
+
+func msgpackAppendString(out []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		out = append(out, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		out = append(out, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		out = append(out, 0xda)
+		out = binary.BigEndian.AppendUint16(out, uint16(n))
+	default:
+		out = append(out, 0xdb)
+		out = binary.BigEndian.AppendUint32(out, uint32(n))
+	}
+	return append(out, s...)
+}
+
+// msgpackAppendHeader writes a map/array header: fix (<16), 16-bit or 32-bit length.
+func msgpackAppendHeader(out []byte, n int, fix, b16, b32 byte) []byte {
+	switch {
+	case n < 16:
+		return append(out, fix|byte(n))
+	case n <= math.MaxUint16:
+		out = append(out, b16)
+		return binary.BigEndian.AppendUint16(out, uint16(n))
+	default:
+		out = append(out, b32)
+		return binary.BigEndian.AppendUint32(out, uint32(n))
+	}
+}
+
+func msgpackAppendInt(out []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i < 128:
+		return append(out, byte(i))
+	case i < 0 && i >= -32:
+		return append(out, byte(int8(i)))
+	default:
+		out = append(out, 0xd3)
+		return binary.BigEndian.AppendUint64(out, uint64(i))
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+const (
+	namespacesCacheKey = "tolgee:namespaces"
+	tagsCacheKey       = "tolgee:tags"
+)
+
+// GetNamespacesFromCache serves the Tolgee used-namespaces payload with the
+// same Redis -> S3 -> Tolgee chain as GetLanguagesFromCache.
+func GetNamespacesFromCache(ctx context.Context) ([]byte, error) {
+	return getProjectListFromCache(ctx, namespacesCacheKey, "used-namespaces")
+}
+
+// GetTagsFromCache serves the Tolgee project tags, cached like the namespaces.
+func GetTagsFromCache(ctx context.Context) ([]byte, error) {
+	return getProjectListFromCache(ctx, tagsCacheKey, "tags")
+}
+
+// projectListQuery asks Tolgee for the whole list in one page.
+var projectListQuery = map[string]string{"size": "1000"}
+
+func getProjectListFromCache(ctx context.Context, key, path string) ([]byte, error) {
+	cached, err := redisGet(ctx, key)
+	if err == nil && len(cached) > 0 {
+		return cached, nil
+	}
+
+	s3c := s3ClientIfEnabled(ctx)
+	if s3c != nil {
+		cached, err = s3c.getObject(ctx, key)
+		if err == nil && len(cached) > 0 {
+			_ = redisPut(ctx, key, cached, 0)
+			return cached, nil
+		}
+	}
+
+	if localenv.GetPromotedOnly() {
+		return nil, errors.New(path + " not promoted yet")
+	}
+
+	b, err := fetchUpstream(ctx, path, func() ([]byte, error) {
+		return GetProjectResource(ctx, localenv.GetTolgeeAppKey(), path, projectListQuery)
+	})
+	if err != nil {
+		return nil, err
+	}
+	storeCacheEntry(ctx, s3c, key, b, "application/json")
+	return b, nil
+}
+
+// refreshProjectLists stores the current namespaces and tags; a failure is
+// logged only, translations do not depend on them.
+func refreshProjectLists(ctx context.Context, appKey string, s3c *s3Client) {
+	for key, path := range map[string]string{namespacesCacheKey: "used-namespaces", tagsCacheKey: "tags"} {
+		b, err := GetProjectResource(ctx, appKey, path, projectListQuery)
+		if err != nil {
+			log.Printf("[refresh] %s error: %v", path, err)
+			continue
+		}
+		storeCacheEntry(ctx, s3c, key, b, "application/json")
+	}
+}
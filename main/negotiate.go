@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/gofiber/fiber/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// resolveNested picks the payload shape for a request: an explicit ?nested=
+// wins, then the X-Platform default from PLATFORM_NESTED_DEFAULTS, then
+// DEFAULT_NESTED.
+func resolveNested(c *fiber.Ctx) bool {
+	switch c.Query("nested") {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	platform := requestPlatform(c)
+	if nested, ok := localenv.GetPlatformNestedDefaults()[platform]; ok && platform != "" {
+		return nested
+	}
+	return localenv.GetDefaultNested()
+}
+
+// requestPlatform returns the lower-cased X-Platform header. The response now
+// depends on a header: shared caches must key on it.
+func requestPlatform(c *fiber.Ctx) string {
+	c.Vary("X-Platform")
+	return strings.ToLower(strings.TrimSpace(c.Get("X-Platform")))
+}
+
+var errInvalidDelimiter = errors.New("delimiter must be at most 4 printable characters")
+
+// resolveDelimiter returns the flat-key delimiter: ?delimiter= when present
+// (even empty), otherwise FLAT_DELIMITER. Empty means Tolgee's own flat keys.
+func resolveDelimiter(c *fiber.Ctx) (string, error) {
+	delim := localenv.GetFlatDelimiter()
+	if c.Request().URI().QueryArgs().Has("delimiter") {
+		delim = c.Query("delimiter")
+	}
+	if len(delim) > 4 {
+		return "", errInvalidDelimiter
+	}
+	for _, r := range delim {
+		if !unicode.IsPrint(r) {
+			return "", errInvalidDelimiter
+		}
+	}
+	return delim, nil
+}
+
+var errInvalidSort = errors.New("sort must be \"alpha\" or empty")
+
+// resolveSortAlpha reports whether ?sort=alpha asked for lexicographic keys.
+func resolveSortAlpha(c *fiber.Ctx) (bool, error) {
+	switch c.Query("sort") {
+	case "":
+		return false, nil
+	case "alpha":
+		return true, nil
+	}
+	return false, errInvalidSort
+}
+
+var errInvalidEscape = errors.New("escape must be \"html\", \"none\" or empty")
+
+// resolveEscapeHTML reports whether values must be HTML-escaped: ?escape=
+// wins, then the X-Platform default from PLATFORM_HTML_ESCAPE.
+func resolveEscapeHTML(c *fiber.Ctx) (bool, error) {
+	switch c.Query("escape") {
+	case "html":
+		return true, nil
+	case "none":
+		return false, nil
+	case "":
+		return localenv.GetPlatformHTMLEscape()[requestPlatform(c)], nil
+	}
+	return false, errInvalidEscape
+}
+
+// isPlainVariantRequest reports whether the request asks for the catalog
+// exactly as stored (no delimiter rewrite, no overrides, no extra sorting).
+func isPlainVariantRequest(c *fiber.Ctx, nested bool) bool {
+	if overridden, _ := c.Locals(localsOverridden).(bool); overridden {
+		return false
+	}
+	if !nested {
+		if delim, err := resolveDelimiter(c); err != nil || delim != "" {
+			return false
+		}
+	}
+	sortAlpha, err := resolveSortAlpha(c)
+	return err == nil && (!sortAlpha || localenv.GetSortSnapshots())
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// outgoingNotification is the JSON body POSTed to OUTGOING_WEBHOOK_URL.
+type outgoingNotification struct {
+	Event string    `json:"event"`
+	At    time.Time `json:"at"`
+	Data  any       `json:"data,omitempty"`
+}
+
+// notifyOutgoing posts an event to OUTGOING_WEBHOOK_URL in the background.
+// When OUTGOING_WEBHOOK_SECRET is set the body is signed (hex HMAC-SHA256)
+// in X-Mensa-Signature. Delivery is best-effort: failures are only logged.
+func notifyOutgoing(event string, data any) {
+	url := localenv.GetOutgoingWebhookURL()
+	if url == "" {
+		return
+	}
+	body, err := json.Marshal(outgoingNotification{Event: event, At: time.Now().UTC(), Data: data})
+	if err != nil {
+		log.Printf("[notify] marshal error event=%s: %v", event, err)
+		return
+	}
+	go func() {
+		req := httpClients().webhooks.R().
+			SetContext(context.Background()).
+			SetHeader("Content-Type", "application/json").
+			SetBody(body)
+		if secret := localenv.GetOutgoingWebhookSecret(); secret != "" {
+			mac := hmac.New(sha256.New, []byte(secret))
+			mac.Write(body)
+			req.SetHeader("X-Mensa-Signature", hex.EncodeToString(mac.Sum(nil)))
+		}
+		resp, err := req.Post(url)
+		if err != nil {
+			log.Printf("[notify] POST error event=%s: %v", event, err)
+			return
+		}
+		if resp.IsError() {
+			log.Printf("[notify] POST non-2xx event=%s status=%d", event, resp.StatusCode())
+			return
+		}
+		log.Printf("[notify] sent event=%s", event)
+	}()
+}
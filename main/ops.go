@@ -0,0 +1,463 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+const (
+	adminOpsLockKey = "tolgee:admin:ops:lock"
+	// adminOpsLockTTL bounds how long a crashed batch can block the next one.
+	adminOpsLockTTL = 30 * time.Minute
+	// adminOpsRefreshTimeout bounds the wait for one refresh op.
+	adminOpsRefreshTimeout = 15 * time.Minute
+	adminOpsPollEvery      = 500 * time.Millisecond
+	// adminOpsBatchPrefix stores each batch report, for jobTTL.
+	adminOpsBatchPrefix = "tolgee:admin:ops:batch:"
+
+	adminOpStatusRunning = "running"
+	adminOpStatusDone    = "done"
+	adminOpStatusFailed  = "failed"
+	adminOpStatusSkipped = "skipped"
+	// adminOpStatusRolledBack is an op undone after a later op failed, and
+	// the batch status when every applied op was undone.
+	adminOpStatusRolledBack = "rolled_back"
+	// adminOpStatusPartial is the batch status when changes stayed applied
+	// after a failure: an undo failed, or continue_on_error was set.
+	adminOpStatusPartial = "partial"
+)
+
+var (
+	errAdminOpsEmpty    = errors.New("ops must list at least one operation")
+	errAdminOpsBusy     = errors.New("another ops batch is running")
+	errAdminOpsRejected = errors.New("ops batch rejected")
+	errAdminOpUnknown   = errors.New("unknown op")
+	errAdminOpNoLangs   = errors.New("op needs languages")
+	errAdminOpNoToggle  = errors.New("read_only op needs enabled")
+	errAdminOpPromoted  = errors.New("refresh disabled: PROMOTED_ONLY mode serves promoted snapshots only")
+
+	// adminOpsLocal stands in for the Redis lock with REDIS_ENABLED=false
+	adminOpsLocal sync.Mutex
+)
+
+// adminOp is one step of POST /api/admin/ops; only the fields of its op are
+// read (languages for purge/promote/refresh, modes and namespaces for
+// refresh, force for rehydrate, enabled and reason for read_only).
+type adminOp struct {
+	Op         string   `json:"op"`
+	Languages  []string `json:"languages,omitempty"`
+	Modes      []string `json:"modes,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	Force      bool     `json:"force,omitempty"`
+	Enabled    *bool    `json:"enabled,omitempty"`
+	Reason     string   `json:"reason,omitempty"`
+}
+
+type adminOpsRequest struct {
+	Ops []adminOp `json:"ops"`
+	// ContinueOnError runs the remaining ops after a failure instead of
+	// skipping them, and undoes nothing.
+	ContinueOnError bool `json:"continue_on_error"`
+}
+
+type adminOpResult struct {
+	Index      int    `json:"index"`
+	Op         string `json:"op"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	UndoError  string `json:"undo_error,omitempty"`
+	Result     any    `json:"result,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+type adminOpsReport struct {
+	ID         string          `json:"id"`
+	Status     string          `json:"status"`
+	StatusURL  string          `json:"status_url"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+	DurationMs int64           `json:"duration_ms"`
+	Results    []adminOpResult `json:"results"`
+}
+
+// adminOpKind describes an op. checkpoint, when set, captures what run is
+// about to change and returns how to put it back; the ops without one only
+// rebuild state from its source (S3, Tolgee) or change nothing. writes ops
+// are refused in read-only mode; precheck rejects the batch up front when
+// the op cannot run in the current configuration.
+type adminOpKind struct {
+	run        func(ctx context.Context, op adminOp) (any, error)
+	checkpoint func(ctx context.Context, op adminOp) (func(ctx context.Context) error, error)
+	precheck   func(ctx context.Context, op adminOp) error
+	writes     bool
+}
+
+// adminOpKinds lists every op; each one is safe to repeat.
+var adminOpKinds = map[string]adminOpKind{
+	"read_only": {
+		run: func(ctx context.Context, op adminOp) (any, error) {
+			return setReadOnly(ctx, *op.Enabled, op.Reason, "ops")
+		},
+		checkpoint: func(ctx context.Context, op adminOp) (func(ctx context.Context) error, error) {
+			previous := loadReadOnly(ctx)
+			return func(ctx context.Context) error {
+				_, err := setReadOnly(ctx, previous.Enabled, previous.Reason, "ops:rollback")
+				return err
+			}, nil
+		},
+	},
+	"purge": {
+		run: func(ctx context.Context, op adminOp) (any, error) {
+			s3c := s3ClientIfEnabled(ctx)
+			for _, lang := range op.Languages {
+				purgeLanguage(ctx, s3c, lang)
+			}
+			return map[string]any{"purged": op.Languages}, nil
+		},
+		checkpoint: func(ctx context.Context, op adminOp) (func(ctx context.Context) error, error) {
+			return checkpointSnapshots(ctx, op.Languages)
+		},
+		writes: true,
+	},
+	"refresh": {
+		run: func(ctx context.Context, op adminOp) (any, error) {
+			scope := &refreshScope{Languages: op.Languages, Modes: op.Modes, Namespaces: op.Namespaces}
+			return waitRefreshJob(ctx, enqueueRefreshJob(ctx, "ops", scope).ID)
+		},
+		checkpoint: func(ctx context.Context, op adminOp) (func(ctx context.Context) error, error) {
+			return checkpointSnapshots(ctx, op.Languages)
+		},
+		precheck: func(ctx context.Context, op adminOp) error {
+			if localenv.GetPromotedOnly() {
+				return errAdminOpPromoted
+			}
+			return nil
+		},
+		writes: true,
+	},
+	"promote": {
+		run: func(ctx context.Context, op adminOp) (any, error) {
+			res, err := promoteSnapshots(ctx, promoteRequest{Languages: op.Languages})
+			if err == nil && len(res.Failed) > 0 {
+				err = fmt.Errorf("%d objects failed to promote", len(res.Failed))
+			}
+			return res, err
+		},
+		checkpoint: func(ctx context.Context, op adminOp) (func(ctx context.Context) error, error) {
+			return checkpointSnapshots(ctx, op.Languages)
+		},
+		precheck: func(ctx context.Context, op adminOp) error {
+			if localenv.GetPromoteSourceBucket() == "" {
+				return errPromoteNoSource
+			}
+			if s3ClientIfEnabled(ctx) == nil {
+				return errPromoteNoS3
+			}
+			return nil
+		},
+		writes: true,
+	},
+	"rehydrate": {
+		run: func(ctx context.Context, op adminOp) (any, error) {
+			return rehydrateFromS3(ctx, op.Force)
+		},
+	},
+	"repair": {
+		run: func(ctx context.Context, op adminOp) (any, error) {
+			return runRepair(ctx)
+		},
+		checkpoint: func(ctx context.Context, op adminOp) (func(ctx context.Context) error, error) {
+			return checkpointSnapshots(ctx, nil)
+		},
+		writes: true,
+	},
+	"verify": {
+		run: func(ctx context.Context, op adminOp) (any, error) {
+			return runVerification(ctx)
+		},
+	},
+	"patches_reload": {
+		run: func(ctx context.Context, op adminOp) (any, error) {
+			return scanPatches(ctx)
+		},
+		precheck: func(ctx context.Context, op adminOp) error {
+			if s3ClientIfEnabled(ctx) == nil {
+				return errPatchesNoS3
+			}
+			return nil
+		},
+	},
+}
+
+// validateAdminOps rejects a malformed batch (400) before anything runs;
+// precheckAdminOps then checks it against the current state.
+func validateAdminOps(req *adminOpsRequest) error {
+	if len(req.Ops) == 0 {
+		return errAdminOpsEmpty
+	}
+	for i := range req.Ops {
+		op := &req.Ops[i]
+		if _, ok := adminOpKinds[op.Op]; !ok {
+			return fmt.Errorf("op %d: %w %q", i, errAdminOpUnknown, op.Op)
+		}
+		switch op.Op {
+		case "purge":
+			if len(op.Languages) == 0 {
+				return fmt.Errorf("op %d: %w", i, errAdminOpNoLangs)
+			}
+		case "read_only":
+			if op.Enabled == nil {
+				return fmt.Errorf("op %d: %w", i, errAdminOpNoToggle)
+			}
+		case "refresh":
+			scope := refreshScope{Languages: op.Languages, Modes: op.Modes, Namespaces: op.Namespaces}
+			if err := scope.validate(); err != nil {
+				return fmt.Errorf("op %d: %w", i, err)
+			}
+			op.Languages, op.Modes, op.Namespaces = scope.Languages, scope.Modes, scope.Namespaces
+		}
+	}
+	return nil
+}
+
+// releaseAdminOpsLock deletes the lock only while it still holds ARGV[1], so
+// a batch whose lock expired never releases the next batch's lock.
+var releaseAdminOpsLock = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// extendAdminOpsLock resets the lock TTL to ARGV[2] ms while it holds ARGV[1].
+var extendAdminOpsLock = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// precheckAdminOps rejects a batch that would fail on the current state
+// before any op runs: a writing op while read-only mode is on at that point
+// of the batch (read_only ops included), or an op its configuration rules out.
+func precheckAdminOps(ctx context.Context, req adminOpsRequest) error {
+	readOnly := isReadOnly(ctx)
+	for i, op := range req.Ops {
+		kind := adminOpKinds[op.Op]
+		if op.Op == "read_only" {
+			readOnly = *op.Enabled
+		}
+		if kind.writes && readOnly {
+			return fmt.Errorf("op %d: %w", i, errReadOnly)
+		}
+		if kind.precheck != nil {
+			if err := kind.precheck(ctx, op); err != nil {
+				return fmt.Errorf("op %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// lockAdminOps takes the batch lock, one batch at a time across replicas.
+// It returns keep, which renews the lock before each step of a long batch
+// (the TTL only covers a crashed one), and release.
+func lockAdminOps(ctx context.Context) (keep, release func(), err error) {
+	if !redisEnabled() {
+		// a single replica: the process lock is enough
+		if !adminOpsLocal.TryLock() {
+			return nil, nil, errAdminOpsBusy
+		}
+		return func() {}, adminOpsLocal.Unlock, nil
+	}
+	token := newJobID()
+	ok, err := rdb.SetNX(ctx, adminOpsLockKey, token, adminOpsLockTTL).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, errAdminOpsBusy
+	}
+	keep = func() {
+		extendAdminOpsLock.Run(context.Background(), rdb, []string{adminOpsLockKey}, token, adminOpsLockTTL.Milliseconds())
+	}
+	release = func() {
+		releaseAdminOpsLock.Run(context.Background(), rdb, []string{adminOpsLockKey}, token)
+	}
+	return keep, release, nil
+}
+
+// startAdminOps takes the lock, prechecks the batch and runs it in the
+// background; the running report is returned and kept up to date under
+// adminOpsBatchPrefix+id.
+func startAdminOps(ctx context.Context, req adminOpsRequest) (*adminOpsReport, error) {
+	keep, release, err := lockAdminOps(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := precheckAdminOps(ctx, req); err != nil {
+		release()
+		return nil, fmt.Errorf("%w: %w", errAdminOpsRejected, err)
+	}
+	id := newJobID()
+	report := &adminOpsReport{
+		ID:        id,
+		Status:    adminOpStatusRunning,
+		StatusURL: "/api/admin/ops/" + id,
+		StartedAt: time.Now().UTC(),
+		Results:   []adminOpResult{},
+	}
+	saveAdminOpsReport(ctx, report)
+	started := *report
+	go func() {
+		defer release()
+		runAdminOps(context.Background(), req, report, keep)
+	}()
+	return &started, nil
+}
+
+// runAdminOps executes the ops strictly in order. A refresh op waits for its
+// job, so later ops see its result. At the first failure the remaining ops
+// are skipped and the ops already run, the failed one included, are undone in
+// reverse order from their checkpoints: the batch ends "rolled_back", or
+// "partial" when an undo failed. With ContinueOnError every op runs and
+// nothing is undone.
+func runAdminOps(ctx context.Context, req adminOpsRequest, report *adminOpsReport, keep func()) {
+	type pendingUndo struct {
+		index int
+		undo  func(ctx context.Context) error
+	}
+	var undos []pendingUndo
+	failed, applied := false, false
+	for i, op := range req.Ops {
+		res := adminOpResult{Index: i, Op: op.Op}
+		if failed && !req.ContinueOnError {
+			res.Status = adminOpStatusSkipped
+			report.Results = append(report.Results, res)
+			continue
+		}
+		keep()
+		start := time.Now()
+		out, undo, err := runAdminOp(ctx, op, !req.ContinueOnError)
+		res.DurationMs = time.Since(start).Milliseconds()
+		res.Result = out
+		res.Status = adminOpStatusDone
+		if err != nil {
+			res.Status, res.Error = adminOpStatusFailed, err.Error()
+			failed = true
+		} else {
+			applied = true
+		}
+		if undo != nil {
+			undos = append(undos, pendingUndo{index: i, undo: undo})
+		}
+		log.Printf("[ops] %d/%d op=%s status=%s duration=%dms", i+1, len(req.Ops), op.Op, res.Status, res.DurationMs)
+		report.Results = append(report.Results, res)
+		saveAdminOpsReport(ctx, report)
+	}
+
+	switch {
+	case !failed:
+		report.Status = adminOpStatusDone
+	case req.ContinueOnError && applied:
+		report.Status = adminOpStatusPartial
+	case req.ContinueOnError || len(undos) == 0:
+		report.Status = adminOpStatusFailed
+	default:
+		report.Status = adminOpStatusRolledBack
+		for j := len(undos) - 1; j >= 0; j-- {
+			keep()
+			res := &report.Results[undos[j].index]
+			if err := undos[j].undo(ctx); err != nil {
+				res.UndoError = err.Error()
+				report.Status = adminOpStatusPartial
+				log.Printf("[ops] undo op=%d %s error: %v", res.Index+1, res.Op, err)
+				continue
+			}
+			if res.Status == adminOpStatusDone {
+				res.Status = adminOpStatusRolledBack
+			}
+			log.Printf("[ops] undone op=%d %s", res.Index+1, res.Op)
+		}
+	}
+	finished := time.Now().UTC()
+	report.FinishedAt = &finished
+	report.DurationMs = finished.Sub(report.StartedAt).Milliseconds()
+	saveAdminOpsReport(ctx, report)
+}
+
+// runAdminOp runs one op, after its checkpoint when undoable is set. The
+// undo is returned even when the op fails, as it may have half-applied.
+func runAdminOp(ctx context.Context, op adminOp, undoable bool) (any, func(ctx context.Context) error, error) {
+	kind := adminOpKinds[op.Op]
+	if kind.writes && isReadOnly(ctx) {
+		return nil, nil, errReadOnly
+	}
+	var undo func(ctx context.Context) error
+	if undoable && kind.checkpoint != nil {
+		var err error
+		if undo, err = kind.checkpoint(ctx, op); err != nil {
+			return nil, nil, fmt.Errorf("checkpoint: %w", err)
+		}
+	}
+	out, err := kind.run(ctx, op)
+	return out, undo, err
+}
+
+func saveAdminOpsReport(ctx context.Context, report *adminOpsReport) {
+	b, err := json.Marshal(report)
+	if err != nil {
+		log.Printf("[ops] marshal error id=%s: %v", report.ID, err)
+		return
+	}
+	if err := redisPut(ctx, adminOpsBatchPrefix+report.ID, b, jobTTL); err != nil {
+		log.Printf("[ops] save error id=%s: %v", report.ID, err)
+	}
+}
+
+// getAdminOpsReport loads a batch report by id; nil if unknown or expired.
+func getAdminOpsReport(ctx context.Context, id string) *adminOpsReport {
+	b, err := redisGet(ctx, adminOpsBatchPrefix+id)
+	if err != nil || len(b) == 0 {
+		return nil
+	}
+	var report adminOpsReport
+	if err := json.Unmarshal(b, &report); err != nil {
+		return nil
+	}
+	return &report
+}
+
+// waitRefreshJob polls a refresh job until it finishes; a failed job is
+// returned along with its error.
+func waitRefreshJob(ctx context.Context, id string) (*refreshJob, error) {
+	ctx, cancel := context.WithTimeout(ctx, adminOpsRefreshTimeout)
+	defer cancel()
+	ticker := time.NewTicker(adminOpsPollEvery)
+	defer ticker.Stop()
+	for {
+		job := getRefreshJob(ctx, id)
+		switch {
+		case job == nil:
+			return nil, fmt.Errorf("refresh job %s not found", id)
+		case job.Status == jobStatusDone:
+			return job, nil
+		case job.Status == jobStatusFailed:
+			return job, errors.New(job.Error)
+		}
+		select {
+		case <-ctx.Done():
+			return job, fmt.Errorf("refresh job %s: %w", id, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// resolveRegionOverlay splits a request into the language whose catalog is
+// served and the REGION_OVERLAYS language laid over it: "de-AT" (or "de" with
+// ?region=AT / X-Region: AT) serves "de" with the de-AT legal namespaces.
+// Without a configured overlay it returns lang and "".
+func resolveRegionOverlay(c *fiber.Ctx, lang string) (base, overlay string) {
+	overlays := localenv.GetRegionOverlays()
+	if len(overlays) == 0 {
+		return lang, ""
+	}
+	// responses without the header differ from those with it: caches must
+	// key on X-Region whether or not this request sent it
+	c.Vary("X-Region")
+	candidate := lang
+	region := c.Query("region")
+	if region == "" {
+		region = c.Get("X-Region")
+	}
+	if region != "" && !strings.Contains(lang, "-") {
+		candidate = lang + "-" + strings.ToUpper(region)
+	}
+	if _, ok := overlays[candidate]; !ok {
+		return lang, ""
+	}
+	base, _, _ = strings.Cut(candidate, "-")
+	return base, candidate
+}
+
+// applyRegionOverlay replaces the overlay's namespaces (top-level sections in
+// nested mode, first key segment in flat mode) with the overlay language's
+// content. A namespace the overlay language lacks keeps the base content.
+// Results are cached per base/overlay/shape combination.
+func applyRegionOverlay(c *fiber.Ctx, overlay string, nested bool, payload []byte) ([]byte, error) {
+	if overlay == "" {
+		return payload, nil
+	}
+	ctx := context.Background()
+	if _, ok := loadManifest(ctx).Languages[overlay]; !ok {
+		return payload, nil
+	}
+	source, err := loadTranslationsVariant(c, overlay, nested)
+	if err != nil {
+		return payload, nil
+	}
+	delim := ""
+	if !nested {
+		delim, _ = resolveDelimiter(c)
+	}
+	key := "tolgee:overlay:" + overlay + ":" + modeLabel(nested) + ":" + sha256Hex([]byte(sha256Hex(payload) + sha256Hex(source) + delim))[:12]
+	c.Set("X-Region-Overlay", overlay)
+	c.Locals(localsOverridden, true)
+	if cached, err := redisGet(ctx, key); err == nil && len(cached) > 0 {
+		return cached, nil
+	}
+
+	var tree, overlayTree map[string]any
+	if err := decodeJSON(payload, &tree); err != nil {
+		return nil, err
+	}
+	if err := decodeJSON(source, &overlayTree); err != nil {
+		return nil, err
+	}
+	namespaces := map[string]bool{}
+	for _, ns := range localenv.GetRegionOverlays()[overlay] {
+		namespaces[ns] = true
+	}
+	sep := "."
+	if delim != "" {
+		sep = delim
+	}
+	inOverlay := func(k string) bool {
+		if nested {
+			return namespaces[k]
+		}
+		ns, _, _ := strings.Cut(k, sep)
+		return namespaces[ns]
+	}
+	replaced := map[string]bool{}
+	for k, v := range overlayTree {
+		if inOverlay(k) {
+			replaced[namespaceOf(k, nested, sep)] = true
+			tree[k] = v
+		}
+	}
+	// flat keys the overlay dropped must not survive from the base
+	if !nested {
+		for k := range tree {
+			if inOverlay(k) && replaced[namespaceOf(k, false, sep)] {
+				if _, ok := overlayTree[k]; !ok {
+					delete(tree, k)
+				}
+			}
+		}
+	}
+	out, err := marshalJSON(tree)
+	if err != nil {
+		return nil, err
+	}
+	_ = redisPut(ctx, key, out, derivedVariantTTL())
+	return out, nil
+}
+
+func namespaceOf(k string, nested bool, sep string) string {
+	if nested {
+		return k
+	}
+	ns, _, _ := strings.Cut(k, sep)
+	return ns
+}
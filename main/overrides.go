@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	overridesCacheKey     = "tolgee:overrides"
+	overridesAuditKey     = "tolgee:overrides:audit"
+	overridesAuditMaxSize = 1000
+
+	// localsOverridden marks a request whose catalog was patched by overrides,
+	// so pre-encoded variants of the plain snapshot must not be served.
+	localsOverridden = "overridden"
+)
+
+// translationOverride replaces one Tolgee key of one language at serve time.
+// Key is the Tolgee key name, with "." separating nested levels.
+type translationOverride struct {
+	Lang      string     `json:"lang"`
+	Key       string     `json:"key"`
+	Value     string     `json:"value"`
+	Reason    string     `json:"reason,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func (o translationOverride) active(now time.Time) bool {
+	return o.ExpiresAt == nil || now.Before(*o.ExpiresAt)
+}
+
+// overrideRequest is the body of PUT /api/admin/overrides; TTL is a Go
+// duration ("2h"), empty for an override that lasts until deleted.
+type overrideRequest struct {
+	Lang   string `json:"lang"`
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+	TTL    string `json:"ttl"`
+}
+
+// overrideAuditEntry records one change to the override store.
+type overrideAuditEntry struct {
+	Action   string               `json:"action"`
+	Lang     string               `json:"lang"`
+	Key      string               `json:"key"`
+	Override *translationOverride `json:"override,omitempty"`
+	Previous *translationOverride `json:"previous,omitempty"`
+	Actor    string               `json:"actor,omitempty"`
+	At       time.Time            `json:"at"`
+}
+
+var (
+	errInvalidOverride = errors.New("override needs lang, key and a valid ttl")
+	errOverrideMissing = errors.New("override not found")
+
+	overridesMu sync.Mutex
+)
+
+// loadOverrides returns every stored override, expired ones included.
+func loadOverrides(ctx context.Context) []translationOverride {
+	b, err := redisGet(ctx, overridesCacheKey)
+	if err != nil || len(b) == 0 {
+		return nil
+	}
+	var list []translationOverride
+	if err := json.Unmarshal(b, &list); err != nil {
+		log.Printf("[overrides] unmarshal error: %v", err)
+		return nil
+	}
+	return list
+}
+
+// activeOverrides returns the overrides currently in force, optionally for one language.
+func activeOverrides(ctx context.Context, lang string) []translationOverride {
+	now := time.Now()
+	out := []translationOverride{}
+	for _, o := range loadOverrides(ctx) {
+		if o.active(now) && (lang == "" || o.Lang == lang) {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// putOverride creates or replaces the override for req.Lang/req.Key.
+func putOverride(ctx context.Context, req overrideRequest, actor string) (*translationOverride, error) {
+	if req.Lang == "" || req.Key == "" {
+		return nil, errInvalidOverride
+	}
+	o := translationOverride{Lang: req.Lang, Key: req.Key, Value: req.Value, Reason: req.Reason, CreatedAt: time.Now().UTC()}
+	if req.TTL != "" {
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			return nil, errInvalidOverride
+		}
+		expires := o.CreatedAt.Add(ttl)
+		o.ExpiresAt = &expires
+	}
+	previous := mutateOverrides(ctx, req.Lang, req.Key, &o)
+	auditOverride(ctx, overrideAuditEntry{Action: "put", Lang: o.Lang, Key: o.Key, Override: &o, Previous: previous, Actor: actor})
+	return &o, nil
+}
+
+// deleteOverride removes the override for lang/key.
+func deleteOverride(ctx context.Context, lang, key, actor string) error {
+	previous := mutateOverrides(ctx, lang, key, nil)
+	if previous == nil {
+		return errOverrideMissing
+	}
+	auditOverride(ctx, overrideAuditEntry{Action: "delete", Lang: lang, Key: key, Previous: previous, Actor: actor})
+	return nil
+}
+
+// mutateOverrides replaces (or with next == nil removes) the lang/key entry,
+// drops expired entries and persists the store to Redis and S3.
+// It returns the entry that was replaced, if any.
+func mutateOverrides(ctx context.Context, lang, key string, next *translationOverride) *translationOverride {
+	overridesMu.Lock()
+	defer overridesMu.Unlock()
+
+	now := time.Now()
+	var previous *translationOverride
+	kept := []translationOverride{}
+	for _, o := range loadOverrides(ctx) {
+		if o.Lang == lang && o.Key == key {
+			prev := o
+			previous = &prev
+			continue
+		}
+		if o.active(now) {
+			kept = append(kept, o)
+		}
+	}
+	if next != nil {
+		kept = append(kept, *next)
+	}
+	b, err := json.Marshal(kept)
+	if err != nil {
+		log.Printf("[overrides] marshal error: %v", err)
+		return previous
+	}
+	storeCacheEntry(ctx, s3ClientIfEnabled(ctx), overridesCacheKey, b, "application/json")
+	return previous
+}
+
+func auditOverride(ctx context.Context, entry overrideAuditEntry) {
+	entry.At = time.Now().UTC()
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	_ = rdb.LPush(ctx, overridesAuditKey, b).Err()
+	_ = rdb.LTrim(ctx, overridesAuditKey, 0, overridesAuditMaxSize-1).Err()
+	log.Printf("[overrides] %s lang=%s key=%q actor=%q", entry.Action, entry.Lang, entry.Key, entry.Actor)
+}
+
+// listOverrideAudit returns the most recent override changes, newest first.
+func listOverrideAudit(ctx context.Context, count int64) []json.RawMessage {
+	raw, err := rdb.LRange(ctx, overridesAuditKey, 0, count-1).Result()
+	if err != nil {
+		return []json.RawMessage{}
+	}
+	out := make([]json.RawMessage, 0, len(raw))
+	for _, r := range raw {
+		out = append(out, json.RawMessage(r))
+	}
+	return out
+}
+
+// applyRequestOverrides merges the active overrides of lang over the catalog
+// shaped for this request (nested paths, or flat keys joined by the delimiter).
+func applyRequestOverrides(c *fiber.Ctx, lang string, nested bool, payload []byte) ([]byte, error) {
+	overrides := activeOverrides(context.Background(), lang)
+	if len(overrides) == 0 {
+		return payload, nil
+	}
+	delim := ""
+	if !nested {
+		delim, _ = resolveDelimiter(c)
+	}
+	var tree map[string]any
+	if err := decodeJSON(payload, &tree); err != nil {
+		return nil, err
+	}
+	for _, o := range overrides {
+		setCatalogValue(tree, o.Key, nested, delim, o.Value)
+	}
+	c.Locals(localsOverridden, true)
+	return marshalJSON(tree)
+}
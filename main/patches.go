@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+const (
+	activePatchesKey = "tolgee:patches"
+	patchesLockKey   = "tolgee:patches:lock"
+)
+
+var (
+	errPatchOp        = errors.New("unsupported patch op")
+	errPatchPath      = errors.New("patch path must be a JSON pointer")
+	errPatchTarget    = errors.New("patch path not found")
+	errPatchTest      = errors.New("patch test failed")
+	errPatchesNoS3    = errors.New("patches need S3")
+	errPatchesPartial = errors.New("some patch files could not be read")
+)
+
+// patchOp is one RFC 6902 operation. Pointer segments are joined into the
+// Tolgee key ("/home/title" → "home.title"), so the same patch applies to
+// nested and flat catalogs; array items are addressed like in Tolgee keys
+// ("/menu/items[0]").
+type patchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	From  string `json:"from,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+// activePatch is a break-glass patch file found in S3 for one language.
+type activePatch struct {
+	Lang     string    `json:"lang"`
+	S3Key    string    `json:"s3_key"`
+	Sha      string    `json:"sha"`
+	Ops      []patchOp `json:"ops,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	LoadedAt time.Time `json:"loaded_at"`
+	// SnapshotSha is the nested snapshot the patch was loaded against, to
+	// spot patches left over once Tolgee is back.
+	SnapshotSha string `json:"snapshot_sha,omitempty"`
+}
+
+// loadActivePatches returns the patches of the last scan, by language.
+func loadActivePatches(ctx context.Context) map[string]activePatch {
+	b, err := redisGet(ctx, activePatchesKey)
+	if err != nil || len(b) == 0 {
+		return map[string]activePatch{}
+	}
+	patches := map[string]activePatch{}
+	if err := decodeJSON(b, &patches); err != nil {
+		log.Printf("[patches] unmarshal error: %v", err)
+		return map[string]activePatch{}
+	}
+	return patches
+}
+
+// listActivePatches returns the patches sorted by language.
+func listActivePatches(ctx context.Context) []activePatch {
+	patches := loadActivePatches(ctx)
+	out := make([]activePatch, 0, len(patches))
+	for _, p := range patches {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Lang < out[j].Lang })
+	return out
+}
+
+// scanPatches reads every <PATCHES_S3_PREFIX><lang>.json object and replaces
+// the active patch set. Invalid files are reported with their error and not
+// applied; removing a file from S3 deactivates its patch on the next scan.
+func scanPatches(ctx context.Context) ([]activePatch, error) {
+	s3c := s3ClientIfEnabled(ctx)
+	if s3c == nil {
+		return nil, errPatchesNoS3
+	}
+	prefix := localenv.GetPatchesS3Prefix()
+	keys, err := s3c.listKeys(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	previous := loadActivePatches(ctx)
+	patches := map[string]activePatch{}
+	var readErr error
+	for _, key := range keys {
+		lang, ok := strings.CutSuffix(strings.TrimPrefix(key, prefix), ".json")
+		if !ok || lang == "" || strings.Contains(lang, "/") {
+			continue
+		}
+		raw, err := s3c.getObject(ctx, key)
+		if err != nil {
+			if prev, ok := previous[lang]; ok {
+				patches[lang] = prev
+			}
+			readErr = errPatchesPartial
+			continue
+		}
+		p := activePatch{Lang: lang, S3Key: key, Sha: sha256Hex(raw), LoadedAt: time.Now().UTC()}
+		if prev, ok := previous[lang]; ok && prev.Sha == p.Sha {
+			patches[lang] = prev
+			continue
+		}
+		if err := parsePatch(raw, &p.Ops); err != nil {
+			p.Ops, p.Error = nil, err.Error()
+		}
+		if snapshot, err := redisGet(ctx, translationsCacheKey(lang, true)); err == nil && len(snapshot) > 0 {
+			p.SnapshotSha = sha256Hex(snapshot)
+		}
+		log.Printf("[patches] loaded lang=%s sha=%.12s ops=%d error=%q", lang, p.Sha, len(p.Ops), p.Error)
+		patches[lang] = p
+	}
+	for lang := range previous {
+		if _, ok := patches[lang]; !ok {
+			log.Printf("[patches] removed lang=%s", lang)
+		}
+	}
+	b, err := json.Marshal(patches)
+	if err != nil {
+		return nil, err
+	}
+	if err := redisPut(ctx, activePatchesKey, b, 0); err != nil {
+		return nil, err
+	}
+	return listActivePatches(ctx), readErr
+}
+
+func parsePatch(raw []byte, ops *[]patchOp) error {
+	if err := decodeJSON(raw, ops); err != nil {
+		return err
+	}
+	for i, op := range *ops {
+		if !strings.HasPrefix(op.Path, "/") || op.From != "" && !strings.HasPrefix(op.From, "/") {
+			return fmt.Errorf("op %d: %w", i, errPatchPath)
+		}
+		switch op.Op {
+		case "add", "replace", "remove", "test":
+		case "move", "copy":
+			if op.From == "" {
+				return fmt.Errorf("op %d: %w", i, errPatchPath)
+			}
+		default:
+			return fmt.Errorf("op %d: %w %q", i, errPatchOp, op.Op)
+		}
+	}
+	return nil
+}
+
+// patchKey turns a JSON pointer into the Tolgee key it addresses in tree.
+// A segment indexing an array becomes a Tolgee index, as in RFC 6901:
+// "/menu/items/0" → "menu.items[0]", and "-" is the position past the
+// last item (where add appends).
+func patchKey(tree map[string]any, pointer string, nested bool, delim string) string {
+	segments := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	var key strings.Builder
+	for i, s := range segments {
+		s = strings.NewReplacer("~1", "/", "~0", "~").Replace(s)
+		if i > 0 {
+			if n, ok := patchArrayIndex(tree, key.String(), s, nested, delim); ok {
+				key.WriteString("[" + strconv.Itoa(n) + "]")
+				continue
+			}
+			key.WriteByte('.')
+		}
+		key.WriteString(s)
+	}
+	return key.String()
+}
+
+// patchArrayIndex resolves segment as an index of the array at key: a
+// number without leading zeros, or "-" for its length.
+func patchArrayIndex(tree map[string]any, key, segment string, nested bool, delim string) (int, bool) {
+	n := -1
+	if segment != "-" {
+		var err error
+		if n, err = strconv.Atoi(segment); err != nil || n < 0 || strconv.Itoa(n) != segment {
+			return 0, false
+		}
+	}
+	length, ok := catalogArrayLen(tree, key, nested, delim)
+	if !ok {
+		return 0, false
+	}
+	if n < 0 {
+		return length, true
+	}
+	return n, true
+}
+
+// catalogArrayLen returns the length of the array at key; in a flat catalog
+// the items are the keys below key[0], key[1], ...
+func catalogArrayLen(tree map[string]any, key string, nested bool, delim string) (int, bool) {
+	if nested {
+		v, _ := lookupCatalogValue(tree, key, true, "")
+		arr, ok := v.([]any)
+		return len(arr), ok
+	}
+	sep := delim
+	if sep == "" {
+		sep = "." // catalogPath keeps the dots
+	}
+	flatItem := func(i int) bool {
+		item := catalogPath(key+"["+strconv.Itoa(i)+"]", false, delim)[0].key
+		for k := range tree {
+			if k == item || strings.HasPrefix(k, item+"[") || strings.HasPrefix(k, item+sep) {
+				return true
+			}
+		}
+		return false
+	}
+	n := 0
+	for flatItem(n) {
+		n++
+	}
+	return n, n > 0
+}
+
+// applyPatchOps runs ops in order over tree. Like RFC 6902, a failing op
+// (e.g. a test) aborts the whole patch; the caller then serves the catalog
+// unpatched.
+func applyPatchOps(tree map[string]any, ops []patchOp, nested bool, delim string) error {
+	for i, op := range ops {
+		key := patchKey(tree, op.Path, nested, delim)
+		current, exists := lookupCatalogValue(tree, key, nested, delim)
+		switch op.Op {
+		case "add":
+			setCatalogValue(tree, key, nested, delim, op.Value)
+		case "replace":
+			if !exists {
+				return fmt.Errorf("op %d %s: %w", i, op.Path, errPatchTarget)
+			}
+			setCatalogValue(tree, key, nested, delim, op.Value)
+		case "remove":
+			if !exists {
+				return fmt.Errorf("op %d %s: %w", i, op.Path, errPatchTarget)
+			}
+			deleteCatalogValue(tree, key, nested, delim)
+		case "test":
+			if !exists || !reflect.DeepEqual(current, op.Value) {
+				return fmt.Errorf("op %d %s: %w", i, op.Path, errPatchTest)
+			}
+		case "move", "copy":
+			from := patchKey(tree, op.From, nested, delim)
+			value, ok := lookupCatalogValue(tree, from, nested, delim)
+			if !ok {
+				return fmt.Errorf("op %d %s: %w", i, op.From, errPatchTarget)
+			}
+			if op.Op == "move" {
+				deleteCatalogValue(tree, from, nested, delim)
+			}
+			setCatalogValue(tree, key, nested, delim, value)
+		}
+	}
+	return nil
+}
+
+// applyS3Patch applies the active break-glass patch of lang, if any, over
+// the catalog shaped for this request.
+func applyS3Patch(c *fiber.Ctx, lang string, nested bool, payload []byte) ([]byte, error) {
+	p, ok := loadActivePatches(context.Background())[lang]
+	if !ok || len(p.Ops) == 0 {
+		return payload, nil
+	}
+	delim := ""
+	if !nested {
+		delim, _ = resolveDelimiter(c)
+	}
+	var tree map[string]any
+	if err := decodeJSON(payload, &tree); err != nil {
+		return nil, err
+	}
+	if err := applyPatchOps(tree, p.Ops, nested, delim); err != nil {
+		log.Printf("[patches] lang=%s sha=%.12s not applied: %v", lang, p.Sha, err)
+		return payload, nil
+	}
+	c.Locals(localsOverridden, true)
+	c.Set("X-Patched", p.Sha[:12])
+	return marshalJSON(tree)
+}
+
+// startPatchPolling rescans the patch prefix every PATCHES_POLL_INTERVAL;
+// a Redis lock keeps replicas from scanning at the same time.
+func startPatchPolling() {
+	interval := localenv.GetPatchesPollInterval()
+	if interval <= 0 || !localenv.GetS3Enabled() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ctx := context.Background()
+			if !redisTryLock(ctx, patchesLockKey, interval/2) {
+				continue
+			}
+			if _, err := scanPatches(ctx); err != nil {
+				log.Printf("[patches] scan error: %v", err)
+			}
+		}
+	}()
+}
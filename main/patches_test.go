@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestApplyPatchOps(t *testing.T) {
+	tests := []struct {
+		name    string
+		tree    string
+		patch   string
+		nested  bool
+		delim   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:   "add",
+			tree:   `{"home":{"title":"a"}}`,
+			patch:  `[{"op":"add","path":"/home/subtitle","value":"b"}]`,
+			nested: true,
+			want:   `{"home":{"title":"a","subtitle":"b"}}`,
+		},
+		{
+			name:   "replace",
+			tree:   `{"home":{"title":"a"}}`,
+			patch:  `[{"op":"replace","path":"/home/title","value":"x"}]`,
+			nested: true,
+			want:   `{"home":{"title":"x"}}`,
+		},
+		{
+			name:    "replace missing",
+			tree:    `{"home":{"title":"a"}}`,
+			patch:   `[{"op":"replace","path":"/home/nope","value":"x"}]`,
+			nested:  true,
+			wantErr: errPatchTarget,
+		},
+		{
+			name:   "remove",
+			tree:   `{"home":{"title":"a","subtitle":"b"}}`,
+			patch:  `[{"op":"remove","path":"/home/subtitle"}]`,
+			nested: true,
+			want:   `{"home":{"title":"a"}}`,
+		},
+		{
+			name:   "move",
+			tree:   `{"home":{"title":"a"}}`,
+			patch:  `[{"op":"move","from":"/home/title","path":"/home/heading"}]`,
+			nested: true,
+			want:   `{"home":{"heading":"a"}}`,
+		},
+		{
+			name:   "copy",
+			tree:   `{"home":{"title":"a"}}`,
+			patch:  `[{"op":"copy","from":"/home/title","path":"/about/title"}]`,
+			nested: true,
+			want:   `{"home":{"title":"a"},"about":{"title":"a"}}`,
+		},
+		{
+			name:   "passing test",
+			tree:   `{"home":{"title":"a"}}`,
+			patch:  `[{"op":"test","path":"/home/title","value":"a"},{"op":"replace","path":"/home/title","value":"x"}]`,
+			nested: true,
+			want:   `{"home":{"title":"x"}}`,
+		},
+		{
+			name:    "failing test aborts",
+			tree:    `{"home":{"title":"a"}}`,
+			patch:   `[{"op":"test","path":"/home/title","value":"b"},{"op":"replace","path":"/home/title","value":"x"}]`,
+			nested:  true,
+			wantErr: errPatchTest,
+		},
+		{
+			name:    "test on a missing path",
+			tree:    `{"home":{"title":"a"}}`,
+			patch:   `[{"op":"test","path":"/home/nope","value":"a"}]`,
+			nested:  true,
+			wantErr: errPatchTest,
+		},
+		{
+			name:   "escaped slash and tilde",
+			tree:   `{"a/b":{"c~d":"v"}}`,
+			patch:  `[{"op":"replace","path":"/a~1b/c~0d","value":"w"}]`,
+			nested: true,
+			want:   `{"a/b":{"c~d":"w"}}`,
+		},
+		{
+			name:   "tilde zero one is a literal tilde one",
+			tree:   `{"x~1y":"v"}`,
+			patch:  `[{"op":"replace","path":"/x~01y","value":"w"}]`,
+			nested: true,
+			want:   `{"x~1y":"w"}`,
+		},
+		{
+			name:   "append with dash",
+			tree:   `{"menu":{"items":["a","b"]}}`,
+			patch:  `[{"op":"add","path":"/menu/items/-","value":"c"}]`,
+			nested: true,
+			want:   `{"menu":{"items":["a","b","c"]}}`,
+		},
+		{
+			name:   "array index segment",
+			tree:   `{"menu":{"items":["a","b"]}}`,
+			patch:  `[{"op":"replace","path":"/menu/items/1","value":"B"}]`,
+			nested: true,
+			want:   `{"menu":{"items":["a","B"]}}`,
+		},
+		{
+			name:   "tolgee array index",
+			tree:   `{"menu":{"items":[{"label":"a"}]}}`,
+			patch:  `[{"op":"replace","path":"/menu/items[0]/label","value":"x"}]`,
+			nested: true,
+			want:   `{"menu":{"items":[{"label":"x"}]}}`,
+		},
+		{
+			name:   "numeric object key",
+			tree:   `{"errors":{"404":"not found"}}`,
+			patch:  `[{"op":"replace","path":"/errors/404","value":"missing"}]`,
+			nested: true,
+			want:   `{"errors":{"404":"missing"}}`,
+		},
+		{
+			name:  "flat replace",
+			tree:  `{"home_title":"a"}`,
+			patch: `[{"op":"replace","path":"/home/title","value":"x"}]`,
+			delim: "_",
+			want:  `{"home_title":"x"}`,
+		},
+		{
+			name:  "flat append with dash",
+			tree:  `{"menu.items[0]":"a","menu.items[1].label":"b"}`,
+			patch: `[{"op":"add","path":"/menu/items/-","value":"c"}]`,
+			delim: ".",
+			want:  `{"menu.items[0]":"a","menu.items[1].label":"b","menu.items[2]":"c"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ops []patchOp
+			if err := parsePatch([]byte(tt.patch), &ops); err != nil {
+				t.Fatalf("parsePatch: %v", err)
+			}
+			tree := mustDecode(t, tt.tree)
+			err := applyPatchOps(tree, ops, tt.nested, tt.delim)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyPatchOps: %v", err)
+			}
+			if want := mustDecode(t, tt.want); !reflect.DeepEqual(tree, want) {
+				t.Errorf("tree = %v, want %v", tree, want)
+			}
+		})
+	}
+}
+
+func TestParsePatchRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  error
+	}{
+		{name: "unknown op", patch: `[{"op":"merge","path":"/a"}]`, want: errPatchOp},
+		{name: "relative path", patch: `[{"op":"remove","path":"a"}]`, want: errPatchPath},
+		{name: "move without from", patch: `[{"op":"move","path":"/a"}]`, want: errPatchPath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ops []patchOp
+			if err := parsePatch([]byte(tt.patch), &ops); !errors.Is(err, tt.want) {
+				t.Errorf("parsePatch err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
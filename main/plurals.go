@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// pluralRulesJSON holds the CLDR plural rules per language. "required" lists
+// the categories a plural message must cover; categories only reached by
+// compact/exponent numbers (e.g. Italian "many") are left out of it.
+//
+//go:embed cldr/plural_rules.json
+var pluralRulesJSON []byte
+
+type pluralRule struct {
+	Category string `json:"category"`
+	Rule     string `json:"rule"`
+}
+
+type pluralRules struct {
+	Rules    []pluralRule `json:"rules"`
+	Required []string     `json:"required"`
+}
+
+var (
+	errNoPluralRules = errors.New("no plural rules for language")
+
+	pluralRulesTable = mustLoadPluralRules()
+)
+
+func mustLoadPluralRules() map[string]pluralRules {
+	table := map[string]pluralRules{}
+	if err := json.Unmarshal(pluralRulesJSON, &table); err != nil {
+		panic("cldr/plural_rules.json: " + err.Error())
+	}
+	return table
+}
+
+// resolvePluralRules looks up a tag, falling back to its base language.
+func resolvePluralRules(lang string) (string, pluralRules, error) {
+	if r, ok := pluralRulesTable[lang]; ok {
+		return lang, r, nil
+	}
+	base, _, _ := strings.Cut(lang, "-")
+	if r, ok := pluralRulesTable[base]; ok {
+		return base, r, nil
+	}
+	return "", pluralRules{}, errNoPluralRules
+}
+
+// icuPluralSelectors returns the selectors of every plural block in an ICU
+// message, nested ones included (e.g. [["one", "other"]]). Quoted text
+// ('{literal}') is skipped.
+func icuPluralSelectors(msg string) [][]string {
+	var out [][]string
+	for i := 0; i < len(msg); i++ {
+		if msg[i] == '\'' {
+			i = skipICUQuote(msg, i)
+			continue
+		}
+		if msg[i] != '{' {
+			continue
+		}
+		end := matchICUBrace(msg, i)
+		if end < 0 {
+			return out
+		}
+		inner := msg[i+1 : end]
+		parts := strings.SplitN(inner, ",", 3)
+		if len(parts) == 3 && strings.TrimSpace(parts[1]) == "plural" {
+			selectors, bodies := parseICUOptions(parts[2])
+			out = append(out, selectors)
+			for _, body := range bodies {
+				out = append(out, icuPluralSelectors(body)...)
+			}
+		} else {
+			out = append(out, icuPluralSelectors(inner)...)
+		}
+		i = end
+	}
+	return out
+}
+
+// matchICUBrace returns the index of the brace closing the one at open, or
+// -1. Braces in quoted text do not count.
+func matchICUBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '\'':
+			i = skipICUQuote(s, i)
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// skipICUQuote returns the index of the last byte of the apostrophe syntax
+// starting at i, following ICU's default (DOUBLE_OPTIONAL) mode: a doubled
+// apostrophe is a literal one, an apostrophe before a syntax character ({,
+// }, # and |) quotes everything up to the next single apostrophe (where a
+// doubled one stays literal), any other apostrophe is plain text. '#' and '|' are only
+// syntax inside plural and choice messages, but quoting them elsewhere
+// makes no difference to the braces.
+func skipICUQuote(s string, i int) int {
+	switch {
+	case i+1 >= len(s):
+		return i
+	case s[i+1] == '\'':
+		return i + 1
+	case strings.IndexByte("{}#|", s[i+1]) < 0:
+		return i
+	}
+	for j := i + 1; j < len(s); j++ {
+		if s[j] != '\'' {
+			continue
+		}
+		if j+1 < len(s) && s[j+1] == '\'' {
+			j++
+			continue
+		}
+		return j
+	}
+	// an unterminated quote runs to the end of the message
+	return len(s) - 1
+}
+
+// parseICUOptions splits "offset:1 one {a} other {b}" into selectors and bodies.
+func parseICUOptions(s string) (selectors, bodies []string) {
+	i := 0
+	for {
+		for i < len(s) && isICUSpace(s[i]) {
+			i++
+		}
+		start := i
+		for i < len(s) && s[i] != '{' && !isICUSpace(s[i]) {
+			i++
+		}
+		token := s[start:i]
+		for i < len(s) && isICUSpace(s[i]) {
+			i++
+		}
+		if strings.HasPrefix(token, "offset:") {
+			continue
+		}
+		if token == "" || i >= len(s) || s[i] != '{' {
+			return selectors, bodies
+		}
+		end := matchICUBrace(s, i)
+		if end < 0 {
+			return selectors, bodies
+		}
+		selectors = append(selectors, token)
+		bodies = append(bodies, s[i+1:end])
+		i = end + 1
+	}
+}
+
+func isICUSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+// pluralGap is a plural message missing categories required by its language.
+type pluralGap struct {
+	Key     string   `json:"key"`
+	Missing []string `json:"missing"`
+}
+
+// languageCoverage is the per-language section of the coverage report.
+type languageCoverage struct {
+	PluralGaps []pluralGap `json:"plural_gaps"`
+	CheckedAt  time.Time   `json:"checked_at"`
+
+	// MissingRequired maps a release ("app@version") to its missing keys
+	MissingRequired map[string][]string `json:"missing_required,omitempty"`
+}
+
+type coverageReport struct {
+	Languages map[string]languageCoverage `json:"languages"`
+}
+
+// coverageKey holds the coverage of one language, so refreshes of different
+// languages never rewrite each other's section.
+func coverageKey(lang string) string {
+	return "tolgee:coverage:" + lang
+}
+
+// checkPluralCoverage lists the plural messages of a flat catalog that do not
+// cover every required CLDR category of lang.
+func checkPluralCoverage(lang string, flat []byte) []pluralGap {
+	gaps := []pluralGap{}
+	_, rules, err := resolvePluralRules(lang)
+	if err != nil {
+		return gaps
+	}
+	var catalog map[string]any
+	if err := json.Unmarshal(flat, &catalog); err != nil {
+		return gaps
+	}
+	keys := make([]string, 0, len(catalog))
+	for k := range catalog {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		msg, ok := catalog[key].(string)
+		if !ok || !strings.Contains(msg, "plural") {
+			continue
+		}
+		missing := map[string]bool{}
+		for _, selectors := range icuPluralSelectors(msg) {
+			present := map[string]bool{}
+			for _, s := range selectors {
+				present[s] = true
+			}
+			for _, req := range rules.Required {
+				if !present[req] {
+					missing[req] = true
+				}
+			}
+		}
+		if len(missing) > 0 {
+			gaps = append(gaps, pluralGap{Key: key, Missing: sortedSetKeys(missing)})
+		}
+	}
+	return gaps
+}
+
+// recordCoverage validates a freshly ingested flat catalog and stores its
+// plural gaps and missing required keys in the coverage report, alerting on
+// new required-key gaps.
+func recordCoverage(ctx context.Context, lang string, flat []byte) {
+	gaps := checkPluralCoverage(lang, flat)
+	if len(gaps) > 0 {
+		log.Printf("[plurals] lang=%s %d messages miss required plural categories", lang, len(gaps))
+	}
+	required := checkRequiredKeys(ctx, flat)
+	previous, _ := loadLanguageCoverage(ctx, lang)
+	alertRequiredKeyGaps(lang, previous.MissingRequired, required)
+	b, err := json.Marshal(languageCoverage{PluralGaps: gaps, CheckedAt: time.Now().UTC(), MissingRequired: required})
+	if err != nil {
+		log.Printf("[plurals] marshal error lang=%s: %v", lang, err)
+		return
+	}
+	_ = redisPut(ctx, coverageKey(lang), b, 0)
+}
+
+func loadLanguageCoverage(ctx context.Context, lang string) (languageCoverage, bool) {
+	var cov languageCoverage
+	b, err := redisGet(ctx, coverageKey(lang))
+	if err != nil || len(b) == 0 {
+		return cov, false
+	}
+	if err := json.Unmarshal(b, &cov); err != nil {
+		log.Printf("[plurals] unmarshal error lang=%s: %v", lang, err)
+		return languageCoverage{}, false
+	}
+	return cov, true
+}
+
+// loadCoverageReport gathers the coverage of every language.
+func loadCoverageReport(ctx context.Context) *coverageReport {
+	report := &coverageReport{Languages: map[string]languageCoverage{}}
+	keys, err := redisScanKeys(ctx, coverageKey("*"))
+	if err != nil {
+		return report
+	}
+	for _, key := range keys {
+		lang := strings.TrimPrefix(key, coverageKey(""))
+		if cov, ok := loadLanguageCoverage(ctx, lang); ok {
+			report.Languages[lang] = cov
+		}
+	}
+	return report
+}
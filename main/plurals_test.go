@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestICUPluralSelectorsQuoting(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want [][]string
+	}{
+		{
+			name: "plain",
+			msg:  "{n, plural, one {# item} other {# items}}",
+			want: [][]string{{"one", "other"}},
+		},
+		{
+			name: "quoted braces in a branch",
+			msg:  "{n, plural, one {'{'# item'}'} other {# items}}",
+			want: [][]string{{"one", "other"}},
+		},
+		{
+			name: "doubled apostrophe",
+			msg:  "{n, plural, one {c''è # messaggio} other {ci sono # messaggi}}",
+			want: [][]string{{"one", "other"}},
+		},
+		{
+			name: "quoted hash",
+			msg:  "{n, plural, one {'#' item} other {# items}}",
+			want: [][]string{{"one", "other"}},
+		},
+		{
+			name: "quoted plural is text",
+			msg:  "'{n, plural, one {x}}' {n, plural, other {y}}",
+			want: [][]string{{"other"}},
+		},
+		{
+			name: "doubled apostrophe inside a quote",
+			msg:  "'{it''s}' {n, plural, one {a} other {b}}",
+			want: [][]string{{"one", "other"}},
+		},
+		{
+			name: "nested in select",
+			msg:  "{g, select, female {{n, plural, one {una} other {'{'n'}' di lei}}} other {{n, plural, other {#}}}}",
+			want: [][]string{{"one", "other"}, {"other"}},
+		},
+		{
+			name: "unterminated quote",
+			msg:  "{n, plural, one {x} other {'{y}}",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := icuPluralSelectors(tt.msg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("icuPluralSelectors(%q) = %v, want %v", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordCoverageConcurrentLanguages(t *testing.T) {
+	withMemoryStore(t)
+	ctx := context.Background()
+	flat := []byte(`{"items":"{n, plural, other {# items}}"}`)
+	langs := []string{"en", "it", "de", "fr"}
+	for i := 0; i < 16; i++ {
+		langs = append(langs, "x-"+strconv.Itoa(i))
+	}
+
+	var wg sync.WaitGroup
+	for _, lang := range langs {
+		wg.Add(1)
+		go func(lang string) {
+			defer wg.Done()
+			recordCoverage(ctx, lang, flat)
+		}(lang)
+	}
+	wg.Wait()
+
+	report := loadCoverageReport(ctx)
+	if len(report.Languages) != len(langs) {
+		t.Fatalf("report has %d languages, want %d: a concurrent write was lost", len(report.Languages), len(langs))
+	}
+	want := []pluralGap{{Key: "items", Missing: []string{"one"}}}
+	if got := report.Languages["en"].PluralGaps; !reflect.DeepEqual(got, want) {
+		t.Errorf("en plural gaps = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// stringProcessor rewrites one translation; key is the Tolgee key ("a.b").
+type stringProcessor func(key, value string) string
+
+// postProcessors is the registry behind POSTPROCESS_RULES: each factory
+// receives the rule argument (after "=") and returns the processor.
+var postProcessors = map[string]func(arg string) stringProcessor{
+	"sentence_case": sentenceCaseProcessor,
+	"nbsp_units":    func(string) stringProcessor { return nbspUnits },
+	"curly_quotes":  func(string) stringProcessor { return curlyQuotes },
+}
+
+// languagePostProcessors builds the pipeline configured for lang ("*" rules
+// first, then the language ones). Unknown rules are logged and skipped.
+func languagePostProcessors(lang string) (pipeline []stringProcessor, spec string) {
+	rules := localenv.GetPostprocessRules()
+	specs := append(append([]string(nil), rules["*"]...), rules[lang]...)
+	for _, rule := range specs {
+		name, arg, _ := strings.Cut(rule, "=")
+		factory, ok := postProcessors[name]
+		if !ok {
+			log.Printf("[postprocess] unknown rule %q for lang=%s", name, lang)
+			continue
+		}
+		pipeline = append(pipeline, factory(arg))
+	}
+	return pipeline, strings.Join(specs, "+")
+}
+
+// applyPostProcessors runs the pipeline configured for the served language
+// (the requested one after a fallback is not what the strings are written in)
+// over every string of the catalog. Processed variants are cached by served
+// language, input sha and rule set; when the served language is unknown the
+// requested one picks the rules and nothing is cached.
+func applyPostProcessors(c *fiber.Ctx, lang string, nested bool, payload []byte) ([]byte, error) {
+	served, known := servedLanguageOf(c)
+	if !known {
+		served = lang
+	}
+	pipeline, spec := languagePostProcessors(served)
+	if len(pipeline) == 0 {
+		return payload, nil
+	}
+	c.Locals(localsOverridden, true)
+
+	sum := sha256.Sum256(append(append([]byte(spec), 0), payload...))
+	key := "tolgee:postprocessed:" + served + ":" + hex.EncodeToString(sum[:8])
+	if known {
+		if cached, err := redisGet(context.Background(), key); err == nil && len(cached) > 0 {
+			return cached, nil
+		}
+	}
+
+	delim := ""
+	if !nested {
+		delim, _ = resolveDelimiter(c)
+	}
+	var tree map[string]any
+	if err := decodeJSON(payload, &tree); err != nil {
+		return nil, err
+	}
+	postProcessTree(tree, "", nested, delim, pipeline)
+	out, err := marshalJSON(tree)
+	if err != nil {
+		return nil, err
+	}
+	if known {
+		_ = redisPut(context.Background(), key, out, derivedVariantTTL())
+	}
+	return out, nil
+}
+
+func postProcessTree(tree map[string]any, prefix string, nested bool, delim string, pipeline []stringProcessor) {
+	for k, v := range tree {
+		key := k
+		switch {
+		case nested && prefix != "":
+			key = prefix + "." + k
+		case !nested && delim != "":
+			key = strings.ReplaceAll(k, delim, ".")
+		}
+		tree[k] = postProcessValue(v, key, nested, delim, pipeline)
+	}
+}
+
+// postProcessValue runs the pipeline on strings; array items are matched as
+// "key[i]", like their Tolgee key.
+func postProcessValue(v any, key string, nested bool, delim string, pipeline []stringProcessor) any {
+	switch val := v.(type) {
+	case map[string]any:
+		postProcessTree(val, key, nested, delim, pipeline)
+	case []any:
+		for i, item := range val {
+			val[i] = postProcessValue(item, key+"["+strconv.Itoa(i)+"]", nested, delim, pipeline)
+		}
+	case string:
+		for _, p := range pipeline {
+			val = p(key, val)
+		}
+		return val
+	}
+	return v
+}
+
+// sentenceCaseProcessor capitalizes the first letter and lowercases
+// Title-Cased words after it, for keys under one of the "|"-separated
+// prefixes (every key when arg is empty). Acronyms and {placeholders} are kept.
+func sentenceCaseProcessor(arg string) stringProcessor {
+	var prefixes []string
+	for _, p := range strings.Split(arg, "|") {
+		if p != "" {
+			prefixes = append(prefixes, p)
+		}
+	}
+	return func(key, value string) string {
+		if len(prefixes) > 0 && !hasAnyPrefix(key, prefixes) {
+			return value
+		}
+		var b strings.Builder
+		depth, first, wordStart := 0, true, true
+		for i, r := range value {
+			switch {
+			case r == '{':
+				depth++
+			case r == '}' && depth > 0:
+				depth--
+			case depth == 0 && unicode.IsLetter(r):
+				if first {
+					r, first = unicode.ToUpper(r), false
+				} else if wordStart && unicode.IsUpper(r) && isTitleWord(value[i+utf8.RuneLen(r):]) {
+					r = unicode.ToLower(r)
+				}
+			}
+			b.WriteRune(r)
+			wordStart = unicode.IsSpace(r)
+		}
+		return b.String()
+	}
+}
+
+// isTitleWord reports whether the rest of a word has no uppercase letters.
+func isTitleWord(rest string) bool {
+	for _, r := range rest {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) {
+			return true
+		}
+		if unicode.IsUpper(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	nbspUnitRe  = regexp.MustCompile(`(\d) (%|€|\$|£|km|kg|cm|mm|m|g|h|min|s)\b`)
+	nbspPunctRe = regexp.MustCompile(` ([;:!?»])`)
+	nbspGuilRe  = regexp.MustCompile(`« `)
+)
+
+// nbspUnits applies French typography: non-breaking space between numbers
+// and units, before high punctuation and inside guillemets.
+func nbspUnits(_, value string) string {
+	value = nbspUnitRe.ReplaceAllString(value, "$1\u00a0$2")
+	value = nbspPunctRe.ReplaceAllString(value, "\u202f$1")
+	return nbspGuilRe.ReplaceAllString(value, "«\u00a0")
+}
+
+// curlyQuotes replaces straight quotes with typographic ones outside HTML
+// tags; apostrophes are only converted between letters, so ICU '{' escapes
+// are left alone.
+func curlyQuotes(_, value string) string {
+	runes := []rune(value)
+	var b strings.Builder
+	inTag := false
+	for i, r := range runes {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>':
+			inTag = false
+		case inTag:
+		case r == '"':
+			if i == 0 || unicode.IsSpace(runes[i-1]) || runes[i-1] == '(' {
+				r = '“'
+			} else {
+				r = '”'
+			}
+		case r == '\'' && i > 0 && i < len(runes)-1 && unicode.IsLetter(runes[i-1]) && unicode.IsLetter(runes[i+1]):
+			r = '’'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
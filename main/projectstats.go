@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// tolgeeProjectStats is the subset of Tolgee /v2/projects/stats we expose.
+type tolgeeProjectStats struct {
+	KeyCount       int64   `json:"keyCount"`
+	LanguageCount  int64   `json:"languageCount"`
+	BaseWordsCount int64   `json:"baseWordsCount"`
+	TranslatedPct  float64 `json:"translatedPercentage"`
+	ReviewedPct    float64 `json:"reviewedPercentage"`
+	LanguageStats  []struct {
+		LanguageTag         string  `json:"languageTag"`
+		TranslatedKeyCount  int64   `json:"translatedKeyCount"`
+		TranslatedWordCount int64   `json:"translatedWordCount"`
+		TranslatedPct       float64 `json:"translatedPercentage"`
+		ReviewedWordCount   int64   `json:"reviewedWordCount"`
+		UntranslatedWords   int64   `json:"untranslatedWordCount"`
+	} `json:"languageStats"`
+}
+
+type languageStats struct {
+	TranslatedKeys    int64   `json:"translated_keys"`
+	TranslatedWords   int64   `json:"translated_words"`
+	ReviewedWords     int64   `json:"reviewed_words"`
+	UntranslatedWords int64   `json:"untranslated_words"`
+	TranslatedPct     float64 `json:"translated_percentage"`
+}
+
+// projectStats is the GET /api/stats payload for the localization dashboard.
+type projectStats struct {
+	KeyCount       int64                    `json:"key_count"`
+	LanguageCount  int64                    `json:"language_count"`
+	BaseWords      int64                    `json:"base_words"`
+	TranslatedPct  float64                  `json:"translated_percentage"`
+	ReviewedPct    float64                  `json:"reviewed_percentage"`
+	Languages      map[string]languageStats `json:"languages"`
+	LastActivityAt *time.Time               `json:"last_activity_at,omitempty"`
+}
+
+// GetProjectStats combines the Tolgee project stats with the timestamp of the
+// latest activity; both go through the proxy cache (TOLGEE_PROXY_TTL).
+func GetProjectStats(ctx context.Context) (*projectStats, error) {
+	raw, err := getTolgeeResourceCached(ctx, "stats", map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	var ts tolgeeProjectStats
+	if err := json.Unmarshal(raw, &ts); err != nil {
+		return nil, err
+	}
+	out := &projectStats{
+		KeyCount:      ts.KeyCount,
+		LanguageCount: ts.LanguageCount,
+		BaseWords:     ts.BaseWordsCount,
+		TranslatedPct: ts.TranslatedPct,
+		ReviewedPct:   ts.ReviewedPct,
+		Languages:     map[string]languageStats{},
+	}
+	for _, l := range ts.LanguageStats {
+		out.Languages[l.LanguageTag] = languageStats{
+			TranslatedKeys:    l.TranslatedKeyCount,
+			TranslatedWords:   l.TranslatedWordCount,
+			ReviewedWords:     l.ReviewedWordCount,
+			UntranslatedWords: l.UntranslatedWords,
+			TranslatedPct:     l.TranslatedPct,
+		}
+	}
+
+	// the last activity is optional: stats are still useful without it
+	if raw, err := getTolgeeResourceCached(ctx, "activity", map[string]string{"size": "1", "sort": "timestamp,desc"}); err == nil {
+		var page struct {
+			Embedded struct {
+				Activities []struct {
+					Timestamp int64 `json:"timestamp"`
+				} `json:"activities"`
+			} `json:"_embedded"`
+		}
+		if json.Unmarshal(raw, &page) == nil && len(page.Embedded.Activities) > 0 {
+			at := time.UnixMilli(page.Embedded.Activities[0].Timestamp).UTC()
+			out.LastActivityAt = &at
+		}
+	}
+	return out, nil
+}
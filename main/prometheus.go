@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Minimal Prometheus text exposition for /metrics: histograms, gauges and
+// counters with labels, rendered in registration order.
+
+type promSeries struct {
+	labels []string
+	counts []uint64
+	sum    float64
+	count  uint64
+	value  float64
+}
+
+type promMetric struct {
+	name       string
+	help       string
+	kind       string // "histogram", "gauge" or "counter"
+	labelNames []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*promSeries
+}
+
+var promRegistry []*promMetric
+
+func newPromMetric(kind, name, help string, buckets []float64, labelNames ...string) *promMetric {
+	m := &promMetric{name: name, help: help, kind: kind, labelNames: labelNames, buckets: buckets, series: map[string]*promSeries{}}
+	promRegistry = append(promRegistry, m)
+	return m
+}
+
+func (m *promMetric) get(labels []string) *promSeries {
+	id := strings.Join(labels, "\x00")
+	s, ok := m.series[id]
+	if !ok {
+		s = &promSeries{labels: labels, counts: make([]uint64, len(m.buckets))}
+		m.series[id] = s
+	}
+	return s
+}
+
+// observe records v in a histogram.
+func (m *promMetric) observe(v float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.get(labels)
+	for i, b := range m.buckets {
+		if v <= b {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// set stores the current value of a gauge.
+func (m *promMetric) set(v float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.get(labels).value = v
+}
+
+// add increments a counter.
+func (m *promMetric) add(v float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.get(labels).value += v
+}
+
+func (m *promMetric) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+	ids := make([]string, 0, len(m.series))
+	for id := range m.series {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		s := m.series[id]
+		if m.kind != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", m.name, promLabels(m.labelNames, s.labels, ""), promFloat(s.value))
+			continue
+		}
+		for i, b := range m.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, promLabels(m.labelNames, s.labels, promFloat(b)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, promLabels(m.labelNames, s.labels, "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", m.name, promLabels(m.labelNames, s.labels, ""), promFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", m.name, promLabels(m.labelNames, s.labels, ""), s.count)
+	}
+}
+
+func promLabels(names, values []string, le string) string {
+	var parts []string
+	for i, n := range names {
+		v := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(values[i])
+		parts = append(parts, n+`="`+v+`"`)
+	}
+	if le != "" {
+		parts = append(parts, `le="`+le+`"`)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func promFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	promPayloadBytes = newPromMetric("histogram", "mensa_payload_bytes",
+		"Size of served catalogs in bytes.",
+		[]float64{1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20},
+		"lang", "mode", "format")
+	promFormatRatio = newPromMetric("histogram", "mensa_format_size_ratio",
+		"Served body size relative to the JSON catalog it was encoded from.",
+		[]float64{0.25, 0.5, 0.6, 0.7, 0.8, 0.9, 1, 1.25},
+		"format")
+	promCompressionRatio = newPromMetric("histogram", "mensa_snapshot_compression_ratio",
+		"Gzip size relative to the raw size of stored snapshots, observed at refresh.",
+		[]float64{0.1, 0.15, 0.2, 0.25, 0.3, 0.4, 0.5, 0.75, 1},
+		"lang", "mode", "format")
+	promSnapshotBytes = newPromMetric("gauge", "mensa_snapshot_bytes",
+		"Size of the last stored snapshot in bytes.",
+		nil, "lang", "mode", "format")
+	promSchemaViolations = newPromMetric("gauge", "mensa_schema_violations",
+		"Schema violations found in the last refreshed snapshot (0 = valid).",
+		nil, "lang")
+	promLintFindings = newPromMetric("gauge", "mensa_lint_findings",
+		"Lint findings in the last refreshed snapshot, by rule and severity.",
+		nil, "lang", "rule", "severity")
+	promRedisShardUp = newPromMetric("gauge", "mensa_redis_shard_up",
+		"Health of each REDIS_SHARDS instance (1 = answering pings).",
+		nil, "shard")
+	promIngestFiltered = newPromMetric("counter", "mensa_ingest_filtered_total",
+		"Values rewritten by the INGEST_FILTERS policies at refresh.",
+		nil, "lang", "filter")
+	promS3StaleWrites = newPromMetric("counter", "mensa_s3_stale_generation_writes_total",
+		"Refresh writes to S3 skipped because the object holds a newer generation.", nil)
+)
+
+var (
+	promMemTierBytes = newPromMetric("gauge", "mensa_memcache_bytes",
+		"Bytes held by the in-process snapshot tier.", nil)
+	promMemTierLookups = newPromMetric("counter", "mensa_memcache_lookups_total",
+		"In-process tier lookups by result (hit, miss, expired).", nil, "result")
+	promMemTierEvictions = newPromMetric("counter", "mensa_memcache_evictions_total",
+		"Snapshots evicted from the in-process tier to stay within MEMORY_CACHE_MAX_BYTES.", nil, "lang")
+)
+
+// recordSchemaViolations publishes the validation outcome of a refresh.
+func recordSchemaViolations(lang string, n int) {
+	promSchemaViolations.set(float64(n), lang)
+}
+
+func recordRedisShardUp(shard string, up bool) {
+	v := 0.0
+	if up {
+		v = 1
+	}
+	promRedisShardUp.set(v, shard)
+}
+
+// recordIngestFiltered counts the values an ingest filter rewrote.
+func recordIngestFiltered(lang, filter string, n int) {
+	promIngestFiltered.add(float64(n), lang, filter)
+}
+
+// recordLintFindings publishes the lint outcome of a refresh, resetting the
+// active rules without findings to 0.
+func recordLintFindings(report *lintReport) {
+	names, severities := activeLintRules()
+	for _, name := range names {
+		promLintFindings.set(float64(report.ByRule[name]), report.Lang, name, severities[name])
+	}
+}
+
+// writePrometheus renders every registered metric plus the runtime and
+// payload-limit counters also published on /debug/vars.
+func writePrometheus(w io.Writer) {
+	for _, m := range promRegistry {
+		m.write(w)
+	}
+	fmt.Fprintf(w, "# HELP mensa_goroutines Number of goroutines.\n# TYPE mensa_goroutines gauge\nmensa_goroutines %d\n", runtime.NumGoroutine())
+	fmt.Fprintf(w, "# HELP mensa_payload_rejected_total Payloads rejected by the size limits.\n# TYPE mensa_payload_rejected_total counter\nmensa_payload_rejected_total %d\n", expPayloadRejected.Value())
+}
+
+func modeLabel(nested bool) string {
+	if nested {
+		return "nested"
+	}
+	return "flat"
+}
+
+// observeServedPayload records the size of a response body and its ratio to
+// the JSON catalog it was encoded from.
+func observeServedPayload(lang string, nested bool, format string, jsonSize, bodySize int) {
+	promPayloadBytes.observe(float64(bodySize), lang, modeLabel(nested), format)
+	if jsonSize > 0 {
+		promFormatRatio.observe(float64(bodySize)/float64(jsonSize), format)
+	}
+}
+
+// observeStoredSnapshot records size and gzip ratio of a snapshot written by a refresh.
+func observeStoredSnapshot(lang string, nested bool, format string, payload []byte) {
+	if len(payload) == 0 {
+		return
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write(payload)
+	_ = zw.Close()
+	promSnapshotBytes.set(float64(len(payload)), lang, modeLabel(nested), format)
+	promCompressionRatio.observe(float64(buf.Len())/float64(len(payload)), lang, modeLabel(nested), format)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+var (
+	errPromoteNoSource = errors.New("PROMOTE_SOURCE_BUCKET is required")
+	errPromoteNoS3     = errors.New("S3 is disabled or misconfigured")
+)
+
+// promoteRequest selects what to promote; no languages means everything.
+type promoteRequest struct {
+	Languages []string `json:"languages"`
+}
+
+type promoteResult struct {
+	SourceBucket string            `json:"source_bucket"`
+	SourcePrefix string            `json:"source_prefix"`
+	Promoted     []string          `json:"promoted"`
+	Failed       map[string]string `json:"failed,omitempty"`
+}
+
+// promoteSnapshots copies the tolgee:* snapshots from PROMOTE_SOURCE_BUCKET
+// (under PROMOTE_SOURCE_PREFIX) into the serving bucket and the store. The
+// compressed copies move with server-side CopyObject; everything else is
+// written like a refresh writes it (storeCacheEntry: memory tier, journal,
+// snapshotHardTTL), with derived artifacts invalidated and the manifest
+// updated for the promoted languages.
+func promoteSnapshots(ctx context.Context, req promoteRequest) (*promoteResult, error) {
+	srcBucket := localenv.GetPromoteSourceBucket()
+	srcPrefix := localenv.GetPromoteSourcePrefix()
+	if srcBucket == "" {
+		return nil, errPromoteNoSource
+	}
+	target := s3ClientIfEnabled(ctx)
+	if target == nil {
+		return nil, errPromoteNoS3
+	}
+
+	source := target.withBucket(srcBucket)
+	keys, err := source.listKeys(ctx, srcPrefix+"tolgee:")
+	if err != nil {
+		return nil, err
+	}
+	res := &promoteResult{SourceBucket: srcBucket, SourcePrefix: srcPrefix, Promoted: []string{}, Failed: map[string]string{}}
+	var manifest manifestBatch
+	for _, srcKey := range keys {
+		key := strings.TrimPrefix(srcKey, srcPrefix)
+		if !promoteSelects(key, req.Languages) {
+			continue
+		}
+		// compressed copies stay in S3 only
+		if isCompressedVariantKey(key) {
+			if err := target.copyObjectFrom(ctx, srcBucket, srcKey, key); err != nil {
+				res.Failed[key] = err.Error()
+				continue
+			}
+			res.Promoted = append(res.Promoted, key)
+			continue
+		}
+		payload, err := readPromoteSource(ctx, source, srcPrefix, srcKey)
+		if err != nil {
+			res.Failed[key] = err.Error()
+			continue
+		}
+		contentType := "application/json"
+		if lang, nested, variant, ok := splitSnapshotKey(key); ok {
+			if variant == "" {
+				invalidateDerivedArtifacts(ctx, target, lang, nested, payload)
+				manifest.add(lang, nested, payload)
+			} else {
+				contentType = storedVariantContentType(variant)
+			}
+		}
+		storeCacheEntry(ctx, target, key, payload, contentType)
+		if written, err := redisGet(ctx, key); err != nil || !bytes.Equal(written, payload) {
+			res.Failed[key] = "written snapshot does not read back"
+			continue
+		}
+		res.Promoted = append(res.Promoted, key)
+	}
+	manifest.flush(ctx, target)
+	log.Printf("[promote] from=%s/%s promoted=%d failed=%d", srcBucket, srcPrefix, len(res.Promoted), len(res.Failed))
+	return res, nil
+}
+
+// readPromoteSource reads a snapshot of the source bucket, following a
+// content-addressed pointer to its blob under the same prefix.
+func readPromoteSource(ctx context.Context, source *s3Client, srcPrefix, srcKey string) ([]byte, error) {
+	raw, _, err := source.readObject(ctx, srcKey)
+	if err != nil {
+		return nil, err
+	}
+	if sha, ok := casPointerSHA(raw); ok {
+		raw, _, err = source.readObject(ctx, srcPrefix+blobKey(sha))
+	}
+	return raw, err
+}
+
+// splitSnapshotKey parses tolgee:lang:<tag>:<nested>[:<storedVariant>].
+func splitSnapshotKey(key string) (lang string, nested bool, variant string, ok bool) {
+	rest, found := strings.CutPrefix(key, "tolgee:lang:")
+	if !found {
+		return "", false, "", false
+	}
+	parts := strings.Split(rest, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || (parts[1] != "true" && parts[1] != "false") {
+		return "", false, "", false
+	}
+	if len(parts) == 3 {
+		variant = parts[2]
+	}
+	return parts[0], parts[1] == "true", variant, true
+}
+
+// storedVariantContentType is the content type storeFormatVariants writes variant with.
+func storedVariantContentType(variant string) string {
+	for _, f := range payloadFormats {
+		if f.storedVariant == variant {
+			return f.contentType
+		}
+	}
+	return "application/octet-stream"
+}
+
+// promoteSelects picks the translation keys of the requested languages; the
+// shared keys (languages, namespaces, tags, manifest) only move on a full promotion.
+func promoteSelects(key string, langs []string) bool {
+	if len(langs) == 0 {
+		return strings.HasPrefix(key, "tolgee:lang:") || key == "tolgee:languages" || key == namespacesCacheKey || key == tagsCacheKey || key == manifestCacheKey
+	}
+	for _, l := range langs {
+		if strings.HasPrefix(key, "tolgee:lang:"+l+":") {
+			return true
+		}
+	}
+	return false
+}
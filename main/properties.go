@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf16"
+)
+
+// encodeCatalogProperties renders a Java .properties file readable as
+// ISO-8859-1 (Properties.load, pre-Java 9 ResourceBundle): everything outside
+// printable ASCII becomes \uXXXX, with surrogate pairs above the BMP.
+// Values keep their ICU syntax, which MessageFormat shares for {0} and quotes.
+func encodeCatalogProperties(_ string, payload []byte) ([]byte, error) {
+	keys, values, err := catalogStrings(payload)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for _, key := range keys {
+		writePropertiesString(&buf, key, true)
+		buf.WriteByte('=')
+		writePropertiesString(&buf, values[key], false)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// writePropertiesString escapes s for a key (separators and spaces too) or a
+// value (only the leading space, which load() would otherwise trim).
+func writePropertiesString(buf *bytes.Buffer, s string, key bool) {
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			buf.WriteString(`\\`)
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r == '\f':
+			buf.WriteString(`\f`)
+		case r == ' ' && (key || i == 0):
+			buf.WriteString(`\ `)
+		case (r == '=' || r == ':') && key, (r == '#' || r == '!') && i == 0:
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			if r > 0xffff {
+				hi, lo := utf16.EncodeRune(r)
+				fmt.Fprintf(buf, `\u%04x\u%04x`, hi, lo)
+			} else {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			}
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// catalogProto is published on /api/catalog.proto so clients can generate
+// their decoders; encodeCatalogProto must stay in sync with it.
+//
+//go:embed proto/catalog.proto
+var catalogProto []byte
+
+const protoWireBytes = 2
+
+// encodeCatalogProto encodes a JSON catalog as mensa.localizations.v1.Catalog.
+func encodeCatalogProto(lang string, payload []byte) ([]byte, error) {
+	var tree map[string]any
+	if err := decodeJSON(payload, &tree); err != nil {
+		return nil, err
+	}
+	var out []byte
+	out = protoAppendBytes(out, 1, []byte(lang))
+	out = protoAppendBytes(out, 2, protoEncodeNode(tree))
+	return out, nil
+}
+
+// protoEncodeNode writes Node.children entries in key order, so equal catalogs
+// always produce identical bytes.
+func protoEncodeNode(node map[string]any) []byte {
+	keys := make([]string, 0, len(node))
+	for k := range node {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var out []byte
+	for _, k := range keys {
+		var entry []byte
+		entry = protoAppendBytes(entry, 1, []byte(k))
+		entry = protoAppendBytes(entry, 2, protoEncodeValue(node[k]))
+		out = protoAppendBytes(out, 1, entry)
+	}
+	return out
+}
+
+func protoEncodeValue(v any) []byte {
+	switch t := v.(type) {
+	case map[string]any:
+		return protoAppendBytes(nil, 2, protoEncodeNode(t))
+	case []any:
+		// arrays become nodes keyed by index
+		node := make(map[string]any, len(t))
+		for i, item := range t {
+			node[fmt.Sprintf("%d", i)] = item
+		}
+		return protoAppendBytes(nil, 2, protoEncodeNode(node))
+	case string:
+		return protoAppendBytes(nil, 1, []byte(t))
+	case nil:
+		return protoAppendBytes(nil, 1, nil)
+	default:
+		return protoAppendBytes(nil, 1, []byte(fmt.Sprint(t)))
+	}
+}
+
+// protoAppendBytes appends a length-delimited field (strings and messages).
+func protoAppendBytes(out []byte, field int, b []byte) []byte {
+	out = binary.AppendUvarint(out, uint64(field)<<3|protoWireBytes)
+	out = binary.AppendUvarint(out, uint64(len(b)))
+	return append(out, b...)
+}
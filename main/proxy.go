@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+var (
+	errProxyPathNotAllowed  = errors.New("tolgee endpoint not allowed by TOLGEE_PROXY_ALLOWED")
+	errProxyQueryNotAllowed = errors.New("query parameter not allowed by TOLGEE_PROXY_QUERY")
+)
+
+// maxProxyQueryValue bounds each forwarded value, and with it the cache key.
+const maxProxyQueryValue = 128
+
+// tolgeeProxyAllowed reports whether path (relative to /v2/projects/) is in
+// the TOLGEE_PROXY_ALLOWED whitelist: exact match or a sub-path of an entry.
+func tolgeeProxyAllowed(path string) bool {
+	if path == "" || strings.Contains(path, "..") {
+		return false
+	}
+	for _, allowed := range localenv.GetTolgeeProxyAllowed() {
+		if path == allowed || strings.HasPrefix(path, allowed+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyCacheKey identifies a proxied request: path plus sorted query.
+func proxyCacheKey(path string, query map[string]string) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := url.Values{}
+	for _, k := range keys {
+		values.Set(k, query[k])
+	}
+	key := "tolgee:proxy:" + path
+	if len(values) > 0 {
+		key += "?" + values.Encode()
+	}
+	return key
+}
+
+// tolgeeProxyQueryAllowed reports whether every query parameter is in the
+// TOLGEE_PROXY_QUERY whitelist, so clients cannot mint cache entries (and
+// Tolgee calls) with arbitrary parameters.
+func tolgeeProxyQueryAllowed(query map[string]string) bool {
+	allowed := localenv.GetTolgeeProxyQuery()
+	for k, v := range query {
+		if !slices.Contains(allowed, k) || len(v) > maxProxyQueryValue {
+			return false
+		}
+	}
+	return true
+}
+
+// GetTolgeeResourceFromCache serves a whitelisted Tolgee read endpoint with
+// the usual discipline: Redis (TOLGEE_PROXY_TTL) → Tolgee live (singleflight,
+// failure cooldown) → Redis + S3; when Tolgee fails the last S3 copy is served.
+func GetTolgeeResourceFromCache(ctx context.Context, path string, query map[string]string) ([]byte, error) {
+	if !tolgeeProxyAllowed(path) {
+		return nil, errProxyPathNotAllowed
+	}
+	if !tolgeeProxyQueryAllowed(query) {
+		return nil, errProxyQueryNotAllowed
+	}
+	return getTolgeeResourceCached(ctx, path, query)
+}
+
+// getTolgeeResourceCached is GetTolgeeResourceFromCache without the whitelist,
+// for endpoints the service itself consumes.
+func getTolgeeResourceCached(ctx context.Context, path string, query map[string]string) ([]byte, error) {
+	delete(query, "ak")
+	key := proxyCacheKey(path, query)
+	if cached, err := redisGet(ctx, key); err == nil && len(cached) > 0 {
+		return cached, nil
+	}
+
+	s3c := s3ClientIfEnabled(ctx)
+	if localenv.GetPromotedOnly() {
+		return s3c.getObject(ctx, key)
+	}
+	body, err := fetchUpstream(ctx, key, func() ([]byte, error) {
+		return GetProjectResource(ctx, localenv.GetTolgeeAppKey(), path, query)
+	})
+	if err != nil {
+		if stale, s3err := s3c.getObject(ctx, key); s3err == nil && len(stale) > 0 {
+			log.Printf("[proxy] serving s3 copy key=%q: %v", key, err)
+			return stale, nil
+		}
+		return nil, err
+	}
+	_ = redisPut(ctx, key, body, tolgeeProxyTTL())
+	if s3c != nil {
+		_ = s3c.putObject(ctx, key, body, "application/json", nil)
+	}
+	return body, nil
+}
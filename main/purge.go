@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// purgeLanguage retires a language that no longer exists in Tolgee: its Redis
+// entries (including derived variants and artifacts) are deleted, its S3 objects are moved
+// under archive/<timestamp>/ and it is dropped from the manifest.
+func purgeLanguage(ctx context.Context, s3c *s3Client, tag string) {
+	for _, nested := range []bool{false, true} {
+		invalidateDerivedArtifacts(ctx, s3c, tag, nested, nil)
+	}
+	keys, err := redisScanKeys(ctx, "tolgee:lang:"+tag+":*")
+	if err != nil {
+		log.Printf("[purge] scan error lang=%s: %v", tag, err)
+	}
+	if len(keys) > 0 {
+		if err := redisDel(ctx, keys...); err != nil {
+			log.Printf("[purge] redis del error lang=%s: %v", tag, err)
+		}
+	}
+	for _, nested := range []bool{false, true} {
+		memForget(translationsCacheKey(tag, nested))
+	}
+
+	if s3c != nil {
+		stamp := time.Now().UTC().Format("20060102T150405Z")
+		for _, nested := range []bool{false, true} {
+			key := translationsCacheKey(tag, nested)
+			objects := []string{key}
+			for _, v := range compressedVariants {
+				objects = append(objects, key+v.suffix)
+			}
+			for _, f := range payloadFormats {
+				if f.storedVariant != "" {
+					objects = append(objects, key+":"+f.storedVariant)
+				}
+			}
+			for _, k := range objects {
+				_ = s3c.archiveObject(ctx, k, "archive/"+stamp+"/"+k)
+			}
+		}
+	}
+
+	_ = redisDel(ctx, lintReportKey(tag), coverageKey(tag))
+	updateManifest(ctx, s3c, func(m *translationsManifest) {
+		delete(m.Languages, tag)
+	})
+	log.Printf("[purge] language removed lang=%s redis_keys=%d", tag, len(keys))
+	notifyOutgoing("language_removed", map[string]string{"language": tag})
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+// encodeCatalogQtTS renders a Qt Linguist .ts file with id-based messages:
+// one <context> per namespace (the first key segment), <message id> set to
+// the full flat key. Values keep their ICU syntax, XML-escaped.
+func encodeCatalogQtTS(lang string, payload []byte) ([]byte, error) {
+	keys, values, err := catalogStrings(payload)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString("<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<!DOCTYPE TS>\n")
+	buf.WriteString(`<TS version="2.1" language="` + qtEscape(strings.ReplaceAll(lang, "-", "_")) + "\">\n")
+	context := ""
+	for i, key := range keys {
+		ns, _, _ := strings.Cut(key, ".")
+		if i == 0 || ns != context {
+			if i > 0 {
+				buf.WriteString("</context>\n")
+			}
+			context = ns
+			buf.WriteString("<context>\n    <name>" + qtEscape(ns) + "</name>\n")
+		}
+		buf.WriteString(`    <message id="` + qtEscape(key) + "\">\n")
+		buf.WriteString("        <source>" + qtEscape(key) + "</source>\n")
+		buf.WriteString("        <translation>" + qtEscape(values[key]) + "</translation>\n")
+		buf.WriteString("    </message>\n")
+	}
+	if len(keys) > 0 {
+		buf.WriteString("</context>\n")
+	}
+	buf.WriteString("</TS>\n")
+	return buf.Bytes(), nil
+}
+
+var qtEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&apos;")
+
+func qtEscape(s string) string { return qtEscaper.Replace(s) }
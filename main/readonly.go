@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+)
+
+const (
+	readOnlyKey        = "tolgee:read-only"
+	readOnlyPendingKey = "tolgee:read-only:pending"
+)
+
+var errReadOnly = errors.New("read-only mode: refreshes are frozen, serving existing caches")
+
+// readOnlyState freezes every refresh (webhook, manual, retry, repair,
+// promotion, warm-up) on all replicas while the caches keep being served.
+type readOnlyState struct {
+	Enabled bool       `json:"enabled"`
+	Reason  string     `json:"reason,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+	By      string     `json:"by,omitempty"`
+	// PendingTrigger is the first webhook deferred while frozen; a full
+	// refresh runs for it when read-only mode is turned off
+	PendingTrigger string `json:"pending_trigger,omitempty"`
+}
+
+// loadReadOnly reads the shared state; Redis errors count as writable so a
+// Redis hiccup cannot freeze refreshes by itself.
+func loadReadOnly(ctx context.Context) readOnlyState {
+	var state readOnlyState
+	if b, err := redisGet(ctx, readOnlyKey); err == nil && len(b) > 0 {
+		if err := json.Unmarshal(b, &state); err != nil {
+			log.Printf("[readonly] unmarshal error: %v", err)
+			return readOnlyState{}
+		}
+	}
+	if pending, err := redisGet(ctx, readOnlyPendingKey); err == nil {
+		state.PendingTrigger = string(pending)
+	}
+	return state
+}
+
+func isReadOnly(ctx context.Context) bool {
+	return loadReadOnly(ctx).Enabled
+}
+
+// deferRefresh remembers that a refresh was owed while frozen (the first
+// trigger wins: any of them leads to the same full refresh).
+func deferRefresh(ctx context.Context, trigger string) {
+	if redisEnabled() {
+		_ = rdb.SetNX(ctx, readOnlyPendingKey, trigger, 0).Err()
+	} else if _, err := redisGet(ctx, readOnlyPendingKey); errors.Is(err, redis.Nil) {
+		_ = redisPut(ctx, readOnlyPendingKey, []byte(trigger), 0)
+	}
+	log.Printf("[readonly] deferred trigger=%s", trigger)
+}
+
+// setReadOnly toggles the mode. Turning it off queues one full refresh when
+// webhooks were deferred meanwhile.
+func setReadOnly(ctx context.Context, enabled bool, reason, actor string) (readOnlyState, error) {
+	if !enabled {
+		if err := redisDel(ctx, readOnlyKey); err != nil {
+			return readOnlyState{}, err
+		}
+		log.Printf("[readonly] disabled by=%q", actor)
+		if trigger := takeDeferredRefresh(ctx); trigger != "" {
+			enqueueRefreshJob(ctx, "read-only:resume:"+trigger, nil)
+		}
+		return loadReadOnly(ctx), nil
+	}
+	now := time.Now().UTC()
+	state := readOnlyState{Enabled: true, Reason: reason, Since: &now, By: actor}
+	b, err := json.Marshal(state)
+	if err != nil {
+		return readOnlyState{}, err
+	}
+	if err := redisPut(ctx, readOnlyKey, b, 0); err != nil {
+		return readOnlyState{}, err
+	}
+	log.Printf("[readonly] enabled by=%q reason=%q", actor, reason)
+	return loadReadOnly(ctx), nil
+}
+
+// takeDeferredRefresh returns and clears the deferred trigger, atomically so
+// that only one replica resumes it.
+func takeDeferredRefresh(ctx context.Context) string {
+	if !redisEnabled() {
+		pending, err := redisGet(ctx, readOnlyPendingKey)
+		if err != nil {
+			return ""
+		}
+		_ = redisDel(ctx, readOnlyPendingKey)
+		return string(pending)
+	}
+	var pending *redis.StringCmd
+	_, _ = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pending = pipe.Get(ctx, readOnlyPendingKey)
+		pipe.Del(ctx, readOnlyPendingKey)
+		return nil
+	})
+	trigger, _ := pending.Result()
+	return trigger
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
+	"log"
 	localenv "mensalocalizations/tools/env"
+	"net"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -10,26 +13,92 @@ import (
 )
 
 var (
-	rdb = redis.NewClient(&redis.Options{
-		Addr:     localenv.GetRedisAddr(),
-		Password: localenv.GetRedisPassword(),
-		DB:       0,
-	})
+	rdb   = newRedisClient()
+	store = newKVStore()
 
 	sf singleflight.Group
 )
 
-// redisPut writes a value with the given TTL into Redis using the shared client.
+// errRedisDisabled is returned by every command sent to rdb with REDIS_ENABLED=false.
+var errRedisDisabled = errors.New("redis disabled (REDIS_ENABLED=false)")
+
+func redisEnabled() bool { return localenv.GetRedisEnabled() }
+
+// newRedisClient connects to REDIS_ADDR, or to the REDIS_SHARDS ring when
+// set (see newRedisRing). With REDIS_ENABLED=false the client never dials
+// and fails every command with errRedisDisabled: values go through the
+// memory store (see newKVStore) and the features that only coordinate
+// replicas (locks, the journal stream, the shared job queue, idempotency)
+// are turned off where they are used.
+func newRedisClient() redis.UniversalClient {
+	if shards := localenv.GetRedisShards(); localenv.GetRedisEnabled() && len(shards) > 0 {
+		return newRedisRing(shards)
+	}
+	if localenv.GetRedisEnabled() {
+		return redis.NewClient(&redis.Options{
+			Addr:     localenv.GetRedisAddr(),
+			Password: localenv.GetRedisPassword(),
+			DB:       0,
+		})
+	}
+	log.Printf("[redis] disabled: values kept in process memory, snapshots served from memory and S3")
+	if localenv.GetMemoryCacheMaxBytes() <= 0 {
+		log.Printf("[redis] MEMORY_CACHE_MAX_BYTES=0: every snapshot request reads S3")
+	}
+	return redis.NewClient(&redis.Options{
+		Dialer: func(context.Context, string, string) (net.Conn, error) {
+			return nil, errRedisDisabled
+		},
+		MaxRetries: -1,
+	})
+}
+
+// redisTryLock takes a replica-wide lock for ttl. Without Redis there is a
+// single replica and nothing to coordinate: the lock is always granted.
+func redisTryLock(ctx context.Context, key string, ttl time.Duration) bool {
+	if !redisEnabled() {
+		return true
+	}
+	ok, err := rdb.SetNX(ctx, key, "1", ttl).Result()
+	return err == nil && ok
+}
+
+// redisPut writes a value with the given TTL into the store.
 // If ttl <= 0, the key is stored without expiration (infinite TTL).
 func redisPut(ctx context.Context, key string, value []byte, ttl time.Duration) error {
-	if ttl <= 0 {
-		return rdb.Set(ctx, key, value, 0).Err()
-	}
-	return rdb.Set(ctx, key, value, ttl).Err()
+	defer observeStage(ctx, stageStore, time.Now())
+	return store.put(ctx, key, value, ttl)
 }
 
-// redisGet fetches a value by key from Redis using the shared client.
-// It returns the raw bytes and any error from the underlying call.
+// redisGet fetches a value by key from the store, following a
+// content-addressed pointer to its blob.
 func redisGet(ctx context.Context, key string) ([]byte, error) {
-	return rdb.Get(ctx, key).Bytes()
+	defer observeStage(ctx, stageRedis, time.Now())
+	b, err := store.get(ctx, key)
+	if err != nil {
+		return b, err
+	}
+	return resolveRedisPointer(ctx, b)
+}
+
+// redisScanKeys returns every key matching a glob pattern, across every shard.
+func redisScanKeys(ctx context.Context, pattern string) ([]string, error) {
+	return store.scan(ctx, pattern)
+}
+
+// redisForEachNode runs fn on every node holding keys: the client itself, or
+// each live shard of the ring (concurrently). Commands that are not bound to
+// one key (SCAN) must go through it.
+func redisForEachNode(ctx context.Context, fn func(ctx context.Context, node redis.Cmdable) error) error {
+	if ring, ok := rdb.(*redis.Ring); ok {
+		return ring.ForEachShard(ctx, func(ctx context.Context, shard *redis.Client) error {
+			return fn(ctx, shard)
+		})
+	}
+	return fn(ctx, rdb)
+}
+
+// redisDel deletes keys from the store.
+func redisDel(ctx context.Context, keys ...string) error {
+	return store.del(ctx, keys...)
 }
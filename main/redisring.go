@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/dgryski/go-rendezvous"
+	"github.com/go-redis/redis/v8"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// redisShardPingTimeout bounds the health check of one shard.
+const redisShardPingTimeout = time.Second
+
+// redisShards tracks the shard clients of the ring and their last known
+// health, for /api/admin/redis/shards and the mensa_redis_shard_up gauge.
+var redisShards = struct {
+	sync.Mutex
+	clients map[string]*redis.Client
+	up      map[string]bool
+}{clients: map[string]*redis.Client{}, up: map[string]bool{}}
+
+type redisShardStatus struct {
+	Name string `json:"name"`
+	Addr string `json:"addr"`
+	Up   bool   `json:"up"`
+}
+
+// newRedisRing spreads keys over the REDIS_SHARDS instances by rendezvous
+// (consistent) hashing, so adding or removing a shard only moves the keys
+// of that shard. Every shard is pinged each REDIS_SHARD_HEARTBEAT; after 3
+// failed pings it leaves the ring and its keys are served by the others
+// (a cache miss, then the S3 fallback) until it answers again.
+func newRedisRing(shards map[string]string) *redis.Ring {
+	ring := redis.NewRing(&redis.RingOptions{
+		Addrs:              shards,
+		Password:           localenv.GetRedisPassword(),
+		HeartbeatFrequency: localenv.GetRedisShardHeartbeat(),
+		NewConsistentHash:  newRedisShardHash,
+		NewClient: func(name string, opt *redis.Options) *redis.Client {
+			client := redis.NewClient(opt)
+			redisShards.Lock()
+			redisShards.clients[name] = client
+			redisShards.up[name] = true
+			redisShards.Unlock()
+			return client
+		},
+	})
+	log.Printf("[redis] sharded over %d instances", len(shards))
+	go watchRedisShards()
+	return ring
+}
+
+// redisShardHash is rendezvous hashing over the live shard names, with
+// xxhash like the go-redis default, pinned here so an upgrade of the client
+// cannot move every key at once.
+type redisShardHash struct {
+	*rendezvous.Rendezvous
+}
+
+func newRedisShardHash(shards []string) redis.ConsistentHash {
+	return redisShardHash{rendezvous.New(shards, xxhash.Sum64String)}
+}
+
+func (h redisShardHash) Get(key string) string {
+	return h.Lookup(key)
+}
+
+// watchRedisShards publishes the health of every shard and logs transitions.
+func watchRedisShards() {
+	ticker := time.NewTicker(localenv.GetRedisShardHeartbeat())
+	defer ticker.Stop()
+	for range ticker.C {
+		checkRedisShards(context.Background())
+	}
+}
+
+func checkRedisShards(ctx context.Context) []redisShardStatus {
+	redisShards.Lock()
+	clients := make(map[string]*redis.Client, len(redisShards.clients))
+	for name, client := range redisShards.clients {
+		clients[name] = client
+	}
+	redisShards.Unlock()
+
+	out := make([]redisShardStatus, 0, len(clients))
+	for name, client := range clients {
+		pingCtx, cancel := context.WithTimeout(ctx, redisShardPingTimeout)
+		up := client.Ping(pingCtx).Err() == nil
+		cancel()
+		redisShards.Lock()
+		if was := redisShards.up[name]; was != up {
+			log.Printf("[redis] shard %s (%s) up=%t", name, client.Options().Addr, up)
+		}
+		redisShards.up[name] = up
+		redisShards.Unlock()
+		recordRedisShardUp(name, up)
+		out = append(out, redisShardStatus{Name: name, Addr: client.Options().Addr, Up: up})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestRedisShardHashStability(t *testing.T) {
+	keys := make([]string, 20000)
+	for i := range keys {
+		keys[i] = "tolgee:lang:" + strconv.Itoa(i) + ":true"
+	}
+	tests := []struct {
+		name          string
+		before, after []string
+	}{
+		{name: "shard added", before: []string{"a", "b", "c"}, after: []string{"a", "b", "c", "d"}},
+		{name: "shard removed", before: []string{"a", "b", "c", "d"}, after: []string{"a", "c", "d"}},
+		{name: "shard marked down", before: []string{"a", "b", "c"}, after: []string{"a", "c"}},
+		{name: "shard replaced", before: []string{"a", "b", "c"}, after: []string{"a", "b", "e"}},
+		{name: "order of shards", before: []string{"a", "b", "c"}, after: []string{"c", "a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after := newRedisShardHash(tt.before), newRedisShardHash(tt.after)
+			inBefore, inAfter := map[string]bool{}, map[string]bool{}
+			for _, s := range tt.before {
+				inBefore[s] = true
+			}
+			for _, s := range tt.after {
+				inAfter[s] = true
+			}
+			moved := 0
+			for _, key := range keys {
+				from, to := before.Get(key), after.Get(key)
+				if from == to {
+					continue
+				}
+				moved++
+				// only keys of a removed shard, or taken by an added one, move
+				if inAfter[from] && inBefore[to] {
+					t.Fatalf("%s moved from %s to %s, both present before and after", key, from, to)
+				}
+			}
+			// a key stays when its top-scoring shard over both sets is in
+			// both: 1 - |before ∩ after| / |before ∪ after| of the keys move
+			kept, union := 0, len(inBefore)
+			for s := range inAfter {
+				if inBefore[s] {
+					kept++
+				} else {
+					union++
+				}
+			}
+			expected := 1 - float64(kept)/float64(union)
+			if got := float64(moved) / float64(len(keys)); got < expected-0.02 || got > expected+0.02 {
+				t.Errorf("moved %.3f of the keys, want %.3f ± 0.02", got, expected)
+			}
+		})
+	}
+}
+
+func TestRedisShardHashBalance(t *testing.T) {
+	shards := []string{"a", "b", "c", "d", "e"}
+	h := newRedisShardHash(shards)
+	counts := map[string]int{}
+	const n = 50000
+	for i := 0; i < n; i++ {
+		counts[h.Get("tolgee:blob:"+strconv.Itoa(i))]++
+	}
+	for _, s := range shards {
+		if share := float64(counts[s]) / n; share < 0.17 || share > 0.23 {
+			t.Errorf("shard %s holds %.3f of the keys, want about 0.2", s, share)
+		}
+	}
+}
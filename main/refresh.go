@@ -0,0 +1,445 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// updateSummary is the outcome of one refresh run. NewLanguages and
+// RemovedLanguages are computed against the previously cached languages payload.
+type updateSummary struct {
+	Languages        []string          `json:"languages"`
+	Refreshed        []string          `json:"refreshed"`
+	Failed           map[string]string `json:"failed,omitempty"`
+	NewLanguages     []string          `json:"new_languages,omitempty"`
+	RemovedLanguages []string          `json:"removed_languages,omitempty"`
+	DurationMs       int64             `json:"duration_ms"`
+
+	// SchemaViolations lists, per language, why the snapshot was rejected
+	SchemaViolations map[string][]string `json:"schema_violations,omitempty"`
+	// GitCommit is the source commit refreshed from with SOURCE=git:
+	GitCommit string `json:"git_commit,omitempty"`
+	// Lint counts the ingest lint findings per refreshed language
+	Lint map[string]lintCounts `json:"lint,omitempty"`
+	// Scope is set when a manual refresh covered only part of the project
+	Scope *refreshScope `json:"scope,omitempty"`
+}
+
+var errUnknownLanguage = errors.New("unknown language")
+
+// RebuildTheCache refreshes the languages list and every translation synchronously.
+// Used for the startup warm-up.
+func RebuildTheCache(ctx context.Context) error {
+	entry := updateHistoryEntry{Trigger: "startup", Status: jobStatusDone, StartedAt: time.Now().UTC()}
+	summary, err := runRefresh(ctx, nil)
+	entry.FinishedAt = time.Now().UTC()
+	entry.Summary = summary
+	if err != nil {
+		entry.Status, entry.Error = jobStatusFailed, err.Error()
+	}
+	recordUpdateHistory(ctx, entry)
+	return err
+}
+
+// runRefresh refreshes the languages list, then the PRIORITY_LANGUAGES and
+// finally every other language, collecting the outcome in an updateSummary.
+// A non-full scope limits the run to its languages, modes and namespaces and
+// leaves the project lists alone.
+func runRefresh(ctx context.Context, scope *refreshScope) (*updateSummary, error) {
+	start := time.Now()
+	// without Redis a single replica writes S3: no generation to order it
+	if redisEnabled() {
+		if gen, err := nextRefreshGeneration.Run(ctx, rdb, []string{refreshGenerationKey}, time.Now().UnixMilli()).Int64(); err == nil {
+			ctx = withRefreshGeneration(ctx, gen)
+		} else {
+			log.Printf("[refresh] generation unavailable, S3 writes unconditional: %v", err)
+		}
+	}
+	appKey := localenv.GetTolgeeAppKey()
+	s3c := s3ClientIfEnabled(ctx)
+	summary := &updateSummary{Failed: map[string]string{}, SchemaViolations: map[string][]string{}, Lint: map[string]lintCounts{}}
+	if _, ok := gitSourceURL(); ok {
+		// the job may run on a replica that has not pulled the change yet
+		if _, _, head, err := syncGitSource(ctx); err != nil {
+			log.Printf("[git] pull error, refreshing from the current clone: %v", err)
+		} else {
+			summary.GitCommit = head.String()
+		}
+	}
+
+	tags, added, removed, err := refreshLanguages(ctx, appKey, s3c)
+	if err != nil {
+		summary.DurationMs = time.Since(start).Milliseconds()
+		return summary, err
+	}
+	summary.Languages = tags
+	summary.NewLanguages = added
+	summary.RemovedLanguages = removed
+	for _, tag := range removed {
+		purgeLanguage(ctx, s3c, tag)
+	}
+	if scope.isFull() {
+		refreshProjectLists(ctx, appKey, s3c)
+		refreshKeyAliases(ctx, appKey)
+	} else {
+		summary.Scope = scope
+	}
+
+	tags, unknown := scope.filterLanguages(tags)
+	warmupSetTotal(ctx, len(tags))
+	for _, tag := range unknown {
+		summary.Failed[tag] = errUnknownLanguage.Error()
+	}
+	priority, rest := splitPriorityLanguages(tags, priorityLanguages())
+	for _, batch := range [][]string{priority, rest} {
+		if len(batch) == 0 {
+			continue
+		}
+		invalid, err := refreshAppTranslations(ctx, appKey, s3c, batch, scope)
+		if err != nil {
+			for _, tag := range batch {
+				summary.Failed[tag] = err.Error()
+				warmupLanguageDone(ctx)
+			}
+			continue
+		}
+		for _, tag := range batch {
+			if violations, ok := invalid[tag]; ok {
+				summary.SchemaViolations[tag] = violations
+				summary.Failed[tag] = errSchemaValidation.Error()
+				continue
+			}
+			summary.Refreshed = append(summary.Refreshed, tag)
+			if report, err := loadLintReport(ctx, tag); err == nil && report != nil && !report.CheckedAt.Before(start) {
+				summary.Lint[tag] = report.Counts
+			}
+		}
+	}
+	summary.DurationMs = time.Since(start).Milliseconds()
+	notifyNewLanguages(summary)
+	exportSnapshotsToGit(ctx, summary)
+	scheduleRefreshRetries(ctx, summary)
+	if len(summary.Failed) > 0 {
+		return summary, fmt.Errorf("%d languages failed to refresh", len(summary.Failed))
+	}
+	return summary, nil
+}
+
+// refreshGenerationKey is a Redis counter shared by all replicas: a refresh
+// that starts later fetches newer content and gets a higher generation.
+const refreshGenerationKey = "tolgee:refresh:generation"
+
+// nextRefreshGeneration returns the current time in ms (ARGV[1]), or the
+// stored counter + 1 when that is already ahead. Losing the key (Redis data
+// loss, a flush) therefore never restarts the counter below
+// the generations already stamped on S3 objects.
+var nextRefreshGeneration = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+if now > current then
+	redis.call('SET', KEYS[1], now)
+	return now
+end
+return redis.call('INCR', KEYS[1])
+`)
+
+// errStaleGeneration is recorded for a write skipped because a newer refresh
+// generation already stored the key.
+var errStaleGeneration = errors.New("a newer refresh generation already wrote the key")
+
+type refreshGenerationCtxKey struct{}
+
+func withRefreshGeneration(ctx context.Context, gen int64) context.Context {
+	return context.WithValue(ctx, refreshGenerationCtxKey{}, gen)
+}
+
+func refreshGenerationFrom(ctx context.Context) (int64, bool) {
+	gen, ok := ctx.Value(refreshGenerationCtxKey{}).(int64)
+	return gen, ok
+}
+
+// refreshLanguages fetches the Tolgee languages, stores them and returns their
+// tags together with the ones added to / removed from the previous payload.
+func refreshLanguages(ctx context.Context, appKey string, s3c *s3Client) (tags, added, removed []string, err error) {
+	model, bytesOfLanguages, err := GetLanguages(ctx, appKey)
+	if err != nil {
+		log.Printf("[refresh] languages error: %v", err)
+		return nil, nil, nil, err
+	}
+	if len(bytesOfLanguages) == 0 {
+		return nil, nil, nil, errors.New("empty languages payload")
+	}
+	previous, _ := redisGet(ctx, "tolgee:languages")
+	storeCacheEntry(ctx, s3c, "tolgee:languages", bytesOfLanguages, "application/json")
+
+	tags = languageTags(model)
+	if len(previous) > 0 {
+		var prevModel TolgeeModel
+		if err := json.Unmarshal(previous, &prevModel); err == nil {
+			added, removed = diffTags(languageTags(&prevModel), tags)
+		}
+	}
+	if len(added) > 0 {
+		log.Printf("[refresh] new languages detected: %v", added)
+	}
+	if len(removed) > 0 {
+		log.Printf("[refresh] removed languages detected: %v", removed)
+	}
+	return tags, added, removed, nil
+}
+
+// diffTags returns the tags only in next (added) and only in prev (removed).
+func diffTags(prev, next []string) (added, removed []string) {
+	inPrev := map[string]bool{}
+	for _, t := range prev {
+		inPrev[t] = true
+	}
+	inNext := map[string]bool{}
+	for _, t := range next {
+		inNext[t] = true
+		if !inPrev[t] {
+			added = append(added, t)
+		}
+	}
+	for _, t := range prev {
+		if !inNext[t] {
+			removed = append(removed, t)
+		}
+	}
+	return added, removed
+}
+
+func languageTags(model *TolgeeModel) []string {
+	tags := make([]string, 0, len(model.Embedded.Languages))
+	for _, lang := range model.Embedded.Languages {
+		tags = append(tags, lang.Tag)
+	}
+	return tags
+}
+
+// notifyNewLanguages announces new languages once their translations are warm.
+func notifyNewLanguages(summary *updateSummary) {
+	for _, tag := range summary.NewLanguages {
+		if _, failed := summary.Failed[tag]; failed {
+			continue
+		}
+		notifyOutgoing("language_added", map[string]string{"language": tag})
+	}
+}
+
+// refreshAppTranslations exports the given languages in nested and flat mode
+// (or the scoped modes) and stores every file in Redis (and S3 when enabled).
+// With scoped namespaces only those are replaced in the stored snapshots. A
+// language whose nested catalog violates the registered schemas is not stored
+// in either mode, so the previous snapshot keeps being served; its violations
+// are returned. A flat-only scope still exports the nested catalog when
+// schemas are registered, only to validate it: flat catalogs cannot be
+// checked on their own and must not be published unchecked.
+func refreshAppTranslations(ctx context.Context, appKey string, s3c *s3Client, tags []string, scope *refreshScope) (map[string][]string, error) {
+	invalid := map[string][]string{}
+	if len(tags) == 0 {
+		return invalid, nil
+	}
+	modes := scope.modes()
+	primary := modes[0]
+	validateOnly := !primary && len(loadSchemas(ctx)) > 0
+	if validateOnly {
+		modes = append([]bool{true}, modes...)
+	}
+	var manifest manifestBatch
+	defer manifest.flush(ctx, s3c)
+	for _, nested := range modes {
+		files, err := GetTranslations(ctx, appKey, strings.Join(tags, ", "), nested)
+		if err != nil {
+			log.Printf("[refresh] translations error langs=%v nested=%t: %v", tags, nested, err)
+			return invalid, fmt.Errorf("GetTranslations: %w", err)
+		}
+		for name, translations := range files {
+			if len(translations) == 0 {
+				continue
+			}
+			key := translationsCacheKey(name, nested)
+			if scope != nil && len(scope.Namespaces) > 0 {
+				merged, err := mergeRefreshedNamespaces(ctx, key, nested, scope.Namespaces, translations)
+				if err != nil {
+					log.Printf("[refresh] namespace merge error lang=%s nested=%t: %v", name, nested, err)
+					continue
+				}
+				translations = merged
+			}
+			translations = applyIngestFilters(name, nested, translations, nested == primary)
+			if nested {
+				violations := snapshotViolations(ctx, translations)
+				recordSchemaViolations(name, len(violations))
+				if len(violations) > 0 {
+					log.Printf("[refresh] schema validation failed lang=%s violations=%d, keeping previous snapshot", name, len(violations))
+					invalid[name] = violations
+				}
+				if validateOnly {
+					continue
+				}
+			}
+			if nested == primary {
+				runIngestLint(ctx, name, nested, translations, files)
+			}
+			if _, rejected := invalid[name]; rejected {
+				if !nested {
+					warmupLanguageDone(ctx)
+				}
+				continue
+			}
+			translations = sortSnapshot(name, nested, translations)
+			invalidateDerivedArtifacts(ctx, s3c, name, nested, translations)
+			storeCacheEntry(ctx, s3c, key, translations, "application/json")
+			storeCompressedVariants(ctx, s3c, key, translations, "application/json")
+			storeFormatVariants(ctx, s3c, key, name, nested, translations)
+			observeStoredSnapshot(name, nested, "json", translations)
+			manifest.add(name, nested, translations)
+			if !nested {
+				recordCoverage(ctx, name, translations)
+				warmupLanguageDone(ctx)
+			}
+		}
+	}
+	log.Printf("[refresh] translations ok langs=%v", tags)
+	return invalid, nil
+}
+
+// snapshotViolations validates a nested export against the catalog schemas;
+// a catalog that cannot be checked counts as a violation (fail closed).
+func snapshotViolations(ctx context.Context, nested []byte) []string {
+	violations, err := validateCatalog(ctx, nested)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	return violations
+}
+
+// sortSnapshot orders the keys of a catalog about to be stored when
+// SORT_SNAPSHOTS is set.
+func sortSnapshot(lang string, nested bool, payload []byte) []byte {
+	if !localenv.GetSortSnapshots() {
+		return payload
+	}
+	sorted, err := sortJSONKeys(payload)
+	if err != nil {
+		log.Printf("[refresh] sort error lang=%s nested=%t: %v", lang, nested, err)
+		return payload
+	}
+	return sorted
+}
+
+// storeCacheEntry writes a payload to the store (see storeHoldsRefreshOutput)
+// and, if s3c is not nil, to S3. A refresh older than the generation already
+// stored is dropped from both, and the memory tier keeps the newer value.
+// Every write is recorded in the mutation journal.
+func storeCacheEntry(ctx context.Context, s3c *s3Client, key string, payload []byte, contentType string) {
+	recordCacheSize(key, len(payload))
+	var before []byte
+	if journalEnabled() {
+		before, _ = redisGet(ctx, key)
+	}
+	stored := payload
+	if contentAddressed(key) {
+		pointer, err := storeBlob(ctx, s3c, payload, contentType, snapshotHardTTL(key))
+		if err != nil {
+			journalCacheMutation(ctx, key, before, payload, s3c != nil, err)
+			return
+		}
+		stored = pointer
+	}
+	// S3 first: its conditional write tells whether a newer refresh got there
+	var writeErr error
+	if s3c != nil {
+		writeErr = s3c.putObject(ctx, key, stored, contentType, map[string]string{})
+		if errors.Is(writeErr, ErrS3StaleGeneration) {
+			// leave the newer value in the store and the memory tier
+			journalCacheMutation(ctx, key, before, payload, true, writeErr)
+			return
+		}
+	}
+	if err := storeRefreshOutput(ctx, s3c, key, stored); errors.Is(err, errStaleGeneration) {
+		journalCacheMutation(ctx, key, before, payload, s3c != nil, errors.Join(writeErr, err))
+		return
+	} else if err != nil {
+		writeErr = errors.Join(writeErr, err)
+	}
+	memForget(key)
+	journalCacheMutation(ctx, key, before, payload, s3c != nil, writeErr)
+}
+
+// storeRefreshOutput writes a refresh result to the store, compared to the
+// generation already stored for key when ctx carries one (errStaleGeneration
+// when it is older).
+func storeRefreshOutput(ctx context.Context, s3c *s3Client, key string, stored []byte) error {
+	if !storeHoldsRefreshOutput(s3c) {
+		return redisDel(ctx, key)
+	}
+	gen, ok := refreshGenerationFrom(ctx)
+	if !ok {
+		return redisPut(ctx, key, stored, snapshotHardTTL(key))
+	}
+	defer observeStage(ctx, stageStore, time.Now())
+	written, err := store.putGeneration(ctx, key, stored, snapshotHardTTL(key), gen)
+	if err != nil {
+		return err
+	}
+	if !written {
+		log.Printf("[refresh] store skipped key=%q: a generation newer than %d wrote it", key, gen)
+		return errStaleGeneration
+	}
+	return nil
+}
+
+// storeFormatVariants pre-encodes the formats that declare a storedVariant.
+func storeFormatVariants(ctx context.Context, s3c *s3Client, key, lang string, nested bool, payload []byte) {
+	for _, f := range payloadFormats {
+		if f.storedVariant == "" {
+			continue
+		}
+		body, err := f.encode(lang, payload)
+		if err != nil {
+			log.Printf("[refresh] %s encode error key=%q: %v", f.storedVariant, key, err)
+			continue
+		}
+		storeCacheEntry(ctx, s3c, key+":"+f.storedVariant, body, f.contentType)
+		observeStoredSnapshot(lang, nested, f.storedVariant, body)
+	}
+}
+
+func translationsCacheKey(lang string, nested bool) string {
+	if nested {
+		return "tolgee:lang:" + lang + ":true"
+	}
+	return "tolgee:lang:" + lang + ":false"
+}
+
+// splitPriorityLanguages partitions tags keeping the priority order for the first group.
+func splitPriorityLanguages(tags, priorityOrder []string) (priority, rest []string) {
+	available := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		available[t] = true
+	}
+	isPriority := make(map[string]bool, len(priorityOrder))
+	for _, p := range priorityOrder {
+		if available[p] && !isPriority[p] {
+			isPriority[p] = true
+			priority = append(priority, p)
+		}
+	}
+	for _, t := range tags {
+		if !isPriority[t] {
+			rest = append(rest, t)
+		}
+	}
+	return priority, rest
+}
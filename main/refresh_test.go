@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func withMemoryStore(t *testing.T) *memoryStore {
+	t.Helper()
+	m := &memoryStore{entries: map[string]memoryStoreEntry{}}
+	previous := store
+	store = m
+	t.Cleanup(func() { store = previous })
+	return m
+}
+
+func TestStoreRefreshOutputRacingGenerations(t *testing.T) {
+	const key = "tolgee:lang:it:true"
+	ctx := context.Background()
+
+	t.Run("older generation finishing last", func(t *testing.T) {
+		withMemoryStore(t)
+		newer := withRefreshGeneration(ctx, 2)
+		older := withRefreshGeneration(ctx, 1)
+		if err := storeRefreshOutput(newer, nil, key, []byte(`{"v":2}`)); err != nil {
+			t.Fatalf("newer write: %v", err)
+		}
+		if err := storeRefreshOutput(older, nil, key, []byte(`{"v":1}`)); !errors.Is(err, errStaleGeneration) {
+			t.Fatalf("older write: err = %v, want errStaleGeneration", err)
+		}
+		got, err := store.get(ctx, key)
+		if err != nil || string(got) != `{"v":2}` {
+			t.Errorf("stored = %s, %v; want the newer value", got, err)
+		}
+	})
+
+	t.Run("same generation rewrites", func(t *testing.T) {
+		withMemoryStore(t)
+		gen := withRefreshGeneration(ctx, 5)
+		for _, v := range []string{`{"v":"a"}`, `{"v":"b"}`} {
+			if err := storeRefreshOutput(gen, nil, key, []byte(v)); err != nil {
+				t.Fatalf("write %s: %v", v, err)
+			}
+		}
+		if got, _ := store.get(ctx, key); string(got) != `{"v":"b"}` {
+			t.Errorf("stored = %s, want the last write of the generation", got)
+		}
+	})
+
+	t.Run("concurrent generations", func(t *testing.T) {
+		withMemoryStore(t)
+		var wg sync.WaitGroup
+		for gen := int64(1); gen <= 50; gen++ {
+			wg.Add(1)
+			go func(gen int64) {
+				defer wg.Done()
+				err := storeRefreshOutput(withRefreshGeneration(ctx, gen), nil, key, []byte(strconv.FormatInt(gen, 10)))
+				if err != nil && !errors.Is(err, errStaleGeneration) {
+					t.Errorf("generation %d: %v", gen, err)
+				}
+			}(gen)
+		}
+		wg.Wait()
+		if got, _ := store.get(ctx, key); string(got) != "50" {
+			t.Errorf("stored generation = %s, want 50", got)
+		}
+	})
+}
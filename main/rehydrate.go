@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const rehydrateBatchSize = 100
+
+// cacheReady is false while Redis is being rehydrated from S3; /api/readyz
+// reports it so traffic is only routed to a warm replica.
+var cacheReady atomic.Bool
+
+// rehydrateReport is the outcome of a bulk Redis reload from S3.
+type rehydrateReport struct {
+	Skipped    bool     `json:"skipped"`
+	Reason     string   `json:"reason,omitempty"`
+	Restored   int      `json:"restored"`
+	Failed     []string `json:"failed,omitempty"`
+	DurationMs int64    `json:"duration_ms"`
+}
+
+// redisLooksEmpty reports whether Redis holds no cached payloads at all,
+// e.g. after a flush or a restart without persistence.
+func redisLooksEmpty(ctx context.Context) (bool, error) {
+	n, err := rdb.Exists(ctx, "tolgee:languages").Result()
+	if err != nil || n > 0 {
+		return false, err
+	}
+	var found atomic.Bool
+	err = redisForEachNode(ctx, func(ctx context.Context, node redis.Cmdable) error {
+		// a SCAN page can be empty while later pages match: walk to cursor 0
+		var cursor uint64
+		for !found.Load() {
+			keys, next, err := node.Scan(ctx, cursor, "tolgee:lang:*", 100).Result()
+			if err != nil {
+				return err
+			}
+			if len(keys) > 0 {
+				found.Store(true)
+			}
+			if cursor = next; cursor == 0 {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return !found.Load(), nil
+}
+
+// rehydratable reports whether an S3 object is refresh output to reload into
+// Redis: snapshots, their blobs, the language list and the manifest. Caches
+// (proxy, apps, artifacts), diagnostics and job state are left to rebuild.
+func rehydratable(key string) bool {
+	if isCompressedVariantKey(key) {
+		return false
+	}
+	return strings.HasPrefix(key, "tolgee:lang:") || strings.HasPrefix(key, blobKeyPrefix) ||
+		key == "tolgee:languages" || key == manifestCacheKey
+}
+
+// rehydrateFromS3 reloads every snapshot stored in S3 into Redis, writing in
+// pipelined batches. Unless force is set it only runs when Redis is empty.
+// Readiness is withdrawn for the duration of the reload.
+func rehydrateFromS3(ctx context.Context, force bool) (*rehydrateReport, error) {
+	start := time.Now()
+	report := &rehydrateReport{}
+	if !redisEnabled() {
+		// the memory store reads S3 through: nothing to reload
+		report.Skipped, report.Reason = true, "redis disabled"
+		return report, nil
+	}
+	if !force {
+		empty, err := redisLooksEmpty(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !empty {
+			report.Skipped, report.Reason = true, "redis not empty"
+			return report, nil
+		}
+	}
+	s3c := s3ClientIfEnabled(ctx)
+	if s3c == nil {
+		report.Skipped, report.Reason = true, "s3 disabled"
+		return report, nil
+	}
+	keys, err := s3c.listKeys(ctx, "tolgee:")
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		report.Skipped, report.Reason = true, "no snapshots in s3"
+		return report, nil
+	}
+
+	cacheReady.Store(false)
+	defer cacheReady.Store(true)
+	log.Printf("[rehydrate] redis empty, reloading %d objects from s3", len(keys))
+
+	pipe := rdb.Pipeline()
+	queued := 0
+	flush := func() {
+		if queued == 0 {
+			return
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("[rehydrate] pipeline error: %v", err)
+		}
+		queued = 0
+	}
+	for _, key := range keys {
+		if !rehydratable(key) {
+			continue
+		}
+		// raw: content-addressed pointers and their blobs are restored as stored
+		payload, _, err := s3c.readObject(ctx, key)
+		if err != nil || len(payload) == 0 {
+			if err == nil {
+				err = errors.New("empty object")
+			}
+			log.Printf("[rehydrate] get error key=%q: %v", key, err)
+			report.Failed = append(report.Failed, key)
+			continue
+		}
+		// the TTL refresh gives the key (SNAPSHOT_HARD_TTL for snapshots)
+		pipe.Set(ctx, key, payload, snapshotHardTTL(key))
+		recordCacheSize(key, len(payload))
+		report.Restored++
+		if queued++; queued >= rehydrateBatchSize {
+			flush()
+		}
+	}
+	flush()
+	report.DurationMs = time.Since(start).Milliseconds()
+	log.Printf("[rehydrate] restored=%d failed=%d in %dms", report.Restored, len(report.Failed), report.DurationMs)
+	return report, nil
+}
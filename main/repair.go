@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+const repairLockKey = "tolgee:repair:lock"
+
+// repairAction is one reconciliation applied to a drifted language/mode.
+type repairAction struct {
+	Lang   string `json:"lang"`
+	Mode   string `json:"mode"`
+	Action string `json:"action"` // "redis_from_s3", "s3_from_tolgee" or "skipped"
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type repairReport struct {
+	Verification *verifyReport  `json:"verification"`
+	Actions      []repairAction `json:"actions"`
+	DurationMs   int64          `json:"duration_ms"`
+}
+
+// runRepair verifies the tiers and reconciles the drift: a Tolgee export that
+// differs from an S3 object older than REPAIR_S3_MAX_AGE is written to Redis
+// and S3; otherwise a Redis entry that differs from S3 is refilled from S3.
+// Recent S3 objects are left alone, as a refresh may be in flight. Exports
+// (already ingest-filtered by runVerification) go through the schema
+// validation and sorting of a refresh: a catalog the refresh rejects is
+// never published by a repair.
+func runRepair(ctx context.Context) (*repairReport, error) {
+	start := time.Now()
+	verification, err := runVerification(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &repairReport{Verification: verification, Actions: []repairAction{}}
+	s3c := s3ClientIfEnabled(ctx)
+	maxAge := localenv.GetRepairS3MaxAge()
+	var manifest manifestBatch
+	defer manifest.flush(ctx, s3c)
+
+	for _, check := range verification.Checks {
+		if check.Status != "drift" {
+			continue
+		}
+		nested := check.Mode == "nested"
+		key := translationsCacheKey(check.Lang, nested)
+		action := repairAction{Lang: check.Lang, Mode: check.Mode}
+
+		fresh := verification.fresh[nested][check.Lang]
+		if check.TolgeeSha != "" && check.S3Sha != check.TolgeeSha && s3c != nil {
+			age, known := s3ObjectAge(ctx, s3c, key)
+			if !known || age > maxAge {
+				action.Action = "s3_from_tolgee"
+				source, ok := verification.fresh[true][check.Lang]
+				if !ok {
+					action.Action, action.Reason = "skipped", "no nested export to validate"
+					report.Actions = append(report.Actions, action)
+					continue
+				}
+				if violations := snapshotViolations(ctx, source); len(violations) > 0 {
+					action.Action, action.Reason = "skipped", fmt.Sprintf("schema validation failed (%d violations)", len(violations))
+					report.Actions = append(report.Actions, action)
+					continue
+				}
+				fresh = sortSnapshot(check.Lang, nested, fresh)
+				invalidateDerivedArtifacts(ctx, s3c, check.Lang, nested, fresh)
+				storeCacheEntry(ctx, s3c, key, fresh, "application/json")
+				storeCompressedVariants(ctx, s3c, key, fresh, "application/json")
+				storeFormatVariants(ctx, s3c, key, check.Lang, nested, fresh)
+				manifest.add(check.Lang, nested, fresh)
+				report.Actions = append(report.Actions, action)
+				continue
+			}
+			action.Reason = "s3 object younger than REPAIR_S3_MAX_AGE"
+		}
+
+		if check.S3Sha != "" && check.RedisSha != check.S3Sha {
+			b, err := s3c.getObject(ctx, key)
+			if err != nil {
+				action.Action, action.Error = "redis_from_s3", err.Error()
+			} else if err := redisPut(ctx, key, b, 0); err != nil {
+				action.Action, action.Error = "redis_from_s3", err.Error()
+			} else {
+				action.Action = "redis_from_s3"
+				memForget(key)
+			}
+			report.Actions = append(report.Actions, action)
+			continue
+		}
+		action.Action = "skipped"
+		if action.Reason == "" {
+			action.Reason = "no tier to repair from"
+		}
+		report.Actions = append(report.Actions, action)
+	}
+	report.DurationMs = time.Since(start).Milliseconds()
+	log.Printf("[repair] drifted=%d actions=%d duration=%dms", verification.Drifted, len(report.Actions), report.DurationMs)
+	return report, nil
+}
+
+func s3ObjectAge(ctx context.Context, s3c *s3Client, key string) (time.Duration, bool) {
+	head, err := s3c.headObject(ctx, key)
+	if err != nil || head.LastModified == nil {
+		return 0, false
+	}
+	return time.Since(*head.LastModified), true
+}
+
+// startRepairSchedule runs runRepair every REPAIR_INTERVAL. A Redis lock
+// keeps replicas from repairing at the same time; each report is posted to
+// the outgoing webhook as "repair_report".
+func startRepairSchedule() {
+	interval := localenv.GetRepairInterval()
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ctx := context.Background()
+			if isReadOnly(ctx) {
+				continue
+			}
+			if !redisTryLock(ctx, repairLockKey, interval/2) {
+				continue
+			}
+			report, err := runRepair(ctx)
+			if err != nil {
+				log.Printf("[repair] error: %v", err)
+				continue
+			}
+			notifyOutgoing("repair_report", report)
+		}
+	}()
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+const requiredKeysCacheKey = "tolgee:required-keys"
+
+// requiredKeyManifest lists the keys a client release reads; every language
+// must contain them (non-empty) before that version ships.
+type requiredKeyManifest struct {
+	App        string    `json:"app"`
+	Version    string    `json:"version"`
+	Keys       []string  `json:"keys"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+func (m requiredKeyManifest) id() string { return m.App + "@" + m.Version }
+
+var (
+	errInvalidRequiredKeys = errors.New("manifest needs app, version and at least one key")
+	errUnknownRequiredKeys = errors.New("unknown required-key manifest")
+
+	requiredKeysMu sync.Mutex
+)
+
+func loadRequiredKeys(ctx context.Context) map[string]requiredKeyManifest {
+	manifests := map[string]requiredKeyManifest{}
+	b, err := redisGet(ctx, requiredKeysCacheKey)
+	if err != nil || len(b) == 0 {
+		return manifests
+	}
+	if err := json.Unmarshal(b, &manifests); err != nil {
+		log.Printf("[required-keys] unmarshal error: %v", err)
+		return map[string]requiredKeyManifest{}
+	}
+	return manifests
+}
+
+// listRequiredKeys returns the manifests sorted by app and version.
+func listRequiredKeys(ctx context.Context) []requiredKeyManifest {
+	manifests := loadRequiredKeys(ctx)
+	out := make([]requiredKeyManifest, 0, len(manifests))
+	for _, m := range manifests {
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].id() < out[j].id() })
+	return out
+}
+
+// putRequiredKeys stores the manifest of app@version and re-checks the cached
+// languages right away, so the uploader sees the gaps without a refresh.
+func putRequiredKeys(ctx context.Context, m requiredKeyManifest) (map[string][]string, error) {
+	if m.App == "" || m.Version == "" || len(m.Keys) == 0 {
+		return nil, errInvalidRequiredKeys
+	}
+	m.UploadedAt = time.Now().UTC()
+	if err := mutateRequiredKeys(ctx, func(all map[string]requiredKeyManifest) bool {
+		all[m.id()] = m
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	log.Printf("[required-keys] put %s keys=%d", m.id(), len(m.Keys))
+
+	missing := map[string][]string{}
+	for lang := range loadManifest(ctx).Languages {
+		flat, err := GetTranslationsFromCache(ctx, lang, false)
+		if err != nil {
+			continue
+		}
+		recordCoverage(ctx, lang, flat)
+		if keys := missingRequiredKeys(flat, m.Keys); len(keys) > 0 {
+			missing[lang] = keys
+		}
+	}
+	return missing, nil
+}
+
+// deleteRequiredKeys removes the manifest of app@version.
+func deleteRequiredKeys(ctx context.Context, app, version string) error {
+	id := requiredKeyManifest{App: app, Version: version}.id()
+	found := false
+	err := mutateRequiredKeys(ctx, func(all map[string]requiredKeyManifest) bool {
+		_, found = all[id]
+		delete(all, id)
+		return found
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errUnknownRequiredKeys
+	}
+	return nil
+}
+
+func mutateRequiredKeys(ctx context.Context, fn func(map[string]requiredKeyManifest) bool) error {
+	requiredKeysMu.Lock()
+	defer requiredKeysMu.Unlock()
+	all := loadRequiredKeys(ctx)
+	if !fn(all) {
+		return nil
+	}
+	b, err := json.Marshal(all)
+	if err != nil {
+		return err
+	}
+	storeCacheEntry(ctx, s3ClientIfEnabled(ctx), requiredKeysCacheKey, b, "application/json")
+	return nil
+}
+
+// checkRequiredKeys returns, per manifest id, the required keys that are
+// missing or empty in a flat catalog.
+func checkRequiredKeys(ctx context.Context, flat []byte) map[string][]string {
+	gaps := map[string][]string{}
+	manifests := loadRequiredKeys(ctx)
+	if len(manifests) == 0 {
+		return gaps
+	}
+	for id, m := range manifests {
+		if keys := missingRequiredKeys(flat, m.Keys); len(keys) > 0 {
+			gaps[id] = keys
+		}
+	}
+	return gaps
+}
+
+func missingRequiredKeys(flat []byte, keys []string) []string {
+	var catalog map[string]any
+	if err := json.Unmarshal(flat, &catalog); err != nil {
+		return nil
+	}
+	var missing []string
+	for _, k := range keys {
+		if v, ok := catalog[k]; !ok || v == nil || v == "" {
+			missing = append(missing, k)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// alertRequiredKeyGaps notifies the manifests whose gaps in lang are new
+// compared to the previous coverage report.
+func alertRequiredKeyGaps(lang string, previous, current map[string][]string) {
+	for id, keys := range current {
+		if sameStrings(previous[id], keys) {
+			continue
+		}
+		log.Printf("[required-keys] lang=%s release=%s misses %d keys", lang, id, len(keys))
+		notifyOutgoing("required_keys_missing", map[string]any{"language": lang, "release": id, "keys": keys})
+	}
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
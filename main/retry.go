@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+const (
+	// retryQueueKey is a sorted set of languages scored by next attempt (unix
+	// seconds); retryAttemptsKey and retryErrorsKey hash per-language state.
+	retryQueueKey    = "tolgee:refresh:retries"
+	retryAttemptsKey = "tolgee:refresh:retries:attempts"
+	retryErrorsKey   = "tolgee:refresh:retries:errors"
+	retryPollEvery   = 10 * time.Second
+)
+
+// pendingRetry is a failed language waiting for its next automatic refresh.
+type pendingRetry struct {
+	Language  string    `json:"language"`
+	Attempts  int64     `json:"attempts"`
+	NextAt    time.Time `json:"next_at"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// retryBackoff doubles REFRESH_RETRY_BACKOFF per attempt up to REFRESH_RETRY_BACKOFF_MAX.
+func retryBackoff(attempt int64) time.Duration {
+	delay, max := localenv.GetRefreshRetryBackoff(), localenv.GetRefreshRetryBackoffMax()
+	for i := int64(1); i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// scheduleRefreshRetries queues the languages that failed in a refresh and
+// clears the ones that succeeded. After REFRESH_RETRY_MAX_ATTEMPTS a language
+// is dropped from the queue and the next webhook or manual refresh takes over.
+func scheduleRefreshRetries(ctx context.Context, summary *updateSummary) {
+	if localenv.GetRefreshRetryMaxAttempts() <= 0 || summary == nil || !redisEnabled() {
+		return
+	}
+	for _, lang := range summary.Refreshed {
+		clearRefreshRetry(ctx, lang)
+	}
+	for lang, cause := range summary.Failed {
+		if cause == errUnknownLanguage.Error() {
+			continue
+		}
+		attempts, err := rdb.HIncrBy(ctx, retryAttemptsKey, lang, 1).Result()
+		if err != nil {
+			log.Printf("[retry] queue error lang=%s: %v", lang, err)
+			continue
+		}
+		if attempts > localenv.GetRefreshRetryMaxAttempts() {
+			log.Printf("[retry] giving up lang=%s after %d attempts: %s", lang, attempts-1, cause)
+			clearRefreshRetry(ctx, lang)
+			continue
+		}
+		next := time.Now().Add(retryBackoff(attempts))
+		pipe := rdb.Pipeline()
+		pipe.ZAdd(ctx, retryQueueKey, &redis.Z{Score: float64(next.Unix()), Member: lang})
+		pipe.HSet(ctx, retryErrorsKey, lang, cause)
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("[retry] queue error lang=%s: %v", lang, err)
+			continue
+		}
+		log.Printf("[retry] queued lang=%s attempt=%d at=%s", lang, attempts, next.UTC().Format(time.RFC3339))
+	}
+}
+
+func clearRefreshRetry(ctx context.Context, lang string) {
+	pipe := rdb.Pipeline()
+	pipe.ZRem(ctx, retryQueueKey, lang)
+	pipe.HDel(ctx, retryAttemptsKey, lang)
+	pipe.HDel(ctx, retryErrorsKey, lang)
+	_, _ = pipe.Exec(ctx)
+}
+
+// listPendingRetries returns the queued retries, soonest first.
+func listPendingRetries(ctx context.Context) []pendingRetry {
+	retries := []pendingRetry{}
+	queued, err := rdb.ZRangeWithScores(ctx, retryQueueKey, 0, -1).Result()
+	if err != nil || len(queued) == 0 {
+		return retries
+	}
+	attempts, _ := rdb.HGetAll(ctx, retryAttemptsKey).Result()
+	causes, _ := rdb.HGetAll(ctx, retryErrorsKey).Result()
+	for _, z := range queued {
+		lang, _ := z.Member.(string)
+		n, _ := strconv.ParseInt(attempts[lang], 10, 64)
+		retries = append(retries, pendingRetry{
+			Language:  lang,
+			Attempts:  n,
+			NextAt:    time.Unix(int64(z.Score), 0).UTC(),
+			LastError: causes[lang],
+		})
+	}
+	return retries
+}
+
+// startRetryLoop polls the queue and refreshes due languages as one scoped
+// job. Entries live in Redis, so a restart (or another replica) picks them up;
+// ZREM decides which replica claims each language.
+func startRetryLoop() {
+	if localenv.GetRefreshRetryMaxAttempts() <= 0 || localenv.GetPromotedOnly() || !redisEnabled() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(retryPollEvery)
+		defer ticker.Stop()
+		for range ticker.C {
+			ctx := context.Background()
+			// frozen: retries stay queued until read-only mode is lifted
+			if isReadOnly(ctx) {
+				continue
+			}
+			due, err := rdb.ZRangeByScore(ctx, retryQueueKey, &redis.ZRangeBy{
+				Min: "-inf",
+				Max: strconv.FormatInt(time.Now().Unix(), 10),
+			}).Result()
+			if err != nil && !errors.Is(err, redis.Nil) {
+				log.Printf("[retry] poll error: %v", err)
+				continue
+			}
+			var claimed []string
+			for _, lang := range due {
+				if n, err := rdb.ZRem(ctx, retryQueueKey, lang).Result(); err == nil && n == 1 {
+					claimed = append(claimed, lang)
+				}
+			}
+			if len(claimed) > 0 {
+				enqueueRefreshJob(ctx, "retry", &refreshScope{Languages: claimed})
+			}
+		}
+	}()
+}
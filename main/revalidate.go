@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// revalidateCheckEvery bounds how often one replica looks at the age of a
+// snapshot it keeps serving from Redis.
+const revalidateCheckEvery = time.Minute
+
+// enqueueRevalidation is set in init: payloadFormats reaches the cache read
+// path, and a direct call into the job queue would close an init cycle.
+var enqueueRevalidation func(ctx context.Context, lang string)
+
+func init() {
+	enqueueRevalidation = func(ctx context.Context, lang string) {
+		enqueueRefreshJob(ctx, "revalidate", &refreshScope{Languages: []string{lang}})
+	}
+}
+
+var revalidateChecks = struct {
+	sync.Mutex
+	last map[string]time.Time
+}{last: map[string]time.Time{}}
+
+// snapshotHardTTL is the Redis TTL of language snapshots, their variants and
+// blobs (SNAPSHOT_HARD_TTL, 0 = no expiry); other keys never expire here.
+func snapshotHardTTL(key string) time.Duration {
+	if !strings.HasPrefix(key, "tolgee:lang:") && !strings.HasPrefix(key, blobKeyPrefix) {
+		return 0
+	}
+	return localenv.GetSnapshotHardTTL()
+}
+
+// maybeRevalidate starts a background refresh of lang when its last refresh
+// is older than SNAPSHOT_SOFT_TTL. The stale snapshot keeps being served;
+// a Redis lock lets a single replica enqueue the job per soft TTL window.
+func maybeRevalidate(lang string, nested bool) {
+	soft := localenv.GetSnapshotSoftTTL()
+	if soft <= 0 || localenv.GetPromotedOnly() {
+		return
+	}
+	key := translationsCacheKey(lang, nested)
+	now := time.Now()
+	revalidateChecks.Lock()
+	if now.Sub(revalidateChecks.last[key]) < revalidateCheckEvery {
+		revalidateChecks.Unlock()
+		return
+	}
+	revalidateChecks.last[key] = now
+	revalidateChecks.Unlock()
+
+	go func() {
+		ctx := context.Background()
+		entry, ok := loadManifest(ctx).Languages[lang]
+		if !ok || entry.UpdatedAt.IsZero() || time.Since(entry.UpdatedAt) < soft {
+			return
+		}
+		if isReadOnly(ctx) {
+			return
+		}
+		if !redisTryLock(ctx, "tolgee:revalidate:"+lang, soft) {
+			return
+		}
+		log.Printf("[cache] soft ttl expired lang=%s age=%s, revalidating", lang, time.Since(entry.UpdatedAt).Round(time.Second))
+		enqueueRevalidation(ctx, lang)
+	}()
+}
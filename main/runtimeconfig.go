@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+const (
+	runtimeConfigCacheKey      = "tolgee:runtime-config"
+	runtimeConfigAuditKey      = "tolgee:runtime-config:audit"
+	runtimeConfigAuditMaxSize  = 1000
+	runtimeConfigReloadEvery   = 5 * time.Second
+	defaultFallbackLanguage    = "en"
+	defaultDerivedVariantTTL   = 24 * time.Hour
+	runtimeConfigTTLDerived    = "derived"
+	runtimeConfigTTLProxy      = "proxy"
+	runtimeConfigTTLMemoryTier = "memory"
+	runtimeConfigTTLArtifact   = "artifact"
+)
+
+// runtimeConfig holds the settings admins can change without a restart; an
+// unset field keeps the env default.
+type runtimeConfig struct {
+	PriorityLanguages []string            `json:"priority_languages,omitempty"`
+	BetaLanguages     []string            `json:"beta_languages,omitempty"`
+	Aliases           map[string]string   `json:"aliases,omitempty"`
+	FallbackChains    map[string][]string `json:"fallback_chains,omitempty"`
+	// TTLOverrides: "derived", "proxy" or "memory" -> Go duration ("2h")
+	TTLOverrides map[string]string `json:"ttl_overrides,omitempty"`
+	UpdatedAt    time.Time         `json:"updated_at,omitempty"`
+	UpdatedBy    string            `json:"updated_by,omitempty"`
+}
+
+type runtimeConfigAuditEntry struct {
+	At       time.Time      `json:"at"`
+	Actor    string         `json:"actor,omitempty"`
+	Previous *runtimeConfig `json:"previous,omitempty"`
+	Next     *runtimeConfig `json:"next,omitempty"`
+}
+
+var (
+	errInvalidRuntimeConfig = errors.New("invalid runtime config")
+
+	runtimeConfigMu sync.Mutex
+	// runtimeConfigCache keeps the last Redis read for runtimeConfigReloadEvery,
+	// since the getters run on every request.
+	runtimeConfigCache struct {
+		sync.Mutex
+		cfg      runtimeConfig
+		loadedAt time.Time
+	}
+)
+
+func loadRuntimeConfig(ctx context.Context) runtimeConfig {
+	var cfg runtimeConfig
+	b, err := redisGet(ctx, runtimeConfigCacheKey)
+	if err != nil || len(b) == 0 {
+		return cfg
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		log.Printf("[config] unmarshal error: %v", err)
+		return runtimeConfig{}
+	}
+	return cfg
+}
+
+func currentRuntimeConfig() runtimeConfig {
+	runtimeConfigCache.Lock()
+	defer runtimeConfigCache.Unlock()
+	if time.Since(runtimeConfigCache.loadedAt) > runtimeConfigReloadEvery {
+		runtimeConfigCache.cfg = loadRuntimeConfig(context.Background())
+		runtimeConfigCache.loadedAt = time.Now()
+	}
+	return runtimeConfigCache.cfg
+}
+
+// putRuntimeConfig validates and replaces the runtime config (an empty config
+// restores every env default) and records the change in the audit trail.
+func putRuntimeConfig(ctx context.Context, next runtimeConfig, actor string) (*runtimeConfig, error) {
+	for name, d := range next.TTLOverrides {
+		switch name {
+		case runtimeConfigTTLDerived, runtimeConfigTTLProxy, runtimeConfigTTLMemoryTier, runtimeConfigTTLArtifact:
+		default:
+			return nil, fmt.Errorf("%w: unknown ttl %q", errInvalidRuntimeConfig, name)
+		}
+		if v, err := time.ParseDuration(d); err != nil || v <= 0 {
+			return nil, fmt.Errorf("%w: ttl %q must be a positive duration", errInvalidRuntimeConfig, name)
+		}
+	}
+	for from, to := range next.Aliases {
+		if from == "" || to == "" || from == to {
+			return nil, fmt.Errorf("%w: alias %q -> %q", errInvalidRuntimeConfig, from, to)
+		}
+	}
+	next.UpdatedAt = time.Now().UTC()
+	next.UpdatedBy = actor
+
+	runtimeConfigMu.Lock()
+	defer runtimeConfigMu.Unlock()
+	previous := loadRuntimeConfig(ctx)
+	b, err := json.Marshal(next)
+	if err != nil {
+		return nil, err
+	}
+	storeCacheEntry(ctx, s3ClientIfEnabled(ctx), runtimeConfigCacheKey, b, "application/json")
+
+	runtimeConfigCache.Lock()
+	runtimeConfigCache.cfg, runtimeConfigCache.loadedAt = next, time.Now()
+	runtimeConfigCache.Unlock()
+
+	if entry, err := json.Marshal(runtimeConfigAuditEntry{At: next.UpdatedAt, Actor: actor, Previous: &previous, Next: &next}); err == nil {
+		_ = rdb.LPush(ctx, runtimeConfigAuditKey, entry).Err()
+		_ = rdb.LTrim(ctx, runtimeConfigAuditKey, 0, runtimeConfigAuditMaxSize-1).Err()
+	}
+	log.Printf("[config] updated actor=%q", actor)
+	return &next, nil
+}
+
+// listRuntimeConfigAudit returns the most recent config changes, newest first.
+func listRuntimeConfigAudit(ctx context.Context, count int64) []json.RawMessage {
+	raw, err := rdb.LRange(ctx, runtimeConfigAuditKey, 0, count-1).Result()
+	if err != nil {
+		return []json.RawMessage{}
+	}
+	out := make([]json.RawMessage, 0, len(raw))
+	for _, r := range raw {
+		out = append(out, json.RawMessage(r))
+	}
+	return out
+}
+
+// effectiveRuntimeConfig merges the overrides with the env defaults, for
+// GET /api/admin/config.
+func effectiveRuntimeConfig() map[string]any {
+	aliases := localenv.GetLanguageAliases()
+	chains := localenv.GetFallbackChains()
+	cfg := currentRuntimeConfig()
+	if cfg.Aliases != nil {
+		aliases = cfg.Aliases
+	}
+	if cfg.FallbackChains != nil {
+		chains = cfg.FallbackChains
+	}
+	return map[string]any{
+		"priority_languages": priorityLanguages(),
+		"beta_languages":     betaLanguages(),
+		"aliases":            aliases,
+		"fallback_chains":    chains,
+		"ttl": map[string]string{
+			runtimeConfigTTLDerived:    derivedVariantTTL().String(),
+			runtimeConfigTTLProxy:      tolgeeProxyTTL().String(),
+			runtimeConfigTTLMemoryTier: memoryTierTTL().String(),
+			runtimeConfigTTLArtifact:   derivedArtifactTTL().String(),
+		},
+		"overrides": cfg,
+	}
+}
+
+// priorityLanguages returns the runtime list, or PRIORITY_LANGUAGES.
+func priorityLanguages() []string {
+	if cfg := currentRuntimeConfig(); cfg.PriorityLanguages != nil {
+		return cfg.PriorityLanguages
+	}
+	return localenv.GetPriorityLanguages()
+}
+
+// resolveLanguageAlias maps a requested tag to the one we store
+// (e.g. "iw" -> "he"), from the runtime aliases or LANGUAGE_ALIASES.
+func resolveLanguageAlias(lang string) string {
+	aliases := currentRuntimeConfig().Aliases
+	if aliases == nil {
+		aliases = localenv.GetLanguageAliases()
+	}
+	if to, ok := aliases[lang]; ok {
+		return to
+	}
+	return lang
+}
+
+// fallbackChain lists the languages tried, in order, when lang is not cached;
+// the default is English only.
+func fallbackChain(lang string) []string {
+	chains := currentRuntimeConfig().FallbackChains
+	if chains == nil {
+		chains = localenv.GetFallbackChains()
+	}
+	if chain, ok := chains[lang]; ok {
+		return chain
+	}
+	return []string{defaultFallbackLanguage}
+}
+
+func runtimeTTL(name string, def time.Duration) time.Duration {
+	if raw, ok := currentRuntimeConfig().TTLOverrides[name]; ok {
+		if d, err := time.ParseDuration(raw); err == nil && d > 0 {
+			return d
+		}
+	}
+	return def
+}
+
+// derivedVariantTTL bounds how long a derived payload survives in Redis; the
+// key embeds the source sha, so a refresh naturally switches to a new entry.
+func derivedVariantTTL() time.Duration {
+	return runtimeTTL(runtimeConfigTTLDerived, defaultDerivedVariantTTL)
+}
+
+func tolgeeProxyTTL() time.Duration {
+	return runtimeTTL(runtimeConfigTTLProxy, localenv.GetTolgeeProxyTTL())
+}
+
+func memoryTierTTL() time.Duration {
+	return runtimeTTL(runtimeConfigTTLMemoryTier, localenv.GetMemoryCacheTTL())
+}
+
+func derivedArtifactTTL() time.Duration {
+	return runtimeTTL(runtimeConfigTTLArtifact, localenv.GetDerivedArtifactTTL())
+}
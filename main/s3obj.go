@@ -7,7 +7,14 @@ import (
 	"io"
 	"log"
 	localenv "mensalocalizations/tools/env"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -16,7 +23,14 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
-var ErrS3ClientNil = errors.New("s3 client is nil")
+var (
+	ErrS3ClientNil       = errors.New("s3 client is nil")
+	ErrS3ConcurrentWrite = errors.New("s3 conditional write kept losing to concurrent writers")
+	ErrS3StaleGeneration = errors.New("s3 object holds a newer refresh generation")
+)
+
+// s3GenerationMetadata holds the refresh generation that wrote an object.
+const s3GenerationMetadata = "refresh-generation"
 
 // S3 client wrapper
 type s3Client struct {
@@ -70,11 +84,100 @@ func newS3ClientFromEnv(ctx context.Context) (*s3Client, error) {
 	return &s3Client{client: client, bucket: bucket}, nil
 }
 
+// sharedS3Client is built once and swapped whole when the credentials rotate:
+// callers keep the client they got for the rest of their operation.
+var sharedS3Client atomic.Pointer[s3Client]
+
+// s3ClientIfEnabled returns the env-configured client, or nil when S3 is
+// disabled or misconfigured (the error is logged, S3 is best-effort).
+func s3ClientIfEnabled(ctx context.Context) *s3Client {
+	if !localenv.GetS3Enabled() {
+		return nil
+	}
+	if c := sharedS3Client.Load(); c != nil {
+		return c
+	}
+	c, err := newS3ClientFromEnv(ctx)
+	if err != nil {
+		log.Printf("[cache][s3] disabled (config error): %v", err)
+		return nil
+	}
+	if !sharedS3Client.CompareAndSwap(nil, c) {
+		return sharedS3Client.Load()
+	}
+	log.Printf("[cache][s3] enabled bucket=%q", c.bucket)
+	return c
+}
+
+// reloadS3Client re-reads the S3 credentials and, when they changed, swaps in
+// a client built from them. Warm caches are untouched.
+func reloadS3Client(ctx context.Context, reason string) {
+	changed, err := localenv.ReloadS3Credentials()
+	if err != nil {
+		log.Printf("[s3] credentials reload error reason=%s: %v", reason, err)
+		return
+	}
+	if !changed || !localenv.GetS3Enabled() {
+		return
+	}
+	c, err := newS3ClientFromEnv(ctx)
+	if err != nil {
+		log.Printf("[s3] credentials reload error reason=%s: %v", reason, err)
+		return
+	}
+	sharedS3Client.Store(c)
+	log.Printf("[s3] credentials rotated reason=%s bucket=%q", reason, c.bucket)
+}
+
+// watchS3Credentials reloads the credentials on SIGHUP and, when they come
+// from files, every S3_CREDENTIALS_RELOAD_INTERVAL.
+func watchS3Credentials() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	var tick <-chan time.Time
+	accessKeyFile, secretKeyFile := localenv.GetS3CredentialFiles()
+	if interval := localenv.GetS3CredentialsReloadInterval(); interval > 0 && (accessKeyFile != "" || secretKeyFile != "") {
+		tick = time.NewTicker(interval).C
+	}
+	go func() {
+		for {
+			select {
+			case <-hup:
+				reloadS3Client(context.Background(), "sighup")
+			case <-tick:
+				reloadS3Client(context.Background(), "watch")
+			}
+		}
+	}()
+}
+
 // getObject reads a raw object by key from the configured bucket.
 func (s *s3Client) getObject(ctx context.Context, key string) ([]byte, error) {
+	b, _, err := s.getObjectWithMetadata(ctx, key)
+	return b, err
+}
+
+// getObjectWithMetadata is getObject also returning the user metadata.
+// Content-addressed pointers are followed to their blob.
+func (s *s3Client) getObjectWithMetadata(ctx context.Context, key string) ([]byte, map[string]string, error) {
+	b, metadata, err := s.readObject(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sha, ok := casPointerSHA(b); ok {
+		if b, _, err = s.readObject(ctx, blobKey(sha)); err != nil {
+			return nil, nil, err
+		}
+	}
+	return b, metadata, nil
+}
+
+// readObject reads the stored bytes of key as-is, pointers included.
+func (s *s3Client) readObject(ctx context.Context, key string) ([]byte, map[string]string, error) {
 	if s == nil {
-		return nil, ErrS3ClientNil
+		return nil, nil, ErrS3ClientNil
 	}
+	defer observeStage(ctx, stageS3, time.Now())
 	log.Printf("[s3] GET key=%q bucket=%q", key, s.bucket)
 	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
@@ -82,29 +185,48 @@ func (s *s3Client) getObject(ctx context.Context, key string) ([]byte, error) {
 	})
 	if err != nil {
 		log.Printf("[s3] GET error key=%q err=%v", key, err)
-		return nil, err
+		return nil, nil, err
 	}
 	defer func() { _ = out.Body.Close() }()
 
-	b, err := io.ReadAll(out.Body)
+	maxObject := localenv.GetMaxPayloadBytes()
+	if out.ContentLength != nil {
+		if err := checkPayloadSize("s3", key, *out.ContentLength, maxObject); err != nil {
+			return nil, nil, err
+		}
+	}
+	reader := io.Reader(out.Body)
+	if maxObject > 0 {
+		reader = io.LimitReader(out.Body, maxObject+1)
+	}
+	b, err := io.ReadAll(reader)
 	if err != nil {
 		log.Printf("[s3] read error key=%q err=%v", key, err)
-		return nil, err
+		return nil, nil, err
+	}
+	if err := checkPayloadSize("s3", key, int64(len(b)), maxObject); err != nil {
+		return nil, nil, err
 	}
 	log.Printf("[s3] GET ok key=%q bytes=%d", key, len(b))
-	return b, nil
+	return b, out.Metadata, nil
 }
 
 // putObject writes a raw object by key into the configured bucket.
 // If contentType is empty, application/octet-stream is used.
-// Metadata can be nil.
+// Metadata can be nil; the storage schema version is always added.
+// When ctx carries a refresh generation the write is conditional, see putObjectConditional.
 func (s *s3Client) putObject(ctx context.Context, key string, payload []byte, contentType string, metadata map[string]string) error {
 	if s == nil {
 		return ErrS3ClientNil
 	}
+	defer observeStage(ctx, stageStore, time.Now())
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
+	metadata = withSchemaVersion(metadata)
+	if gen, ok := refreshGenerationFrom(ctx); ok {
+		return s.putObjectConditional(ctx, key, payload, contentType, metadata, gen)
+	}
 	log.Printf("[s3] PUT key=%q bucket=%q bytes=%d", key, s.bucket, len(payload))
 	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket:      aws.String(s.bucket),
@@ -120,3 +242,223 @@ func (s *s3Client) putObject(ctx context.Context, key string, payload []byte, co
 	}
 	return nil
 }
+
+// putEncodedObject writes a pre-compressed body with its Content-Encoding,
+// so S3 and CDNs in front of it serve it as-is. It is never conditional:
+// readers check the source sha in metadata instead.
+func (s *s3Client) putEncodedObject(ctx context.Context, key string, payload []byte, contentType, contentEncoding string, metadata map[string]string) error {
+	if s == nil {
+		return ErrS3ClientNil
+	}
+	defer observeStage(ctx, stageStore, time.Now())
+	log.Printf("[s3] PUT key=%q bucket=%q bytes=%d encoding=%s", key, s.bucket, len(payload), contentEncoding)
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(key),
+		Body:            bytes.NewReader(payload),
+		ContentType:     aws.String(contentType),
+		ContentEncoding: aws.String(contentEncoding),
+		Metadata:        withSchemaVersion(metadata),
+		ACL:             types.ObjectCannedACLPrivate,
+	})
+	if err != nil {
+		log.Printf("[s3] PUT error key=%q err=%v", key, err)
+	}
+	return err
+}
+
+// putObjectConditional only lets the object advance: the refresh generation is
+// stored in metadata, an object written by a newer generation is never
+// overwritten, and the write uses If-Match/If-None-Match on the ETag seen, so
+// two replicas racing on the same key cannot interleave. Lost races are retried.
+func (s *s3Client) putObjectConditional(ctx context.Context, key string, payload []byte, contentType string, metadata map[string]string, gen int64) error {
+	meta := make(map[string]string, len(metadata)+1)
+	for k, v := range metadata {
+		meta[k] = v
+	}
+	meta[s3GenerationMetadata] = strconv.FormatInt(gen, 10)
+
+	for attempt := 0; attempt < 3; attempt++ {
+		input := &s3.PutObjectInput{
+			Bucket:      aws.String(s.bucket),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(payload),
+			ContentType: aws.String(contentType),
+			Metadata:    meta,
+			ACL:         types.ObjectCannedACLPrivate,
+		}
+		head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key),
+		})
+		var notFound *types.NotFound
+		switch {
+		case errors.As(err, &notFound):
+			input.IfNoneMatch = aws.String("*")
+		case err != nil:
+			log.Printf("[s3] HEAD error key=%q err=%v", key, err)
+			return err
+		default:
+			if current, err := strconv.ParseInt(head.Metadata[s3GenerationMetadata], 10, 64); err == nil && current > gen {
+				log.Printf("[s3] PUT skipped key=%q: stored generation %d newer than %d", key, current, gen)
+				promS3StaleWrites.add(1)
+				return ErrS3StaleGeneration
+			}
+			input.IfMatch = head.ETag
+		}
+
+		log.Printf("[s3] PUT key=%q bucket=%q bytes=%d generation=%d", key, s.bucket, len(payload), gen)
+		_, err = s.client.PutObject(ctx, input)
+		if err == nil {
+			return nil
+		}
+		if !isS3PreconditionError(err) {
+			log.Printf("[s3] PUT error key=%q err=%v", key, err)
+			return err
+		}
+		log.Printf("[s3] PUT lost race key=%q attempt=%d", key, attempt+1)
+	}
+	return ErrS3ConcurrentWrite
+}
+
+// isS3PreconditionError matches the API codes returned by failed conditional writes.
+func isS3PreconditionError(err error) bool {
+	var apiErr interface{ ErrorCode() string }
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	code := apiErr.ErrorCode()
+	return code == "PreconditionFailed" || code == "ConditionalRequestConflict"
+}
+
+// archiveObject moves key to archiveKey (server-side copy, then delete).
+// A missing source is not an error. A content-addressed pointer is archived
+// as its blob, which the blob GC may collect once the pointer is gone.
+func (s *s3Client) archiveObject(ctx context.Context, key, archiveKey string) error {
+	if s == nil {
+		return ErrS3ClientNil
+	}
+	log.Printf("[s3] ARCHIVE key=%q to=%q bucket=%q", key, archiveKey, s.bucket)
+	srcKey := key
+	if raw, _, err := s.readObject(ctx, key); err == nil {
+		if sha, ok := casPointerSHA(raw); ok {
+			srcKey = blobKey(sha)
+		}
+	}
+	if err := s.copyObjectFrom(ctx, s.bucket, srcKey, archiveKey); err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil
+		}
+		return err
+	}
+	return s.deleteObject(ctx, key)
+}
+
+// deleteObject removes key from the configured bucket.
+func (s *s3Client) deleteObject(ctx context.Context, key string) error {
+	if s == nil {
+		return ErrS3ClientNil
+	}
+	log.Printf("[s3] DELETE key=%q bucket=%q", key, s.bucket)
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		log.Printf("[s3] DELETE error key=%q err=%v", key, err)
+	}
+	return err
+}
+
+// withBucket returns a client sharing the same connection but targeting bucket.
+func (s *s3Client) withBucket(bucket string) *s3Client {
+	if s == nil {
+		return nil
+	}
+	return &s3Client{client: s.client, bucket: bucket}
+}
+
+// listKeys returns every object key under prefix.
+func (s *s3Client) listKeys(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	if err := s.eachObject(ctx, prefix, func(key string, _ int64) {
+		keys = append(keys, key)
+	}); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// listObjectSizes returns the size of every object under prefix, as listed
+// (no object is read).
+func (s *s3Client) listObjectSizes(ctx context.Context, prefix string) (map[string]int64, error) {
+	sizes := map[string]int64{}
+	if err := s.eachObject(ctx, prefix, func(key string, size int64) {
+		sizes[key] = size
+	}); err != nil {
+		return nil, err
+	}
+	return sizes, nil
+}
+
+// eachObject calls fn with the key and size of every object under prefix.
+func (s *s3Client) eachObject(ctx context.Context, prefix string, fn func(key string, size int64)) error {
+	if s == nil {
+		return ErrS3ClientNil
+	}
+	p := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			log.Printf("[s3] LIST error prefix=%q err=%v", prefix, err)
+			return err
+		}
+		for _, obj := range page.Contents {
+			fn(aws.ToString(obj.Key), aws.ToInt64(obj.Size))
+		}
+	}
+	return nil
+}
+
+// copyObjectFrom copies srcBucket/srcKey into key of this bucket (server-side).
+func (s *s3Client) copyObjectFrom(ctx context.Context, srcBucket, srcKey, key string) error {
+	if s == nil {
+		return ErrS3ClientNil
+	}
+	log.Printf("[s3] COPY from=%q/%q key=%q bucket=%q", srcBucket, srcKey, key, s.bucket)
+	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(key),
+		CopySource: aws.String(srcBucket + "/" + url.PathEscape(srcKey)),
+		ACL:        types.ObjectCannedACLPrivate,
+	})
+	if err != nil {
+		log.Printf("[s3] COPY error key=%q err=%v", key, err)
+	}
+	return err
+}
+
+// headObject returns the metadata of key.
+func (s *s3Client) headObject(ctx context.Context, key string) (*s3.HeadObjectOutput, error) {
+	if s == nil {
+		return nil, ErrS3ClientNil
+	}
+	return s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+}
+
+// withSchemaVersion returns a copy of metadata stamped with the storage schema version.
+func withSchemaVersion(metadata map[string]string) map[string]string {
+	meta := make(map[string]string, len(metadata)+1)
+	for k, v := range metadata {
+		meta[k] = v
+	}
+	meta[s3SchemaMetadata] = strconv.Itoa(storageSchemaVersion)
+	return meta
+}
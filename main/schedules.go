@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+)
+
+const keySchedulesCacheKey = "tolgee:key-schedules"
+
+// keySchedule time-boxes a key (e.g. seasonal campaign copy): outside
+// [ValidFrom, ValidUntil) the key is replaced by FallbackKey's value, or
+// removed from the served payload when no fallback is set.
+// An empty Lang applies the schedule to every language.
+type keySchedule struct {
+	Lang        string     `json:"lang,omitempty"`
+	Key         string     `json:"key"`
+	ValidFrom   *time.Time `json:"valid_from,omitempty"`
+	ValidUntil  *time.Time `json:"valid_until,omitempty"`
+	FallbackKey string     `json:"fallback_key,omitempty"`
+	Reason      string     `json:"reason,omitempty"`
+}
+
+func (s keySchedule) inWindow(now time.Time) bool {
+	if s.ValidFrom != nil && now.Before(*s.ValidFrom) {
+		return false
+	}
+	return s.ValidUntil == nil || now.Before(*s.ValidUntil)
+}
+
+var (
+	errInvalidSchedule   = errors.New("schedule needs a key and valid_from and/or valid_until")
+	errScheduleMissing   = errors.New("schedule not found")
+	errSchedulesNotSaved = errors.New("schedules could not be saved")
+
+	keySchedulesMu sync.Mutex
+)
+
+// loadKeySchedules is the schedule list for serving: empty when it cannot
+// be read.
+func loadKeySchedules(ctx context.Context) []keySchedule {
+	list, err := readKeySchedules(ctx)
+	if err != nil {
+		log.Printf("[schedules] read error: %v", err)
+		return []keySchedule{}
+	}
+	return list
+}
+
+// readKeySchedules reads the stored list; a missing list is empty.
+func readKeySchedules(ctx context.Context) ([]keySchedule, error) {
+	b, err := redisGet(ctx, keySchedulesCacheKey)
+	if errors.Is(err, redis.Nil) || (err == nil && len(b) == 0) {
+		return []keySchedule{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []keySchedule
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// putKeySchedule creates or replaces the schedule for s.Lang/s.Key.
+func putKeySchedule(ctx context.Context, s keySchedule) error {
+	if s.Key == "" || (s.ValidFrom == nil && s.ValidUntil == nil) {
+		return errInvalidSchedule
+	}
+	if s.ValidFrom != nil && s.ValidUntil != nil && !s.ValidFrom.Before(*s.ValidUntil) {
+		return errInvalidSchedule
+	}
+	if _, err := mutateKeySchedules(ctx, s.Lang, s.Key, &s); err != nil {
+		return err
+	}
+	log.Printf("[schedules] put lang=%q key=%q", s.Lang, s.Key)
+	return nil
+}
+
+func deleteKeySchedule(ctx context.Context, lang, key string) error {
+	found, err := mutateKeySchedules(ctx, lang, key, nil)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errScheduleMissing
+	}
+	log.Printf("[schedules] delete lang=%q key=%q", lang, key)
+	return nil
+}
+
+// mutateKeySchedules replaces (or with next == nil removes) the lang/key
+// schedule and persists the list; it reports whether an entry was replaced.
+// A list it cannot read is left alone rather than overwritten.
+func mutateKeySchedules(ctx context.Context, lang, key string, next *keySchedule) (bool, error) {
+	keySchedulesMu.Lock()
+	defer keySchedulesMu.Unlock()
+
+	list, err := readKeySchedules(ctx)
+	if err != nil {
+		return false, err
+	}
+	found := false
+	kept := []keySchedule{}
+	for _, s := range list {
+		if s.Lang == lang && s.Key == key {
+			found = true
+			continue
+		}
+		kept = append(kept, s)
+	}
+	if !found && next == nil {
+		return false, nil
+	}
+	if next != nil {
+		kept = append(kept, *next)
+	}
+	b, err := json.Marshal(kept)
+	if err != nil {
+		return found, err
+	}
+	storeCacheEntry(ctx, s3ClientIfEnabled(ctx), keySchedulesCacheKey, b, "application/json")
+	if written, err := redisGet(ctx, keySchedulesCacheKey); err != nil || !bytes.Equal(written, b) {
+		return found, errSchedulesNotSaved
+	}
+	return found, nil
+}
+
+// applyKeySchedules substitutes or removes the keys of lang whose schedule
+// window is closed right now.
+func applyKeySchedules(c *fiber.Ctx, lang string, nested bool, payload []byte) ([]byte, error) {
+	now := time.Now()
+	var expired []keySchedule
+	for _, s := range loadKeySchedules(context.Background()) {
+		if (s.Lang == "" || s.Lang == lang) && !s.inWindow(now) {
+			expired = append(expired, s)
+		}
+	}
+	if len(expired) == 0 {
+		return payload, nil
+	}
+	delim := ""
+	if !nested {
+		delim, _ = resolveDelimiter(c)
+	}
+	var tree map[string]any
+	if err := decodeJSON(payload, &tree); err != nil {
+		return nil, err
+	}
+	for _, s := range expired {
+		if s.FallbackKey != "" {
+			if v, ok := lookupCatalogValue(tree, s.FallbackKey, nested, delim); ok {
+				setCatalogValue(tree, s.Key, nested, delim, v)
+				continue
+			}
+		}
+		deleteCatalogValue(tree, s.Key, nested, delim)
+	}
+	c.Locals(localsOverridden, true)
+	return marshalJSON(tree)
+}
+
+// catalogStep is one hop into a served catalog: an object key, or an array
+// index when index >= 0.
+type catalogStep struct {
+	key   string
+	index int
+}
+
+// catalogPath maps a Tolgee key ("a.b", "a.items[0]") to its location in a
+// served catalog: object keys and array indexes when nested, a single
+// (delimiter-joined) key when flat.
+func catalogPath(key string, nested bool, delim string) []catalogStep {
+	if !nested {
+		if delim != "" {
+			key = strings.ReplaceAll(key, ".", delim)
+		}
+		return []catalogStep{{key: key, index: -1}}
+	}
+	var steps []catalogStep
+	for _, part := range strings.Split(key, ".") {
+		name, indexes := splitArrayIndexes(part)
+		steps = append(steps, catalogStep{key: name, index: -1})
+		for _, i := range indexes {
+			steps = append(steps, catalogStep{index: i})
+		}
+	}
+	return steps
+}
+
+// splitArrayIndexes splits "items[0][1]" into "items" and [0 1]. Anything
+// that is not a well-formed suffix is kept as part of the name.
+func splitArrayIndexes(part string) (string, []int) {
+	var indexes []int
+	name := part
+	for strings.HasSuffix(name, "]") {
+		open := strings.LastIndexByte(name, '[')
+		if open <= 0 {
+			break
+		}
+		i, err := strconv.Atoi(name[open+1 : len(name)-1])
+		if err != nil || i < 0 {
+			break
+		}
+		indexes = append([]int{i}, indexes...)
+		name = name[:open]
+	}
+	if len(indexes) == 0 {
+		return part, nil
+	}
+	return name, indexes
+}
+
+func lookupCatalogValue(tree map[string]any, key string, nested bool, delim string) (any, bool) {
+	var node any = tree
+	for _, step := range catalogPath(key, nested, delim) {
+		var ok bool
+		if node, ok = catalogChild(node, step); !ok {
+			return nil, false
+		}
+	}
+	return node, true
+}
+
+func catalogChild(node any, step catalogStep) (any, bool) {
+	if step.index < 0 {
+		m, ok := node.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		v, ok := m[step.key]
+		return v, ok
+	}
+	arr, ok := node.([]any)
+	if !ok || step.index >= len(arr) {
+		return nil, false
+	}
+	return arr[step.index], true
+}
+
+// setCatalogValue creates the missing objects and arrays along the path;
+// arrays are padded with null up to the index.
+func setCatalogValue(tree map[string]any, key string, nested bool, delim string, value any) {
+	setCatalogNode(tree, catalogPath(key, nested, delim), value)
+}
+
+func setCatalogNode(node any, steps []catalogStep, value any) any {
+	if len(steps) == 0 {
+		return value
+	}
+	step := steps[0]
+	if step.index < 0 {
+		m, ok := node.(map[string]any)
+		if !ok {
+			m = map[string]any{}
+		}
+		m[step.key] = setCatalogNode(m[step.key], steps[1:], value)
+		return m
+	}
+	arr, _ := node.([]any)
+	for len(arr) <= step.index {
+		arr = append(arr, nil)
+	}
+	arr[step.index] = setCatalogNode(arr[step.index], steps[1:], value)
+	return arr
+}
+
+// deleteCatalogValue removes an object key; an array element becomes null
+// instead, so the following items keep their index.
+func deleteCatalogValue(tree map[string]any, key string, nested bool, delim string) {
+	steps := catalogPath(key, nested, delim)
+	var node any = tree
+	for _, step := range steps[:len(steps)-1] {
+		var ok bool
+		if node, ok = catalogChild(node, step); !ok {
+			return
+		}
+	}
+	last := steps[len(steps)-1]
+	switch parent := node.(type) {
+	case map[string]any:
+		if last.index < 0 {
+			delete(parent, last.key)
+		}
+	case []any:
+		if last.index >= 0 && last.index < len(parent) {
+			parent[last.index] = nil
+		}
+	}
+}
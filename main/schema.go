@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/goccy/go-json"
+)
+
+const schemasCacheKey = "tolgee:schemas"
+
+// globalSchemaScope applies a schema to the whole nested catalog; any other
+// scope is a namespace (top-level section).
+const globalSchemaScope = "*"
+
+// maxSchemaViolations caps the violations reported per language.
+const maxSchemaViolations = 50
+
+var (
+	errInvalidSchema = errors.New("schema must be a JSON object")
+	errUnknownSchema = errors.New("unknown schema")
+	// errSchemaValidation marks a language whose new snapshot was rejected
+	errSchemaValidation = errors.New("schema validation failed, previous snapshot kept")
+
+	schemasMu sync.Mutex
+)
+
+func loadSchemas(ctx context.Context) map[string]json.RawMessage {
+	schemas := map[string]json.RawMessage{}
+	b, err := redisGet(ctx, schemasCacheKey)
+	if err != nil || len(b) == 0 {
+		return schemas
+	}
+	if err := json.Unmarshal(b, &schemas); err != nil {
+		log.Printf("[schema] unmarshal error: %v", err)
+		return map[string]json.RawMessage{}
+	}
+	return schemas
+}
+
+// putSchema registers (or replaces) the schema of scope; with schema == nil
+// the scope is removed. It reports whether the scope existed.
+func putSchema(ctx context.Context, scope string, schema json.RawMessage) (bool, error) {
+	if scope == "" {
+		return false, errInvalidSchema
+	}
+	if schema != nil {
+		var probe map[string]any
+		if err := json.Unmarshal(schema, &probe); err != nil || probe == nil {
+			return false, errInvalidSchema
+		}
+		if err := compileSchemaPatterns(probe); err != nil {
+			return false, err
+		}
+	}
+	schemasMu.Lock()
+	defer schemasMu.Unlock()
+
+	schemas := loadSchemas(ctx)
+	_, existed := schemas[scope]
+	if schema == nil {
+		delete(schemas, scope)
+	} else {
+		schemas[scope] = schema
+	}
+	b, err := json.Marshal(schemas)
+	if err != nil {
+		return existed, err
+	}
+	storeCacheEntry(ctx, s3ClientIfEnabled(ctx), schemasCacheKey, b, "application/json")
+	log.Printf("[schema] put scope=%q removed=%t", scope, schema == nil)
+	return existed, nil
+}
+
+// compileSchemaPatterns rejects schemas whose "pattern" does not compile, so
+// a typo surfaces on upload instead of failing every refresh.
+func compileSchemaPatterns(schema map[string]any) error {
+	if p, ok := schema["pattern"].(string); ok {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
+	for _, k := range []string{"items", "additionalProperties"} {
+		if child, ok := schema[k].(map[string]any); ok {
+			if err := compileSchemaPatterns(child); err != nil {
+				return err
+			}
+		}
+	}
+	if props, ok := schema["properties"].(map[string]any); ok {
+		for _, p := range props {
+			if child, ok := p.(map[string]any); ok {
+				if err := compileSchemaPatterns(child); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateCatalog checks a nested catalog against the registered schemas and
+// returns the violations as "<path>: <reason>", sorted.
+func validateCatalog(ctx context.Context, nested []byte) ([]string, error) {
+	schemas := loadSchemas(ctx)
+	if len(schemas) == 0 {
+		return nil, nil
+	}
+	var tree map[string]any
+	if err := decodeJSON(nested, &tree); err != nil {
+		return nil, err
+	}
+	var violations []string
+	for scope, raw := range schemas {
+		var schema map[string]any
+		if err := decodeJSON(raw, &schema); err != nil {
+			return nil, fmt.Errorf("schema %q: %w", scope, err)
+		}
+		var value any = tree
+		path := ""
+		if scope != globalSchemaScope {
+			path = scope
+			v, ok := tree[scope]
+			if !ok {
+				violations = append(violations, scope+": namespace is missing")
+				continue
+			}
+			value = v
+		}
+		validateSchemaValue(schema, value, path, &violations)
+	}
+	sort.Strings(violations)
+	if len(violations) > maxSchemaViolations {
+		violations = append(violations[:maxSchemaViolations], fmt.Sprintf("... and %d more", len(violations)-maxSchemaViolations))
+	}
+	return violations, nil
+}
+
+// validateSchemaValue implements the JSON Schema subset a catalog needs:
+// type, required, properties, additionalProperties, items, minLength,
+// maxLength and pattern.
+func validateSchemaValue(schema map[string]any, v any, path string, violations *[]string) {
+	report := func(format string, args ...any) {
+		at := path
+		if at == "" {
+			at = "(root)"
+		}
+		*violations = append(*violations, at+": "+fmt.Sprintf(format, args...))
+	}
+	if t, ok := schema["type"]; ok && !schemaTypeMatches(t, v) {
+		report("expected type %v, got %s", t, jsonTypeName(v))
+		return
+	}
+	switch val := v.(type) {
+	case map[string]any:
+		if required, ok := schema["required"].([]any); ok {
+			for _, r := range required {
+				if name, ok := r.(string); ok {
+					if _, present := val[name]; !present {
+						report("missing required key %q", name)
+					}
+				}
+			}
+		}
+		props, _ := schema["properties"].(map[string]any)
+		for k, child := range val {
+			if p, ok := props[k].(map[string]any); ok {
+				validateSchemaValue(p, child, joinSchemaPath(path, k), violations)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					report("unexpected key %q", k)
+				}
+			case map[string]any:
+				validateSchemaValue(extra, child, joinSchemaPath(path, k), violations)
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range val {
+				validateSchemaValue(items, item, path+"["+strconv.Itoa(i)+"]", violations)
+			}
+		}
+	case string:
+		n := len([]rune(val))
+		if min, ok := schemaInt(schema["minLength"]); ok && n < min {
+			report("shorter than %d characters", min)
+		}
+		if max, ok := schemaInt(schema["maxLength"]); ok && n > max {
+			report("longer than %d characters", max)
+		}
+		if p, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(p); err == nil && !re.MatchString(val) {
+				report("does not match pattern %q", p)
+			}
+		}
+	}
+}
+
+func joinSchemaPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func schemaTypeMatches(t any, v any) bool {
+	switch tt := t.(type) {
+	case string:
+		actual := jsonTypeName(v)
+		return tt == actual || (tt == "number" && actual == "integer")
+	case []any:
+		for _, one := range tt {
+			if schemaTypeMatches(one, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jsonTypeName(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case json.Number:
+		if _, err := val.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	}
+	return "unknown"
+}
+
+func schemaInt(v any) (int, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := n.Int64()
+	return int(i), err == nil
+}
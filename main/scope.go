@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+)
+
+var errInvalidRefreshScope = errors.New(`body must be {languages?: [...], modes?: ["nested"|"flat"], namespaces?: [...]}`)
+
+// refreshScope narrows a manual refresh; an empty field means "all". Namespaces
+// are top-level sections (nested) or first key segments (flat): only those are
+// replaced in the stored snapshots, the rest of each catalog is kept as is.
+type refreshScope struct {
+	Languages  []string `json:"languages,omitempty"`
+	Modes      []string `json:"modes,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// validate normalizes the scope (trimmed, deduplicated, sorted) and rejects
+// unknown modes.
+func (s *refreshScope) validate() error {
+	for _, list := range []*[]string{&s.Languages, &s.Modes, &s.Namespaces} {
+		set := map[string]bool{}
+		for _, v := range *list {
+			if v = strings.TrimSpace(v); v != "" {
+				set[v] = true
+			}
+		}
+		*list = sortedSetKeys(set)
+	}
+	for _, mode := range s.Modes {
+		if mode != "nested" && mode != "flat" {
+			return errInvalidRefreshScope
+		}
+	}
+	return nil
+}
+
+// isFull reports whether the scope covers the whole project.
+func (s *refreshScope) isFull() bool {
+	return s == nil || len(s.Languages)+len(s.Modes)+len(s.Namespaces) == 0
+}
+
+// modes returns the export shapes to refresh, nested first.
+func (s *refreshScope) modes() []bool {
+	if s == nil || len(s.Modes) == 0 {
+		return []bool{true, false}
+	}
+	var out []bool
+	for _, nested := range []bool{true, false} {
+		for _, mode := range s.Modes {
+			if mode == modeLabel(nested) {
+				out = append(out, nested)
+			}
+		}
+	}
+	return out
+}
+
+// dedupeScope identifies the scope in the pending-job slot.
+func (s *refreshScope) dedupeScope() string {
+	if s.isFull() {
+		return ""
+	}
+	return strings.Join(s.Modes, ",") + "|" + strings.Join(s.Namespaces, ",")
+}
+
+// filterLanguages keeps the scoped languages that exist in tags; the others
+// are returned as unknown.
+func (s *refreshScope) filterLanguages(tags []string) (kept, unknown []string) {
+	if s == nil || len(s.Languages) == 0 {
+		return tags, nil
+	}
+	known := map[string]bool{}
+	for _, t := range tags {
+		known[t] = true
+	}
+	for _, t := range s.Languages {
+		if known[t] {
+			kept = append(kept, t)
+		} else {
+			unknown = append(unknown, t)
+		}
+	}
+	sort.Strings(kept)
+	return kept, unknown
+}
+
+// mergeRefreshedNamespaces lays the scoped namespaces of a fresh export over
+// the stored snapshot of key. A namespace missing from the export is removed;
+// without a stored snapshot the whole export is kept.
+func mergeRefreshedNamespaces(ctx context.Context, key string, nested bool, namespaces []string, fresh []byte) ([]byte, error) {
+	stored, err := redisGet(ctx, key)
+	if err != nil || len(stored) == 0 {
+		return fresh, nil
+	}
+	var current, next map[string]any
+	if err := decodeJSON(stored, &current); err != nil {
+		return nil, err
+	}
+	if err := decodeJSON(fresh, &next); err != nil {
+		return nil, err
+	}
+	scoped := map[string]bool{}
+	for _, ns := range namespaces {
+		scoped[ns] = true
+	}
+	inScope := func(k string) bool {
+		if !nested {
+			k, _, _ = strings.Cut(k, ".")
+		}
+		return scoped[k]
+	}
+	for k := range current {
+		if inScope(k) {
+			delete(current, k)
+		}
+	}
+	for k, v := range next {
+		if inScope(k) {
+			current[k] = v
+		}
+	}
+	return marshalJSON(current)
+}
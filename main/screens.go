@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+)
+
+const screensCacheKey = "tolgee:screens"
+
+// screenManifest declares the keys a client screen uses, as Tolgee key
+// prefixes ("onboarding.", "common.ok").
+type screenManifest struct {
+	Name     string   `json:"name"`
+	Prefixes []string `json:"prefixes"`
+}
+
+var (
+	errInvalidScreen = errors.New("screen manifest needs a name and at least one prefix")
+	errUnknownScreen = errors.New("unknown screen")
+
+	screensMu sync.Mutex
+)
+
+func loadScreens(ctx context.Context) map[string]screenManifest {
+	screens := map[string]screenManifest{}
+	b, err := redisGet(ctx, screensCacheKey)
+	if err != nil || len(b) == 0 {
+		return screens
+	}
+	if err := json.Unmarshal(b, &screens); err != nil {
+		log.Printf("[screens] unmarshal error: %v", err)
+		return map[string]screenManifest{}
+	}
+	return screens
+}
+
+// listScreens returns the registered manifests sorted by name.
+func listScreens(ctx context.Context) []screenManifest {
+	screens := loadScreens(ctx)
+	out := make([]screenManifest, 0, len(screens))
+	for _, s := range screens {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// putScreen registers (or replaces) a screen manifest; with prefixes == nil
+// the screen is removed. It reports whether the screen existed.
+func putScreen(ctx context.Context, name string, prefixes []string) (bool, error) {
+	if name == "" || (prefixes != nil && len(prefixes) == 0) {
+		return false, errInvalidScreen
+	}
+	screensMu.Lock()
+	defer screensMu.Unlock()
+
+	screens := loadScreens(ctx)
+	_, existed := screens[name]
+	if prefixes == nil {
+		delete(screens, name)
+	} else {
+		screens[name] = screenManifest{Name: name, Prefixes: prefixes}
+	}
+	b, err := json.Marshal(screens)
+	if err != nil {
+		return existed, err
+	}
+	storeCacheEntry(ctx, s3ClientIfEnabled(ctx), screensCacheKey, b, "application/json")
+	log.Printf("[screens] put name=%q prefixes=%d", name, len(prefixes))
+	return existed, nil
+}
+
+// lookupScreen returns the registered manifest for name.
+func lookupScreen(ctx context.Context, name string) (screenManifest, error) {
+	m, ok := loadScreens(ctx)[name]
+	if !ok {
+		return screenManifest{}, errUnknownScreen
+	}
+	return m, nil
+}
+
+// screenSubset keeps only the keys of the request catalog matching the screen
+// prefixes. Subsets are cached by served language, registered screen, catalog
+// sha, shape and manifest; when the served language is unknown they are
+// computed inline.
+func screenSubset(c *fiber.Ctx, m screenManifest, nested bool, payload []byte) ([]byte, error) {
+	delim := ""
+	if !nested {
+		delim, _ = resolveDelimiter(c)
+	}
+	served, known := servedLanguageOf(c)
+	sum := sha256.Sum256([]byte(sha256Hex(payload) + "\x00" + delim + "\x00" + strings.Join(m.Prefixes, "\x00")))
+	key := "tolgee:screen:" + served + ":" + m.Name + ":" + strconv.FormatBool(nested) + ":" + hex.EncodeToString(sum[:6])
+	if known {
+		if cached, err := redisGet(context.Background(), key); err == nil && len(cached) > 0 {
+			return cached, nil
+		}
+	}
+
+	var tree map[string]any
+	if err := decodeJSON(payload, &tree); err != nil {
+		return nil, err
+	}
+	flat := tree
+	if nested {
+		flat = make(map[string]any, len(tree))
+		flattenInto(flat, "", tree, ".")
+	}
+	out := map[string]any{}
+	for k, v := range flat {
+		tolgeeKey := k
+		if !nested && delim != "" {
+			tolgeeKey = strings.ReplaceAll(k, delim, ".")
+		}
+		if hasAnyPrefix(tolgeeKey, m.Prefixes) {
+			setCatalogValue(out, tolgeeKey, nested, delim, v)
+		}
+	}
+	b, err := marshalJSON(out)
+	if err != nil {
+		return nil, err
+	}
+	if known {
+		_ = redisPut(context.Background(), key, b, derivedVariantTTL())
+	}
+	return b, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+const (
+	tolgeeSlotsKey     = "tolgee:upstream:slots"
+	tolgeeSlotPollWait = 100 * time.Millisecond
+)
+
+var (
+	promTolgeeInflight = newPromMetric("gauge", "mensa_tolgee_inflight",
+		"Tolgee requests in flight in this process.", nil)
+	promTolgeeSlotWait = newPromMetric("histogram", "mensa_tolgee_slot_wait_seconds",
+		"Time spent queueing for a Tolgee concurrency slot.",
+		[]float64{0, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30}, "result")
+)
+
+// tolgeeSlots is the process-wide semaphore, sized on first use.
+var tolgeeSlots struct {
+	once     sync.Once
+	ch       chan struct{}
+	inflight atomic.Int64
+	seq      atomic.Int64
+}
+
+// acquireGlobalSlot drops expired leases, then takes a slot if fewer than
+// ARGV[2] are held. Leases expire so a crashed replica cannot leak slots.
+var acquireGlobalSlot = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+if redis.call('ZCARD', KEYS[1]) < tonumber(ARGV[2]) then
+	redis.call('ZADD', KEYS[1], ARGV[3], ARGV[4])
+	redis.call('PEXPIRE', KEYS[1], ARGV[5])
+	return 1
+end
+return 0
+`)
+
+// acquireTolgeeSlot waits up to TOLGEE_QUEUE_TIMEOUT for a slot under
+// TOLGEE_MAX_CONCURRENCY and, when set, TOLGEE_GLOBAL_MAX_CONCURRENCY, so a
+// cache wipe queues cold paths instead of opening hundreds of connections
+// to Tolgee. On timeout it returns an upstreamCooldownError (503 with
+// Retry-After for clients). The release func must always be called.
+func acquireTolgeeSlot(ctx context.Context) (func(), error) {
+	start := time.Now()
+	timeout := localenv.GetTolgeeQueueTimeout()
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	releaseLocal := func() {}
+	if limit := localenv.GetTolgeeMaxConcurrency(); limit > 0 {
+		tolgeeSlots.once.Do(func() { tolgeeSlots.ch = make(chan struct{}, limit) })
+		select {
+		case tolgeeSlots.ch <- struct{}{}:
+			releaseLocal = func() { <-tolgeeSlots.ch }
+		case <-waitCtx.Done():
+			return nil, tolgeeBusy(start, "process")
+		}
+	}
+
+	releaseGlobal, err := acquireGlobalTolgeeSlot(waitCtx)
+	if err != nil {
+		releaseLocal()
+		return nil, tolgeeBusy(start, "replicas")
+	}
+	promTolgeeSlotWait.observe(time.Since(start).Seconds(), "acquired")
+	promTolgeeInflight.set(float64(tolgeeSlots.inflight.Add(1)))
+	return func() {
+		promTolgeeInflight.set(float64(tolgeeSlots.inflight.Add(-1)))
+		releaseGlobal()
+		releaseLocal()
+	}, nil
+}
+
+func tolgeeBusy(start time.Time, scope string) error {
+	promTolgeeSlotWait.observe(time.Since(start).Seconds(), "timeout")
+	log.Printf("[upstream] no tolgee slot within %s (%s limit)", localenv.GetTolgeeQueueTimeout(), scope)
+	return &upstreamCooldownError{key: "tolgee", retryAfter: time.Second, cause: "too many concurrent Tolgee requests (" + scope + ")"}
+}
+
+// acquireGlobalTolgeeSlot polls the Redis slot set until ctx is done. Redis
+// errors fail open: the process limit still applies.
+func acquireGlobalTolgeeSlot(ctx context.Context) (func(), error) {
+	limit := localenv.GetTolgeeGlobalMaxConcurrency()
+	if limit <= 0 || !redisEnabled() {
+		return func() {}, nil
+	}
+	host, _ := os.Hostname()
+	member := host + ":" + strconv.Itoa(os.Getpid()) + ":" + strconv.FormatInt(tolgeeSlots.seq.Add(1), 10)
+	lease := localenv.GetTolgeeSlotLease()
+	for {
+		now := time.Now()
+		ok, err := acquireGlobalSlot.Run(ctx, rdb, []string{tolgeeSlotsKey},
+			now.UnixMilli(), limit, now.Add(lease).UnixMilli(), member, lease.Milliseconds()).Int()
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[upstream] global slot error, continuing without: %v", err)
+			return func() {}, nil
+		}
+		if ok == 1 {
+			return func() {
+				_ = rdb.ZRem(context.Background(), tolgeeSlotsKey, member).Err()
+			}, nil
+		}
+		select {
+		case <-time.After(tolgeeSlotPollWait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
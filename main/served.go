@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// servedLanguage records which language GetTranslationsFromCache actually
+// served for a request, the languages it tried on the way and the tier the
+// snapshot came from.
+type servedLanguage struct {
+	lang   string
+	tried  []string
+	source string
+}
+
+// Tiers a snapshot can be served from.
+const (
+	servedFromMemory = "memory"
+	servedFromRedis  = "redis"
+	servedFromS3     = "s3"
+)
+
+// localsServedLanguage exposes the request's servedLanguage to the encoders.
+const localsServedLanguage = "servedLanguage"
+
+type servedLanguageCtxKey struct{}
+
+func withServedLanguage(ctx context.Context, rec *servedLanguage) context.Context {
+	return context.WithValue(ctx, servedLanguageCtxKey{}, rec)
+}
+
+// recordServedLanguage keeps the first lookup only: later ones (e.g. the base
+// language loaded for backfilling) are not what the client asked for.
+func recordServedLanguage(ctx context.Context, lang string, tried []string, source string) {
+	rec, ok := ctx.Value(servedLanguageCtxKey{}).(*servedLanguage)
+	if !ok || rec.lang != "" {
+		return
+	}
+	rec.lang = lang
+	rec.tried = append([]string(nil), tried...)
+	rec.source = source
+}
+
+// servedLanguageOf returns the language whose snapshot the request served,
+// as recorded by GetTranslationsFromCache (or set by the handler); false
+// when unknown, e.g. the raw path param of a request that never loaded one.
+func servedLanguageOf(c *fiber.Ctx) (string, bool) {
+	rec, ok := c.Locals(localsServedLanguage).(*servedLanguage)
+	if !ok || rec.lang == "" {
+		return "", false
+	}
+	return rec.lang, true
+}
+
+// servedLanguageLabel is the served language as a metric label, "other" when
+// it is unknown, so arbitrary request paths cannot mint new series.
+func servedLanguageLabel(c *fiber.Ctx) string {
+	if lang, ok := servedLanguageOf(c); ok {
+		return lang
+	}
+	return "other"
+}
+
+// setServedLanguageHeaders exposes a fallback to client telemetry:
+// X-Requested-Language, X-Served-Language and X-Fallback-Chain (the languages
+// tried, in order, ending with the served one).
+func setServedLanguageHeaders(c *fiber.Ctx, requested string, rec *servedLanguage) {
+	if rec.lang == "" || rec.lang == requested {
+		return
+	}
+	c.Set("X-Requested-Language", requested)
+	c.Set("X-Served-Language", rec.lang)
+	c.Set("X-Fallback-Chain", strings.Join(rec.tried, ","))
+}
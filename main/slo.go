@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+const (
+	modifiedAtCacheKey = "tolgee:modified-at"
+	// modifiedAtGlobal marks webhook activity that names no language (e.g. a
+	// key rename), which makes every language potentially stale.
+	modifiedAtGlobal     = "*"
+	sloStateReloadEvery  = 5 * time.Second
+	sloMinWindowRequests = 100
+)
+
+// sloState caches the Tolgee modification times and the snapshot times of
+// the manifest for sloStateReloadEvery: staleness is observed on every request.
+var sloState struct {
+	sync.Mutex
+	modified map[string]time.Time
+	updated  map[string]time.Time
+	loadedAt time.Time
+}
+
+// sloWindow counts requests per minute over FRESHNESS_SLO_WINDOW.
+var sloWindow struct {
+	sync.Mutex
+	minutes  map[int64]*sloMinute
+	breached bool
+}
+
+type sloMinute struct{ total, within int64 }
+
+var (
+	promServedStaleness = newPromMetric("histogram", "mensa_served_staleness_seconds",
+		"Time a served snapshot had been behind the last Tolgee modification (0 = up to date).",
+		[]float64{0, 60, 300, 900, 1800, 3600, 6 * 3600, 24 * 3600},
+		"lang")
+	promFreshnessRequests = newPromMetric("counter", "mensa_freshness_slo_requests_total",
+		"Served catalogs by freshness SLO outcome (within, breach).", nil, "result")
+	promFreshnessRatio = newPromMetric("gauge", "mensa_freshness_slo_ratio",
+		"Share of requests within FRESHNESS_SLO_MAX_AGE over FRESHNESS_SLO_WINDOW.", nil)
+	promFreshnessBreached = newPromMetric("gauge", "mensa_freshness_slo_breached",
+		"1 while the freshness SLO ratio is below FRESHNESS_SLO_TARGET.", nil)
+)
+
+func init() {
+	registerWebhookHook(webhookEventAny, "modified-at", func(ctx context.Context, ev webhookEvent) error {
+		if ev.Type == webhookEventOther || !redisEnabled() {
+			return nil
+		}
+		langs := ev.Languages
+		if len(langs) == 0 {
+			langs = []string{modifiedAtGlobal}
+		}
+		now := time.Now().UTC().Format(time.RFC3339Nano)
+		values := make([]any, 0, 2*len(langs))
+		for _, lang := range langs {
+			values = append(values, lang, now)
+		}
+		return rdb.HSet(ctx, modifiedAtCacheKey, values...).Err()
+	})
+}
+
+// loadSLOState returns the cached modification and snapshot times.
+func loadSLOState(ctx context.Context) (modified, updated map[string]time.Time) {
+	sloState.Lock()
+	defer sloState.Unlock()
+	if sloState.modified != nil && time.Since(sloState.loadedAt) < sloStateReloadEvery {
+		return sloState.modified, sloState.updated
+	}
+	modified = map[string]time.Time{}
+	if raw, err := rdb.HGetAll(ctx, modifiedAtCacheKey).Result(); err == nil {
+		for lang, v := range raw {
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				modified[lang] = t
+			}
+		}
+	}
+	updated = map[string]time.Time{}
+	for lang, entry := range loadManifest(ctx).Languages {
+		updated[lang] = entry.UpdatedAt
+	}
+	sloState.modified, sloState.updated, sloState.loadedAt = modified, updated, time.Now()
+	return modified, updated
+}
+
+// servedStaleness is how long the snapshot of lang has been behind Tolgee:
+// zero when it was refreshed after the last modification webhook.
+func servedStaleness(ctx context.Context, lang string) time.Duration {
+	modified, updated := loadSLOState(ctx)
+	changed := modified[lang]
+	if global := modified[modifiedAtGlobal]; global.After(changed) {
+		changed = global
+	}
+	if changed.IsZero() || !updated[lang].Before(changed) {
+		return 0
+	}
+	return time.Since(changed)
+}
+
+// observeServedFreshness records the staleness of a served catalog against
+// the FRESHNESS_SLO_MAX_AGE objective (the served language, not the requested
+// one, when a fallback kicked in).
+func observeServedFreshness(c *fiber.Ctx, lang string) {
+	maxAge := localenv.GetFreshnessSLOMaxAge()
+	if maxAge <= 0 {
+		return
+	}
+	if served := c.GetRespHeader("X-Served-Language"); served != "" {
+		lang = served
+	}
+	age := servedStaleness(context.Background(), lang)
+	promServedStaleness.observe(age.Seconds(), lang)
+	within := age <= maxAge
+	if within {
+		promFreshnessRequests.add(1, "within")
+	} else {
+		promFreshnessRequests.add(1, "breach")
+	}
+	recordSLOWindow(within)
+}
+
+// recordSLOWindow updates the rolling ratio and notifies on transitions
+// between meeting and breaching FRESHNESS_SLO_TARGET. Windows with fewer than
+// sloMinWindowRequests requests never alert.
+func recordSLOWindow(within bool) {
+	now := time.Now().Unix() / 60
+	window := int64(localenv.GetFreshnessSLOWindow() / time.Minute)
+	if window < 1 {
+		window = 1
+	}
+	target := localenv.GetFreshnessSLOTarget()
+
+	sloWindow.Lock()
+	if sloWindow.minutes == nil {
+		sloWindow.minutes = map[int64]*sloMinute{}
+	}
+	m := sloWindow.minutes[now]
+	if m == nil {
+		m = &sloMinute{}
+		sloWindow.minutes[now] = m
+	}
+	m.total++
+	if within {
+		m.within++
+	}
+	var total, ok int64
+	for minute, counts := range sloWindow.minutes {
+		if minute <= now-window {
+			delete(sloWindow.minutes, minute)
+			continue
+		}
+		total += counts.total
+		ok += counts.within
+	}
+	ratio := float64(ok) / float64(total)
+	breached := total >= sloMinWindowRequests && ratio < target
+	changed := breached != sloWindow.breached
+	sloWindow.breached = breached
+	sloWindow.Unlock()
+
+	promFreshnessRatio.set(ratio)
+	if breached {
+		promFreshnessBreached.set(1)
+	} else {
+		promFreshnessBreached.set(0)
+	}
+	if !changed {
+		return
+	}
+	event := "freshness_slo_recovered"
+	if breached {
+		event = "freshness_slo_breach"
+	}
+	log.Printf("[slo] %s ratio=%.4f target=%.4f requests=%d", event, ratio, target, total)
+	notifyOutgoing(event, map[string]any{
+		"ratio":    ratio,
+		"target":   target,
+		"requests": total,
+		"max_age":  localenv.GetFreshnessSLOMaxAge().String(),
+		"window":   localenv.GetFreshnessSLOWindow().String(),
+	})
+}
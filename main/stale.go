@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// applyStaleBanner injects "<STALE_BANNER_KEY>.stale" and ".age_seconds" when
+// the language was last refreshed more than STALE_BANNER_AFTER ago, so apps
+// can show a "content may be outdated" notice.
+func applyStaleBanner(c *fiber.Ctx, nested bool, payload []byte) ([]byte, error) {
+	threshold := localenv.GetStaleBannerAfter()
+	if threshold <= 0 {
+		return payload, nil
+	}
+	return injectStaleBanner(c, loadManifest(context.Background()), threshold, nested, payload)
+}
+
+// injectStaleBanner ages the language the request served, not the one it
+// asked for: after a fallback the payload is the other language's snapshot.
+func injectStaleBanner(c *fiber.Ctx, m *translationsManifest, threshold time.Duration, nested bool, payload []byte) ([]byte, error) {
+	lang, ok := servedLanguageOf(c)
+	if !ok {
+		return payload, nil
+	}
+	entry, ok := m.Languages[lang]
+	if !ok || entry.UpdatedAt.IsZero() {
+		return payload, nil
+	}
+	age := time.Since(entry.UpdatedAt)
+	if age <= threshold {
+		return payload, nil
+	}
+	delim := ""
+	if !nested {
+		delim, _ = resolveDelimiter(c)
+	}
+	var tree map[string]any
+	if err := decodeJSON(payload, &tree); err != nil {
+		return nil, err
+	}
+	key := localenv.GetStaleBannerKey()
+	setCatalogValue(tree, key+".stale", nested, delim, true)
+	setCatalogValue(tree, key+".age_seconds", nested, delim, int64(age.Seconds()))
+	c.Locals(localsOverridden, true)
+	return marshalJSON(tree)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+func TestInjectStaleBannerUsesServedLanguage(t *testing.T) {
+	now := time.Now()
+	manifest := &translationsManifest{Languages: map[string]manifestEntry{
+		"it": {UpdatedAt: now.Add(-2 * time.Hour)},
+		"en": {UpdatedAt: now.Add(-time.Minute)},
+		"de": {UpdatedAt: now.Add(-3 * time.Hour)},
+	}}
+	tests := []struct {
+		name      string
+		served    *servedLanguage // nil: not recorded
+		wantStale bool
+	}{
+		{name: "requested language served", served: &servedLanguage{lang: "it", tried: []string{"it"}}, wantStale: true},
+		{name: "fresh fallback for a stale request", served: &servedLanguage{lang: "en", tried: []string{"it", "en"}}, wantStale: false},
+		{name: "stale fallback for a fresh request", served: &servedLanguage{lang: "de", tried: []string{"en", "de"}}, wantStale: true},
+		{name: "served language unknown", served: nil, wantStale: false},
+	}
+	app := fiber.New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := app.AcquireCtx(&fasthttp.RequestCtx{})
+			defer app.ReleaseCtx(c)
+			if tt.served != nil {
+				c.Locals(localsServedLanguage, tt.served)
+			}
+			out, err := injectStaleBanner(c, manifest, time.Hour, true, []byte(`{"title":"x"}`))
+			if err != nil {
+				t.Fatalf("injectStaleBanner: %v", err)
+			}
+			meta, _ := mustDecode(t, string(out))[localenv.GetStaleBannerKey()].(map[string]any)
+			if stale := meta["stale"] == true; stale != tt.wantStale {
+				t.Errorf("stale = %v, want %v (payload %s)", stale, tt.wantStale, out)
+			}
+		})
+	}
+}
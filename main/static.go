@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	localenv "mensalocalizations/tools/env"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Crawlers and probes hit these paths constantly; answering them explicitly
+// keeps them out of the catch-all translation fallback.
+
+func makeRobotsHandler() fiber.Handler {
+	body := localenv.GetRobotsTxt()
+	if !strings.HasSuffix(body, "\n") {
+		body += "\n"
+	}
+	return func(c *fiber.Ctx) error {
+		c.Set("Content-type", "text/plain; charset=utf-8")
+		c.Set("Cache-Control", "public, max-age=86400")
+		return c.Status(http.StatusOK).SendString(body)
+	}
+}
+
+// makeFaviconHandler serves FAVICON_FILE, read once at startup, or 204.
+func makeFaviconHandler() fiber.Handler {
+	var icon []byte
+	if path := localenv.GetFaviconFile(); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("[static] favicon read error path=%q: %v", path, err)
+		}
+		icon = b
+	}
+	return func(c *fiber.Ctx) error {
+		c.Set("Cache-Control", "public, max-age=86400")
+		if len(icon) == 0 {
+			return c.SendStatus(http.StatusNoContent)
+		}
+		c.Set("Content-type", http.DetectContentType(icon))
+		return c.Status(http.StatusOK).Send(icon)
+	}
+}
+
+// makeWellKnownHealthHandler answers like /api/healthz with a configurable
+// body, JSON ones typed as application/health+json.
+func makeWellKnownHealthHandler() fiber.Handler {
+	body := localenv.GetWellKnownHealthBody()
+	contentType := "text/plain; charset=utf-8"
+	if strings.HasPrefix(strings.TrimSpace(body), "{") {
+		contentType = "application/health+json"
+	}
+	return func(c *fiber.Ctx) error {
+		c.Set("Content-type", contentType)
+		c.Set("Cache-Control", "no-store")
+		return c.Status(http.StatusOK).SendString(body)
+	}
+}
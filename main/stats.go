@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+const (
+	statsBucket    = time.Hour
+	statsRetention = 90 * 24 * time.Hour
+	statsMaxLabel  = 32
+	// statsMaxFields caps the fields of one hourly bucket: past it, new
+	// combinations are counted under <lang>|<platform>|other.
+	statsMaxFields = 5000
+	// statsFlushEvery is how often the stats writer sends its counters.
+	statsFlushEvery = 2 * time.Second
+	statsQueueSize  = 4096
+)
+
+var (
+	errInvalidStatsRange = errors.New("from/to must be RFC3339 with from before to, at most 90 days apart")
+	errInvalidGroupBy    = errors.New("group_by must list lang, platform and/or version")
+
+	// statsVersionRe accepts the X-App-Version values clients send:
+	// major.minor[.patch] with an optional "v" and pre-release suffix.
+	statsVersionRe = regexp.MustCompile(`^v?\d{1,4}\.\d{1,4}(\.\d{1,6})?(-[0-9a-z.]{1,16})?$`)
+	// statsPlatforms are always counted by name, together with the platforms
+	// configured in the PLATFORM_* settings; anything else is "other".
+	statsPlatforms = []string{"android", "ios", "web"}
+
+	statsQueue      = make(chan statsField, statsQueueSize)
+	statsWriterOnce sync.Once
+)
+
+func statsBucketKey(t time.Time) string {
+	return "tolgee:stats:" + t.UTC().Truncate(statsBucket).Format("2006010215")
+}
+
+// statsLabel normalizes a dimension value: bounded, without the field separator.
+func statsLabel(v string) string {
+	v = strings.ToLower(strings.TrimSpace(strings.ReplaceAll(v, "|", "")))
+	if len(v) > statsMaxLabel {
+		v = v[:statsMaxLabel]
+	}
+	if v == "" {
+		return "unknown"
+	}
+	return v
+}
+
+// statsPlatform keeps known platforms (X-Platform) and collapses the rest to
+// "other", so the bucket never carries free-form client input.
+func statsPlatform(v string) string {
+	v = statsLabel(v)
+	if v == "unknown" || slices.Contains(statsPlatforms, v) {
+		return v
+	}
+	_, nested := localenv.GetPlatformNestedDefaults()[v]
+	_, escape := localenv.GetPlatformHTMLEscape()[v]
+	_, budget := localenv.GetPlatformMaxPayloadBytes()[v]
+	if nested || escape || budget {
+		return v
+	}
+	return "other"
+}
+
+// statsVersion keeps X-App-Version when it looks like a release number.
+func statsVersion(v string) string {
+	v = statsLabel(v)
+	if v == "unknown" || statsVersionRe.MatchString(v) {
+		return v
+	}
+	return "other"
+}
+
+// recordRequestStats counts a served catalog in the hourly bucket by served
+// language, X-Platform and X-App-Version, and observes its freshness for the
+// SLO. Requests whose served language is unknown count as "other". It never
+// blocks the response: the counter goes to the stats writer.
+func recordRequestStats(c *fiber.Ctx) {
+	lang := "other"
+	if served, ok := servedLanguageOf(c); ok {
+		lang = statsLabel(served)
+		observeServedFreshness(c, served)
+	}
+	prefix := lang + "|" + statsPlatform(c.Get("X-Platform")) + "|"
+	countRequestStats(statsField{
+		key:      statsBucketKey(time.Now()),
+		field:    prefix + statsVersion(c.Get("X-App-Version")),
+		overflow: prefix + "other",
+	})
+}
+
+// statsField is one counter of an hourly bucket; overflow is the field
+// counted instead once the bucket holds statsMaxFields.
+type statsField struct {
+	key, field, overflow string
+}
+
+// countRequestStats hands a counter to the stats writer, dropping it when
+// the writer is behind rather than slowing the request down.
+func countRequestStats(f statsField) {
+	statsWriterOnce.Do(func() { go runStatsWriter() })
+	select {
+	case statsQueue <- f:
+	default:
+	}
+}
+
+// runStatsWriter is the single goroutine writing request stats: it sums the
+// counters in memory and sends them every statsFlushEvery in one pipeline.
+func runStatsWriter() {
+	ticker := time.NewTicker(statsFlushEvery)
+	defer ticker.Stop()
+	pending := map[statsField]int64{}
+	for {
+		select {
+		case f := <-statsQueue:
+			pending[f]++
+		case <-ticker.C:
+			if len(pending) == 0 {
+				continue
+			}
+			flushRequestStats(pending)
+			pending = map[statsField]int64{}
+		}
+	}
+}
+
+// countStatsField adds ARGV[4] to an existing field, or to a new one while
+// the bucket is under ARGV[2] fields, else to the overflow field ARGV[3].
+var countStatsField = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 1 or redis.call('HLEN', KEYS[1]) < tonumber(ARGV[2]) then
+	return redis.call('HINCRBY', KEYS[1], ARGV[1], ARGV[4])
+end
+return redis.call('HINCRBY', KEYS[1], ARGV[3], ARGV[4])
+`)
+
+// flushRequestStats sends the summed counters in one pipeline (they are
+// dropped without Redis).
+func flushRequestStats(pending map[statsField]int64) {
+	if !redisEnabled() {
+		return
+	}
+	ctx := context.Background()
+	pipe := rdb.Pipeline()
+	keys := map[string]bool{}
+	for f, n := range pending {
+		countStatsField.Eval(ctx, pipe, []string{f.key}, f.field, statsMaxFields, f.overflow, n)
+		keys[f.key] = true
+	}
+	for key := range keys {
+		pipe.Expire(ctx, key, statsRetention)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("[stats] record error: %v", err)
+	}
+}
+
+// statsCount is one aggregated row of the stats report.
+type statsCount struct {
+	Lang     string `json:"lang,omitempty"`
+	Platform string `json:"platform,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Requests int64  `json:"requests"`
+}
+
+type statsReport struct {
+	From    time.Time    `json:"from"`
+	To      time.Time    `json:"to"`
+	GroupBy []string     `json:"group_by"`
+	Total   int64        `json:"total"`
+	Rows    []statsCount `json:"rows"`
+}
+
+// parseStatsQuery reads ?from=&to= (default: the last 24h) and ?group_by=.
+func parseStatsQuery(c *fiber.Ctx) (from, to time.Time, groupBy []string, err error) {
+	to = time.Now().UTC()
+	from = to.Add(-24 * time.Hour)
+	if v := c.Query("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, nil, errInvalidStatsRange
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, nil, errInvalidStatsRange
+		}
+	}
+	if !from.Before(to) || to.Sub(from) > statsRetention {
+		return from, to, nil, errInvalidStatsRange
+	}
+	groupBy = splitKeysQuery(c.Query("group_by", "lang"))
+	for _, d := range groupBy {
+		if d != "lang" && d != "platform" && d != "version" {
+			return from, to, nil, errInvalidGroupBy
+		}
+	}
+	return from.UTC(), to.UTC(), groupBy, nil
+}
+
+// loadRequestStats sums the hourly buckets overlapping [from, to) grouped by
+// the requested dimensions, most requested first.
+func loadRequestStats(ctx context.Context, from, to time.Time, groupBy []string) (*statsReport, error) {
+	pipe := rdb.Pipeline()
+	var cmds []*redis.StringStringMapCmd
+	for t := from.Truncate(statsBucket); t.Before(to); t = t.Add(statsBucket) {
+		cmds = append(cmds, pipe.HGetAll(ctx, statsBucketKey(t)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	report := &statsReport{From: from, To: to, GroupBy: groupBy, Rows: []statsCount{}}
+	rows := map[string]*statsCount{}
+	for _, cmd := range cmds {
+		for field, raw := range cmd.Val() {
+			n, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				continue
+			}
+			parts := strings.SplitN(field, "|", 3)
+			if len(parts) != 3 {
+				continue
+			}
+			row := statsCount{}
+			for _, d := range groupBy {
+				switch d {
+				case "lang":
+					row.Lang = parts[0]
+				case "platform":
+					row.Platform = parts[1]
+				case "version":
+					row.Version = parts[2]
+				}
+			}
+			id := row.Lang + "|" + row.Platform + "|" + row.Version
+			if rows[id] == nil {
+				rows[id] = &row
+			}
+			rows[id].Requests += n
+			report.Total += n
+		}
+	}
+	for _, r := range rows {
+		report.Rows = append(report.Rows, *r)
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		if report.Rows[i].Requests != report.Rows[j].Requests {
+			return report.Rows[i].Requests > report.Rows[j].Requests
+		}
+		a, b := report.Rows[i], report.Rows[j]
+		return a.Lang+a.Platform+a.Version < b.Lang+b.Platform+b.Version
+	})
+	return report, nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/goccy/go-json"
+)
+
+const (
+	// storageSchemaVersion is the S3 layout written by this build: tolgee:*
+	// object keys, each stamped with the schema-version metadata.
+	storageSchemaVersion  = 1
+	s3SchemaMetadata      = "schema-version"
+	storageSchemaKey      = "tolgee:storage-schema"
+	legacyLocalizationsNS = "localizations/"
+)
+
+// storageMigration rewrites one older layout into the current one.
+// Migrations are idempotent and run in order; they never delete the source.
+type storageMigration struct {
+	name  string
+	apply func(ctx context.Context, s3c *s3Client) (migrated int, err error)
+}
+
+var storageMigrations = []storageMigration{
+	{name: "import-localizations-prefix", apply: migrateLocalizationsPrefix},
+	{name: "stamp-schema-version", apply: migrateStampSchemaVersion},
+}
+
+// migrationReport is the outcome of runStorageMigrations.
+type migrationReport struct {
+	FromVersion int            `json:"from_version"`
+	ToVersion   int            `json:"to_version"`
+	Migrated    map[string]int `json:"migrated"`
+	Skipped     bool           `json:"skipped"`
+}
+
+// storedSchemaVersion reads the version marker; 0 means a pre-versioning bucket.
+func storedSchemaVersion(ctx context.Context, s3c *s3Client) int {
+	b, err := s3c.getObject(ctx, storageSchemaKey)
+	if err != nil {
+		return 0
+	}
+	var marker struct {
+		Version int `json:"version"`
+	}
+	if json.Unmarshal(b, &marker) != nil {
+		return 0
+	}
+	return marker.Version
+}
+
+// runStorageMigrations brings the bucket to storageSchemaVersion and writes
+// the version marker. A bucket already at the current version is left alone
+// unless force is set.
+func runStorageMigrations(ctx context.Context, force bool) (*migrationReport, error) {
+	s3c := s3ClientIfEnabled(ctx)
+	if s3c == nil {
+		return nil, errors.New("S3 is disabled or misconfigured")
+	}
+	report := &migrationReport{FromVersion: storedSchemaVersion(ctx, s3c), ToVersion: storageSchemaVersion, Migrated: map[string]int{}}
+	if report.FromVersion >= storageSchemaVersion && !force {
+		report.Skipped = true
+		return report, nil
+	}
+	for _, m := range storageMigrations {
+		n, err := m.apply(ctx, s3c)
+		report.Migrated[m.name] = n
+		if err != nil {
+			return report, errors.New(m.name + ": " + err.Error())
+		}
+		log.Printf("[storage] migration %s migrated=%d", m.name, n)
+	}
+	marker, _ := json.Marshal(map[string]int{"version": storageSchemaVersion})
+	if err := s3c.putObject(ctx, storageSchemaKey, marker, "application/json", nil); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// migrateLocalizationsPrefix imports the legacy localizations/<tag>/flat.json,
+// localizations/<tag>/nested.json and localizations/<tag>.json (flat) objects
+// as tolgee:lang:<tag>:<nested> when the current key does not exist yet.
+func migrateLocalizationsPrefix(ctx context.Context, s3c *s3Client) (int, error) {
+	keys, err := s3c.listKeys(ctx, legacyLocalizationsNS)
+	if err != nil {
+		return 0, err
+	}
+	migrated := 0
+	for _, legacy := range keys {
+		target, ok := legacyLocalizationsTarget(legacy)
+		if !ok {
+			continue
+		}
+		if _, err := s3c.headObject(ctx, target); err == nil {
+			continue
+		}
+		payload, err := s3c.getObject(ctx, legacy)
+		if err != nil {
+			return migrated, err
+		}
+		if err := s3c.putObject(ctx, target, payload, "application/json", nil); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, nil
+}
+
+func legacyLocalizationsTarget(key string) (string, bool) {
+	rest := strings.TrimSuffix(strings.TrimPrefix(key, legacyLocalizationsNS), ".json")
+	if rest == strings.TrimPrefix(key, legacyLocalizationsNS) {
+		return "", false
+	}
+	tag, mode, hasMode := strings.Cut(rest, "/")
+	if tag == "" {
+		return "", false
+	}
+	switch {
+	case !hasMode || mode == "flat":
+		return translationsCacheKey(tag, false), true
+	case mode == "nested":
+		return translationsCacheKey(tag, true), true
+	}
+	return "", false
+}
+
+// migrateStampSchemaVersion adds the schema-version metadata to tolgee:*
+// objects written before versioning (in-place copy, content unchanged).
+func migrateStampSchemaVersion(ctx context.Context, s3c *s3Client) (int, error) {
+	keys, err := s3c.listKeys(ctx, "tolgee:")
+	if err != nil {
+		return 0, err
+	}
+	migrated := 0
+	for _, key := range keys {
+		head, err := s3c.headObject(ctx, key)
+		if err != nil {
+			return migrated, err
+		}
+		if head.Metadata[s3SchemaMetadata] != "" {
+			continue
+		}
+		meta := map[string]string{}
+		for k, v := range head.Metadata {
+			meta[k] = v
+		}
+		meta[s3SchemaMetadata] = strconv.Itoa(storageSchemaVersion)
+		_, err = s3c.client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:            aws.String(s3c.bucket),
+			Key:               aws.String(key),
+			CopySource:        aws.String(s3c.bucket + "/" + url.PathEscape(key)),
+			ContentType:       head.ContentType,
+			Metadata:          meta,
+			MetadataDirective: types.MetadataDirectiveReplace,
+			ACL:               types.ObjectCannedACLPrivate,
+		})
+		if err != nil {
+			log.Printf("[storage] stamp error key=%q err=%v", key, err)
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/go-redis/redis/v8"
+)
+
+// kvStore holds the values behind redisGet, redisPut, redisDel and
+// redisScanKeys. A missing key reads as redis.Nil in every implementation.
+type kvStore interface {
+	get(ctx context.Context, key string) ([]byte, error)
+	put(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// putGeneration is put for a refresh of generation gen: it reports false
+	// and writes nothing when a newer generation already wrote key.
+	putGeneration(ctx context.Context, key string, value []byte, ttl time.Duration, gen int64) (bool, error)
+	del(ctx context.Context, keys ...string) error
+	scan(ctx context.Context, pattern string) ([]string, error)
+}
+
+// newKVStore returns the Redis store, or the memory store for REDIS_ENABLED=false.
+func newKVStore() kvStore {
+	if redisEnabled() {
+		return redisStore{}
+	}
+	return newMemoryStore()
+}
+
+// redisStore is the shared client (a single node or the REDIS_SHARDS ring).
+type redisStore struct{}
+
+func (redisStore) get(ctx context.Context, key string) ([]byte, error) {
+	return rdb.Get(ctx, key).Bytes()
+}
+
+func (redisStore) put(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl < 0 {
+		ttl = 0
+	}
+	return rdb.Set(ctx, key, value, ttl).Err()
+}
+
+// storeGenerationKey holds the generation of the last refresh that wrote key.
+// The hash tag puts it on the shard of key, so one script reaches both.
+func storeGenerationKey(key string) string {
+	return refreshGenerationKey + ":{" + key + "}"
+}
+
+// putIfNewerGeneration sets KEYS[1] to ARGV[1] (PX ARGV[3] when > 0) and
+// records generation ARGV[2] in KEYS[2], unless KEYS[2] is already newer.
+var putIfNewerGeneration = redis.NewScript(`
+if tonumber(redis.call('GET', KEYS[2]) or '0') > tonumber(ARGV[2]) then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[3])
+	redis.call('SET', KEYS[2], ARGV[2], 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+	redis.call('SET', KEYS[2], ARGV[2])
+end
+return 1
+`)
+
+func (redisStore) putGeneration(ctx context.Context, key string, value []byte, ttl time.Duration, gen int64) (bool, error) {
+	n, err := putIfNewerGeneration.Run(ctx, rdb, []string{key, storeGenerationKey(key)}, value, gen, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// del deletes keys one command each, so on a ring every key reaches its own
+// shard (a multi-key DEL is routed by its first key only).
+func (redisStore) del(ctx context.Context, keys ...string) error {
+	pipe := rdb.Pipeline()
+	for _, key := range keys {
+		pipe.Del(ctx, key)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// scan walks SCAN (non-blocking) on every shard.
+func (redisStore) scan(ctx context.Context, pattern string) ([]string, error) {
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	err := redisForEachNode(ctx, func(ctx context.Context, node redis.Cmdable) error {
+		iter := node.Scan(ctx, 0, pattern, 500).Iterator()
+		for iter.Next(ctx) {
+			mu.Lock()
+			keys = append(keys, iter.Val())
+			mu.Unlock()
+		}
+		return iter.Err()
+	})
+	return keys, err
+}
+
+// memoryStore keeps values in process memory for a single-container
+// deployment without Redis. A key it does not hold is read from S3, where
+// refreshes write their output (see storeHoldsRefreshOutput); S3 reads are
+// not kept, the in-memory tier caches the hot ones, but a key missing from
+// S3 too is remembered for memoryStoreMissTTL, so state read on every
+// request (read-only mode, overrides) does not cost an S3 request each time.
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryStoreEntry
+}
+
+type memoryStoreEntry struct {
+	value      []byte
+	expires    time.Time // zero: no expiry
+	missing    bool      // not in S3 either
+	generation int64     // refresh that wrote value, see putGeneration
+}
+
+const (
+	memoryStoreSweepEvery = time.Minute
+	memoryStoreMissTTL    = 30 * time.Second
+)
+
+func newMemoryStore() *memoryStore {
+	m := &memoryStore{entries: map[string]memoryStoreEntry{}}
+	go func() {
+		for range time.Tick(memoryStoreSweepEvery) {
+			m.sweep(time.Now())
+		}
+	}()
+	return m
+}
+
+func (e memoryStoreEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// sweep drops expired entries, which would otherwise stay until read.
+func (m *memoryStore) sweep(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, e := range m.entries {
+		if e.expired(now) {
+			delete(m.entries, key)
+		}
+	}
+}
+
+func (m *memoryStore) get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	e, ok := m.entries[key]
+	if ok && e.expired(time.Now()) {
+		delete(m.entries, key)
+		ok = false
+	}
+	m.mu.Unlock()
+	switch {
+	case ok && e.missing:
+		return nil, redis.Nil
+	case ok:
+		return e.value, nil
+	}
+	s3c := s3ClientIfEnabled(ctx)
+	if s3c == nil {
+		return nil, redis.Nil
+	}
+	// raw: pointers are resolved by redisGet like those read from Redis
+	b, _, err := s3c.readObject(ctx, key)
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		m.mu.Lock()
+		if _, held := m.entries[key]; !held {
+			m.entries[key] = memoryStoreEntry{missing: true, expires: time.Now().Add(memoryStoreMissTTL)}
+		}
+		m.mu.Unlock()
+		return nil, redis.Nil
+	}
+	return b, err
+}
+
+func (m *memoryStore) put(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	e := memoryStoreEntry{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	m.mu.Lock()
+	m.entries[key] = e
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memoryStore) putGeneration(_ context.Context, key string, value []byte, ttl time.Duration, gen int64) (bool, error) {
+	e := memoryStoreEntry{value: append([]byte(nil), value...), generation: gen}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if current, ok := m.entries[key]; ok && !current.expired(time.Now()) && current.generation > gen {
+		return false, nil
+	}
+	m.entries[key] = e
+	return true, nil
+}
+
+func (m *memoryStore) del(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+// scan matches the held keys only: objects only present in S3 are not listed.
+func (m *memoryStore) scan(_ context.Context, pattern string) ([]string, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	for key, e := range m.entries {
+		if e.missing || e.expired(now) {
+			continue
+		}
+		if ok, err := path.Match(pattern, key); err != nil {
+			return nil, err
+		} else if ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// storeHoldsRefreshOutput reports whether refreshes write snapshots to the
+// store as well as to S3. Without Redis the memory store reads S3 through,
+// so a second copy in memory would only double the footprint: writers
+// delete the key instead, dropping a remembered miss.
+func storeHoldsRefreshOutput(s3c *s3Client) bool {
+	return redisEnabled() || s3c == nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// structuredExportParams select Tolgee's own JSON flavour, with arrays kept
+// as arrays, for the QA pipeline (?format=tolgee-structured).
+var structuredExportParams = map[string]string{
+	"format":        "JSON_TOLGEE",
+	"supportArrays": "true",
+}
+
+// encodeTolgeeStructured ignores the served catalog and returns the structured
+// export of lang, encrypted namespaces included: the format is admin-only. It is fetched on first use and cached under the sha of the
+// nested catalog, so a refresh that changes the language invalidates it.
+func encodeTolgeeStructured(lang string, _ []byte) ([]byte, error) {
+	ctx := context.Background()
+	source, err := GetTranslationsFromCache(ctx, lang, true)
+	if err != nil {
+		return nil, err
+	}
+	key := "tolgee:structured:" + lang + ":" + sha256Hex(source)[:12]
+	if cached, err := redisGet(ctx, key); err == nil && len(cached) > 0 {
+		return cached, nil
+	}
+	if localenv.GetPromotedOnly() {
+		return nil, errors.New("structured exports are not available in promoted-only mode")
+	}
+
+	b, err := fetchUpstream(ctx, "structured:"+lang, func() ([]byte, error) {
+		files, err := exportTranslations(ctx, localenv.GetTolgeeAppKey(), lang, true, structuredExportParams)
+		if err != nil {
+			return nil, err
+		}
+		payload, ok := files[lang]
+		if !ok {
+			return nil, fmt.Errorf("structured export has no file for %q", lang)
+		}
+		return payload, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	_ = redisPut(ctx, key, b, derivedVariantTTL())
+	return b, nil
+}
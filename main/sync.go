@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	"github.com/goccy/go-json"
+)
+
+// syncRequest is the body of POST /api/sync: the hashes the client holds.
+type syncRequest struct {
+	Lang     string            `json:"lang"`
+	Sha      string            `json:"sha"`
+	Sections map[string]string `json:"sections"`
+}
+
+type syncSection struct {
+	Sha  string          `json:"sha"`
+	Data json.RawMessage `json:"data"`
+}
+
+// syncResponse carries only the sections whose sha differs from the client's.
+type syncResponse struct {
+	Lang     string                 `json:"lang"`
+	Sha      string                 `json:"sha"`
+	Sections map[string]syncSection `json:"sections"`
+	Removed  []string               `json:"removed,omitempty"`
+}
+
+// buildSyncResponse diffs the nested catalog top-level sections against the
+// client's hashes. Hashes are sha256 of the canonical (key-sorted) JSON.
+func buildSyncResponse(ctx context.Context, req syncRequest) (*syncResponse, error) {
+	payload, err := GetTranslationsFromCache(ctx, req.Lang, true)
+	if err != nil {
+		return nil, err
+	}
+	canonical, err := sortJSONKeys(payload)
+	if err != nil {
+		return nil, err
+	}
+	resp := &syncResponse{Lang: req.Lang, Sha: sha256Hex(canonical), Sections: map[string]syncSection{}}
+	if req.Sha != "" && req.Sha == resp.Sha {
+		return resp, nil
+	}
+
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(canonical, &sections); err != nil {
+		return nil, err
+	}
+	for name, data := range sections {
+		if isEncryptedNamespace(name) {
+			continue
+		}
+		sha := sha256Hex(data)
+		if req.Sections[name] == sha {
+			continue
+		}
+		resp.Sections[name] = syncSection{Sha: sha, Data: data}
+	}
+	for name := range req.Sections {
+		if _, ok := sections[name]; !ok {
+			resp.Removed = append(resp.Removed, name)
+		}
+	}
+	sort.Strings(resp.Removed)
+	return resp, nil
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+var errInvalidTag = errors.New("tag must be a comma-separated list of at most 8 tags of 1-64 characters")
+
+// resolveTags parses ?tag=mobile,beta into a sorted, de-duplicated list, so
+// equivalent requests share one cache entry.
+func resolveTags(c *fiber.Ctx) ([]string, error) {
+	raw := splitKeysQuery(c.Query("tag"))
+	if len(raw) > 8 {
+		return nil, errInvalidTag
+	}
+	seen := make(map[string]bool, len(raw))
+	tags := make([]string, 0, len(raw))
+	for _, t := range raw {
+		if len(t) > 64 || strings.ContainsAny(t, ":+") {
+			return nil, errInvalidTag
+		}
+		if !seen[t] {
+			seen[t] = true
+			tags = append(tags, t)
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// GetTaggedTranslationsFromCache serves only the keys tagged with one of tags, exported
+// from Tolgee on first use and kept as a derived artifact of the full
+// catalog, so a refresh that changes the language also invalidates them.
+func GetTaggedTranslationsFromCache(ctx context.Context, lang string, nested bool, tags []string) ([]byte, error) {
+	source, err := GetTranslationsFromCache(ctx, lang, nested)
+	if err != nil {
+		return nil, err
+	}
+	artifact := derivedArtifact{Lang: lang, Nested: nested, Format: "json", Filters: "tag=" + strings.Join(tags, "+")}
+	return loadDerivedArtifact(ctx, artifact, source, func() ([]byte, error) {
+		if localenv.GetPromotedOnly() {
+			return nil, errors.New("tagged exports are not available in promoted-only mode")
+		}
+		// tags are sorted by resolveTags: one flight per distinct export
+		return fetchUpstream(ctx, "tagged:"+lang+":"+strconv.FormatBool(nested)+":"+strings.Join(tags, "+"), func() ([]byte, error) {
+			files, err := GetTaggedTranslations(ctx, localenv.GetTolgeeAppKey(), lang, nested, tags)
+			if err != nil {
+				return nil, err
+			}
+			// no tagged key in this language: Tolgee exports no file at all
+			if payload := files[lang]; len(payload) > 0 {
+				return payload, nil
+			}
+			return []byte("{}"), nil
+		})
+	})
+}
+
+// loadTaggedVariant is loadTranslationsVariant for ?tag= requests; custom
+// delimiters are derived from the nested tagged export.
+func loadTaggedVariant(c *fiber.Ctx, lang string, nested bool, tags []string) ([]byte, error) {
+	c.Locals(localsOverridden, true)
+	if nested {
+		return GetTaggedTranslationsFromCache(c.UserContext(), lang, true, tags)
+	}
+	delim, err := resolveDelimiter(c)
+	if err != nil {
+		return nil, fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	if delim == "" {
+		return GetTaggedTranslationsFromCache(c.UserContext(), lang, false, tags)
+	}
+	source, err := GetTaggedTranslationsFromCache(c.UserContext(), lang, true, tags)
+	if err != nil {
+		return nil, err
+	}
+	return flattenTranslations(source, delim)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stages of the cold path, timed wherever they run.
+const (
+	stageNegotiate = "negotiate"
+	stageRedis     = "redis"
+	stageS3        = "s3"
+	stageTolgee    = "tolgee"
+	stageStore     = "store"
+)
+
+var promStageDuration = newPromMetric("histogram", "mensa_stage_duration_seconds",
+	"Time spent per cold-path stage (negotiate, redis, s3, tolgee, store) by origin (request, warmup, background).",
+	[]float64{0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 30},
+	"stage", "origin")
+
+// stageTimings sums the stages run on behalf of one request or warm-up.
+type stageTimings struct {
+	origin string
+	mu     sync.Mutex
+	stages map[string]*stageTotal
+}
+
+type stageTotal struct {
+	Duration time.Duration
+	Calls    int
+}
+
+type stageTimingsKey struct{}
+
+func withStageTimings(ctx context.Context, origin string) (context.Context, *stageTimings) {
+	t := &stageTimings{origin: origin, stages: map[string]*stageTotal{}}
+	return context.WithValue(ctx, stageTimingsKey{}, t), t
+}
+
+// observeStage records the time since start for stage, in the metrics and in
+// the timings carried by ctx, if any. Use as defer observeStage(ctx, s, time.Now()).
+func observeStage(ctx context.Context, stage string, start time.Time) {
+	d := time.Since(start)
+	origin := "background"
+	if t, ok := ctx.Value(stageTimingsKey{}).(*stageTimings); ok {
+		origin = t.origin
+		t.mu.Lock()
+		total := t.stages[stage]
+		if total == nil {
+			total = &stageTotal{}
+			t.stages[stage] = total
+		}
+		total.Duration += d
+		total.Calls++
+		t.mu.Unlock()
+	}
+	promStageDuration.observe(d.Seconds(), stage, origin)
+}
+
+// snapshot returns the totals so far, keyed by stage.
+func (t *stageTimings) snapshot() map[string]stageTotal {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]stageTotal, len(t.stages))
+	for k, v := range t.stages {
+		out[k] = *v
+	}
+	return out
+}
+
+// serverTiming renders the stages as Server-Timing entries followed by the
+// total, e.g. `redis;dur=0.42;desc="2 calls", total;dur=1.30`.
+func (t *stageTimings) serverTiming(total time.Duration) string {
+	stages := t.snapshot()
+	names := make([]string, 0, len(stages))
+	for name := range stages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		s := stages[name]
+		parts = append(parts, name+";dur="+formatMillis(s.Duration)+`;desc="`+strconv.Itoa(s.Calls)+` calls"`)
+	}
+	parts = append(parts, "total;dur="+formatMillis(total))
+	return strings.Join(parts, ", ")
+}
+
+// stageMillis is snapshot in milliseconds, for JSON status payloads.
+func (t *stageTimings) stageMillis() map[string]float64 {
+	out := map[string]float64{}
+	for name, s := range t.snapshot() {
+		out[name] = float64(s.Duration.Microseconds()) / 1000
+	}
+	return out
+}
+
+func formatMillis(d time.Duration) string {
+	return strconv.FormatFloat(float64(d.Microseconds())/1000, 'f', 2, 64)
+}
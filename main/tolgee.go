@@ -17,6 +17,8 @@ import (
 
 	"github.com/go-resty/resty/v2"
 	"github.com/goccy/go-json"
+
+	localenv "mensalocalizations/tools/env"
 )
 
 type tolgeeSignatureHeader struct {
@@ -26,28 +28,35 @@ type tolgeeSignatureHeader struct {
 
 // GetLanguages calls Tolgee /languages endpoint and returns the raw JSON body.
 func GetLanguages(ctx context.Context, appKey string) (*TolgeeModel, []byte, error) {
-	if appKey == "" {
+	if dir, ok := localSourceDir(); ok {
+		return localLanguages(dir)
+	}
+	if appKey == "" && !tolgeeOffline() {
 		return nil, nil, errors.New("tolgee app key is required")
 	}
 
+	release, err := acquireTolgeeSlot(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+	defer observeStage(ctx, stageTolgee, time.Now())
 	url := "https://app.tolgee.io/v2/projects/languages"
-	client := resty.New().
-		SetTimeout(0).
-		SetRetryCount(0)
-
-	resp, err := client.R().
+	resp, err := httpClients().tolgee.R().
 		SetContext(ctx).
+		SetResponseBodyLimit(int(localenv.GetMaxPayloadBytes())).
 		SetResult(&TolgeeModel{}).
-		SetQueryParams(map[string]string{
-			"ak":   appKey,
-			"size": "1000",
-		}).
+		SetHeader("X-API-Key", appKey).
+		SetQueryParam("size", "1000").
 		Get(url)
+	if errors.Is(err, resty.ErrResponseBodyTooLarge) {
+		return nil, nil, checkPayloadSize("tolgee", "languages", localenv.GetMaxPayloadBytes()+1, localenv.GetMaxPayloadBytes())
+	}
 	if err != nil {
 		return nil, nil, err
 	}
 	if resp.StatusCode() < http.StatusOK || resp.StatusCode() >= http.StatusMultipleChoices {
-		return nil, nil, fmt.Errorf("tolgee languages non-2xx: status=%d", resp.StatusCode())
+		return nil, nil, &tolgeeStatusError{resource: "languages", status: resp.StatusCode()}
 	}
 	return resp.Result().(*TolgeeModel), resp.Body(), nil
 }
@@ -56,22 +65,42 @@ func GetLanguages(ctx context.Context, appKey string) (*TolgeeModel, []byte, err
 // It requests a ZIP archive and returns its files as a map[name][]byte.
 // If nested is false, structureDelimiter is set to flatten the output.
 func GetTranslations(ctx context.Context, appKey, lang string, nested bool) (map[string][]byte, error) {
-	if appKey == "" {
+	return exportTranslations(ctx, appKey, lang, nested, nil)
+}
+
+// GetTaggedTranslations exports only the keys carrying one of tags
+// (Tolgee filterTagIn).
+func GetTaggedTranslations(ctx context.Context, appKey, lang string, nested bool, tags []string) (map[string][]byte, error) {
+	return exportTranslations(ctx, appKey, lang, nested, map[string]string{
+		"filterTagIn": strings.Join(tags, ","),
+	})
+}
+
+func exportTranslations(ctx context.Context, appKey, lang string, nested bool, filters map[string]string) (map[string][]byte, error) {
+	if dir, ok := localSourceDir(); ok {
+		return localExport(dir, lang, nested, filters)
+	}
+	if appKey == "" && !tolgeeOffline() {
 		return nil, errors.New("tolgee app key is required")
 	}
 	if lang == "" {
 		return nil, errors.New("language tag is required")
 	}
 
+	release, err := acquireTolgeeSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	defer observeStage(ctx, stageTolgee, time.Now())
 	url := "https://app.tolgee.io/v2/projects/export"
-	client := resty.New().
-		SetTimeout(0).
-		SetRetryCount(0)
-
-	req := client.R().
+	maxObject := localenv.GetMaxPayloadBytes()
+	maxAggregate := localenv.GetMaxAggregatePayloadBytes()
+	req := httpClients().tolgee.R().
 		SetContext(ctx).
+		SetResponseBodyLimit(int(maxAggregate)).
+		SetHeader("X-API-Key", appKey).
 		SetQueryParams(map[string]string{
-			"ak":        appKey,
 			"size":      "1000",
 			"languages": lang,
 			"format":    "JSON",
@@ -80,14 +109,24 @@ func GetTranslations(ctx context.Context, appKey, lang string, nested bool) (map
 
 	if !nested {
 		req.SetQueryParam("structureDelimiter", "")
+	} else {
+		// keys like "carousel[0]" become real arrays instead of "carousel[0]" objects
+		req.SetQueryParam("supportArrays", "true")
+	}
+	if branch := localenv.GetTolgeeProductionBranch(); branch != "" {
+		req.SetQueryParam("branch", branch)
 	}
+	req.SetQueryParams(filters)
 
 	resp, err := req.Get(url)
+	if errors.Is(err, resty.ErrResponseBodyTooLarge) {
+		return nil, checkPayloadSize("tolgee", "export:"+lang, maxAggregate+1, maxAggregate)
+	}
 	if err != nil {
 		return nil, err
 	}
 	if resp.StatusCode() < http.StatusOK || resp.StatusCode() >= http.StatusMultipleChoices {
-		return nil, fmt.Errorf("tolgee export non-2xx: status=%d", resp.StatusCode())
+		return nil, &tolgeeStatusError{resource: "export", status: resp.StatusCode()}
 	}
 
 	zipBytes := resp.Body()
@@ -97,25 +136,107 @@ func GetTranslations(ctx context.Context, appKey, lang string, nested bool) (map
 	}
 
 	files := make(map[string][]byte)
+	var total int64
 	for _, f := range zr.File {
 		if f.FileInfo().IsDir() {
 			continue
 		}
+		if err := checkPayloadSize("tolgee", f.Name, int64(f.UncompressedSize64), maxObject); err != nil {
+			return nil, err
+		}
 		rc, err := f.Open()
 		if err != nil {
 			return nil, fmt.Errorf("zip open %s: %w", f.Name, err)
 		}
-		data, err := io.ReadAll(rc)
+		// the zip header can lie about the size: never read past the limit
+		reader := io.Reader(rc)
+		if maxObject > 0 {
+			reader = io.LimitReader(rc, maxObject+1)
+		}
+		data, err := io.ReadAll(reader)
 		_ = rc.Close()
 		if err != nil {
 			return nil, fmt.Errorf("zip read %s: %w", f.Name, err)
 		}
+		if err := checkPayloadSize("tolgee", f.Name, int64(len(data)), maxObject); err != nil {
+			return nil, err
+		}
+		total += int64(len(data))
+		if err := checkPayloadSize("tolgee", "export:"+lang, total, maxAggregate); err != nil {
+			return nil, err
+		}
 		files[strings.ReplaceAll(f.Name, ".json", "")] = data
 	}
 
 	return files, nil
 }
 
+// tolgeeStatusError is a non-2xx answer from Tolgee.
+type tolgeeStatusError struct {
+	resource string
+	status   int
+}
+
+func (e *tolgeeStatusError) Error() string {
+	return fmt.Sprintf("tolgee %s non-2xx: status=%d", e.resource, e.status)
+}
+
+// GetProjectResource calls a read-only Tolgee project endpoint
+// (/v2/projects/<path>) with the app key and returns the raw JSON body.
+func GetProjectResource(ctx context.Context, appKey, path string, query map[string]string) ([]byte, error) {
+	if _, ok := localSourceDir(); ok {
+		return nil, errLocalSourceUnsupported
+	}
+	if appKey == "" && !tolgeeOffline() {
+		return nil, errors.New("tolgee app key is required")
+	}
+
+	release, err := acquireTolgeeSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	defer observeStage(ctx, stageTolgee, time.Now())
+	url := "https://app.tolgee.io/v2/projects/" + strings.TrimPrefix(path, "/")
+	resp, err := httpClients().tolgee.R().
+		SetContext(ctx).
+		SetResponseBodyLimit(int(localenv.GetMaxPayloadBytes())).
+		SetQueryParams(query).
+		SetHeader("X-API-Key", appKey).
+		Get(url)
+	if errors.Is(err, resty.ErrResponseBodyTooLarge) {
+		return nil, checkPayloadSize("tolgee", path, localenv.GetMaxPayloadBytes()+1, localenv.GetMaxPayloadBytes())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() < http.StatusOK || resp.StatusCode() >= http.StatusMultipleChoices {
+		return nil, &tolgeeStatusError{resource: path, status: resp.StatusCode()}
+	}
+	return resp.Body(), nil
+}
+
+// DownloadScreenshot fetches a screenshot image from the (signed) URL Tolgee
+// returned and its content type.
+func DownloadScreenshot(ctx context.Context, url string) ([]byte, string, error) {
+	release, err := acquireTolgeeSlot(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer release()
+	resp, err := httpClients().screenshots.R().
+		SetContext(ctx).
+		SetResponseBodyLimit(int(localenv.GetMaxPayloadBytes())).
+		Get(url)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode() < http.StatusOK || resp.StatusCode() >= http.StatusMultipleChoices {
+		return nil, "", &tolgeeStatusError{resource: "screenshot", status: resp.StatusCode()}
+	}
+	return resp.Body(), resp.Header().Get("Content-Type"), nil
+}
+
 func verifyTolgeeSignature(secret string, rawHeader string, body []byte) bool {
 	if secret == "" || rawHeader == "" {
 		return false
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+
+	"github.com/goccy/go-json"
+)
+
+// sortJSONKeys re-encodes a JSON document with object keys in lexicographic
+// order at every level. Numbers are kept verbatim.
+func sortJSONKeys(payload []byte) ([]byte, error) {
+	var v any
+	if err := decodeJSON(payload, &v); err != nil {
+		return nil, err
+	}
+	// maps are always encoded with sorted keys
+	return marshalJSON(v)
+}
+
+// decodeJSON unmarshals keeping numbers as json.Number, so re-encoding a
+// payload does not alter numeric literals.
+func decodeJSON(payload []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// marshalJSON encodes v without HTML escaping, so translator markup such as
+// "<b>" is stored byte-for-byte instead of as "\u003cb\u003e".
+func marshalJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/goccy/go-json"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// transliterationJSON holds the built-in transliterators, keyed by ICU-style
+// id. A value is either a mapping table (lowercase source → target) or a
+// compound id ("A; B") chaining other transliterators and built-in steps.
+//
+//go:embed cldr/transliteration.json
+var transliterationJSON []byte
+
+const (
+	maxTransliterateTexts  = 1000
+	maxInlineTranslitRules = 500
+	maxTranslitCacheSize   = 256
+	maxTranslitDepth       = 8
+	// a table step tries up to maxInlineTranslitSource runes at every position
+	maxInlineTranslitSource = 16
+	maxInlineTranslitTarget = 64
+	maxTransliterateBytes   = 1 << 20
+)
+
+var (
+	errUnknownTransliterator = errors.New("unknown transliterator")
+	errTransliterateEmpty    = errors.New("id and text or texts are required")
+	errTransliterateTooMany  = fmt.Errorf("at most %d texts and %d inline rules are allowed", maxTransliterateTexts, maxInlineTranslitRules)
+	errTransliterateCycle    = errors.New("transliterator definitions are nested too deeply")
+	errTransliterateTooLarge = fmt.Errorf("texts must total at most %d bytes", maxTransliterateBytes)
+	errTranslitRuleTooLong   = fmt.Errorf("inline rules map at most %d characters to at most %d", maxInlineTranslitSource, maxInlineTranslitTarget)
+)
+
+// translitSteps are the built-in steps usable inside compound ids.
+var translitSteps = map[string]func(string) string{
+	"remove-marks": removeMarks,
+	"nfc":          norm.NFC.String,
+	"nfd":          norm.NFD.String,
+	"lower":        strings.ToLower,
+	"upper":        strings.ToUpper,
+}
+
+var (
+	translitDefsOnce sync.Once
+	translitDefs     map[string]json.RawMessage
+
+	translitCacheMu sync.Mutex
+	translitCache   = map[string]*transliterator{}
+)
+
+// loadTranslitDefs merges TRANSLITERATION_RULES_FILE over the embedded
+// table; ids are case-insensitive, like in ICU.
+func loadTranslitDefs() map[string]json.RawMessage {
+	translitDefsOnce.Do(func() {
+		translitDefs = map[string]json.RawMessage{}
+		merge := func(source string, data []byte) {
+			var defs map[string]json.RawMessage
+			if err := json.Unmarshal(data, &defs); err != nil {
+				log.Printf("[transliterate] %s: %v", source, err)
+				return
+			}
+			for id, def := range defs {
+				translitDefs[strings.ToLower(id)] = def
+			}
+		}
+		merge("cldr/transliteration.json", transliterationJSON)
+		if path := localenv.GetTransliterationRulesFile(); path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				log.Printf("[transliterate] %v", err)
+			} else {
+				merge(path, data)
+			}
+		}
+	})
+	return translitDefs
+}
+
+// transliteratorIDs lists the configured ids for error messages.
+func transliteratorIDs() []string {
+	defs := loadTranslitDefs()
+	ids := make([]string, 0, len(defs)+len(translitSteps))
+	for id := range defs {
+		ids = append(ids, id)
+	}
+	for id := range translitSteps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// transliterator is a compiled chain of steps.
+type transliterator struct {
+	steps []func(string) string
+}
+
+func (t *transliterator) apply(s string) string {
+	for _, step := range t.steps {
+		s = step(s)
+	}
+	return s
+}
+
+// compiledTransliterator resolves and compiles id, with the inline rules (if
+// any) applied first. Compiled chains are cached by id and rules, since
+// building the lookup tables dominates the cost of short texts.
+func compiledTransliterator(id string, inline map[string]string) (*transliterator, error) {
+	cacheKey := strings.ToLower(strings.TrimSpace(id)) + "\x00" + canonicalRules(inline)
+	translitCacheMu.Lock()
+	t, ok := translitCache[cacheKey]
+	translitCacheMu.Unlock()
+	if ok {
+		return t, nil
+	}
+
+	t = &transliterator{}
+	if len(inline) > 0 {
+		t.steps = append(t.steps, compileTranslitTable(inline))
+	}
+	steps, err := resolveTransliterator(id, 0)
+	if err != nil {
+		return nil, err
+	}
+	t.steps = append(t.steps, steps...)
+
+	translitCacheMu.Lock()
+	if len(translitCache) >= maxTranslitCacheSize {
+		translitCache = map[string]*transliterator{}
+	}
+	translitCache[cacheKey] = t
+	translitCacheMu.Unlock()
+	return t, nil
+}
+
+func resolveTransliterator(id string, depth int) ([]func(string) string, error) {
+	if depth > maxTranslitDepth {
+		return nil, errTransliterateCycle
+	}
+	var steps []func(string) string
+	for _, part := range strings.Split(id, ";") {
+		name := strings.ToLower(strings.TrimSpace(part))
+		if name == "" {
+			continue
+		}
+		if step, ok := translitSteps[name]; ok {
+			steps = append(steps, step)
+			continue
+		}
+		def, ok := loadTranslitDefs()[name]
+		if !ok {
+			return nil, fmt.Errorf("%w %q (available: %s)", errUnknownTransliterator, strings.TrimSpace(part), strings.Join(transliteratorIDs(), ", "))
+		}
+		var compound string
+		if err := json.Unmarshal(def, &compound); err == nil {
+			nested, err := resolveTransliterator(compound, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, nested...)
+			continue
+		}
+		var table map[string]string
+		if err := json.Unmarshal(def, &table); err != nil {
+			return nil, fmt.Errorf("transliterator %q: %w", name, err)
+		}
+		steps = append(steps, compileTranslitTable(table))
+	}
+	return steps, nil
+}
+
+func canonicalRules(rules map[string]string) string {
+	if len(rules) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(rules))
+	for k := range rules {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(rules[k])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// compileTranslitTable returns a longest-match replacement step. Sources are
+// matched case-insensitively; an uppercase source yields a capitalized
+// target, or a fully uppercase one inside uppercase words ("ЩИ" → "SHCHI").
+func compileTranslitTable(rules map[string]string) func(string) string {
+	table := make(map[string]string, len(rules))
+	maxLen := 0
+	for from, to := range rules {
+		if from == "" {
+			continue
+		}
+		table[strings.ToLower(from)] = to
+		if n := len([]rune(from)); n > maxLen {
+			maxLen = n
+		}
+	}
+	return func(s string) string {
+		src := []rune(s)
+		var b strings.Builder
+		for i := 0; i < len(src); {
+			matched := false
+			for n := min(maxLen, len(src)-i); n > 0; n-- {
+				chunk := src[i : i+n]
+				to, ok := table[strings.ToLower(string(chunk))]
+				if !ok {
+					continue
+				}
+				b.WriteString(translitCase(src, i, n, to))
+				i += n
+				matched = true
+				break
+			}
+			if !matched {
+				b.WriteRune(src[i])
+				i++
+			}
+		}
+		return b.String()
+	}
+}
+
+func translitCase(src []rune, i, n int, to string) string {
+	if !unicode.IsUpper(src[i]) || to == "" {
+		return to
+	}
+	upperWord := n > 1 && unicode.IsUpper(src[i+1])
+	if i+n < len(src) && unicode.IsUpper(src[i+n]) {
+		upperWord = true
+	}
+	if i > 0 && unicode.IsUpper(src[i-1]) {
+		upperWord = true
+	}
+	if upperWord {
+		return strings.ToUpper(to)
+	}
+	out := []rune(to)
+	out[0] = unicode.ToUpper(out[0])
+	return string(out)
+}
+
+// removeMarks strips combining marks (accents) after canonical
+// decomposition: "Perché" → "Perche".
+func removeMarks(s string) string {
+	out, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), s)
+	if err != nil {
+		return s
+	}
+	return out
+}
+
+// transliterateRequest is the body of POST /api/transliterate.
+type transliterateRequest struct {
+	ID    string            `json:"id"`
+	Text  *string           `json:"text"`
+	Texts []string          `json:"texts"`
+	Rules map[string]string `json:"rules"`
+}
+
+// transliterate validates req and returns either the single converted text
+// or the converted list, mirroring the request shape.
+func transliterate(req transliterateRequest) (any, error) {
+	if strings.TrimSpace(req.ID) == "" && len(req.Rules) == 0 || req.Text == nil && len(req.Texts) == 0 {
+		return nil, errTransliterateEmpty
+	}
+	if len(req.Texts) > maxTransliterateTexts || len(req.Rules) > maxInlineTranslitRules {
+		return nil, errTransliterateTooMany
+	}
+	for from, to := range req.Rules {
+		if utf8.RuneCountInString(from) > maxInlineTranslitSource || utf8.RuneCountInString(to) > maxInlineTranslitTarget {
+			return nil, errTranslitRuleTooLong
+		}
+	}
+	size := 0
+	if req.Text != nil {
+		size = len(*req.Text)
+	}
+	for _, s := range req.Texts {
+		size += len(s)
+	}
+	if size > maxTransliterateBytes {
+		return nil, errTransliterateTooLarge
+	}
+	t, err := compiledTransliterator(req.ID, req.Rules)
+	if err != nil {
+		return nil, err
+	}
+	if req.Text != nil {
+		return map[string]any{"id": req.ID, "text": t.apply(*req.Text)}, nil
+	}
+	out := make([]string, len(req.Texts))
+	for i, s := range req.Texts {
+		out[i] = t.apply(s)
+	}
+	return map[string]any{"id": req.ID, "texts": out}, nil
+}
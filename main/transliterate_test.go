@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTransliterate(t *testing.T) {
+	tests := []struct {
+		name  string
+		id    string
+		rules map[string]string
+		text  string
+		want  string
+	}{
+		{name: "cyrillic", id: "Cyrillic-Latin", text: "Москва", want: "Moskva"},
+		{name: "multi-letter target capitalized", id: "Cyrillic-Latin", text: "Щука и Жук", want: "Shchuka i Zhuk"},
+		{name: "uppercase word", id: "Cyrillic-Latin", text: "ЩИ", want: "SHCHI"},
+		{name: "dropped signs", id: "Cyrillic-Latin", text: "объявление", want: "obyavlenie"},
+		{name: "greek longest match", id: "Greek-Latin", text: "ουρανός άγγελος", want: "ouranos angelos"},
+		{name: "final sigma", id: "Greek-Latin", text: "λόγος", want: "logos"},
+		{name: "remove marks", id: "Latin-ASCII", text: "Perché così", want: "Perche cosi"},
+		{name: "latin letters", id: "Latin-ASCII", text: "Straße Œuvre Łódź", want: "Strasse Oeuvre Lodz"},
+		{name: "compound any-ascii", id: "Any-ASCII", text: "Ёлка façade", want: "Yolka facade"},
+		{name: "ids are case-insensitive", id: "cyrillic-latin", text: "да", want: "da"},
+		{name: "built-in steps", id: "Latin-ASCII; upper", text: "città", want: "CITTA"},
+		{name: "untouched text", id: "Cyrillic-Latin", text: "Mensa 2026!", want: "Mensa 2026!"},
+		{name: "inline rules first", id: "Cyrillic-Latin", rules: map[string]string{"х": "h"}, text: "Хлеб", want: "Hleb"},
+		{name: "inline rules alone", rules: map[string]string{"ä": "ae", "ö": "oe"}, text: "Öl und Bär", want: "Oel und Baer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := tt.text
+			out, err := transliterate(transliterateRequest{ID: tt.id, Text: &text, Rules: tt.rules})
+			if err != nil {
+				t.Fatalf("transliterate: %v", err)
+			}
+			if got := out.(map[string]any)["text"]; got != tt.want {
+				t.Errorf("transliterate(%q, %q) = %q, want %q", tt.id, tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransliterateRejects(t *testing.T) {
+	text := "x"
+	tests := []struct {
+		name string
+		req  transliterateRequest
+		want error
+	}{
+		{name: "no id nor rules", req: transliterateRequest{Text: &text}, want: errTransliterateEmpty},
+		{name: "no text", req: transliterateRequest{ID: "Any-Latin"}, want: errTransliterateEmpty},
+		{name: "unknown id", req: transliterateRequest{ID: "Klingon-Latin", Text: &text}, want: errUnknownTransliterator},
+		{name: "too many texts", req: transliterateRequest{ID: "Any-Latin", Texts: make([]string, maxTransliterateTexts+1)}, want: errTransliterateTooMany},
+		{name: "rule too long", req: transliterateRequest{Rules: map[string]string{strings.Repeat("a", maxInlineTranslitSource+1): "b"}, Text: &text}, want: errTranslitRuleTooLong},
+		{name: "too large", req: transliterateRequest{ID: "Any-Latin", Texts: []string{strings.Repeat("x", maxTransliterateBytes+1)}}, want: errTransliterateTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := transliterate(tt.req); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// errUpstreamUnavailable is what clients see of a failed upstream read.
+var errUpstreamUnavailable = errors.New("tolgee is unavailable, try again later")
+
+// upstreamCooldownError is returned while a recent upstream failure is still
+// remembered, so callers can answer 503 with a Retry-After instead of retrying.
+type upstreamCooldownError struct {
+	key        string
+	retryAfter time.Duration
+	cause      string
+}
+
+func (e *upstreamCooldownError) Error() string {
+	return fmt.Sprintf("upstream %q failed recently (%s), retry after %s", e.key, e.cause, e.retryAfter.Round(time.Second))
+}
+
+// RetryAfterSeconds is the value for the Retry-After header (at least 1).
+func (e *upstreamCooldownError) RetryAfterSeconds() string {
+	secs := int64(e.retryAfter.Seconds())
+	if secs < 1 {
+		secs = 1
+	}
+	return strconv.FormatInt(secs, 10)
+}
+
+// upstreamFailureCause describes a failure without its text: transport
+// errors carry the request URL, which must not reach clients.
+func upstreamFailureCause(err error) string {
+	var status *tolgeeStatusError
+	var urlErr *url.Error
+	switch {
+	case errors.As(err, &status):
+		return "status " + strconv.Itoa(status.status)
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &urlErr) && urlErr.Timeout():
+		return "timeout"
+	case errors.As(err, &urlErr):
+		return "transport error"
+	}
+	return "upstream error"
+}
+
+// fetchUpstream runs fn once per key across concurrent callers (singleflight).
+// A failure is stored in Redis for UPSTREAM_FAILURE_COOLDOWN, shared by all
+// replicas: during that window callers get an upstreamCooldownError without
+// hitting the upstream again.
+func fetchUpstream(ctx context.Context, key string, fn func() ([]byte, error)) ([]byte, error) {
+	cooldown := localenv.GetUpstreamFailureCooldown()
+	failKey := "tolgee:upstream-failed:" + key
+
+	if cooldown > 0 {
+		if cause, err := redisGet(ctx, failKey); err == nil && len(cause) > 0 {
+			ttl := cooldown
+			if redisEnabled() {
+				ttl, _ = rdb.TTL(ctx, failKey).Result()
+			}
+			return nil, &upstreamCooldownError{key: key, retryAfter: ttl, cause: string(cause)}
+		}
+	}
+
+	v, err, _ := sf.Do("upstream:"+key, func() (interface{}, error) {
+		b, err := fn()
+		if err != nil {
+			log.Printf("[upstream] fetch failed key=%q cooldown=%s err=%v", key, cooldown, err)
+			// a full slot queue is not an upstream failure
+			var busy *upstreamCooldownError
+			if cooldown > 0 && !errors.As(err, &busy) {
+				_ = redisPut(ctx, failKey, []byte(upstreamFailureCause(err)), cooldown)
+			}
+			return nil, err
+		}
+		return b, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
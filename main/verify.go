@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+// tierCheck is the verification outcome of one language/mode. Shas are taken
+// on the canonical (key-sorted) JSON, so SORT_SNAPSHOTS does not count as drift.
+type tierCheck struct {
+	Lang      string   `json:"lang"`
+	Mode      string   `json:"mode"`
+	RedisSha  string   `json:"redis_sha,omitempty"`
+	S3Sha     string   `json:"s3_sha,omitempty"`
+	TolgeeSha string   `json:"tolgee_sha,omitempty"`
+	Status    string   `json:"status"` // "in_sync" or "drift"
+	Drift     []string `json:"drift,omitempty"`
+}
+
+type verifyReport struct {
+	CheckedAt  time.Time   `json:"checked_at"`
+	InSync     int         `json:"in_sync"`
+	Drifted    int         `json:"drifted"`
+	Checks     []tierCheck `json:"checks"`
+	TolgeeSkip string      `json:"tolgee_skipped,omitempty"`
+
+	// fresh keeps the Tolgee exports (by mode, then language) for repairs
+	fresh map[bool]map[string][]byte
+}
+
+// runVerification compares, for every language and mode, the Redis entry,
+// the S3 object and a fresh Tolgee export. Tiers that disagree with the
+// others (or are missing) are listed in Drift.
+func runVerification(ctx context.Context) (*verifyReport, error) {
+	report := &verifyReport{CheckedAt: time.Now().UTC(), Checks: []tierCheck{}, fresh: map[bool]map[string][]byte{}}
+	s3c := s3ClientIfEnabled(ctx)
+
+	langs := map[string]bool{}
+	for tag := range loadManifest(ctx).Languages {
+		langs[tag] = true
+	}
+	if localenv.GetPromotedOnly() {
+		report.TolgeeSkip = "promoted-only mode"
+	} else {
+		appKey := localenv.GetTolgeeAppKey()
+		model, _, err := GetLanguages(ctx, appKey)
+		if err != nil {
+			return nil, err
+		}
+		tags := languageTags(model)
+		for _, tag := range tags {
+			langs[tag] = true
+		}
+		for _, nested := range []bool{false, true} {
+			files, err := GetTranslations(ctx, appKey, strings.Join(tags, ", "), nested)
+			if err != nil {
+				return nil, err
+			}
+			// compared as a refresh would store them
+			for name, payload := range files {
+				files[name] = applyIngestFilters(name, nested, payload, false)
+			}
+			report.fresh[nested] = files
+		}
+	}
+
+	sorted := make([]string, 0, len(langs))
+	for tag := range langs {
+		sorted = append(sorted, tag)
+	}
+	sort.Strings(sorted)
+	for _, lang := range sorted {
+		for _, nested := range []bool{false, true} {
+			key := translationsCacheKey(lang, nested)
+			check := tierCheck{Lang: lang, Mode: modeLabel(nested)}
+			if b, err := redisGet(ctx, key); err == nil {
+				check.RedisSha = canonicalSha(b)
+			}
+			if s3c != nil {
+				if b, err := s3c.getObject(ctx, key); err == nil {
+					check.S3Sha = canonicalSha(b)
+				}
+			}
+			tiers := map[string]string{"redis": check.RedisSha}
+			if s3c != nil {
+				tiers["s3"] = check.S3Sha
+			}
+			if report.TolgeeSkip == "" {
+				check.TolgeeSha = canonicalSha(report.fresh[nested][lang])
+				tiers["tolgee"] = check.TolgeeSha
+			}
+			check.Drift = driftedTiers(tiers)
+			check.Status = "in_sync"
+			if len(check.Drift) > 0 {
+				check.Status = "drift"
+				report.Drifted++
+			} else {
+				report.InSync++
+			}
+			report.Checks = append(report.Checks, check)
+		}
+	}
+	return report, nil
+}
+
+// canonicalSha hashes the key-sorted JSON; "" for a missing or invalid payload.
+func canonicalSha(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	canonical, err := sortJSONKeys(b)
+	if err != nil {
+		return ""
+	}
+	return sha256Hex(canonical)
+}
+
+// driftedTiers returns the tiers whose sha differs from the majority (the
+// reference is Tolgee when present), plus every tier missing the entry.
+func driftedTiers(tiers map[string]string) []string {
+	reference, ok := tiers["tolgee"]
+	if !ok || reference == "" {
+		counts := map[string]int{}
+		for _, sha := range tiers {
+			if sha != "" {
+				counts[sha]++
+			}
+		}
+		for sha, n := range counts {
+			if n > counts[reference] || (n == counts[reference] && sha < reference) {
+				reference = sha
+			}
+		}
+	}
+	var drift []string
+	for name, sha := range tiers {
+		if sha == "" || sha != reference {
+			drift = append(drift, name)
+		}
+	}
+	sort.Strings(drift)
+	return drift
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	versionedCacheControl = "public, max-age=31536000, immutable"
+	minVersionShaLength   = 12
+)
+
+var errVersionNotCurrent = errors.New("snapshot sha is not current for this language")
+
+// versionEntry is one language of /api/manifest: the current snapshot shas
+// and the immutable URLs serving them.
+type versionEntry struct {
+	FlatSha   string    `json:"flat_sha,omitempty"`
+	NestedSha string    `json:"nested_sha,omitempty"`
+	FlatURL   string    `json:"flat_url,omitempty"`
+	NestedURL string    `json:"nested_url,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type versionManifest struct {
+	GeneratedAt time.Time               `json:"generated_at"`
+	Languages   map[string]versionEntry `json:"languages"`
+}
+
+func versionedURL(sha, lang string) string {
+	return "/api/v/" + sha + "/" + lang
+}
+
+// buildVersionManifest hands out the current sha of every cached snapshot.
+func buildVersionManifest(ctx context.Context) versionManifest {
+	m := loadManifest(ctx)
+	out := versionManifest{GeneratedAt: m.GeneratedAt, Languages: make(map[string]versionEntry, len(m.Languages))}
+	for lang, entry := range m.Languages {
+		v := versionEntry{FlatSha: entry.FlatSha, NestedSha: entry.NestedSha, UpdatedAt: entry.UpdatedAt}
+		if entry.FlatSha != "" {
+			v.FlatURL = versionedURL(entry.FlatSha, lang)
+		}
+		if entry.NestedSha != "" {
+			v.NestedURL = versionedURL(entry.NestedSha, lang)
+		}
+		out.Languages[lang] = v
+	}
+	return out
+}
+
+// loadVersionedSnapshot returns the snapshot of lang whose sha256 starts with
+// sha, and whether it is the nested one. Only current snapshots are served:
+// older shas fail instead of returning different bytes under the same URL.
+// ENCRYPTED_NAMESPACES are left out: the URL is public and cached forever.
+func loadVersionedSnapshot(ctx context.Context, sha, lang string) ([]byte, bool, error) {
+	sha = strings.ToLower(sha)
+	if len(sha) < minVersionShaLength {
+		return nil, false, errVersionNotCurrent
+	}
+	entry, ok := loadManifest(ctx).Languages[lang]
+	if !ok {
+		return nil, false, errVersionNotCurrent
+	}
+	for _, nested := range []bool{false, true} {
+		current := entry.FlatSha
+		if nested {
+			current = entry.NestedSha
+		}
+		if current == "" || !strings.HasPrefix(current, sha) {
+			continue
+		}
+		payload, err := GetTranslationsFromCache(ctx, lang, nested)
+		if err != nil {
+			return nil, false, err
+		}
+		// the manifest may lag a concurrent refresh
+		if !strings.HasPrefix(sha256Hex(payload), sha) {
+			return nil, false, errVersionNotCurrent
+		}
+		if payload, err = withoutEncryptedNamespaces(payload, nested); err != nil {
+			return nil, false, err
+		}
+		return payload, nested, nil
+	}
+	return nil, false, errVersionNotCurrent
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	localenv "mensalocalizations/tools/env"
+)
+
+const (
+	warmupStatusPending = "pending"
+	warmupStatusRunning = "running"
+	warmupStatusDone    = "done"
+	warmupStatusFailed  = "failed"
+)
+
+// warmupProgress is the startup warm-up state of this process.
+type warmupProgress struct {
+	Status         string     `json:"status"`
+	LanguagesDone  int        `json:"languages_done"`
+	LanguagesTotal int        `json:"languages_total"`
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+	ElapsedMs      int64      `json:"elapsed_ms"`
+	EtaSeconds     *int64     `json:"eta_seconds,omitempty"`
+	Deadline       string     `json:"deadline"`
+	// Backgrounded is true when the deadline passed and the server started
+	// listening while the warm-up went on
+	Backgrounded bool `json:"backgrounded"`
+	// StageMs sums the time spent per stage (redis, s3, tolgee, store)
+	StageMs map[string]float64 `json:"stage_ms,omitempty"`
+}
+
+var warmup = struct {
+	sync.Mutex
+	progress warmupProgress
+	timings  *stageTimings
+}{progress: warmupProgress{Status: warmupStatusPending}}
+
+type warmupCtxKey struct{}
+
+// withWarmupProgress marks ctx as the startup warm-up: the refresh reports
+// its language count and every stored language to the progress.
+func withWarmupProgress(ctx context.Context) context.Context {
+	return context.WithValue(ctx, warmupCtxKey{}, true)
+}
+
+func isWarmup(ctx context.Context) bool {
+	on, _ := ctx.Value(warmupCtxKey{}).(bool)
+	return on
+}
+
+func warmupSetTotal(ctx context.Context, total int) {
+	if !isWarmup(ctx) {
+		return
+	}
+	warmup.Lock()
+	warmup.progress.LanguagesTotal = total
+	warmup.Unlock()
+}
+
+// warmupLanguageDone counts a language as warm (stored or given up on).
+func warmupLanguageDone(ctx context.Context) {
+	if !isWarmup(ctx) {
+		return
+	}
+	warmup.Lock()
+	warmup.progress.LanguagesDone++
+	warmup.Unlock()
+}
+
+// runWarmup runs the startup refresh and returns once it completes or
+// WARMUP_DEADLINE elapses, whichever comes first; past the deadline the
+// remaining languages keep refreshing in the background (priority languages
+// go first, so they are usually warm by then).
+func runWarmup() {
+	started := time.Now().UTC()
+	warmup.Lock()
+	warmup.progress.Status = warmupStatusRunning
+	warmup.progress.StartedAt = &started
+	warmup.progress.Deadline = localenv.GetWarmupDeadline().String()
+	ctx, timings := withStageTimings(withWarmupProgress(context.Background()), "warmup")
+	warmup.timings = timings
+	warmup.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := RebuildTheCache(ctx)
+		finished := time.Now().UTC()
+		warmup.Lock()
+		warmup.progress.FinishedAt = &finished
+		warmup.progress.Status = warmupStatusDone
+		if err != nil {
+			warmup.progress.Status = warmupStatusFailed
+		}
+		warmup.Unlock()
+		log.Printf("[warmup] finished in %s stages=%v err=%v", finished.Sub(started).Round(time.Millisecond), timings.stageMillis(), err)
+	}()
+
+	deadline := localenv.GetWarmupDeadline()
+	if deadline <= 0 {
+		<-done
+		return
+	}
+	select {
+	case <-done:
+	case <-time.After(deadline):
+		warmup.Lock()
+		warmup.progress.Backgrounded = true
+		p := warmup.progress
+		warmup.Unlock()
+		log.Printf("[warmup] deadline %s reached at %d/%d languages, continuing in background", deadline, p.LanguagesDone, p.LanguagesTotal)
+	}
+}
+
+// getWarmupProgress returns a snapshot with the elapsed time and, while
+// running, an ETA extrapolated from the languages done so far.
+func getWarmupProgress() warmupProgress {
+	warmup.Lock()
+	p := warmup.progress
+	timings := warmup.timings
+	warmup.Unlock()
+	if p.StartedAt == nil {
+		return p
+	}
+	if timings != nil {
+		p.StageMs = timings.stageMillis()
+	}
+	end := time.Now()
+	if p.FinishedAt != nil {
+		end = *p.FinishedAt
+	}
+	elapsed := end.Sub(*p.StartedAt)
+	p.ElapsedMs = elapsed.Milliseconds()
+	if p.Status == warmupStatusRunning && p.LanguagesDone > 0 && p.LanguagesTotal >= p.LanguagesDone {
+		eta := int64((elapsed / time.Duration(p.LanguagesDone) * time.Duration(p.LanguagesTotal-p.LanguagesDone)).Seconds())
+		p.EtaSeconds = &eta
+	}
+	return p
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/goccy/go-json"
+)
+
+// webhookEventType is the normalized kind of a Tolgee webhook activity.
+type webhookEventType string
+
+const (
+	webhookEventAny                webhookEventType = "*"
+	webhookEventTranslationUpdated webhookEventType = "translation_updated"
+	webhookEventKeyCreated         webhookEventType = "key_created"
+	webhookEventKeyDeleted         webhookEventType = "key_deleted"
+	webhookEventLanguageAdded      webhookEventType = "language_added"
+	webhookEventLanguageDeleted    webhookEventType = "language_deleted"
+	webhookEventOther              webhookEventType = "other"
+)
+
+// tolgeeActivityTypes maps Tolgee activity types to event types;
+// anything not listed becomes webhookEventOther.
+var tolgeeActivityTypes = map[string]webhookEventType{
+	"SET_TRANSLATIONS":          webhookEventTranslationUpdated,
+	"SET_TRANSLATION_STATE":     webhookEventTranslationUpdated,
+	"SET_OUTDATED_FLAG":         webhookEventTranslationUpdated,
+	"BATCH_MACHINE_TRANSLATE":   webhookEventTranslationUpdated,
+	"BATCH_PRE_TRANSLATE_BY_TM": webhookEventTranslationUpdated,
+	"IMPORT":                    webhookEventTranslationUpdated,
+	"CREATE_KEY":                webhookEventKeyCreated,
+	"KEY_DELETE":                webhookEventKeyDeleted,
+	"CREATE_LANGUAGE":           webhookEventLanguageAdded,
+	"DELETE_LANGUAGE":           webhookEventLanguageDeleted,
+}
+
+// webhookEvent is a parsed Tolgee webhook delivery.
+type webhookEvent struct {
+	Type         webhookEventType `json:"type"`
+	ActivityType string           `json:"activity_type"`
+	Languages    []string         `json:"languages,omitempty"`
+	Keys         []string         `json:"keys,omitempty"`
+	Raw          json.RawMessage  `json:"-"`
+}
+
+// tolgeeWebhookPayload is the subset of the Tolgee PROJECT_ACTIVITY body we read.
+type tolgeeWebhookPayload struct {
+	EventType    string `json:"eventType"`
+	ActivityData struct {
+		Type             string                          `json:"type"`
+		ModifiedEntities map[string][]tolgeeModifiedItem `json:"modifiedEntities"`
+	} `json:"activityData"`
+}
+
+type tolgeeModifiedItem struct {
+	Description map[string]any `json:"description"`
+	Relations   map[string]struct {
+		Data map[string]any `json:"data"`
+	} `json:"relations"`
+}
+
+// parseTolgeeWebhook extracts the event type and the affected language tags
+// and key names. Unknown or malformed bodies yield webhookEventOther.
+func parseTolgeeWebhook(body []byte) webhookEvent {
+	ev := webhookEvent{Type: webhookEventOther, Raw: body}
+	var payload tolgeeWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		log.Printf("[webhook] payload unmarshal error: %v", err)
+		return ev
+	}
+	ev.ActivityType = payload.ActivityData.Type
+	if t, ok := tolgeeActivityTypes[ev.ActivityType]; ok {
+		ev.Type = t
+	}
+
+	langs := map[string]bool{}
+	keys := map[string]bool{}
+	for entity, items := range payload.ActivityData.ModifiedEntities {
+		for _, item := range items {
+			if entity == "Language" {
+				if tag, ok := item.Description["tag"].(string); ok {
+					langs[tag] = true
+				}
+			}
+			if entity == "Key" {
+				if name, ok := item.Description["name"].(string); ok {
+					keys[name] = true
+				}
+			}
+			if tag, ok := item.Relations["language"].Data["tag"].(string); ok {
+				langs[tag] = true
+			}
+			if name, ok := item.Relations["key"].Data["name"].(string); ok {
+				keys[name] = true
+			}
+		}
+	}
+	ev.Languages = sortedSetKeys(langs)
+	ev.Keys = sortedSetKeys(keys)
+	return ev
+}
+
+// webhookHook reacts to a webhook event; errors are logged, never returned to Tolgee.
+type webhookHook func(ctx context.Context, ev webhookEvent) error
+
+var (
+	webhookHooksMu sync.RWMutex
+	webhookHooks   = map[webhookEventType][]namedWebhookHook{}
+)
+
+type namedWebhookHook struct {
+	name string
+	fn   webhookHook
+}
+
+// registerWebhookHook subscribes fn to an event type (webhookEventAny for all).
+func registerWebhookHook(t webhookEventType, name string, fn webhookHook) {
+	webhookHooksMu.Lock()
+	defer webhookHooksMu.Unlock()
+	webhookHooks[t] = append(webhookHooks[t], namedWebhookHook{name: name, fn: fn})
+}
+
+// dispatchWebhookEvent runs the hooks for ev.Type and then the catch-all ones.
+func dispatchWebhookEvent(ctx context.Context, ev webhookEvent) {
+	webhookHooksMu.RLock()
+	hooks := append(append([]namedWebhookHook(nil), webhookHooks[ev.Type]...), webhookHooks[webhookEventAny]...)
+	webhookHooksMu.RUnlock()
+
+	for _, h := range hooks {
+		if err := h.fn(ctx, ev); err != nil {
+			log.Printf("[webhook] hook %s error event=%s: %v", h.name, ev.Type, err)
+		}
+	}
+}
+
+func init() {
+	registerWebhookHook(webhookEventAny, "log", func(_ context.Context, ev webhookEvent) error {
+		log.Printf("[webhook] event=%s activity=%s langs=%v keys=%d", ev.Type, ev.ActivityType, ev.Languages, len(ev.Keys))
+		return nil
+	})
+}
+
+func sortedSetKeys(set map[string]bool) []string {
+	out := make([]string, 0, len(set))
+	for k := range set {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -3,14 +3,25 @@ package env
 import (
 	"fmt"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 )
 
 type config struct {
 	// --- mensa-localizations: Redis ---
+	// RedisEnabled=false runs without Redis: keyed values in process memory,
+	// snapshots in memory and S3 only, coordination features off
+	// (single-container deployments)
+	RedisEnabled  bool   `env:"REDIS_ENABLED" envDefault:"true"`
 	RedisAddr     string `env:"REDIS_ADDR" envDefault:"localhost:6379"`
 	RedisPassword string `env:"REDIS_PASSWORD" envDefault:""`
+	// RedisShards: name -> address of the instances keys are hashed over,
+	// e.g. "a:redis-a:6379,b:redis-b:6379" (replaces REDIS_ADDR when set)
+	RedisShards         map[string]string `env:"REDIS_SHARDS" envDefault:""`
+	RedisShardHeartbeat time.Duration     `env:"REDIS_SHARD_HEARTBEAT" envDefault:"500ms"`
 
 	// --- mensa-localizations: MinIO/S3 fallback & versioning ---
 	S3Enabled        bool   `env:"S3_ENABLED" envDefault:"true"`
@@ -21,9 +32,252 @@ type config struct {
 	S3SecretKey      string `env:"S3_SECRET_KEY" envDefault:""`
 	S3ForcePathStyle bool   `env:"S3_FORCE_PATH_STYLE" envDefault:"true"`
 
+	// S3AccessKeyFile/S3SecretKeyFile (e.g. mounted secrets) win over the
+	// plain variables and are re-read on SIGHUP and every S3_CREDENTIALS_RELOAD_INTERVAL
+	S3AccessKeyFile             string        `env:"S3_ACCESS_KEY_FILE" envDefault:""`
+	S3SecretKeyFile             string        `env:"S3_SECRET_KEY_FILE" envDefault:""`
+	S3CredentialsReloadInterval time.Duration `env:"S3_CREDENTIALS_RELOAD_INTERVAL" envDefault:"1m"`
+
+	// StorageMigrateOnStart runs the S3 layout migrations before the warm-up
+	StorageMigrateOnStart bool `env:"STORAGE_MIGRATE_ON_START" envDefault:"false"`
+
+	// --- git source (SOURCE=git:<url>) ---
+	GitSourceBranch string `env:"GIT_SOURCE_BRANCH" envDefault:"main"`
+	// GitSourcePath is the repository directory holding <lang>.json ("" = root)
+	GitSourcePath  string `env:"GIT_SOURCE_PATH" envDefault:""`
+	GitSourceToken string `env:"GIT_SOURCE_TOKEN" envDefault:""`
+	// GitSourceDir holds the clone (default <tmp>/mensa-git-source)
+	GitSourceDir           string        `env:"GIT_SOURCE_DIR" envDefault:""`
+	GitSourcePollInterval  time.Duration `env:"GIT_SOURCE_POLL_INTERVAL" envDefault:"1m"`
+	GitSourceWebhookSecret string        `env:"GIT_SOURCE_WEBHOOK_SECRET" envDefault:""`
+
+	// --- git export (commit every refreshed snapshot to a repository) ---
+	GitExportURL    string `env:"GIT_EXPORT_URL" envDefault:""`
+	GitExportBranch string `env:"GIT_EXPORT_BRANCH" envDefault:"main"`
+	GitExportPath   string `env:"GIT_EXPORT_PATH" envDefault:"translations"`
+	GitExportToken  string `env:"GIT_EXPORT_TOKEN" envDefault:""`
+	// GitExportDir holds the working clone (default <tmp>/mensa-git-export)
+	GitExportDir   string `env:"GIT_EXPORT_DIR" envDefault:""`
+	GitUsername    string `env:"GIT_USERNAME" envDefault:"git"`
+	GitAuthorName  string `env:"GIT_AUTHOR_NAME" envDefault:"mensa-localizations"`
+	GitAuthorEmail string `env:"GIT_AUTHOR_EMAIL" envDefault:"mensa-localizations@localhost"`
+
+	// --- snapshot promotion (staging -> this bucket) ---
+	PromoteSourceBucket string `env:"PROMOTE_SOURCE_BUCKET" envDefault:""`
+	PromoteSourcePrefix string `env:"PROMOTE_SOURCE_PREFIX" envDefault:""`
+	// PromotedOnly: never call Tolgee, serve only promoted snapshots
+	PromotedOnly bool `env:"PROMOTED_ONLY" envDefault:"false"`
+
 	// --- tolgee single app ---
+	// Source is "tolgee", "local:/path" to read <path>/<lang>.json with hot
+	// reload instead of calling Tolgee (offline development), or "git:<url>"
+	// to serve a repository of JSON catalogs
+	Source        string `env:"SOURCE" envDefault:"tolgee"`
 	TolgeeAppKey  string `env:"TOLGEE_APP_KEY" envDefault:""`
 	WebhookSecret string `env:"WEBHOOK_SECRET" envDefault:""`
+
+	// TolgeeProxyAllowed: read-only /v2/projects/<path> endpoints exposed on /api/tolgee/*
+	TolgeeProxyAllowed []string      `env:"TOLGEE_PROXY_ALLOWED" envSeparator:"," envDefault:"stats,tags,namespaces,used-namespaces,languages"`
+	TolgeeProxyTTL     time.Duration `env:"TOLGEE_PROXY_TTL" envDefault:"5m"`
+	// TolgeeProxyQuery: query parameters forwarded (and cached) by /api/tolgee/*
+	TolgeeProxyQuery []string `env:"TOLGEE_PROXY_QUERY" envSeparator:"," envDefault:"page,size,sort,languages"`
+	// TolgeeProductionBranch is exported for /api (empty = the project default branch)
+	TolgeeProductionBranch string `env:"TOLGEE_PRODUCTION_BRANCH" envDefault:""`
+	// TolgeeBranchChannels: channel -> Tolgee branch served on /api/branch/:channel, e.g. "checkout:feature/checkout"
+	TolgeeBranchChannels map[string]string `env:"TOLGEE_BRANCH_CHANNELS" envDefault:""`
+	BranchCacheTTL       time.Duration     `env:"BRANCH_CACHE_TTL" envDefault:"1m"`
+
+	// --- tenant apps ---
+	// Apps: further Tolgee projects served on /api/:app/*, app -> app key, e.g. "quiz:tgpak_xxx"
+	Apps map[string]string `env:"APPS" envDefault:""`
+	// AppCacheTTL: how long a tenant catalog is served from Redis before the next export
+	AppCacheTTL time.Duration `env:"APP_CACHE_TTL" envDefault:"10m"`
+	// ScreenshotProxy copies key screenshots to S3 and serves them on /api/screenshots/:id
+	ScreenshotProxy bool `env:"SCREENSHOT_PROXY" envDefault:"false"`
+
+	// --- payload shape defaults (used when ?nested= is absent) ---
+	DefaultNested          bool            `env:"DEFAULT_NESTED" envDefault:"false"`
+	PlatformNestedDefaults map[string]bool `env:"PLATFORM_NESTED_DEFAULTS" envDefault:""`
+	// PlatformHTMLEscape: platforms served HTML-escaped values by default, e.g. "web:true"
+	PlatformHTMLEscape map[string]bool `env:"PLATFORM_HTML_ESCAPE" envDefault:""`
+	// PlatformMaxPayloadBytes: per X-Platform size budget, e.g. "kaios:65536"
+	PlatformMaxPayloadBytes map[string]int64 `env:"PLATFORM_MAX_PAYLOAD_BYTES" envDefault:""`
+	// NamespacePriority: namespaces served first when a payload is split by budget
+	NamespacePriority []string `env:"NAMESPACE_PRIORITY" envSeparator:"," envDefault:""`
+	// FlatDelimiter joins nested keys in flat mode ("" = keys as exported by Tolgee)
+	FlatDelimiter string `env:"FLAT_DELIMITER" envDefault:""`
+	// SortSnapshots stores every snapshot with lexicographically sorted keys
+	SortSnapshots bool `env:"SORT_SNAPSHOTS" envDefault:"false"`
+
+	// LanguageGroups: regional bundles, e.g. "dach:de|de-AT|de-CH,nordic:sv|da"
+	LanguageGroups map[string]string `env:"LANGUAGE_GROUPS" envDefault:""`
+
+	// RegionOverlays: language -> namespaces laid over its base language,
+	// e.g. "de-AT:legal|tos,de-CH:legal"
+	RegionOverlays map[string]string `env:"REGION_OVERLAYS" envDefault:""`
+
+	// PostprocessRules: serve-time rules per language ("*" = all), "+"-separated,
+	// e.g. "en:curly_quotes,fr:nbsp_units,it:sentence_case=onboarding.|menu."
+	PostprocessRules map[string]string `env:"POSTPROCESS_RULES" envDefault:""`
+
+	// --- ingest lint ---
+	// LintRules: rule -> severity (error, warning, info, off), e.g. "double_spaces:error"
+	LintRules map[string]string `env:"LINT_RULES" envDefault:"max_length:warning,forbidden_chars:error,double_spaces:warning,trailing_whitespace:warning,equals_base:info"`
+	// LintMaxLength: key prefix -> max characters, e.g. "button.:24,title.:60"
+	LintMaxLength map[string]int `env:"LINT_MAX_LENGTH" envDefault:""`
+	// LintForbiddenChars: characters never allowed in a value
+	LintForbiddenChars string `env:"LINT_FORBIDDEN_CHARS" envDefault:""`
+	// IngestFilters: namespace ("*" = all) -> "+"-separated filters applied at refresh,
+	// e.g. "*:strip_control+strip_zero_width,buttons:deny_emoji,marketing:allow_emoji"
+	IngestFilters map[string]string `env:"INGEST_FILTERS" envDefault:""`
+	// LengthBudgetTruncate cuts served values over their LINT_MAX_LENGTH budget with "…"
+	LengthBudgetTruncate bool `env:"LENGTH_BUDGET_TRUNCATE" envDefault:"false"`
+
+	// --- fallback language inference ---
+	// GeoIPDBPath: MaxMind Country/City database used when there is no Accept-Language
+	GeoIPDBPath string `env:"GEOIP_DB_PATH" envDefault:""`
+	// CountryLanguages maps ISO country -> language tag, e.g. "IT:it,AT:de-AT"
+	CountryLanguages map[string]string `env:"COUNTRY_LANGUAGES" envDefault:""`
+	// ProxyHeader carries the client IP set by the reverse proxy (e.g. X-Real-IP);
+	// it is read only on requests from TrustedProxies (IPs or CIDRs)
+	ProxyHeader    string   `env:"PROXY_HEADER" envDefault:""`
+	TrustedProxies []string `env:"TRUSTED_PROXIES" envSeparator:"," envDefault:""`
+
+	// LanguageAliases map requested tags to stored ones, e.g. "iw:he,pt-PT:pt"
+	LanguageAliases map[string]string `env:"LANGUAGE_ALIASES" envDefault:""`
+	// FallbackChains: languages tried when a tag is not cached, e.g. "de-CH:de|en" (default en)
+	FallbackChains map[string]string `env:"FALLBACK_CHAINS" envDefault:""`
+
+	// BetaLanguages are only served to clients opting in with ?include_beta=true
+	BetaLanguages []string `env:"BETA_LANGUAGES" envSeparator:"," envDefault:""`
+
+	// PriorityLanguages are refreshed before every other language
+	PriorityLanguages []string `env:"PRIORITY_LANGUAGES" envSeparator:"," envDefault:"it,en"`
+
+	// RefreshDebounce: minimum interval between two completed refreshes (0 = disabled)
+	RefreshDebounce time.Duration `env:"REFRESH_DEBOUNCE" envDefault:"0s"`
+
+	// StaleBannerAfter: inject the stale marker when a language is older than this (0 = disabled)
+	StaleBannerAfter time.Duration `env:"STALE_BANNER_AFTER" envDefault:"0s"`
+	StaleBannerKey   string        `env:"STALE_BANNER_KEY" envDefault:"_meta"`
+
+	// BackfillEmptyFromBase serves the base-language value for empty strings
+	BackfillEmptyFromBase bool `env:"BACKFILL_EMPTY_FROM_BASE" envDefault:"false"`
+
+	// UpstreamFailureCooldown: how long a failed Tolgee fetch is remembered (0 = disabled)
+	UpstreamFailureCooldown time.Duration `env:"UPSTREAM_FAILURE_COOLDOWN" envDefault:"30s"`
+
+	// --- outbound HTTP (Tolgee, screenshots, webhooks; proxy from HTTPS_PROXY/NO_PROXY) ---
+	HTTPMaxIdleConns        int           `env:"HTTP_MAX_IDLE_CONNS" envDefault:"100"`
+	HTTPMaxIdleConnsPerHost int           `env:"HTTP_MAX_IDLE_CONNS_PER_HOST" envDefault:"16"`
+	HTTPMaxConnsPerHost     int           `env:"HTTP_MAX_CONNS_PER_HOST" envDefault:"32"`
+	HTTPIdleConnTimeout     time.Duration `env:"HTTP_IDLE_CONN_TIMEOUT" envDefault:"90s"`
+	HTTPDialTimeout         time.Duration `env:"HTTP_DIAL_TIMEOUT" envDefault:"10s"`
+	HTTPTLSHandshakeTimeout time.Duration `env:"HTTP_TLS_HANDSHAKE_TIMEOUT" envDefault:"10s"`
+
+	// --- Tolgee concurrency (0 = unlimited) ---
+	// TolgeeMaxConcurrency bounds in-flight Tolgee requests of this process
+	TolgeeMaxConcurrency int `env:"TOLGEE_MAX_CONCURRENCY" envDefault:"8"`
+	// TolgeeGlobalMaxConcurrency bounds them across replicas, through Redis
+	TolgeeGlobalMaxConcurrency int `env:"TOLGEE_GLOBAL_MAX_CONCURRENCY" envDefault:"0"`
+	// TolgeeQueueTimeout is how long a request waits for a slot before failing
+	TolgeeQueueTimeout time.Duration `env:"TOLGEE_QUEUE_TIMEOUT" envDefault:"10s"`
+	// TolgeeSlotLease frees a global slot whose holder died without releasing it
+	TolgeeSlotLease time.Duration `env:"TOLGEE_SLOT_LEASE" envDefault:"5m"`
+
+	// --- payload limits (bytes, 0 = unlimited) ---
+	MaxPayloadBytes          int64 `env:"MAX_PAYLOAD_BYTES" envDefault:"16777216"`
+	MaxAggregatePayloadBytes int64 `env:"MAX_AGGREGATE_PAYLOAD_BYTES" envDefault:"134217728"`
+
+	// --- in-process snapshot tier (0 bytes = disabled) ---
+	MemoryCacheMaxBytes int64         `env:"MEMORY_CACHE_MAX_BYTES" envDefault:"0"`
+	MemoryCacheTTL      time.Duration `env:"MEMORY_CACHE_TTL" envDefault:"30s"`
+
+	// --- snapshot freshness (0 = disabled) ---
+	// SnapshotSoftTTL: a snapshot served after this long since its last
+	// refresh triggers a background refresh of its language
+	SnapshotSoftTTL time.Duration `env:"SNAPSHOT_SOFT_TTL" envDefault:"0s"`
+	// SnapshotHardTTL: snapshots expire from Redis (S3 still serves them)
+	SnapshotHardTTL time.Duration `env:"SNAPSHOT_HARD_TTL" envDefault:"0s"`
+
+	// --- content-addressed snapshots ---
+	// ContentAddressedStorage stores each distinct snapshot once as
+	// tolgee:blob:<sha>, the variant keys holding a pointer to it
+	ContentAddressedStorage bool `env:"CONTENT_ADDRESSED_STORAGE" envDefault:"false"`
+	// BlobGCInterval: how often unreferenced blobs are swept (0 = never)
+	BlobGCInterval time.Duration `env:"BLOB_GC_INTERVAL" envDefault:"1h"`
+
+	// --- derived artifacts (format conversions, filtered subsets) ---
+	// DerivedArtifactTTL bounds their life in Redis; S3 keeps them until the
+	// source snapshot changes
+	DerivedArtifactTTL time.Duration `env:"DERIVED_ARTIFACT_TTL" envDefault:"24h"`
+
+	// --- drift repair ---
+	// RepairInterval runs the verify+repair job on a schedule (0 = disabled)
+	RepairInterval time.Duration `env:"REPAIR_INTERVAL" envDefault:"0s"`
+	// RepairS3MaxAge: S3 objects older than this are refreshed from Tolgee on drift
+	RepairS3MaxAge time.Duration `env:"REPAIR_S3_MAX_AGE" envDefault:"1h"`
+
+	// --- break-glass patches (RFC 6902 files in S3, poll 0 = admin trigger only) ---
+	PatchesS3Prefix     string        `env:"PATCHES_S3_PREFIX" envDefault:"patches/"`
+	PatchesPollInterval time.Duration `env:"PATCHES_POLL_INTERVAL" envDefault:"1m"`
+
+	// --- freshness SLO (max age 0 = disabled) ---
+	// FreshnessSLOMaxAge: a served snapshot older than the last Tolgee change by more than this breaches
+	FreshnessSLOMaxAge time.Duration `env:"FRESHNESS_SLO_MAX_AGE" envDefault:"15m"`
+	FreshnessSLOTarget float64       `env:"FRESHNESS_SLO_TARGET" envDefault:"0.99"`
+	FreshnessSLOWindow time.Duration `env:"FRESHNESS_SLO_WINDOW" envDefault:"1h"`
+
+	// WarmupDeadline: the server starts listening after this even if the
+	// startup warm-up is still running (0 = wait for the whole warm-up)
+	WarmupDeadline time.Duration `env:"WARMUP_DEADLINE" envDefault:"60s"`
+
+	// --- refresh retries of failed languages (max attempts 0 = disabled) ---
+	RefreshRetryMaxAttempts int64         `env:"REFRESH_RETRY_MAX_ATTEMPTS" envDefault:"8"`
+	RefreshRetryBackoff     time.Duration `env:"REFRESH_RETRY_BACKOFF" envDefault:"30s"`
+	RefreshRetryBackoffMax  time.Duration `env:"REFRESH_RETRY_BACKOFF_MAX" envDefault:"30m"`
+
+	// UpdateHistorySize caps tolgee:update:history, the finished refresh summaries (0 = disabled)
+	UpdateHistorySize int64 `env:"UPDATE_HISTORY_SIZE" envDefault:"50"`
+
+	// JournalMaxLen caps the tolgee:journal stream of cache mutations (0 = disabled)
+	JournalMaxLen int64 `env:"JOURNAL_MAX_LEN" envDefault:"10000"`
+
+	// KeyAliasesFromTolgee derives old→new key aliases from the Tolgee
+	// activity log (key renames) on every full refresh
+	KeyAliasesFromTolgee bool `env:"KEY_ALIASES_FROM_TOLGEE" envDefault:"false"`
+
+	// --- premium content ---
+	// EncryptedNamespaces are only served encrypted with a client key
+	EncryptedNamespaces []string `env:"ENCRYPTED_NAMESPACES" envSeparator:"," envDefault:""`
+	// ClientEncryptionKeys: X-Client-Id -> hex AES-256 key, e.g. "tv-app:<64 hex>"
+	ClientEncryptionKeys map[string]string `env:"CLIENT_ENCRYPTION_KEYS" envDefault:""`
+
+	// --- outgoing notifications ---
+	OutgoingWebhookURL    string `env:"OUTGOING_WEBHOOK_URL" envDefault:""`
+	OutgoingWebhookSecret string `env:"OUTGOING_WEBHOOK_SECRET" envDefault:""`
+
+	// --- crawler / probe endpoints ---
+	// RobotsTxt is served at /robots.txt; literal "\n" sequences become newlines
+	RobotsTxt string `env:"ROBOTS_TXT" envDefault:"User-agent: *\nDisallow: /"`
+	// FaviconFile is served at /favicon.ico; empty answers 204 No Content
+	FaviconFile         string `env:"FAVICON_FILE" envDefault:""`
+	WellKnownHealthBody string `env:"WELL_KNOWN_HEALTH_BODY" envDefault:"{\"status\":\"pass\"}"`
+
+	// --- text utilities ---
+	// TransliterationRulesFile: JSON {id: {from: to} | "compound; id"} merged
+	// over the built-in transliterators of POST /api/transliterate
+	TransliterationRulesFile string `env:"TRANSLITERATION_RULES_FILE" envDefault:""`
+
+	// --- admin / debug ---
+	AdminToken string `env:"ADMIN_TOKEN" envDefault:""`
+	// IdempotencyTTL keeps the responses of requests sent with Idempotency-Key
+	IdempotencyTTL time.Duration `env:"IDEMPOTENCY_TTL" envDefault:"24h"`
+	// DiagnosticsOnShutdown stores a state summary in S3 on SIGTERM/SIGINT
+	DiagnosticsOnShutdown bool `env:"DIAGNOSTICS_ON_SHUTDOWN" envDefault:"true"`
+	// AdminListenAddr serves /api/admin, /metrics and /debug on a separate
+	// listener (e.g. ":9090"); empty keeps them on the public :3000
+	AdminListenAddr string `env:"ADMIN_LISTEN_ADDR" envDefault:""`
 }
 
 var cfg = config{}
@@ -35,12 +289,55 @@ func init() {
 	if err := env.Parse(&cfg); err != nil {
 		fmt.Printf("%+v\n", err)
 	}
+	if _, err := ReloadS3Credentials(); err != nil {
+		fmt.Printf("%+v\n", err)
+	}
+}
+
+// s3Credentials are the only settings swapped at runtime, hence the lock.
+var s3Credentials struct {
+	sync.RWMutex
+	accessKey, secretKey string
+}
+
+// ReloadS3Credentials re-reads S3_ACCESS_KEY/S3_SECRET_KEY, or their _FILE
+// variants when set, and reports whether they changed. On a read error the
+// current credentials are kept.
+func ReloadS3Credentials() (bool, error) {
+	accessKey, err := readSecret("S3_ACCESS_KEY", cfg.S3AccessKeyFile)
+	if err != nil {
+		return false, err
+	}
+	secretKey, err := readSecret("S3_SECRET_KEY", cfg.S3SecretKeyFile)
+	if err != nil {
+		return false, err
+	}
+	s3Credentials.Lock()
+	defer s3Credentials.Unlock()
+	changed := accessKey != s3Credentials.accessKey || secretKey != s3Credentials.secretKey
+	s3Credentials.accessKey, s3Credentials.secretKey = accessKey, secretKey
+	return changed, nil
+}
+
+func readSecret(name, file string) (string, error) {
+	if file == "" {
+		return os.Getenv(name), nil
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("read %s_FILE: %w", name, err)
+	}
+	return strings.TrimSpace(string(b)), nil
 }
 
 // --- Nuovi getter usati da mensa-localizations/main.go ---
+func GetRedisEnabled() bool    { return cfg.RedisEnabled }
 func GetRedisAddr() string     { return cfg.RedisAddr }
 func GetRedisPassword() string { return cfg.RedisPassword }
 
+func GetRedisShards() map[string]string     { return cfg.RedisShards }
+func GetRedisShardHeartbeat() time.Duration { return cfg.RedisShardHeartbeat }
+
 func GetS3Enabled() bool { return cfg.S3Enabled }
 func GetS3Bucket() string {
 	return cfg.S3Bucket
@@ -52,13 +349,223 @@ func GetS3Endpoint() string {
 	return cfg.S3Endpoint
 }
 func GetS3AccessKey() string {
-	return cfg.S3AccessKey
+	s3Credentials.RLock()
+	defer s3Credentials.RUnlock()
+	return s3Credentials.accessKey
 }
 func GetS3SecretKey() string {
-	return cfg.S3SecretKey
+	s3Credentials.RLock()
+	defer s3Credentials.RUnlock()
+	return s3Credentials.secretKey
+}
+func GetS3CredentialFiles() (accessKeyFile, secretKeyFile string) {
+	return cfg.S3AccessKeyFile, cfg.S3SecretKeyFile
 }
+func GetS3CredentialsReloadInterval() time.Duration { return cfg.S3CredentialsReloadInterval }
 func GetS3ForcePathStyle() bool {
 	return cfg.S3ForcePathStyle
 }
-func GetTolgeeAppKey() string  { return cfg.TolgeeAppKey }
-func GetWebhookSecret() string { return cfg.WebhookSecret }
+func GetStorageMigrateOnStart() bool { return cfg.StorageMigrateOnStart }
+
+func GetPromoteSourceBucket() string { return cfg.PromoteSourceBucket }
+func GetPromoteSourcePrefix() string { return cfg.PromoteSourcePrefix }
+func GetPromotedOnly() bool          { return cfg.PromotedOnly }
+
+func GetSource() string { return cfg.Source }
+
+func GetGitSourceBranch() string              { return cfg.GitSourceBranch }
+func GetGitSourcePath() string                { return cfg.GitSourcePath }
+func GetGitSourceToken() string               { return cfg.GitSourceToken }
+func GetGitSourceDir() string                 { return cfg.GitSourceDir }
+func GetGitSourcePollInterval() time.Duration { return cfg.GitSourcePollInterval }
+func GetGitSourceWebhookSecret() string       { return cfg.GitSourceWebhookSecret }
+
+func GetGitExportURL() string    { return cfg.GitExportURL }
+func GetGitExportBranch() string { return cfg.GitExportBranch }
+func GetGitExportPath() string   { return cfg.GitExportPath }
+func GetGitExportToken() string  { return cfg.GitExportToken }
+func GetGitExportDir() string    { return cfg.GitExportDir }
+func GetGitUsername() string     { return cfg.GitUsername }
+func GetGitAuthorName() string   { return cfg.GitAuthorName }
+func GetGitAuthorEmail() string  { return cfg.GitAuthorEmail }
+func GetTolgeeAppKey() string    { return cfg.TolgeeAppKey }
+func GetWebhookSecret() string   { return cfg.WebhookSecret }
+
+func GetTolgeeProxyAllowed() []string  { return cfg.TolgeeProxyAllowed }
+func GetTolgeeProxyTTL() time.Duration { return cfg.TolgeeProxyTTL }
+func GetTolgeeProxyQuery() []string    { return cfg.TolgeeProxyQuery }
+
+func GetScreenshotProxy() bool { return cfg.ScreenshotProxy }
+
+func GetTolgeeProductionBranch() string          { return cfg.TolgeeProductionBranch }
+func GetTolgeeBranchChannels() map[string]string { return cfg.TolgeeBranchChannels }
+func GetBranchCacheTTL() time.Duration           { return cfg.BranchCacheTTL }
+
+func GetApps() map[string]string    { return cfg.Apps }
+func GetAppCacheTTL() time.Duration { return cfg.AppCacheTTL }
+
+func GetDefaultNested() bool                       { return cfg.DefaultNested }
+func GetPlatformNestedDefaults() map[string]bool   { return cfg.PlatformNestedDefaults }
+func GetPlatformHTMLEscape() map[string]bool       { return cfg.PlatformHTMLEscape }
+func GetPlatformMaxPayloadBytes() map[string]int64 { return cfg.PlatformMaxPayloadBytes }
+func GetNamespacePriority() []string               { return cfg.NamespacePriority }
+func GetFlatDelimiter() string                     { return cfg.FlatDelimiter }
+func GetSortSnapshots() bool                       { return cfg.SortSnapshots }
+
+// GetLanguageGroups returns the configured groups with their tags in order.
+func GetLanguageGroups() map[string][]string {
+	groups := make(map[string][]string, len(cfg.LanguageGroups))
+	for name, members := range cfg.LanguageGroups {
+		for _, tag := range strings.Split(members, "|") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				groups[name] = append(groups[name], tag)
+			}
+		}
+	}
+	return groups
+}
+
+// GetRegionOverlays returns the overlaid namespaces per overlay language.
+func GetRegionOverlays() map[string][]string {
+	overlays := make(map[string][]string, len(cfg.RegionOverlays))
+	for lang, namespaces := range cfg.RegionOverlays {
+		for _, ns := range strings.Split(namespaces, "|") {
+			if ns = strings.TrimSpace(ns); ns != "" {
+				overlays[lang] = append(overlays[lang], ns)
+			}
+		}
+	}
+	return overlays
+}
+
+func GetLintRules() map[string]string  { return cfg.LintRules }
+func GetLintMaxLength() map[string]int { return cfg.LintMaxLength }
+func GetLintForbiddenChars() string    { return cfg.LintForbiddenChars }
+func GetLengthBudgetTruncate() bool    { return cfg.LengthBudgetTruncate }
+
+// GetIngestFilters returns the filter names per namespace.
+func GetIngestFilters() map[string][]string {
+	filters := make(map[string][]string, len(cfg.IngestFilters))
+	for namespace, spec := range cfg.IngestFilters {
+		for _, name := range strings.Split(spec, "+") {
+			if name = strings.TrimSpace(name); name != "" {
+				filters[namespace] = append(filters[namespace], name)
+			}
+		}
+	}
+	return filters
+}
+
+// GetPostprocessRules returns the rule specs ("name" or "name=arg") per language.
+func GetPostprocessRules() map[string][]string {
+	rules := make(map[string][]string, len(cfg.PostprocessRules))
+	for lang, spec := range cfg.PostprocessRules {
+		for _, rule := range strings.Split(spec, "+") {
+			if rule = strings.TrimSpace(rule); rule != "" {
+				rules[lang] = append(rules[lang], rule)
+			}
+		}
+	}
+	return rules
+}
+
+func GetGeoIPDBPath() string                 { return cfg.GeoIPDBPath }
+func GetCountryLanguages() map[string]string { return cfg.CountryLanguages }
+func GetProxyHeader() string                 { return cfg.ProxyHeader }
+func GetTrustedProxies() []string            { return cfg.TrustedProxies }
+
+func GetLanguageAliases() map[string]string { return cfg.LanguageAliases }
+func GetBetaLanguages() []string            { return cfg.BetaLanguages }
+
+// GetFallbackChains returns the configured chains with their tags in order.
+func GetFallbackChains() map[string][]string {
+	chains := make(map[string][]string, len(cfg.FallbackChains))
+	for lang, spec := range cfg.FallbackChains {
+		for _, tag := range strings.Split(spec, "|") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				chains[lang] = append(chains[lang], tag)
+			}
+		}
+	}
+	return chains
+}
+
+func GetPriorityLanguages() []string    { return cfg.PriorityLanguages }
+func GetRefreshDebounce() time.Duration { return cfg.RefreshDebounce }
+
+func GetStaleBannerAfter() time.Duration { return cfg.StaleBannerAfter }
+func GetStaleBannerKey() string          { return cfg.StaleBannerKey }
+
+func GetBackfillEmptyFromBase() bool { return cfg.BackfillEmptyFromBase }
+
+func GetUpstreamFailureCooldown() time.Duration {
+	return cfg.UpstreamFailureCooldown
+}
+
+func GetHTTPMaxIdleConns() int                  { return cfg.HTTPMaxIdleConns }
+func GetHTTPMaxIdleConnsPerHost() int           { return cfg.HTTPMaxIdleConnsPerHost }
+func GetHTTPMaxConnsPerHost() int               { return cfg.HTTPMaxConnsPerHost }
+func GetHTTPIdleConnTimeout() time.Duration     { return cfg.HTTPIdleConnTimeout }
+func GetHTTPDialTimeout() time.Duration         { return cfg.HTTPDialTimeout }
+func GetHTTPTLSHandshakeTimeout() time.Duration { return cfg.HTTPTLSHandshakeTimeout }
+
+func GetTolgeeMaxConcurrency() int         { return cfg.TolgeeMaxConcurrency }
+func GetTolgeeGlobalMaxConcurrency() int   { return cfg.TolgeeGlobalMaxConcurrency }
+func GetTolgeeQueueTimeout() time.Duration { return cfg.TolgeeQueueTimeout }
+func GetTolgeeSlotLease() time.Duration    { return cfg.TolgeeSlotLease }
+
+func GetAdminToken() string { return cfg.AdminToken }
+
+func GetIdempotencyTTL() time.Duration { return cfg.IdempotencyTTL }
+
+func GetRobotsTxt() string           { return strings.ReplaceAll(cfg.RobotsTxt, `\n`, "\n") }
+func GetFaviconFile() string         { return cfg.FaviconFile }
+func GetWellKnownHealthBody() string { return cfg.WellKnownHealthBody }
+
+func GetKeyAliasesFromTolgee() bool { return cfg.KeyAliasesFromTolgee }
+
+func GetTransliterationRulesFile() string { return cfg.TransliterationRulesFile }
+
+func GetAdminListenAddr() string { return cfg.AdminListenAddr }
+
+func GetDiagnosticsOnShutdown() bool { return cfg.DiagnosticsOnShutdown }
+
+func GetMaxPayloadBytes() int64          { return cfg.MaxPayloadBytes }
+func GetMaxAggregatePayloadBytes() int64 { return cfg.MaxAggregatePayloadBytes }
+
+func GetMemoryCacheMaxBytes() int64    { return cfg.MemoryCacheMaxBytes }
+func GetMemoryCacheTTL() time.Duration { return cfg.MemoryCacheTTL }
+
+func GetDerivedArtifactTTL() time.Duration { return cfg.DerivedArtifactTTL }
+
+func GetSnapshotSoftTTL() time.Duration { return cfg.SnapshotSoftTTL }
+func GetSnapshotHardTTL() time.Duration { return cfg.SnapshotHardTTL }
+
+func GetContentAddressedStorage() bool { return cfg.ContentAddressedStorage }
+func GetBlobGCInterval() time.Duration { return cfg.BlobGCInterval }
+
+func GetPatchesS3Prefix() string            { return cfg.PatchesS3Prefix }
+func GetPatchesPollInterval() time.Duration { return cfg.PatchesPollInterval }
+
+func GetRepairInterval() time.Duration { return cfg.RepairInterval }
+func GetRepairS3MaxAge() time.Duration { return cfg.RepairS3MaxAge }
+
+func GetFreshnessSLOMaxAge() time.Duration { return cfg.FreshnessSLOMaxAge }
+func GetFreshnessSLOTarget() float64       { return cfg.FreshnessSLOTarget }
+func GetFreshnessSLOWindow() time.Duration { return cfg.FreshnessSLOWindow }
+
+func GetJournalMaxLen() int64 { return cfg.JournalMaxLen }
+
+func GetUpdateHistorySize() int64 { return cfg.UpdateHistorySize }
+
+func GetWarmupDeadline() time.Duration { return cfg.WarmupDeadline }
+
+func GetRefreshRetryMaxAttempts() int64        { return cfg.RefreshRetryMaxAttempts }
+func GetRefreshRetryBackoff() time.Duration    { return cfg.RefreshRetryBackoff }
+func GetRefreshRetryBackoffMax() time.Duration { return cfg.RefreshRetryBackoffMax }
+
+func GetEncryptedNamespaces() []string           { return cfg.EncryptedNamespaces }
+func GetClientEncryptionKeys() map[string]string { return cfg.ClientEncryptionKeys }
+
+func GetOutgoingWebhookURL() string    { return cfg.OutgoingWebhookURL }
+func GetOutgoingWebhookSecret() string { return cfg.OutgoingWebhookSecret }